@@ -2,6 +2,7 @@ package bevm
 
 import (
 	"crypto/ecdsa"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"math/big"
@@ -14,9 +15,13 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
+	"go.dedis.ch/cothority/v3"
 	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/byzcoin/contracts"
 	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/cothority/v3/skipchain"
 	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/onet/v3/network"
 	"go.dedis.ch/protobuf"
 	"golang.org/x/xerrors"
 )
@@ -224,6 +229,46 @@ func NewBEvm(bcClient *byzcoin.Client, signer darc.Signer, gDarc *darc.Darc) (
 	return instanceID, nil
 }
 
+// CoinConfig defines how the accounts of a BEvm instance are funded with
+// ByzCoin coins
+type CoinConfig struct {
+	// Coin is the type of the coins, which is the name of their coin
+	// instances
+	Coin byzcoin.InstanceID
+	// FeeAccount is the coin instance receiving the gas fees
+	FeeAccount byzcoin.InstanceID
+	// MinGasPrice is the minimum gas price of the EVM transactions
+	MinGasPrice uint64
+}
+
+// NewBEvmWithCoins creates a new ByzCoin EVM instance whose accounts are
+// funded with ByzCoin coins instead of being credited
+func NewBEvmWithCoins(bcClient *byzcoin.Client, signer darc.Signer,
+	gDarc *darc.Darc, config CoinConfig) (byzcoin.InstanceID, error) {
+	instanceID := byzcoin.NewInstanceID(nil)
+
+	minGasPrice := make([]byte, 8)
+	binary.LittleEndian.PutUint64(minGasPrice, config.MinGasPrice)
+
+	tx, err := spawnBEvm(bcClient, signer,
+		byzcoin.NewInstanceID(gDarc.GetBaseID()), &byzcoin.Spawn{
+			ContractID: ContractBEvmID,
+			Args: byzcoin.Arguments{
+				{Name: "coin", Value: config.Coin.Slice()},
+				{Name: "feeAccount", Value: config.FeeAccount.Slice()},
+				{Name: "minGasPrice", Value: minGasPrice},
+			},
+		})
+	if err != nil {
+		return instanceID, xerrors.Errorf("failed to execute ByzCoin "+
+			"spawn command for BEvm contract: %v", err)
+	}
+
+	instanceID = tx.Instructions[0].DeriveID("")
+
+	return instanceID, nil
+}
+
 // NewClient creates a new ByzCoin EVM client, connected to the given ByzCoin
 // instance
 func NewClient(bcClient *byzcoin.Client, signer darc.Signer,
@@ -264,7 +309,7 @@ func (client *Client) Deploy(gasLimit uint64, gasPrice uint64, amount uint64,
 	callData := append(contract.Bytecode, packedArgs...)
 	tx := types.NewContractCreation(account.Nonce, big.NewInt(int64(amount)),
 		gasLimit, big.NewInt(int64(gasPrice)), callData)
-	signedTxBuffer, err := account.signAndMarshalTx(tx)
+	signedTxBuffer, _, err := account.signAndMarshalTx(tx)
 	if err != nil {
 		return nil, xerrors.Errorf("failed to prepare EVM transaction for "+
 			"contract deployment: %v", err)
@@ -293,35 +338,219 @@ func (client *Client) Deploy(gasLimit uint64, gasPrice uint64, amount uint64,
 func (client *Client) Transaction(gasLimit uint64, gasPrice uint64,
 	amount uint64, account *EvmAccount, contractInstance *EvmContractInstance,
 	method string, args ...interface{}) error {
+	_, err := client.transaction(gasLimit, gasPrice, amount, account,
+		contractInstance, method, args...)
+
+	return err
+}
+
+// TransactionWithReceipt performs a new transaction like Transaction, and
+// returns its receipt. The status of the receipt tells whether the EVM
+// execution succeeded.
+func (client *Client) TransactionWithReceipt(gasLimit uint64, gasPrice uint64,
+	amount uint64, account *EvmAccount, contractInstance *EvmContractInstance,
+	method string, args ...interface{}) (*types.Receipt, error) {
+	txHash, err := client.transaction(gasLimit, gasPrice, amount, account,
+		contractInstance, method, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.GetReceipt(txHash)
+}
+
+func (client *Client) transaction(gasLimit uint64, gasPrice uint64,
+	amount uint64, account *EvmAccount, contractInstance *EvmContractInstance,
+	method string, args ...interface{}) (common.Hash, error) {
 	log.Lvlf2(">>> EVM method '%s()' on %s", method, contractInstance)
 	defer log.Lvlf2("<<< EVM method '%s()' on %s", method, contractInstance)
 
 	callData, err := contractInstance.packMethod(method, args...)
 	if err != nil {
-		return xerrors.Errorf("failed to pack arguments for contract "+
-			"method '%s': %v", method, err)
+		return common.Hash{}, xerrors.Errorf("failed to pack arguments "+
+			"for contract method '%s': %v", method, err)
 	}
 
 	tx := types.NewTransaction(account.Nonce, contractInstance.Address,
 		big.NewInt(int64(amount)), gasLimit, big.NewInt(int64(gasPrice)),
 		callData)
-	signedTxBuffer, err := account.signAndMarshalTx(tx)
+	signedTxBuffer, txHash, err := account.signAndMarshalTx(tx)
 	if err != nil {
-		return xerrors.Errorf("failed to prepare EVM transaction for "+
-			"method execution: %v", err)
+		return common.Hash{}, xerrors.Errorf("failed to prepare EVM "+
+			"transaction for method execution: %v", err)
 	}
 
 	err = client.invoke("transaction", byzcoin.Arguments{
 		{Name: "tx", Value: signedTxBuffer},
 	})
 	if err != nil {
-		return xerrors.Errorf("failed to invoke ByzCoin transaction for "+
-			"EVM method execution: %v", err)
+		return common.Hash{}, xerrors.Errorf("failed to invoke ByzCoin "+
+			"transaction for EVM method execution: %v", err)
 	}
 
 	account.Nonce++
 
-	return nil
+	return txHash, nil
+}
+
+// GetReceipt returns the receipt of an EVM transaction executed by the BEvm
+// instance, holding its status, the gas it used and the logs it emitted
+func (client *Client) GetReceipt(txHash common.Hash) (*types.Receipt, error) {
+	instID := receiptInstanceID(client.instanceID, txHash)
+
+	// Retrieve the proof of the BEvmValue instance holding the receipt
+	proofResponse, err := client.bcClient.GetProof(instID[:])
+	if err != nil {
+		return nil, xerrors.Errorf("failed to retrieve receipt: %v", err)
+	}
+
+	// Validate the proof
+	err = proofResponse.Proof.Verify(client.bcClient.ID)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to verify receipt proof: %v", err)
+	}
+
+	value, contractID, _, err := proofResponse.Proof.Get(instID[:])
+	if err != nil {
+		return nil, xerrors.Errorf("no receipt for transaction '%s': %v",
+			txHash.Hex(), err)
+	}
+	if contractID != ContractBEvmValueID {
+		return nil, xerrors.Errorf("receipt instance is of contract '%s'",
+			contractID)
+	}
+
+	var receipt types.Receipt
+	err = receipt.UnmarshalJSON(value)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to decode receipt: %v", err)
+	}
+
+	return &receipt, nil
+}
+
+// LogFilter selects EVM logs. An empty list of addresses matches all the
+// contracts. Topics are matched by position, as in Ethereum: an empty list at
+// a position matches any topic, otherwise the topic of the log at that
+// position must be one of the list.
+type LogFilter struct {
+	Addresses []common.Address
+	Topics    [][]common.Hash
+}
+
+// Match returns true if the log is selected by the filter
+func (filter LogFilter) Match(evmLog *types.Log) bool {
+	if len(filter.Addresses) > 0 {
+		found := false
+		for _, address := range filter.Addresses {
+			if address == evmLog.Address {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(filter.Topics) > len(evmLog.Topics) {
+		return false
+	}
+
+	for i, topics := range filter.Topics {
+		if len(topics) == 0 {
+			continue
+		}
+		found := false
+		for _, topic := range topics {
+			if topic == evmLog.Topics[i] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// LogHandler is called by SubscribeLogs for every matching log, with the ID
+// of the block that included its transaction, or with an error
+type LogHandler func(evmLog *types.Log, blockID skipchain.SkipBlockID,
+	err error)
+
+// SubscribeLogs calls the handler for every log emitted by the EVM
+// transactions of the new blocks that matches the filter. This function
+// blocks until the streaming stops.
+func (client *Client) SubscribeLogs(filter LogFilter,
+	handler LogHandler) error {
+	return client.bcClient.StreamTransactions(
+		func(resp byzcoin.StreamingResponse, err error) {
+			if err != nil {
+				handler(nil, nil, err)
+				return
+			}
+
+			txs, err := client.blockTransactions(resp.Block)
+			if err != nil {
+				handler(nil, resp.Block.Hash, err)
+				return
+			}
+
+			for _, tx := range txs {
+				receipt, err := client.GetReceipt(tx.Hash())
+				if err != nil {
+					handler(nil, resp.Block.Hash, err)
+					continue
+				}
+
+				for _, evmLog := range receipt.Logs {
+					if filter.Match(evmLog) {
+						handler(evmLog, resp.Block.Hash, nil)
+					}
+				}
+			}
+		})
+}
+
+// blockTransactions returns the EVM transactions executed by the BEvm
+// instance in the block
+func (client *Client) blockTransactions(block *skipchain.SkipBlock) (
+	[]*types.Transaction, error) {
+	var body byzcoin.DataBody
+	err := protobuf.DecodeWithConstructors(block.Payload, &body,
+		network.DefaultConstructors(cothority.Suite))
+	if err != nil {
+		return nil, xerrors.Errorf("failed to decode block body: %v", err)
+	}
+
+	var txs []*types.Transaction
+	for _, txResult := range body.TxResults {
+		if !txResult.Accepted {
+			continue
+		}
+
+		for _, instr := range txResult.ClientTransaction.Instructions {
+			if instr.Invoke == nil ||
+				instr.Invoke.Command != "transaction" ||
+				!instr.InstanceID.Equal(client.instanceID) {
+				continue
+			}
+
+			tx := new(types.Transaction)
+			err = tx.UnmarshalJSON(instr.Invoke.Args.Search("tx"))
+			if err != nil {
+				return nil, xerrors.Errorf("failed to decode EVM "+
+					"transaction: %v", err)
+			}
+
+			txs = append(txs, tx)
+		}
+	}
+
+	return txs, nil
 }
 
 // Call performs a new call (contract view method call, without state change)
@@ -340,19 +569,9 @@ func (client *Client) Call(account *EvmAccount,
 			"view method '%s': %v", method, err)
 	}
 
-	// Retrieve the EVM state
-	stateDb, err := getEvmDb(client.bcClient, client.instanceID)
-	if err != nil {
-		return nil, xerrors.Errorf("failed to retrieve EVM state: %v", err)
-	}
-
-	// Instantiate a new EVM
-	evm := vm.NewEVM(getContext(), stateDb, getChainConfig(), getVMConfig())
-
 	// Perform the call (1 Ether should be enough for everyone [tm]...)
-	ret, _, err := evm.Call(vm.AccountRef(account.Address),
-		contractInstance.Address, callData, uint64(1*WeiPerEther),
-		big.NewInt(0))
+	ret, err := client.call(account.Address, contractInstance.Address,
+		callData, uint64(1*WeiPerEther), big.NewInt(0))
 	if err != nil {
 		return nil, xerrors.Errorf("failed to executing EVM view "+
 			"method: %v", err)
@@ -368,6 +587,26 @@ func (client *Client) Call(account *EvmAccount,
 	return result, nil
 }
 
+// Execute a call on the EVM, on the current state and without changing it
+func (client *Client) call(from common.Address, to common.Address,
+	callData []byte, gas uint64, value *big.Int) ([]byte, error) {
+	// Retrieve the EVM state
+	stateDb, err := getEvmDb(client.bcClient, client.instanceID)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to retrieve EVM state: %v", err)
+	}
+
+	// Instantiate a new EVM
+	evm := vm.NewEVM(getContext(), stateDb, getChainConfig(), getVMConfig())
+
+	ret, _, err := evm.Call(vm.AccountRef(from), to, callData, gas, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
 // CreditAccount credits the given Ethereum address with the given amount
 func (client *Client) CreditAccount(amount *big.Int,
 	address common.Address) error {
@@ -384,6 +623,74 @@ func (client *Client) CreditAccount(amount *big.Int,
 	return nil
 }
 
+// Deposit transfers coins from a coin instance to the given Ethereum address.
+// The client's signer must be allowed to fetch coins from the coin instance.
+func (client *Client) Deposit(coinAccount byzcoin.InstanceID, amount uint64,
+	address common.Address) error {
+	amountBuf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(amountBuf, amount)
+
+	_, err := execByzCoinInstructions(client.bcClient, client.signer,
+		byzcoin.Instruction{
+			InstanceID: coinAccount,
+			Invoke: &byzcoin.Invoke{
+				ContractID: contracts.ContractCoinID,
+				Command:    "fetch",
+				Args: byzcoin.Arguments{
+					{Name: "coins", Value: amountBuf},
+				},
+			},
+		},
+		byzcoin.Instruction{
+			InstanceID: client.instanceID,
+			Invoke: &byzcoin.Invoke{
+				ContractID: ContractBEvmID,
+				Command:    "deposit",
+				Args: byzcoin.Arguments{
+					{Name: "address", Value: address.Bytes()},
+				},
+			},
+		})
+	if err != nil {
+		return xerrors.Errorf("failed to deposit coins: %v", err)
+	}
+
+	log.Lvlf2("Deposited %d coins on '%x'", amount, address)
+
+	return nil
+}
+
+// Withdraw transfers coins from an Ethereum account to the given coin
+// instance
+func (client *Client) Withdraw(account *EvmAccount, amount uint64,
+	destination byzcoin.InstanceID) error {
+	hash := WithdrawalHash(client.instanceID, account.Address, destination,
+		amount, account.Nonce)
+	signature, err := crypto.Sign(hash, account.PrivateKey)
+	if err != nil {
+		return xerrors.Errorf("failed to sign withdrawal: %v", err)
+	}
+
+	amountBuf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(amountBuf, amount)
+
+	err = client.invoke("withdraw", byzcoin.Arguments{
+		{Name: "address", Value: account.Address.Bytes()},
+		{Name: "amount", Value: amountBuf},
+		{Name: "destination", Value: destination.Slice()},
+		{Name: "signature", Value: signature},
+	})
+	if err != nil {
+		return xerrors.Errorf("failed to withdraw coins: %v", err)
+	}
+
+	account.Nonce++
+
+	log.Lvlf2("Withdrew %d coins from '%x'", amount, account.Address)
+
+	return nil
+}
+
 // GetAccountBalance returns the current balance of a Ethereum address
 func (client *Client) GetAccountBalance(address common.Address) (
 	*big.Int, error) {
@@ -548,23 +855,26 @@ func decodeEvmArray(abiType string, abi abi.Argument, arg interface{}) (
 // Helper functions
 
 // signAndMarshalTx signs an Ethereum transaction and returns it in byte
-// format, ready to be included into a Byzcoin transaction
+// format, ready to be included into a Byzcoin transaction, together with the
+// hash of the signed transaction
 func (account EvmAccount) signAndMarshalTx(tx *types.Transaction) (
-	[]byte, error) {
+	[]byte, common.Hash, error) {
 	var signer types.Signer = types.HomesteadSigner{}
 
 	signedTx, err := types.SignTx(tx, signer, account.PrivateKey)
 	if err != nil {
-		return nil, xerrors.Errorf("failed to sign EVM transaction: %v", err)
+		return nil, common.Hash{},
+			xerrors.Errorf("failed to sign EVM transaction: %v", err)
 	}
 
 	signedBuffer, err := signedTx.MarshalJSON()
 	if err != nil {
-		return nil, xerrors.Errorf("failed to serialize EVM transaction "+
-			"to JSON: %v", err)
+		return nil, common.Hash{},
+			xerrors.Errorf("failed to serialize EVM transaction "+
+				"to JSON: %v", err)
 	}
 
-	return signedBuffer, nil
+	return signedBuffer, signedTx.Hash(), nil
 }
 
 // Retrieve a read-only EVM state database from ByzCoin
@@ -646,19 +956,30 @@ func execByzCoinTx(bcClient *byzcoin.Client,
 	signer darc.Signer, instanceID byzcoin.InstanceID,
 	spawnInstr *byzcoin.Spawn, invokeInstr *byzcoin.Invoke,
 	deleteInstr *byzcoin.Delete) (*byzcoin.ClientTransaction, error) {
+	return execByzCoinInstructions(bcClient, signer, byzcoin.Instruction{
+		InstanceID: instanceID,
+		Spawn:      spawnInstr,
+		Invoke:     invokeInstr,
+		Delete:     deleteInstr,
+	})
+}
+
+// Execute a ByzCoin transaction made of several instructions, all signed by
+// the signer
+func execByzCoinInstructions(bcClient *byzcoin.Client, signer darc.Signer,
+	instrs ...byzcoin.Instruction) (*byzcoin.ClientTransaction, error) {
 	counters, err := bcClient.GetSignerCounters(signer.Identity().String())
 	if err != nil {
 		return nil, xerrors.Errorf("failed to retrieve signer "+
 			"counters from ByzCoin: %v", err)
 	}
 
-	tx, err := bcClient.CreateTransaction(byzcoin.Instruction{
-		InstanceID:    instanceID,
-		SignerCounter: []uint64{counters.Counters[0] + 1},
-		Spawn:         spawnInstr,
-		Invoke:        invokeInstr,
-		Delete:        deleteInstr,
-	})
+	for i := range instrs {
+		instrs[i].SignerCounter = []uint64{counters.Counters[0] +
+			uint64(i) + 1}
+	}
+
+	tx, err := bcClient.CreateTransaction(instrs...)
 	if err != nil {
 		return nil, xerrors.Errorf("failed to create ByzCoin "+
 			"transaction: %v", err)
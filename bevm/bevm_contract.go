@@ -1,6 +1,7 @@
 package bevm
 
 import (
+	"encoding/binary"
 	"fmt"
 	"math/big"
 
@@ -8,7 +9,9 @@ import (
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/byzcoin/contracts"
 	"go.dedis.ch/cothority/v3/darc"
 	"go.dedis.ch/onet/v3/log"
 	"go.dedis.ch/protobuf"
@@ -26,6 +29,10 @@ var ContractBEvmValueID = "bevm_value"
 var nilAddress = common.HexToAddress(
 	"0x0000000000000000000000000000000000000000")
 
+// WeiPerCoin is the number of Wei a ByzCoin coin is worth when funding an
+// Ethereum account
+const WeiPerCoin = 1e9
+
 // ByzCoin contract state for BEvm
 type contractBEvm struct {
 	byzcoin.BasicContract
@@ -57,6 +64,13 @@ func contractBEvmFromBytes(in []byte) (byzcoin.Contract, error) {
 type State struct {
 	RootHash common.Hash // Hash of the last commit in the EVM state database
 	KeyList  []string    // List of keys contained in the EVM state database
+	// Type of the ByzCoin coins funding the EVM accounts; if not set, the
+	// accounts are funded by the 'credit' command
+	Coin []byte `protobuf:"opt"`
+	// Coin instance receiving the gas fees
+	FeeAccount []byte `protobuf:"opt"`
+	// Minimum gas price of the EVM transactions
+	MinGasPrice uint64 `protobuf:"opt"`
 }
 
 // NewEvmDb creates a new EVM state database from the contract state
@@ -161,6 +175,12 @@ func (c *contractBEvm) Spawn(rst byzcoin.ReadOnlyStateTrie,
 			xerrors.Errorf("failed to create new BEvm contract state: %v", err)
 	}
 
+	err = spawnCoinConfig(rst, inst, contractState)
+	if err != nil {
+		return nil, nil,
+			xerrors.Errorf("failed to configure BEvm coins: %v", err)
+	}
+
 	contractData, err := protobuf.Encode(contractState)
 	if err != nil {
 		return nil, nil, xerrors.Errorf("failed to encode BEvm "+
@@ -175,6 +195,44 @@ func (c *contractBEvm) Spawn(rst byzcoin.ReadOnlyStateTrie,
 	return
 }
 
+// Helper function that sets the coin configuration of a new BEvm instance
+// from the spawn arguments
+func spawnCoinConfig(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction,
+	contractState *State) error {
+	coinName := inst.Spawn.Args.Search("coin")
+	feeAccount := inst.Spawn.Args.Search("feeAccount")
+	minGasPrice := inst.Spawn.Args.Search("minGasPrice")
+
+	if coinName == nil && feeAccount == nil && minGasPrice == nil {
+		return nil
+	}
+	if coinName == nil || feeAccount == nil {
+		return xerrors.New("both 'coin' and 'feeAccount' arguments must " +
+			"be provided")
+	}
+	if len(coinName) != len(byzcoin.InstanceID{}) {
+		return xerrors.New("'coin' argument must be an instance ID")
+	}
+
+	_, err := getCoinAccount(rst, feeAccount, coinName)
+	if err != nil {
+		return xerrors.Errorf("invalid fee account: %v", err)
+	}
+
+	contractState.Coin = coinName
+	contractState.FeeAccount = feeAccount
+
+	if minGasPrice != nil {
+		if len(minGasPrice) != 8 {
+			return xerrors.New("'minGasPrice' argument must be a 64-bit " +
+				"integer")
+		}
+		contractState.MinGasPrice = binary.LittleEndian.Uint64(minGasPrice)
+	}
+
+	return nil
+}
+
 // Helper function to check that all required arguments are provided
 func checkArguments(inst byzcoin.Instruction, names ...string) error {
 	for _, name := range names {
@@ -213,31 +271,118 @@ func (c *contractBEvm) Invoke(rst byzcoin.ReadOnlyStateTrie,
 					"invocation on BEvm: %v", err)
 		}
 
+		if c.Coin != nil {
+			return nil, nil, xerrors.New("this BEvm instance is funded " +
+				"with coins, use 'deposit' instead of 'credit'")
+		}
+
 		address := common.BytesToAddress(inst.Invoke.Args.Search("address"))
 		amount := new(big.Int).SetBytes(inst.Invoke.Args.Search("amount"))
 
 		stateDb.AddBalance(address, amount)
 
-		contractState, stateChanges, err := NewContractState(stateDb)
+		sc, err = c.updateState(stateDb, inst.InstanceID, darcID)
+		if err != nil {
+			return nil, nil, err
+		}
+
+	case "deposit": // Credit an Ethereum account with coins
+		err := checkArguments(inst, "address")
 		if err != nil {
 			return nil, nil,
-				xerrors.Errorf("failed to creating new BEvm contract "+
-					"state: %v", err)
+				xerrors.Errorf("failed to validate arguments for 'deposit' "+
+					"invocation on BEvm: %v", err)
+		}
+
+		if c.Coin == nil {
+			return nil, nil, xerrors.New("this BEvm instance does not " +
+				"accept coins")
+		}
+
+		address := common.BytesToAddress(inst.Invoke.Args.Search("address"))
+
+		// Take the coins of the BEvm type, and pass on the others
+		cout = []byzcoin.Coin{}
+		deposit := byzcoin.Coin{Name: byzcoin.NewInstanceID(c.Coin)}
+		for _, co := range coins {
+			if deposit.Name.Equal(co.Name) {
+				err = deposit.SafeAdd(co.Value)
+				if err != nil {
+					return nil, nil, xerrors.Errorf("failed to add "+
+						"coins: %v", err)
+				}
+			} else {
+				cout = append(cout, co)
+			}
+		}
+		if deposit.Value == 0 {
+			return nil, nil, xerrors.New("no coins to deposit")
+		}
+
+		stateDb.AddBalance(address, coinsToWei(deposit.Value))
+
+		sc, err = c.updateState(stateDb, inst.InstanceID, darcID)
+		if err != nil {
+			return nil, nil, err
 		}
 
-		contractData, err := protobuf.Encode(contractState)
+	case "withdraw": // Transfer coins from an Ethereum account
+		err := checkArguments(inst, "address", "amount", "destination",
+			"signature")
 		if err != nil {
 			return nil, nil,
-				xerrors.Errorf("failed to encode BEvm contract state: %v", err)
+				xerrors.Errorf("failed to validate arguments for "+
+					"'withdraw' invocation on BEvm: %v", err)
+		}
+
+		if c.Coin == nil {
+			return nil, nil, xerrors.New("this BEvm instance does not " +
+				"accept coins")
+		}
+
+		address := common.BytesToAddress(inst.Invoke.Args.Search("address"))
+		amountBuf := inst.Invoke.Args.Search("amount")
+		if len(amountBuf) != 8 {
+			return nil, nil, xerrors.New("'amount' argument must be a " +
+				"64-bit integer")
+		}
+		amount := binary.LittleEndian.Uint64(amountBuf)
+		destination := inst.Invoke.Args.Search("destination")
+
+		// The withdrawal must be signed by the owner of the account, and
+		// uses its nonce to prevent replays
+		nonce := stateDb.GetNonce(address)
+		hash := WithdrawalHash(inst.InstanceID, address,
+			byzcoin.NewInstanceID(destination), amount, nonce)
+		pubKey, err := crypto.SigToPub(hash,
+			inst.Invoke.Args.Search("signature"))
+		if err != nil {
+			return nil, nil, xerrors.Errorf("invalid withdrawal "+
+				"signature: %v", err)
+		}
+		if crypto.PubkeyToAddress(*pubKey) != address {
+			return nil, nil, xerrors.New("withdrawal not signed by the " +
+				"account owner")
+		}
+
+		wei := coinsToWei(amount)
+		if stateDb.GetBalance(address).Cmp(wei) < 0 {
+			return nil, nil, xerrors.New("insufficient balance for " +
+				"withdrawal")
+		}
+		stateDb.SubBalance(address, wei)
+		stateDb.SetNonce(address, nonce+1)
+
+		coinSc, err := transferCoins(rst, destination, c.Coin, amount)
+		if err != nil {
+			return nil, nil, xerrors.Errorf("failed to withdraw: %v", err)
 		}
 
-		// State changes to ByzCoin contain the Update to the main contract
-		// state, plus whatever changes were produced by the EVM on its state
-		// database.
-		sc = append([]byzcoin.StateChange{
-			byzcoin.NewStateChange(byzcoin.Update, inst.InstanceID,
-				ContractBEvmID, contractData, darcID),
-		}, stateChanges...)
+		sc, err = c.updateState(stateDb, inst.InstanceID, darcID)
+		if err != nil {
+			return nil, nil, err
+		}
+		sc = append(sc, coinSc)
 
 	case "transaction":
 		// Perform an Ethereum transaction (contract method call with state
@@ -256,6 +401,11 @@ func (c *contractBEvm) Invoke(rst byzcoin.ReadOnlyStateTrie,
 				"transaction: %v", err)
 		}
 
+		if ethTx.GasPrice().Cmp(new(big.Int).SetUint64(c.MinGasPrice)) < 0 {
+			return nil, nil, xerrors.Errorf("gas price is below the "+
+				"minimum of %d", c.MinGasPrice)
+		}
+
 		txReceipt, err := sendTx(&ethTx, stateDb)
 		if err != nil {
 			return nil, nil,
@@ -271,26 +421,29 @@ func (c *contractBEvm) Invoke(rst byzcoin.ReadOnlyStateTrie,
 		log.Lvlf2("\\--> status = %d, gas used = %d, receipt = %s",
 			txReceipt.Status, txReceipt.GasUsed, txReceipt.TxHash.Hex())
 
-		contractState, stateChanges, err := NewContractState(stateDb)
+		receiptSc, err := receiptStateChange(inst.InstanceID, txReceipt,
+			darcID)
 		if err != nil {
 			return nil, nil,
-				xerrors.Errorf("failed to create new BEvm contract "+
-					"state: %v", err)
+				xerrors.Errorf("failed to store EVM transaction receipt: %v",
+					err)
 		}
 
-		contractData, err := protobuf.Encode(contractState)
-		if err != nil {
-			return nil, nil,
-				xerrors.Errorf("failed to encode BEvm contract state: %v", err)
+		var feeSc []byzcoin.StateChange
+		if c.Coin != nil {
+			feeSc, err = c.collectFees(rst, stateDb)
+			if err != nil {
+				return nil, nil,
+					xerrors.Errorf("failed to collect gas fees: %v", err)
+			}
 		}
 
-		// State changes to ByzCoin contain the Update to the main contract
-		// state, plus whatever changes were produced by the EVM on its state
-		// database.
-		sc = append([]byzcoin.StateChange{
-			byzcoin.NewStateChange(byzcoin.Update, inst.InstanceID,
-				ContractBEvmID, contractData, darcID),
-		}, stateChanges...)
+		sc, err = c.updateState(stateDb, inst.InstanceID, darcID)
+		if err != nil {
+			return nil, nil, err
+		}
+		sc = append(sc, receiptSc)
+		sc = append(sc, feeSc...)
 
 	default:
 		err = fmt.Errorf("unknown Invoke command: '%s'", inst.Invoke.Command)
@@ -299,6 +452,132 @@ func (c *contractBEvm) Invoke(rst byzcoin.ReadOnlyStateTrie,
 	return
 }
 
+// Helper function that builds the state changes of an invocation: the Update
+// to the main contract state, plus whatever changes were produced by the EVM
+// on its state database
+func (c *contractBEvm) updateState(stateDb *state.StateDB,
+	instanceID byzcoin.InstanceID, darcID darc.ID) (
+	[]byzcoin.StateChange, error) {
+	contractState, stateChanges, err := NewContractState(stateDb)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to create new BEvm contract "+
+			"state: %v", err)
+	}
+
+	// The coin configuration does not change
+	contractState.Coin = c.Coin
+	contractState.FeeAccount = c.FeeAccount
+	contractState.MinGasPrice = c.MinGasPrice
+
+	contractData, err := protobuf.Encode(contractState)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to encode BEvm contract "+
+			"state: %v", err)
+	}
+
+	return append([]byzcoin.StateChange{
+		byzcoin.NewStateChange(byzcoin.Update, instanceID,
+			ContractBEvmID, contractData, darcID),
+	}, stateChanges...), nil
+}
+
+// Helper function that transfers the gas fees, collected by the EVM on the
+// coinbase address, to the fee account. The remainder that does not make a
+// whole coin stays on the coinbase address.
+func (c *contractBEvm) collectFees(rst byzcoin.ReadOnlyStateTrie,
+	stateDb *state.StateDB) ([]byzcoin.StateChange, error) {
+	fees := new(big.Int).Div(stateDb.GetBalance(nilAddress),
+		big.NewInt(WeiPerCoin))
+	if fees.Sign() == 0 {
+		return nil, nil
+	}
+	if !fees.IsUint64() {
+		return nil, xerrors.New("fees do not fit in a coin amount")
+	}
+
+	stateDb.SubBalance(nilAddress, coinsToWei(fees.Uint64()))
+
+	feeSc, err := transferCoins(rst, c.FeeAccount, c.Coin, fees.Uint64())
+	if err != nil {
+		return nil, err
+	}
+
+	return []byzcoin.StateChange{feeSc}, nil
+}
+
+// Helper function that retrieves a coin instance, checking its type
+func getCoinAccount(rst byzcoin.ReadOnlyStateTrie, accountID []byte,
+	coinName []byte) (*byzcoin.Coin, error) {
+	value, _, contractID, _, err := rst.GetValues(accountID)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to retrieve coin instance: %v", err)
+	}
+	if contractID != contracts.ContractCoinID {
+		return nil, xerrors.Errorf("instance is of contract '%s' instead "+
+			"of '%s'", contractID, contracts.ContractCoinID)
+	}
+
+	var account byzcoin.Coin
+	err = protobuf.Decode(value, &account)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to decode coin instance: %v", err)
+	}
+	if !account.Name.Equal(byzcoin.NewInstanceID(coinName)) {
+		return nil, xerrors.New("coin instance is of a different type")
+	}
+
+	return &account, nil
+}
+
+// Helper function that adds coins to a coin instance
+func transferCoins(rst byzcoin.ReadOnlyStateTrie, accountID []byte,
+	coinName []byte, amount uint64) (byzcoin.StateChange, error) {
+	account, err := getCoinAccount(rst, accountID, coinName)
+	if err != nil {
+		return byzcoin.StateChange{}, err
+	}
+
+	err = account.SafeAdd(amount)
+	if err != nil {
+		return byzcoin.StateChange{}, xerrors.Errorf("failed to add "+
+			"coins: %v", err)
+	}
+
+	accountBuf, err := protobuf.Encode(account)
+	if err != nil {
+		return byzcoin.StateChange{}, xerrors.Errorf("failed to encode "+
+			"coin instance: %v", err)
+	}
+
+	_, _, _, darcID, err := rst.GetValues(accountID)
+	if err != nil {
+		return byzcoin.StateChange{}, xerrors.Errorf("failed to retrieve "+
+			"coin instance: %v", err)
+	}
+
+	return byzcoin.NewStateChange(byzcoin.Update,
+		byzcoin.NewInstanceID(accountID), contracts.ContractCoinID,
+		accountBuf, darcID), nil
+}
+
+// Helper function that converts a number of coins into Wei
+func coinsToWei(amount uint64) *big.Int {
+	wei := new(big.Int).SetUint64(amount)
+	return wei.Mul(wei, big.NewInt(WeiPerCoin))
+}
+
+// WithdrawalHash returns the hash to be signed by the owner of an Ethereum
+// account to withdraw coins from it. The nonce is the current nonce of the
+// account.
+func WithdrawalHash(bevmID byzcoin.InstanceID, address common.Address,
+	destination byzcoin.InstanceID, amount uint64, nonce uint64) []byte {
+	buf := make([]byte, 16)
+	binary.LittleEndian.PutUint64(buf, amount)
+	binary.LittleEndian.PutUint64(buf[8:], nonce)
+
+	return crypto.Keccak256(bevmID[:], address.Bytes(), destination[:], buf)
+}
+
 // Helper function that sends a transaction to the EVM
 func sendTx(tx *types.Transaction, stateDb *state.StateDB) (
 	*types.Receipt, error) {
@@ -326,6 +605,9 @@ func sendTx(tx *types.Transaction, stateDb *state.StateDB) (
 		Time:       0,
 	}
 
+	// The logs emitted by the transaction are attached to its hash
+	stateDb.Prepare(tx.Hash(), common.Hash{}, 0)
+
 	// Apply transaction to the general EVM state
 	receipt, usedGas, err := core.ApplyTransaction(chainConfig, bc,
 		&nilAddress, gp, stateDb, header, tx, ug, vmConfig)
@@ -337,6 +619,33 @@ func sendTx(tx *types.Transaction, stateDb *state.StateDB) (
 	return receipt, nil
 }
 
+// receiptInstanceID returns the ID of the BEvm value instance holding the
+// receipt of a transaction. The prefix of the key makes sure it cannot
+// collide with the keys used by the EVM, which are hashes or prefixed hashes.
+func receiptInstanceID(bevmID byzcoin.InstanceID,
+	txHash common.Hash) byzcoin.InstanceID {
+	db := ByzDatabase{bevmIID: bevmID}
+
+	return db.getValueInstanceID(append([]byte("bevm-receipt-"),
+		txHash.Bytes()...))
+}
+
+// Helper function that creates the BEvm value instance holding the receipt of
+// a transaction. The receipts are not part of the EVM state database, so that
+// the list of its keys doesn't grow with every transaction.
+func receiptStateChange(bevmID byzcoin.InstanceID, receipt *types.Receipt,
+	darcID darc.ID) (byzcoin.StateChange, error) {
+	receiptBuf, err := receipt.MarshalJSON()
+	if err != nil {
+		return byzcoin.StateChange{},
+			xerrors.Errorf("failed to encode receipt: %v", err)
+	}
+
+	return byzcoin.NewStateChange(byzcoin.Create,
+		receiptInstanceID(bevmID, receipt.TxHash), ContractBEvmValueID,
+		receiptBuf, darcID), nil
+}
+
 // Delete deletes an existing BEvm contract
 func (c *contractBEvm) Delete(rst byzcoin.ReadOnlyStateTrie,
 	inst byzcoin.Instruction, coins []byzcoin.Coin) (sc []byzcoin.StateChange,
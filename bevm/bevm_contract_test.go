@@ -1,6 +1,7 @@
 package bevm
 
 import (
+	"encoding/binary"
 	"io/ioutil"
 	"math/big"
 	"os"
@@ -9,13 +10,17 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"go.dedis.ch/cothority/v3"
 	"go.dedis.ch/onet/v3/log"
 
 	"github.com/stretchr/testify/require"
 	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/byzcoin/contracts"
 	"go.dedis.ch/cothority/v3/darc"
 	"go.dedis.ch/onet/v3"
+	"go.dedis.ch/protobuf"
 )
 
 var txParams = struct {
@@ -280,6 +285,189 @@ func Test_InvokeTokenContract(t *testing.T) {
 	require.Equal(t, newB, balance)
 }
 
+func Test_TransactionReceipt(t *testing.T) {
+	log.LLvl1("TransactionReceipt")
+
+	// Create a new ledger and prepare for proper closing
+	bct := newBCTest(t)
+	defer bct.Close()
+
+	// Spawn a new BEvm instance
+	instanceID, err := NewBEvm(bct.cl, bct.signer, bct.gDarc)
+	require.NoError(t, err)
+
+	// Create a new BEvm client
+	bevmClient, err := NewClient(bct.cl, bct.signer, instanceID)
+	require.NoError(t, err)
+
+	// Initialize two accounts
+	a, err := NewEvmAccount(testPrivateKeys[0])
+	require.NoError(t, err)
+	b, err := NewEvmAccount(testPrivateKeys[1])
+	require.NoError(t, err)
+
+	// Credit the accounts
+	err = bevmClient.CreditAccount(big.NewInt(5*WeiPerEther), a.Address)
+	require.NoError(t, err)
+	err = bevmClient.CreditAccount(big.NewInt(5*WeiPerEther), b.Address)
+	require.NoError(t, err)
+
+	// Deploy an ERC20 Token contract
+	erc20Contract, err := NewEvmContract(
+		"ERC20Token", getContractData(t, "ERC20Token", "abi"), getContractData(t, "ERC20Token", "bin"))
+	require.NoError(t, err)
+	erc20Instance, err := bevmClient.Deploy(txParams.GasLimit, txParams.GasPrice, 0, a, erc20Contract)
+	require.NoError(t, err)
+
+	// Transfer 100 tokens from A to B; the receipt holds the Transfer event
+	receipt, err := bevmClient.TransactionWithReceipt(txParams.GasLimit, txParams.GasPrice, 0, a, erc20Instance, "transfer", b.Address, big.NewInt(100))
+	require.NoError(t, err)
+	require.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+	require.True(t, receipt.GasUsed > 0)
+	require.Equal(t, 1, len(receipt.Logs))
+
+	transferTopic := crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+	transferLog := receipt.Logs[0]
+	require.Equal(t, erc20Instance.Address, transferLog.Address)
+	require.Equal(t, receipt.TxHash, transferLog.TxHash)
+	require.Equal(t, 3, len(transferLog.Topics))
+	require.Equal(t, transferTopic, transferLog.Topics[0])
+	require.Equal(t, common.BytesToHash(a.Address.Bytes()), transferLog.Topics[1])
+	require.Equal(t, common.BytesToHash(b.Address.Bytes()), transferLog.Topics[2])
+	require.Equal(t, big.NewInt(100), new(big.Int).SetBytes(transferLog.Data))
+
+	// The receipt can be retrieved again from its hash
+	stored, err := bevmClient.GetReceipt(receipt.TxHash)
+	require.NoError(t, err)
+	require.Equal(t, receipt.GasUsed, stored.GasUsed)
+	_, err = bevmClient.GetReceipt(common.Hash{})
+	require.Error(t, err)
+
+	// The receipt is not part of the EVM state database
+	pr, err := bct.cl.GetProof(instanceID.Slice())
+	require.NoError(t, err)
+	stateBuf, _, _, err := pr.Proof.Get(instanceID.Slice())
+	require.NoError(t, err)
+	var state State
+	require.NoError(t, protobuf.Decode(stateBuf, &state))
+	for _, key := range state.KeyList {
+		require.NotContains(t, key, "bevm-receipt-")
+	}
+
+	// Try to transfer 101 tokens from B to A; the EVM execution fails
+	receipt, err = bevmClient.TransactionWithReceipt(txParams.GasLimit, txParams.GasPrice, 0, b, erc20Instance, "transfer", a.Address, big.NewInt(101))
+	require.NoError(t, err)
+	require.Equal(t, types.ReceiptStatusFailed, receipt.Status)
+	require.Equal(t, 0, len(receipt.Logs))
+
+	// Log filters
+	require.True(t, LogFilter{}.Match(transferLog))
+	require.True(t, LogFilter{
+		Addresses: []common.Address{erc20Instance.Address},
+		Topics:    [][]common.Hash{{transferTopic}, {}, {common.BytesToHash(b.Address.Bytes())}},
+	}.Match(transferLog))
+	require.False(t, LogFilter{
+		Addresses: []common.Address{a.Address},
+	}.Match(transferLog))
+	require.False(t, LogFilter{
+		Topics: [][]common.Hash{{transferTopic}, {common.BytesToHash(b.Address.Bytes())}},
+	}.Match(transferLog))
+	require.False(t, LogFilter{
+		Topics: [][]common.Hash{{}, {}, {}, {}},
+	}.Match(transferLog))
+}
+
+func Test_Coins(t *testing.T) {
+	log.LLvl1("Coins")
+
+	// Create a new ledger and prepare for proper closing
+	bct := newBCTest(t)
+	defer bct.Close()
+
+	// Spawn a user coin account with some coins, and the fee account
+	userCoin := bct.spawnCoin(1e10)
+	feeCoin := bct.spawnCoin(0)
+
+	// Spawn a new BEvm instance funded with coins
+	instanceID, err := NewBEvmWithCoins(bct.cl, bct.signer, bct.gDarc, CoinConfig{
+		Coin:        contracts.CoinName,
+		FeeAccount:  feeCoin,
+		MinGasPrice: WeiPerCoin,
+	})
+	require.NoError(t, err)
+
+	// Create a new BEvm client
+	bevmClient, err := NewClient(bct.cl, bct.signer, instanceID)
+	require.NoError(t, err)
+
+	a, err := NewEvmAccount(testPrivateKeys[0])
+	require.NoError(t, err)
+	b, err := NewEvmAccount(testPrivateKeys[1])
+	require.NoError(t, err)
+
+	// Ether cannot be created out of thin air
+	err = bevmClient.CreditAccount(big.NewInt(5*WeiPerEther), a.Address)
+	require.Error(t, err)
+
+	// Deposit 1 Ether worth of coins
+	err = bevmClient.Deposit(userCoin, 1e9, a.Address)
+	require.NoError(t, err)
+	require.Equal(t, uint64(9e9), bct.getCoinValue(userCoin))
+	balance, err := bevmClient.GetAccountBalance(a.Address)
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(WeiPerEther), balance)
+
+	// The gas fees of the deployment go to the fee account
+	erc20Contract, err := NewEvmContract(
+		"ERC20Token", getContractData(t, "ERC20Token", "abi"), getContractData(t, "ERC20Token", "bin"))
+	require.NoError(t, err)
+	erc20Instance, err := bevmClient.Deploy(txParams.GasLimit, WeiPerCoin, 0, a, erc20Contract)
+	require.NoError(t, err)
+	fees := bct.getCoinValue(feeCoin)
+	require.True(t, fees > 0)
+
+	// With a gas price of one coin, the fees are the gas used
+	receipt, err := bevmClient.TransactionWithReceipt(txParams.GasLimit, WeiPerCoin, 0, a, erc20Instance, "transfer", b.Address, big.NewInt(100))
+	require.NoError(t, err)
+	require.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+	require.Equal(t, fees+receipt.GasUsed, bct.getCoinValue(feeCoin))
+	fees += receipt.GasUsed
+
+	balance, err = bevmClient.GetAccountBalance(a.Address)
+	require.NoError(t, err)
+	require.Equal(t, new(big.Int).Sub(big.NewInt(WeiPerEther), coinsToWei(fees)), balance)
+
+	// The gas price cannot be below the minimum
+	err = bevmClient.Transaction(txParams.GasLimit, 1, 0, a, erc20Instance, "transfer", b.Address, big.NewInt(100))
+	require.Error(t, err)
+
+	// Withdraw coins back to the user account
+	err = bevmClient.Withdraw(a, 1000, userCoin)
+	require.NoError(t, err)
+	require.Equal(t, uint64(9e9+1000), bct.getCoinValue(userCoin))
+	balance, err = bevmClient.GetAccountBalance(a.Address)
+	require.NoError(t, err)
+	require.Equal(t, new(big.Int).Sub(big.NewInt(WeiPerEther), coinsToWei(fees+1000)), balance)
+
+	// The account keeps working after the withdrawal, which uses its nonce
+	err = bevmClient.Transaction(txParams.GasLimit, WeiPerCoin, 0, a, erc20Instance, "transfer", b.Address, big.NewInt(100))
+	require.NoError(t, err)
+
+	// Cannot withdraw more than the balance, nor without the signature of
+	// the account owner
+	err = bevmClient.Withdraw(a, 1e9, userCoin)
+	require.Error(t, err)
+	err = bevmClient.Withdraw(b, 1, userCoin)
+	require.Error(t, err)
+	err = bevmClient.invoke("withdraw", byzcoin.Arguments{
+		{Name: "address", Value: b.Address.Bytes()},
+		{Name: "amount", Value: make([]byte, 8)},
+		{Name: "destination", Value: userCoin.Slice()},
+		{Name: "signature", Value: make([]byte, 65)},
+	})
+	require.Error(t, err)
+}
+
 func Test_InvokeLoanContract(t *testing.T) {
 	log.LLvl1("LoanContract")
 	//Preparing ledger
@@ -407,7 +595,9 @@ func newBCTest(t *testing.T) (out *bcTest) {
 	// to create and update keyValue contracts.
 	var err error
 	out.gMsg, err = byzcoin.DefaultGenesisMsg(byzcoin.CurrentVersion, out.roster,
-		[]string{"spawn:bevm", "invoke:bevm.credit", "invoke:bevm.transaction", "delete:bevm"},
+		[]string{"spawn:bevm", "invoke:bevm.credit", "invoke:bevm.transaction", "delete:bevm",
+			"invoke:bevm.deposit", "invoke:bevm.withdraw",
+			"spawn:coin", "invoke:coin.mint", "invoke:coin.fetch"},
 		out.signer.Identity())
 	require.NoError(t, err)
 	out.gDarc = &out.gMsg.GenesisDarc
@@ -428,6 +618,43 @@ func (bct *bcTest) Close() {
 	bct.local.CloseAll()
 }
 
+// Spawn a coin instance holding the given amount of coins
+func (bct *bcTest) spawnCoin(amount uint64) byzcoin.InstanceID {
+	tx, err := execByzCoinTx(bct.cl, bct.signer,
+		byzcoin.NewInstanceID(bct.gDarc.GetBaseID()), &byzcoin.Spawn{
+			ContractID: contracts.ContractCoinID,
+		}, nil, nil)
+	require.NoError(bct.t, err)
+	coinID := tx.Instructions[0].DeriveID("")
+
+	if amount > 0 {
+		coins := make([]byte, 8)
+		binary.LittleEndian.PutUint64(coins, amount)
+		_, err = execByzCoinTx(bct.cl, bct.signer, coinID, nil,
+			&byzcoin.Invoke{
+				ContractID: contracts.ContractCoinID,
+				Command:    "mint",
+				Args:       byzcoin.Arguments{{Name: "coins", Value: coins}},
+			}, nil)
+		require.NoError(bct.t, err)
+	}
+
+	return coinID
+}
+
+// Retrieve the number of coins in a coin instance
+func (bct *bcTest) getCoinValue(coinID byzcoin.InstanceID) uint64 {
+	resp, err := bct.cl.GetProof(coinID.Slice())
+	require.NoError(bct.t, err)
+	value, _, _, err := resp.Proof.Get(coinID.Slice())
+	require.NoError(bct.t, err)
+
+	var coin byzcoin.Coin
+	require.NoError(bct.t, protobuf.Decode(value, &coin))
+
+	return coin.Value
+}
+
 // Helper functions
 
 // Sometimes, the result of a call to an Ethereum method is unpacked to a
@@ -12,6 +12,7 @@ import (
 	"go.dedis.ch/cothority/v3/bevm"
 	"go.dedis.ch/cothority/v3/byzcoin"
 	"go.dedis.ch/cothority/v3/byzcoin/bcadmin/lib"
+	"go.dedis.ch/cothority/v3/byzcoin/contracts"
 	"go.dedis.ch/cothority/v3/darc"
 	"go.dedis.ch/onet/v3/cfgpath"
 	"go.dedis.ch/onet/v3/log"
@@ -50,6 +51,21 @@ var cmds = cli.Commands{
 				Name:  "outID",
 				Usage: "output file for the BEvm ID (optional)",
 			},
+			cli.StringFlag{
+				Name: "feeAccount",
+				Usage: "coin instance receiving the gas fees; if set, the " +
+					"accounts are funded with coins (optional)",
+			},
+			cli.StringFlag{
+				Name: "coin",
+				Usage: "type of the coins funding the accounts (default " +
+					"is the ByzCoin coin)",
+			},
+			cli.Uint64Flag{
+				Name:  "minGasPrice",
+				Value: 0,
+				Usage: "minimum gas price of the transactions",
+			},
 		},
 		Action: spawn,
 	},
@@ -149,7 +165,18 @@ func spawn(c *cli.Context) error {
 		return xerrors.Errorf("failed to load DARC data: %v", err)
 	}
 
-	bevmInstID, err := bevm.NewBEvm(cl, *signer, darc)
+	var bevmInstID byzcoin.InstanceID
+	feeAccountStr := c.String("feeAccount")
+	if feeAccountStr == "" {
+		bevmInstID, err = bevm.NewBEvm(cl, *signer, darc)
+	} else {
+		var config bevm.CoinConfig
+		config, err = getCoinConfig(c, feeAccountStr)
+		if err != nil {
+			return xerrors.Errorf("invalid coin configuration: %v", err)
+		}
+		bevmInstID, err = bevm.NewBEvmWithCoins(cl, *signer, darc, config)
+	}
 	if err != nil {
 		return xerrors.Errorf("failed to spawn new BEvm instance: %v", err)
 	}
@@ -172,6 +199,31 @@ func spawn(c *cli.Context) error {
 	return nil
 }
 
+func getCoinConfig(c *cli.Context, feeAccountStr string) (
+	bevm.CoinConfig, error) {
+	feeAccount, err := hex.DecodeString(feeAccountStr)
+	if err != nil {
+		return bevm.CoinConfig{}, xerrors.Errorf("invalid fee account "+
+			"ID: %v", err)
+	}
+
+	coin := contracts.CoinName
+	if coinStr := c.String("coin"); coinStr != "" {
+		coinBuf, err := hex.DecodeString(coinStr)
+		if err != nil {
+			return bevm.CoinConfig{}, xerrors.Errorf("invalid coin "+
+				"type: %v", err)
+		}
+		coin = byzcoin.NewInstanceID(coinBuf)
+	}
+
+	return bevm.CoinConfig{
+		Coin:        coin,
+		FeeAccount:  byzcoin.NewInstanceID(feeAccount),
+		MinGasPrice: c.Uint64("minGasPrice"),
+	}, nil
+}
+
 func delete(c *cli.Context) error {
 	bcFile := c.String("bc")
 
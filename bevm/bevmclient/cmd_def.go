@@ -52,6 +52,32 @@ var cmds = cli.Commands{
 		Flags:     commonFlags,
 		Action:    creditAccount,
 	},
+	{
+		Name:      "deposit",
+		Usage:     "credit a BEvm account with coins from a coin instance",
+		ArgsUsage: "<amount in coins>",
+		Flags: append(commonFlags,
+			cli.StringFlag{
+				Name:     "coinID",
+				Usage:    "coin instance to take the coins from",
+				Required: true,
+			},
+		),
+		Action: deposit,
+	},
+	{
+		Name:      "withdraw",
+		Usage:     "transfer coins from a BEvm account to a coin instance",
+		ArgsUsage: "<amount in coins>",
+		Flags: append(commonFlags,
+			cli.StringFlag{
+				Name:     "coinID",
+				Usage:    "coin instance to send the coins to",
+				Required: true,
+			},
+		),
+		Action: withdraw,
+	},
 	{
 		Name:      "getAccountBalance",
 		Usage:     "retrieve the balance of a BEvm account",
@@ -132,4 +158,19 @@ var cmds = cli.Commands{
 		),
 		Action: executeCall,
 	},
+	{
+		Name: "gateway",
+		Usage: "serve the Ethereum JSON-RPC API of a BEvm instance " +
+			"over HTTP",
+		Aliases:   []string{"gw"},
+		ArgsUsage: "",
+		Flags: append(commonFlags,
+			cli.StringFlag{
+				Name:  "listen",
+				Value: "localhost:8545",
+				Usage: "address to listen on",
+			},
+		),
+		Action: runGateway,
+	},
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"math/big"
+	"net/http"
 	"reflect"
 	"strconv"
 
@@ -76,6 +77,70 @@ func creditAccount(ctx *cli.Context) error {
 	return nil
 }
 
+func deposit(ctx *cli.Context) error {
+	// Retrieve options and arguments
+
+	opt, err := handleCommonOptions(ctx)
+	if err != nil {
+		return xerrors.Errorf("failed to handle provided options: %v", err)
+	}
+
+	coinID, amount, err := getCoinArguments(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Perform command
+
+	err = opt.bevmClient.Deposit(coinID, amount, opt.account.Address)
+	if err != nil {
+		return xerrors.Errorf("failed to deposit coins: %v", err)
+	}
+
+	_, err = fmt.Fprintf(ctx.App.Writer, "Deposited %d coins on account %s\n",
+		amount, opt.account.Address.Hex())
+	if err != nil {
+		return xerrors.Errorf("failed to write report msg: %v", err)
+	}
+
+	return nil
+}
+
+func withdraw(ctx *cli.Context) error {
+	// Retrieve options and arguments
+
+	opt, err := handleCommonOptions(ctx)
+	if err != nil {
+		return xerrors.Errorf("failed to handle provided options: %v", err)
+	}
+
+	coinID, amount, err := getCoinArguments(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Perform command
+
+	err = opt.bevmClient.Withdraw(opt.account, amount, coinID)
+	if err != nil {
+		return xerrors.Errorf("failed to withdraw coins: %v", err)
+	}
+
+	// Save the account nonce
+	err = writeAccountFile(opt.account, opt.accountName, false)
+	if err != nil {
+		return xerrors.Errorf("failed to update account file: %v", err)
+	}
+
+	_, err = fmt.Fprintf(ctx.App.Writer, "Withdrew %d coins from account "+
+		"%s\n", amount, opt.account.Address.Hex())
+	if err != nil {
+		return xerrors.Errorf("failed to write report msg: %v", err)
+	}
+
+	return nil
+}
+
 func getAccountBalance(ctx *cli.Context) error {
 	// Retrieve options and arguments
 
@@ -300,3 +365,35 @@ func executeCall(ctx *cli.Context) error {
 
 	return nil
 }
+
+func runGateway(ctx *cli.Context) error {
+	// Retrieve options and arguments
+
+	bevmClient, err := loadBEvmClient(ctx)
+	if err != nil {
+		return xerrors.Errorf("failed to handle provided options: %v", err)
+	}
+
+	listen := ctx.String("listen")
+
+	// Perform command
+
+	gw, err := bevm.NewGateway(bevmClient)
+	if err != nil {
+		return xerrors.Errorf("failed to create gateway: %v", err)
+	}
+	defer gw.Close()
+
+	_, err = fmt.Fprintf(ctx.App.Writer,
+		"Serving the Ethereum JSON-RPC API on http://%s\n", listen)
+	if err != nil {
+		return xerrors.Errorf("failed to write report msg: %v", err)
+	}
+
+	err = http.ListenAndServe(listen, gw)
+	if err != nil {
+		return xerrors.Errorf("failed to serve JSON-RPC API: %v", err)
+	}
+
+	return nil
+}
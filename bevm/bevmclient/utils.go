@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -148,10 +149,29 @@ type commonOptions struct {
 }
 
 func handleCommonOptions(ctx *cli.Context) (*commonOptions, error) {
+	accountName := ctx.String("accountName")
+
+	bevmClient, err := loadBEvmClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := readAccountFile(accountName)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read account from file: %v", err)
+	}
+
+	return &commonOptions{
+		account:     account,
+		accountName: accountName,
+		bevmClient:  bevmClient,
+	}, nil
+}
+
+func loadBEvmClient(ctx *cli.Context) (*bevm.Client, error) {
 	bcFile := ctx.String("bc")
 	bevmIDStr := ctx.String("bevmID")
 	signerStr := ctx.String("sign")
-	accountName := ctx.String("accountName")
 
 	bevmID, err := hex.DecodeString(bevmIDStr)
 	if err != nil {
@@ -180,14 +200,25 @@ func handleCommonOptions(ctx *cli.Context) (*commonOptions, error) {
 			"instance: %v", err)
 	}
 
-	account, err := readAccountFile(accountName)
+	return bevmClient, nil
+}
+
+func getCoinArguments(ctx *cli.Context) (byzcoin.InstanceID, uint64, error) {
+	coinID, err := hex.DecodeString(ctx.String("coinID"))
 	if err != nil {
-		return nil, xerrors.Errorf("failed to read account from file: %v", err)
+		return byzcoin.InstanceID{}, 0,
+			xerrors.Errorf("failed to decode coin instance ID: %v", err)
 	}
 
-	return &commonOptions{
-		account:     account,
-		accountName: accountName,
-		bevmClient:  bevmClient,
-	}, nil
+	if !ctx.Args().Present() {
+		return byzcoin.InstanceID{}, 0, xerrors.New("missing <amount> argument")
+	}
+
+	amount, err := strconv.ParseUint(ctx.Args().First(), 0, 64)
+	if err != nil {
+		return byzcoin.InstanceID{}, 0,
+			xerrors.Errorf("failed to parse <amount> value: %v", err)
+	}
+
+	return byzcoin.NewInstanceID(coinID), amount, nil
 }
@@ -0,0 +1,589 @@
+package bevm
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/skipchain"
+	"golang.org/x/xerrors"
+)
+
+// maxLogBlocks is the largest range of blocks accepted by eth_getLogs
+const maxLogBlocks = 1000
+
+// maxLogReceipts is the largest number of receipts retrieved for one
+// eth_getLogs request
+const maxLogReceipts = 1000
+
+// maxGatewayBlocks is the number of latest blocks whose EVM transactions are
+// kept in memory by the gateway
+const maxGatewayBlocks = 1000
+
+// Gateway serves the Ethereum JSON-RPC API for a BEvm instance over HTTP, so
+// that the usual Ethereum tools can interact with it. Transactions are sent
+// to ByzCoin with the client's signer, and the state is read from the latest
+// ByzCoin block.
+//
+// The Ethereum blocks are the ByzCoin blocks. The gateway keeps the EVM
+// transactions of the latest maxGatewayBlocks ByzCoin blocks in memory,
+// indexed by hash, to find the block of a receipt and to search logs.
+type Gateway struct {
+	client     *Client
+	skipClient *skipchain.Client
+	server     *rpc.Server
+
+	// sendLock serializes the ByzCoin transactions of the gateway, as they
+	// are all signed by the signer of the client and need consecutive
+	// counters.
+	sendLock sync.Mutex
+
+	blocksLock sync.Mutex
+	// first is the index of the oldest block in blocks
+	first  uint64
+	blocks []gatewayBlock
+	txs    map[common.Hash]txLocation
+}
+
+// gatewayBlock holds the EVM transactions of a ByzCoin block
+type gatewayBlock struct {
+	hash common.Hash
+	txs  []*types.Transaction
+}
+
+// txLocation is the position of an EVM transaction in the ByzCoin blocks
+type txLocation struct {
+	block    uint64
+	position int
+}
+
+// NewGateway creates a new Ethereum JSON-RPC gateway for the BEvm instance
+// of the client
+func NewGateway(client *Client) (*Gateway, error) {
+	gw := &Gateway{
+		client:     client,
+		skipClient: skipchain.NewClient(),
+		server:     rpc.NewServer(),
+		txs:        make(map[common.Hash]txLocation),
+	}
+
+	err := gw.server.RegisterName("eth", &EthAPI{gw: gw})
+	if err != nil {
+		return nil, xerrors.Errorf("failed to register eth API: %v", err)
+	}
+
+	err = gw.server.RegisterName("net", &NetAPI{})
+	if err != nil {
+		return nil, xerrors.Errorf("failed to register net API: %v", err)
+	}
+
+	return gw, nil
+}
+
+// ServeHTTP handles a JSON-RPC request
+func (gw *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	gw.server.ServeHTTP(w, r)
+}
+
+// Close stops the gateway
+func (gw *Gateway) Close() {
+	gw.server.Stop()
+}
+
+// Update the in-memory index with the ByzCoin blocks created since the last
+// update, and return the index of the latest block
+func (gw *Gateway) update() (uint64, error) {
+	gw.blocksLock.Lock()
+	defer gw.blocksLock.Unlock()
+
+	bcClient := gw.client.bcClient
+	from := skipchain.SkipBlockID(bcClient.ID)
+	if len(gw.blocks) > 0 {
+		from = gw.blocks[len(gw.blocks)-1].hash.Bytes()
+	}
+
+	// The highest forward links lead quickly to the latest block
+	chain, err := gw.skipClient.GetUpdateChain(&bcClient.Roster, from)
+	if err != nil {
+		return 0, xerrors.Errorf("failed to retrieve latest ByzCoin "+
+			"block: %v", err)
+	}
+	latest := uint64(chain.Update[len(chain.Update)-1].Index)
+	next := gw.first + uint64(len(gw.blocks))
+	if latest < next {
+		return latest, nil
+	}
+
+	// Skip the blocks that would be evicted right away
+	if latest-next >= maxGatewayBlocks {
+		next = latest - maxGatewayBlocks + 1
+		reply, err := gw.skipClient.GetSingleBlockByIndex(&bcClient.Roster,
+			bcClient.ID, int(next))
+		if err != nil {
+			return 0, xerrors.Errorf("failed to retrieve ByzCoin block "+
+				"%d: %v", next, err)
+		}
+		from = reply.SkipBlock.Hash
+		gw.first = next
+		gw.blocks = nil
+		gw.txs = make(map[common.Hash]txLocation)
+	}
+
+	blocks, err := gw.skipClient.GetUpdateChainLevel(&bcClient.Roster, from,
+		0, -1)
+	if err != nil {
+		return 0, xerrors.Errorf("failed to retrieve ByzCoin blocks: %v", err)
+	}
+
+	for _, block := range blocks {
+		index := uint64(block.Index)
+		if index < next {
+			continue
+		}
+
+		// The transactions of a pruned block are lost, so it is kept
+		// without any.
+		var txs []*types.Transaction
+		if len(block.Payload) > 0 {
+			txs, err = gw.client.blockTransactions(block)
+			if err != nil {
+				return 0, xerrors.Errorf("failed to decode block %d: %v",
+					block.Index, err)
+			}
+		}
+
+		for i, tx := range txs {
+			gw.txs[tx.Hash()] = txLocation{block: index, position: i}
+		}
+		gw.blocks = append(gw.blocks, gatewayBlock{
+			hash: common.BytesToHash(block.Hash),
+			txs:  txs,
+		})
+		next = index + 1
+	}
+
+	gw.evict()
+
+	return next - 1, nil
+}
+
+// Remove the oldest blocks from the in-memory index, so that it holds at
+// most maxGatewayBlocks blocks
+func (gw *Gateway) evict() {
+	n := len(gw.blocks) - maxGatewayBlocks
+	if n <= 0 {
+		return
+	}
+
+	for _, block := range gw.blocks[:n] {
+		for _, tx := range block.txs {
+			delete(gw.txs, tx.Hash())
+		}
+	}
+	gw.blocks = append([]gatewayBlock(nil), gw.blocks[n:]...)
+	gw.first += uint64(n)
+}
+
+// Return the blocks from index 'from' to index 'to', which must have been
+// indexed
+func (gw *Gateway) getBlocks(from, to uint64) ([]gatewayBlock, error) {
+	gw.blocksLock.Lock()
+	defer gw.blocksLock.Unlock()
+
+	if from < gw.first {
+		return nil, xerrors.Errorf("the blocks before %d are not "+
+			"available", gw.first)
+	}
+
+	return append([]gatewayBlock(nil),
+		gw.blocks[from-gw.first:to-gw.first+1]...), nil
+}
+
+// Find the block and the position of an EVM transaction
+func (gw *Gateway) findTransaction(txHash common.Hash) (uint64, gatewayBlock,
+	int, bool) {
+	gw.blocksLock.Lock()
+	defer gw.blocksLock.Unlock()
+
+	location, found := gw.txs[txHash]
+	if !found {
+		return 0, gatewayBlock{}, 0, false
+	}
+
+	return location.block, gw.blocks[location.block-gw.first],
+		location.position, true
+}
+
+// Retrieve the receipts of the EVM transactions of a block, with their logs
+// positioned in the block
+func (gw *Gateway) blockReceipts(index uint64, block gatewayBlock) (
+	[]*types.Receipt, error) {
+	receipts := make([]*types.Receipt, len(block.txs))
+	logIndex := uint(0)
+	for i, tx := range block.txs {
+		receipt, err := gw.client.GetReceipt(tx.Hash())
+		if err != nil {
+			return nil, xerrors.Errorf("failed to retrieve receipt: %v", err)
+		}
+
+		for _, evmLog := range receipt.Logs {
+			evmLog.BlockNumber = index
+			evmLog.BlockHash = block.hash
+			evmLog.TxHash = tx.Hash()
+			evmLog.TxIndex = uint(i)
+			evmLog.Index = logIndex
+			logIndex++
+		}
+
+		receipts[i] = receipt
+	}
+
+	return receipts, nil
+}
+
+// Check that a block parameter refers to the latest state, the only one
+// available through the gateway
+func checkLatest(blockNumber *rpc.BlockNumber, latest uint64) error {
+	if blockNumber == nil ||
+		*blockNumber == rpc.LatestBlockNumber ||
+		*blockNumber == rpc.PendingBlockNumber ||
+		uint64(*blockNumber) == latest {
+		return nil
+	}
+
+	return xerrors.New("only the latest state is available")
+}
+
+// Resolve a block parameter into a block index
+func resolveBlock(blockNumber *rpc.BlockNumber, latest uint64) uint64 {
+	if blockNumber == nil ||
+		*blockNumber == rpc.LatestBlockNumber ||
+		*blockNumber == rpc.PendingBlockNumber ||
+		uint64(*blockNumber) > latest {
+		return latest
+	}
+
+	return uint64(*blockNumber)
+}
+
+// ---------------------------------------------------------------------------
+
+// EthAPI implements the "eth" namespace of the Ethereum JSON-RPC API
+type EthAPI struct {
+	gw *Gateway
+}
+
+// CallArgs are the arguments of eth_call
+type CallArgs struct {
+	From     *common.Address `json:"from"`
+	To       *common.Address `json:"to"`
+	Gas      *hexutil.Uint64 `json:"gas"`
+	GasPrice *hexutil.Big    `json:"gasPrice"`
+	Value    *hexutil.Big    `json:"value"`
+	Data     *hexutil.Bytes  `json:"data"`
+}
+
+// LogQuery holds the arguments of eth_getLogs. The address can be a single
+// address or a list of addresses, and every topic can be null, a single topic
+// or a list of topics.
+type LogQuery struct {
+	FromBlock *rpc.BlockNumber
+	ToBlock   *rpc.BlockNumber
+	Filter    LogFilter
+}
+
+// UnmarshalJSON decodes the filter object of eth_getLogs
+func (query *LogQuery) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		FromBlock *rpc.BlockNumber  `json:"fromBlock"`
+		ToBlock   *rpc.BlockNumber  `json:"toBlock"`
+		Address   json.RawMessage   `json:"address"`
+		Topics    []json.RawMessage `json:"topics"`
+	}
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+
+	query.FromBlock = raw.FromBlock
+	query.ToBlock = raw.ToBlock
+	query.Filter = LogFilter{}
+
+	if len(raw.Address) > 0 && string(raw.Address) != "null" {
+		var address common.Address
+		if json.Unmarshal(raw.Address, &address) == nil {
+			query.Filter.Addresses = []common.Address{address}
+		} else {
+			err = json.Unmarshal(raw.Address, &query.Filter.Addresses)
+			if err != nil {
+				return xerrors.Errorf("invalid address: %v", err)
+			}
+		}
+	}
+
+	for _, rawTopic := range raw.Topics {
+		var topics []common.Hash
+		if len(rawTopic) > 0 && string(rawTopic) != "null" {
+			var topic common.Hash
+			if json.Unmarshal(rawTopic, &topic) == nil {
+				topics = []common.Hash{topic}
+			} else {
+				err = json.Unmarshal(rawTopic, &topics)
+				if err != nil {
+					return xerrors.Errorf("invalid topic: %v", err)
+				}
+			}
+		}
+		query.Filter.Topics = append(query.Filter.Topics, topics)
+	}
+
+	return nil
+}
+
+// ChainId returns the chain ID used to sign the EVM transactions. The name is
+// dictated by the JSON-RPC method eth_chainId.
+func (api *EthAPI) ChainId() *hexutil.Big {
+	return (*hexutil.Big)(getChainConfig().ChainID)
+}
+
+// BlockNumber returns the index of the latest ByzCoin block
+func (api *EthAPI) BlockNumber() (hexutil.Uint64, error) {
+	latest, err := api.gw.update()
+	if err != nil {
+		return 0, err
+	}
+
+	return hexutil.Uint64(latest), nil
+}
+
+// GetBalance returns the balance of an address
+func (api *EthAPI) GetBalance(address common.Address,
+	blockNumber *rpc.BlockNumber) (*hexutil.Big, error) {
+	latest, err := api.gw.update()
+	if err != nil {
+		return nil, err
+	}
+	err = checkLatest(blockNumber, latest)
+	if err != nil {
+		return nil, err
+	}
+
+	stateDb, err := getEvmDb(api.gw.client.bcClient,
+		api.gw.client.instanceID)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to retrieve EVM state: %v", err)
+	}
+
+	return (*hexutil.Big)(stateDb.GetBalance(address)), nil
+}
+
+// GetTransactionCount returns the nonce of an address
+func (api *EthAPI) GetTransactionCount(address common.Address,
+	blockNumber *rpc.BlockNumber) (hexutil.Uint64, error) {
+	latest, err := api.gw.update()
+	if err != nil {
+		return 0, err
+	}
+	err = checkLatest(blockNumber, latest)
+	if err != nil {
+		return 0, err
+	}
+
+	stateDb, err := getEvmDb(api.gw.client.bcClient,
+		api.gw.client.instanceID)
+	if err != nil {
+		return 0, xerrors.Errorf("failed to retrieve EVM state: %v", err)
+	}
+
+	return hexutil.Uint64(stateDb.GetNonce(address)), nil
+}
+
+// Call executes a contract method on the latest state, without changing it
+func (api *EthAPI) Call(args CallArgs,
+	blockNumber *rpc.BlockNumber) (hexutil.Bytes, error) {
+	latest, err := api.gw.update()
+	if err != nil {
+		return nil, err
+	}
+	err = checkLatest(blockNumber, latest)
+	if err != nil {
+		return nil, err
+	}
+
+	if args.To == nil {
+		return nil, xerrors.New("missing 'to' address")
+	}
+
+	var from common.Address
+	if args.From != nil {
+		from = *args.From
+	}
+	// 1 Ether should be enough for everyone [tm]...
+	gas := uint64(1 * WeiPerEther)
+	if args.Gas != nil {
+		gas = uint64(*args.Gas)
+	}
+	value := big.NewInt(0)
+	if args.Value != nil {
+		value = args.Value.ToInt()
+	}
+	var data []byte
+	if args.Data != nil {
+		data = *args.Data
+	}
+
+	ret, err := api.gw.client.call(from, *args.To, data, gas, value)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to execute EVM call: %v", err)
+	}
+
+	return ret, nil
+}
+
+// SendRawTransaction sends a signed EVM transaction to ByzCoin, and returns
+// its hash once it has been included in a block
+func (api *EthAPI) SendRawTransaction(encodedTx hexutil.Bytes) (
+	common.Hash, error) {
+	tx := new(types.Transaction)
+	err := rlp.DecodeBytes(encodedTx, tx)
+	if err != nil {
+		return common.Hash{}, xerrors.Errorf("failed to decode EVM "+
+			"transaction: %v", err)
+	}
+
+	txBuffer, err := tx.MarshalJSON()
+	if err != nil {
+		return common.Hash{}, xerrors.Errorf("failed to serialize EVM "+
+			"transaction to JSON: %v", err)
+	}
+
+	api.gw.sendLock.Lock()
+	err = api.gw.client.invoke("transaction", byzcoin.Arguments{
+		{Name: "tx", Value: txBuffer},
+	})
+	api.gw.sendLock.Unlock()
+	if err != nil {
+		return common.Hash{}, xerrors.Errorf("failed to invoke ByzCoin "+
+			"transaction: %v", err)
+	}
+
+	return tx.Hash(), nil
+}
+
+// GetTransactionReceipt returns the receipt of an EVM transaction, or nil if
+// the transaction is unknown
+func (api *EthAPI) GetTransactionReceipt(txHash common.Hash) (
+	map[string]interface{}, error) {
+	_, err := api.gw.update()
+	if err != nil {
+		return nil, err
+	}
+
+	index, block, position, found := api.gw.findTransaction(txHash)
+	if !found {
+		return nil, nil
+	}
+
+	receipts, err := api.gw.blockReceipts(index, block)
+	if err != nil {
+		return nil, err
+	}
+	receipt := receipts[position]
+	tx := block.txs[position]
+
+	from, err := types.Sender(types.MakeSigner(getChainConfig(),
+		big.NewInt(0)), tx)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to recover transaction "+
+			"sender: %v", err)
+	}
+
+	logs := receipt.Logs
+	if logs == nil {
+		logs = []*types.Log{}
+	}
+
+	fields := map[string]interface{}{
+		"transactionHash":   txHash,
+		"transactionIndex":  hexutil.Uint64(position),
+		"blockHash":         block.hash,
+		"blockNumber":       hexutil.Uint64(index),
+		"from":              from,
+		"to":                tx.To(),
+		"cumulativeGasUsed": hexutil.Uint64(receipt.CumulativeGasUsed),
+		"gasUsed":           hexutil.Uint64(receipt.GasUsed),
+		"contractAddress":   nil,
+		"logs":              logs,
+		"logsBloom":         receipt.Bloom,
+		"status":            hexutil.Uint(receipt.Status),
+	}
+	if receipt.ContractAddress != (common.Address{}) {
+		fields["contractAddress"] = receipt.ContractAddress
+	}
+
+	return fields, nil
+}
+
+// GetLogs returns the logs matching the query
+func (api *EthAPI) GetLogs(query LogQuery) ([]*types.Log, error) {
+	latest, err := api.gw.update()
+	if err != nil {
+		return nil, err
+	}
+
+	from := resolveBlock(query.FromBlock, latest)
+	to := resolveBlock(query.ToBlock, latest)
+	if from > to {
+		return nil, xerrors.New("'fromBlock' is after 'toBlock'")
+	}
+	if to-from >= maxLogBlocks {
+		return nil, xerrors.Errorf("the range cannot be larger than %d "+
+			"blocks", maxLogBlocks)
+	}
+
+	blocks, err := api.gw.getBlocks(from, to)
+	if err != nil {
+		return nil, err
+	}
+	count := 0
+	for _, block := range blocks {
+		count += len(block.txs)
+	}
+	if count > maxLogReceipts {
+		return nil, xerrors.Errorf("the range cannot hold more than %d "+
+			"transactions", maxLogReceipts)
+	}
+
+	logs := []*types.Log{}
+	for i, block := range blocks {
+		receipts, err := api.gw.blockReceipts(from+uint64(i), block)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, receipt := range receipts {
+			for _, evmLog := range receipt.Logs {
+				if query.Filter.Match(evmLog) {
+					logs = append(logs, evmLog)
+				}
+			}
+		}
+	}
+
+	return logs, nil
+}
+
+// NetAPI implements the "net" namespace of the Ethereum JSON-RPC API
+type NetAPI struct{}
+
+// Version returns the network ID, which is the chain ID
+func (api *NetAPI) Version() string {
+	return getChainConfig().ChainID.String()
+}
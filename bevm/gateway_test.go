@@ -0,0 +1,222 @@
+package bevm
+
+import (
+	"context"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/onet/v3/log"
+)
+
+// Interact with a BEvm instance through the Ethereum JSON-RPC gateway
+func Test_Gateway(t *testing.T) {
+	log.LLvl1("Gateway")
+
+	// Create a new ledger and prepare for proper closing
+	bct := newBCTest(t)
+	defer bct.Close()
+
+	// Spawn a new BEvm instance
+	instanceID, err := NewBEvm(bct.cl, bct.signer, bct.gDarc)
+	require.NoError(t, err)
+
+	// Create a new BEvm client
+	bevmClient, err := NewClient(bct.cl, bct.signer, instanceID)
+	require.NoError(t, err)
+
+	// Initialize two accounts
+	a, err := NewEvmAccount(testPrivateKeys[0])
+	require.NoError(t, err)
+	b, err := NewEvmAccount(testPrivateKeys[1])
+	require.NoError(t, err)
+
+	// Credit the first account
+	err = bevmClient.CreditAccount(big.NewInt(5*WeiPerEther), a.Address)
+	require.NoError(t, err)
+
+	// Deploy an ERC20 Token contract
+	erc20Contract, err := NewEvmContract(
+		"ERC20Token", getContractData(t, "ERC20Token", "abi"), getContractData(t, "ERC20Token", "bin"))
+	require.NoError(t, err)
+	erc20Instance, err := bevmClient.Deploy(txParams.GasLimit, txParams.GasPrice, 0, a, erc20Contract)
+	require.NoError(t, err)
+
+	// Start the gateway and connect an Ethereum client
+	gw, err := NewGateway(bevmClient)
+	require.NoError(t, err)
+	defer gw.Close()
+	server := httptest.NewServer(gw)
+	defer server.Close()
+
+	rpcClient, err := rpc.DialHTTP(server.URL)
+	require.NoError(t, err)
+	defer rpcClient.Close()
+	ethClient := ethclient.NewClient(rpcClient)
+	ctx := context.Background()
+
+	var chainID hexutil.Big
+	require.NoError(t, rpcClient.Call(&chainID, "eth_chainId"))
+	require.Equal(t, getChainConfig().ChainID, chainID.ToInt())
+
+	// State reads
+	balance, err := ethClient.BalanceAt(ctx, b.Address, nil)
+	require.NoError(t, err)
+	assertBigInt0(t, balance)
+
+	nonce, err := ethClient.NonceAt(ctx, a.Address, nil)
+	require.NoError(t, err)
+	require.Equal(t, a.Nonce, nonce)
+
+	_, err = ethClient.BalanceAt(ctx, a.Address, big.NewInt(1))
+	require.Error(t, err)
+
+	// Transfer 100 tokens from A to B with a raw transaction
+	callData, err := erc20Contract.Abi.Pack("transfer", b.Address, big.NewInt(100))
+	require.NoError(t, err)
+	tx := types.NewTransaction(nonce, erc20Instance.Address, big.NewInt(0),
+		txParams.GasLimit, big.NewInt(int64(txParams.GasPrice)), callData)
+	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(getChainConfig().ChainID), a.PrivateKey)
+	require.NoError(t, err)
+	require.NoError(t, ethClient.SendTransaction(ctx, signedTx))
+
+	receipt, err := ethClient.TransactionReceipt(ctx, signedTx.Hash())
+	require.NoError(t, err)
+	require.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+	require.Equal(t, 1, len(receipt.Logs))
+	require.Equal(t, signedTx.Hash(), receipt.Logs[0].TxHash)
+
+	_, err = ethClient.TransactionReceipt(ctx, common.Hash{})
+	require.Equal(t, ethereum.NotFound, err)
+
+	var blockNumber hexutil.Uint64
+	require.NoError(t, rpcClient.Call(&blockNumber, "eth_blockNumber"))
+	require.True(t, uint64(blockNumber) >= receipt.Logs[0].BlockNumber)
+
+	// View method call
+	callData, err = erc20Contract.Abi.Pack("balanceOf", b.Address)
+	require.NoError(t, err)
+	ret, err := ethClient.CallContract(ctx, ethereum.CallMsg{
+		From: a.Address,
+		To:   &erc20Instance.Address,
+		Data: callData,
+	}, nil)
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(100), new(big.Int).SetBytes(ret))
+
+	// Log search
+	transferTopic := crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+	logs, err := ethClient.FilterLogs(ctx, ethereum.FilterQuery{
+		Addresses: []common.Address{erc20Instance.Address},
+		Topics:    [][]common.Hash{{transferTopic}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	require.Equal(t, *receipt.Logs[0], logs[0])
+
+	logs, err = ethClient.FilterLogs(ctx, ethereum.FilterQuery{
+		Addresses: []common.Address{b.Address},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 0, len(logs))
+}
+
+// Send raw transactions concurrently through the gateway
+func Test_GatewayConcurrentSends(t *testing.T) {
+	log.LLvl1("Gateway concurrent sends")
+
+	bct := newBCTest(t)
+	defer bct.Close()
+
+	instanceID, err := NewBEvm(bct.cl, bct.signer, bct.gDarc)
+	require.NoError(t, err)
+	bevmClient, err := NewClient(bct.cl, bct.signer, instanceID)
+	require.NoError(t, err)
+
+	// Every sender has its own account, so that the EVM nonces don't depend
+	// on the order of the transactions.
+	to, err := NewEvmAccount(testPrivateKeys[0])
+	require.NoError(t, err)
+	var senders []*EvmAccount
+	for _, key := range testPrivateKeys[1:] {
+		a, err := NewEvmAccount(key)
+		require.NoError(t, err)
+		err = bevmClient.CreditAccount(big.NewInt(WeiPerEther), a.Address)
+		require.NoError(t, err)
+		senders = append(senders, a)
+	}
+
+	gw, err := NewGateway(bevmClient)
+	require.NoError(t, err)
+	defer gw.Close()
+	server := httptest.NewServer(gw)
+	defer server.Close()
+
+	rpcClient, err := rpc.DialHTTP(server.URL)
+	require.NoError(t, err)
+	defer rpcClient.Close()
+	ethClient := ethclient.NewClient(rpcClient)
+	ctx := context.Background()
+
+	errs := make(chan error, len(senders))
+	for _, a := range senders {
+		tx := types.NewTransaction(a.Nonce, to.Address, big.NewInt(1000),
+			txParams.GasLimit, big.NewInt(int64(txParams.GasPrice)), nil)
+		signedTx, err := types.SignTx(tx, types.NewEIP155Signer(getChainConfig().ChainID), a.PrivateKey)
+		require.NoError(t, err)
+		go func() {
+			errs <- ethClient.SendTransaction(ctx, signedTx)
+		}()
+	}
+	for range senders {
+		require.NoError(t, <-errs)
+	}
+
+	balance, err := ethClient.BalanceAt(ctx, to.Address, nil)
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(int64(1000*len(senders))), balance)
+}
+
+// Only the latest blocks are kept in the gateway index
+func Test_GatewayEvict(t *testing.T) {
+	log.LLvl1("Gateway eviction")
+
+	gw := &Gateway{txs: make(map[common.Hash]txLocation)}
+	var hashes []common.Hash
+	for i := 0; i < maxGatewayBlocks+10; i++ {
+		tx := types.NewTransaction(uint64(i), common.Address{}, big.NewInt(0),
+			txParams.GasLimit, big.NewInt(int64(txParams.GasPrice)), nil)
+		gw.txs[tx.Hash()] = txLocation{block: uint64(i)}
+		gw.blocks = append(gw.blocks, gatewayBlock{
+			txs: []*types.Transaction{tx},
+		})
+		hashes = append(hashes, tx.Hash())
+	}
+
+	gw.evict()
+	require.Equal(t, uint64(10), gw.first)
+	require.Equal(t, maxGatewayBlocks, len(gw.blocks))
+	require.Equal(t, maxGatewayBlocks, len(gw.txs))
+
+	_, _, _, found := gw.findTransaction(hashes[9])
+	require.False(t, found)
+	index, block, position, found := gw.findTransaction(hashes[10])
+	require.True(t, found)
+	require.Equal(t, uint64(10), index)
+	require.Equal(t, 0, position)
+	require.Equal(t, hashes[10], block.txs[0].Hash())
+
+	_, err := gw.getBlocks(9, 20)
+	require.Error(t, err)
+	blocks, err := gw.getBlocks(10, 20)
+	require.NoError(t, err)
+	require.Equal(t, 11, len(blocks))
+}
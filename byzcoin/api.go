@@ -149,8 +149,8 @@ func (c *Client) CreateTransaction(instrs ...Instruction) (ClientTransaction, er
 }
 
 // AddTransaction adds a transaction. It does not return any feedback
-// on the transaction. Use GetProof to find out if the transaction
-// was committed. The Client's Roster and ID should be initialized before
+// on the transaction. Use GetTxStatus or GetProof to find out if the
+// transaction was committed. The Client's Roster and ID should be initialized before
 // calling this method (see NewClientFromConfig).
 func (c *Client) AddTransaction(tx ClientTransaction) (*AddTxResponse, error) {
 	resp, err := c.AddTransactionAndWait(tx, 0)
@@ -209,6 +209,24 @@ func (c *Client) AddTransactionAndWait(tx ClientTransaction, wait int) (*AddTxRe
 	return reply, nil
 }
 
+// SimulateTransaction asks a node to execute the transaction against its
+// latest state, without adding it to the ledger. The signer counters are not
+// consumed, so the same transaction can be sent afterwards with
+// AddTransaction. A refused transaction is not an error: the reply has
+// Accepted set to false and holds the error.
+func (c *Client) SimulateTransaction(tx ClientTransaction) (*SimulateTxResponse, error) {
+	reply := &SimulateTxResponse{}
+	_, err := c.SendProtobufParallel(c.Roster.List, &SimulateTxRequest{
+		Version:     CurrentVersion,
+		SkipchainID: c.ID,
+		Transaction: tx,
+	}, reply, c.options)
+	if err != nil {
+		return nil, cothority.ErrorOrNil(err, "request failed")
+	}
+	return reply, nil
+}
+
 // GetProof returns a proof for the key stored in the skipchain starting from
 // the genesis block. The proof can prove the existence or the absence of the
 // key. Note that the integrity of the proof is verified.
@@ -318,6 +336,120 @@ func (c *Client) getProofRaw(key []byte, from, include *skipchain.SkipBlock) (*G
 	return reply, nil
 }
 
+// GetHistoricalProof returns a proof for the key as it was stored after the
+// block at the given index, starting from the genesis block. The proof is
+// verified and its latest block is the block at the given index. As the node
+// may have to replay the chain to build the proof, the request can be slow.
+func (c *Client) GetHistoricalProof(key []byte, index int) (*GetProofResponse, error) {
+	if c.Genesis == nil {
+		if err := c.fetchGenesis(); err != nil {
+			return nil, xerrors.Errorf("fetching genesis: %v", err)
+		}
+	}
+
+	decoder := func(buf []byte, msg interface{}) error {
+		err := protobuf.Decode(buf, msg)
+		if err != nil {
+			return xerrors.Errorf("decoding: %+v", err)
+		}
+
+		gpr, ok := msg.(*GetProofResponse)
+		if !ok {
+			return xerrors.New("couldn't cast msg")
+		}
+
+		if err := gpr.Proof.VerifyFromBlock(c.Genesis); err != nil {
+			return xerrors.Errorf("proof verification: %+v", err)
+		}
+
+		if gpr.Proof.Latest.Index != index {
+			return xerrors.New("proof is not for the requested block")
+		}
+
+		return nil
+	}
+
+	req := &GetHistoricalProof{
+		Version:    CurrentVersion,
+		Key:        key,
+		ID:         c.Genesis.Hash,
+		BlockIndex: index,
+	}
+
+	reply := &GetProofResponse{}
+	_, err := c.SendProtobufParallelWithDecoder(c.Roster.List, req, reply, c.options, decoder)
+	if err != nil {
+		return nil, xerrors.Errorf("sending: %+v", err)
+	}
+
+	return reply, nil
+}
+
+// GetMultiProof returns a proof for all the keys, starting from the genesis
+// block. The proof can prove the existence or the absence of each key. Note
+// that the integrity of the proof is verified, but the caller still needs to
+// check each key.
+func (c *Client) GetMultiProof(keys [][]byte) (*GetMultiProofResponse, error) {
+	req := &GetMultiProof{
+		Version: CurrentVersion,
+		Keys:    keys,
+	}
+	rep, err := c.getMultiProofRaw(req, &req.ID)
+	return rep, cothority.ErrorOrNil(err, "request failed")
+}
+
+// GetPrefixProof returns a proof for all the keys of which the path in the
+// trie starts with the prefix, starting from the genesis block. Use
+// MultiProof.Instances to get the instances that are proven. The prefix must
+// have at least two bytes, and the request fails if the proof is too big.
+func (c *Client) GetPrefixProof(prefix []byte) (*GetMultiProofResponse, error) {
+	req := &GetPrefixProof{
+		Version: CurrentVersion,
+		Prefix:  prefix,
+	}
+	rep, err := c.getMultiProofRaw(req, &req.ID)
+	return rep, cothority.ErrorOrNil(err, "request failed")
+}
+
+func (c *Client) getMultiProofRaw(req interface{}, id *skipchain.SkipBlockID) (*GetMultiProofResponse, error) {
+	if c.Genesis == nil {
+		if err := c.fetchGenesis(); err != nil {
+			return nil, xerrors.Errorf("fetching genesis: %v", err)
+		}
+	}
+	*id = c.Genesis.Hash
+
+	decoder := func(buf []byte, msg interface{}) error {
+		err := protobuf.Decode(buf, msg)
+		if err != nil {
+			return xerrors.Errorf("decoding: %+v", err)
+		}
+
+		gpr, ok := msg.(*GetMultiProofResponse)
+		if !ok {
+			return xerrors.New("couldn't cast msg")
+		}
+
+		if err := gpr.Proof.VerifyFromBlock(c.Genesis); err != nil {
+			return xerrors.Errorf("proof verification: %+v", err)
+		}
+
+		return nil
+	}
+
+	reply := &GetMultiProofResponse{}
+	_, err := c.SendProtobufParallelWithDecoder(c.Roster.List, req, reply, c.options, decoder)
+	if err != nil {
+		return nil, xerrors.Errorf("sending: %+v", err)
+	}
+
+	if c.Latest == nil || c.Latest.Index < reply.Proof.Latest.Index {
+		c.Latest = &reply.Proof.Latest
+	}
+
+	return reply, nil
+}
+
 // GetDeferredData makes a request to retrieve the deferred instruction data
 // and return the reply if the proof can be verified.
 func (c *Client) GetDeferredData(instrID InstanceID) (*DeferredData, error) {
@@ -505,8 +637,21 @@ func (c *Client) WaitProof(id InstanceID, interval time.Duration, value []byte)
 // It contacts any random node by default. A specific node can be chosen by
 // using `c.UseNode`.
 func (c *Client) StreamTransactions(handler func(StreamingResponse, error)) error {
+	return c.StreamFilteredTransactions(nil, nil, handler)
+}
+
+// StreamFilteredTransactions works like StreamTransactions, but the service
+// only sends the transactions and state changes matching the filter, which
+// can be nil to get the whole blocks. If resumeFrom is the ID of a block, the
+// blocks following it are sent first, which lets a client reconnect without
+// missing any block. The payload of the filtered blocks is removed, so only
+// the integrity of the block headers is verified.
+func (c *Client) StreamFilteredTransactions(filter *StreamingFilter, resumeFrom skipchain.SkipBlockID,
+	handler func(StreamingResponse, error)) error {
 	req := StreamingRequest{
-		ID: c.ID,
+		ID:         c.ID,
+		Filter:     filter,
+		ResumeFrom: resumeFrom,
 	}
 	n := int(rand.Int31n(int32(len(c.Roster.List))))
 	if c.options != nil {
@@ -589,6 +734,68 @@ func (c *Client) GetSignerCounters(ids ...string) (*GetSignerCountersResponse, e
 	return &reply, cothority.ErrorOrNil(err, "request failed")
 }
 
+// GetTxStatus asks every node of the roster what happened to the transaction
+// with the given hash, as returned by ClientTransaction.Instructions.Hash,
+// and returns the most advanced status. A transaction is only known by the
+// nodes it has been sent to until it is in a block.
+func (c *Client) GetTxStatus(txHash []byte) (*GetTxStatusResponse, error) {
+	req := &GetTxStatus{
+		Version:     CurrentVersion,
+		SkipchainID: c.ID,
+		TxHash:      txHash,
+	}
+
+	var best *GetTxStatusResponse
+	lastErr := xerrors.New("empty roster")
+	for _, si := range c.Roster.List {
+		reply := &GetTxStatusResponse{}
+		err := c.SendProtobuf(si, req, reply)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if best == nil || reply.Status > best.Status {
+			best = reply
+		}
+	}
+	if best == nil {
+		return nil, cothority.ErrorOrNil(lastErr, "request failed")
+	}
+	return best, nil
+}
+
+// GetTxReceipt returns the transaction with the given hash, as returned by
+// ClientTransaction.Instructions.Hash, with its receipt. The block holding
+// the transaction is verified to be part of the skipchain, so any node can
+// answer. The Client's Roster and ID should be initialized before calling
+// this method (see NewClientFromConfig).
+func (c *Client) GetTxReceipt(txHash []byte) (*TxResult, error) {
+	if c.Genesis == nil {
+		if err := c.fetchGenesis(); err != nil {
+			return nil, xerrors.Errorf("fetching genesis block: %v", err)
+		}
+	}
+
+	reply := &GetTxReceiptResponse{}
+	_, err := c.SendProtobufParallel(c.Roster.List, &GetTxReceipt{
+		Version:     CurrentVersion,
+		SkipchainID: c.ID,
+		TxHash:      txHash,
+	}, reply, c.options)
+	if err != nil {
+		return nil, cothority.ErrorOrNil(err, "request failed")
+	}
+
+	if err := reply.Proof.VerifyFromBlock(c.Genesis); err != nil {
+		return nil, xerrors.Errorf("proof verification: %v", err)
+	}
+	txr, err := reply.Proof.TxResult(txHash)
+	if err != nil {
+		return nil, xerrors.Errorf("reading proof: %v", err)
+	}
+	return txr, nil
+}
+
 // DownloadState is used by a new node to ask to download the global state.
 // The first call to DownloadState needs to have start = 0, so that the
 // service creates a snapshot of the current state which it will serve over
@@ -653,6 +860,27 @@ func (c *Client) ResolveInstanceID(darcID darc.ID, name string) (InstanceID, err
 	return reply.InstanceID, cothority.ErrorOrNil(err, "request failed")
 }
 
+// GetInstances returns one page of the instances of the ledger that match
+// the given contract ID and darc ID. An empty contractID or a nil darcID
+// matches all instances. The cursor must be nil for the first page and then
+// set to the NextCursor of the previous reply, until it is empty.
+func (c *Client) GetInstances(contractID string, darcID darc.ID, cursor []byte, pageSize int) (*GetInstancesResponse, error) {
+	req := GetInstances{
+		SkipChainID: c.ID,
+		ContractID:  contractID,
+		DarcID:      darcID,
+		Cursor:      cursor,
+		PageSize:    pageSize,
+	}
+	reply := &GetInstancesResponse{}
+
+	_, err := c.SendProtobufParallel(c.Roster.List, &req, reply, c.options)
+	if err != nil {
+		return nil, cothority.ErrorOrNil(err, "request failed")
+	}
+	return reply, nil
+}
+
 // WaitPropagation contacts all nodes in the cl.Roster until they all
 // have the same latest block. If there is an error when calling
 // `GetProof`, the error will be ignored. This helps when waiting
@@ -740,6 +968,24 @@ func DebugRemove(si *network.ServerIdentity, byzcoinID skipchain.SkipBlockID) er
 	return cothority.ErrorOrNil(err, "request failed")
 }
 
+// Compact removes the unreachable nodes of the state trie of the
+// byzcoin-instance and, if keepBlocks is not 0, prunes the bodies of all the
+// blocks but the genesis block and the last keepBlocks blocks.
+func Compact(si *network.ServerIdentity, byzcoinID skipchain.SkipBlockID, keepBlocks int) (*CompactResponse, error) {
+	sig, err := schnorr.Sign(cothority.Suite, si.GetPrivate(), byzcoinID)
+	if err != nil {
+		return nil, xerrors.Errorf("sign error: %v", err)
+	}
+	request := &CompactRequest{
+		ByzCoinID:  byzcoinID,
+		KeepBlocks: keepBlocks,
+		Signature:  sig,
+	}
+	reply := &CompactResponse{}
+	err = onet.NewClient(cothority.Suite, ServiceName).SendProtobuf(si, request, reply)
+	return reply, cothority.ErrorOrNil(err, "request failed")
+}
+
 // DefaultGenesisMsg creates the message that is used to for creating the
 // genesis Darc and block. It will contain rules for spawning and evolving the
 // darc contract.
@@ -364,11 +364,12 @@ func newTestService(c *onet.Context) (onet.Service, error) {
 	s := &Service{
 		ServiceProcessor:       onet.NewServiceProcessor(c),
 		contracts:              newContractRegistry(),
-		txBuffer:               newTxBuffer(),
+		txPool:                 newTxPool(),
 		storage:                &bcStorage{},
 		darcToSc:               make(map[string]skipchain.SkipBlockID),
 		stateChangeCache:       newStateChangeCache(),
 		stateChangeStorage:     newStateChangeStorage(c),
+		txIndex:                newTxIndex(c),
 		heartbeatsTimeout:      make(chan string, 1),
 		closeLeaderMonitorChan: make(chan bool, 1),
 		heartbeats:             newHeartbeats(),
@@ -0,0 +1,212 @@
+package byzcoin
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"net/url"
+	"strconv"
+
+	"go.dedis.ch/cothority/v3/darc"
+	"golang.org/x/xerrors"
+)
+
+// The built-in attributes can be used in the darc expressions of every
+// contract. They only depend on the state of the chain, so all the nodes
+// agree on the result:
+//
+//	attr:block:after=N&before=M
+//	attr:time:after=T&before=U
+//	attr:spawned:blocks=K
+//
+// The first one checks that the index of the latest block is strictly between
+// N and M, the second one that the timestamp of the latest block, in seconds
+// since the epoch, is strictly between T and U. The bounds are optional. The
+// last one checks that the darc of the instance has been spawned in one of
+// the last K blocks, so a darc spawned in the current block doesn't match.
+// The index of the block of the spawn is stored in the trie from
+// VersionSpawnIndex on, so the darcs spawned before don't match.
+const (
+	// AttrBlock is the name of the block index attribute.
+	AttrBlock = "block"
+	// AttrTime is the name of the block timestamp attribute.
+	AttrTime = "time"
+	// AttrSpawned is the name of the attribute restricting a darc to the
+	// blocks after its spawn.
+	AttrSpawned = "spawned"
+)
+
+// BuiltinAttrInterpreters returns the interpreters of the built-in
+// attributes for the instruction.
+func BuiltinAttrInterpreters(rst ReadOnlyStateTrie, inst Instruction) darc.AttrInterpreters {
+	return darc.AttrInterpreters{
+		AttrBlock: func(attr string) error {
+			return evalBlockAttr(rst, attr)
+		},
+		AttrTime: func(attr string) error {
+			return evalTimeAttr(rst, attr)
+		},
+		AttrSpawned: func(attr string) error {
+			return evalSpawnedAttr(rst, inst, attr)
+		},
+	}
+}
+
+// parseInterval returns the values of "after" and "before" in the query, or
+// the defaults if they are missing.
+func parseInterval(attr string, after, before int64) (int64, int64, error) {
+	vals, err := url.ParseQuery(attr)
+	if err != nil {
+		return 0, 0, xerrors.Errorf("parsing query: %v", err)
+	}
+	if str := vals.Get("after"); len(str) > 0 {
+		after, err = strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			return 0, 0, xerrors.Errorf("parsing after: %v", err)
+		}
+	}
+	if str := vals.Get("before"); len(str) > 0 {
+		before, err = strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			return 0, 0, xerrors.Errorf("parsing before: %v", err)
+		}
+	}
+	return after, before, nil
+}
+
+func evalBlockAttr(rst ReadOnlyStateTrie, attr string) error {
+	index := int64(rst.GetIndex())
+	// Without bounds, the interval always contains the current index.
+	after, before, err := parseInterval(attr, -1, index+1)
+	if err != nil {
+		return err
+	}
+	if after < index && index < before {
+		return nil
+	}
+	return xerrors.Errorf("the current block index is %d which does not fit in the interval (%d, %d)", index, after, before)
+}
+
+func evalTimeAttr(rst ReadOnlyStateTrie, attr string) error {
+	header, err := latestHeader(rst)
+	if err != nil {
+		return err
+	}
+	ts := header.Timestamp / 1e9
+	after, before, err := parseInterval(attr, ts-1, ts+1)
+	if err != nil {
+		return err
+	}
+	if after < ts && ts < before {
+		return nil
+	}
+	return xerrors.Errorf("the current block timestamp is %d which does not fit in the interval (%d, %d)", ts, after, before)
+}
+
+func evalSpawnedAttr(rst ReadOnlyStateTrie, inst Instruction, attr string) error {
+	vals, err := url.ParseQuery(attr)
+	if err != nil {
+		return xerrors.Errorf("parsing query: %v", err)
+	}
+	blocks, err := strconv.Atoi(vals.Get("blocks"))
+	if err != nil {
+		return xerrors.Errorf("parsing blocks: %v", err)
+	}
+	if blocks <= 0 {
+		return xerrors.New("blocks must be bigger than 0")
+	}
+
+	config, err := LoadConfigFromTrie(rst)
+	if err != nil {
+		return xerrors.Errorf("reading config: %v", err)
+	}
+	d, err := getInstanceDarc(rst, inst.InstanceID, config.DarcContractIDs)
+	if err != nil {
+		return xerrors.Errorf("getting darc: %v", err)
+	}
+	buf, _, _, _, err := rst.GetValues(spawnIndexKey(NewInstanceID(d.GetBaseID())))
+	if err != nil {
+		return xerrors.Errorf("the block of the spawn of the darc is unknown: %v", err)
+	}
+	index := int(binary.LittleEndian.Uint64(buf))
+	if index <= rst.GetIndex() && index > rst.GetIndex()-blocks {
+		return nil
+	}
+	return xerrors.Errorf("the darc has not been spawned in the last %d blocks", blocks)
+}
+
+// spawnIndexKey returns the key of the trie holding the index of the block in
+// which the darc instance has been spawned.
+func spawnIndexKey(id InstanceID) []byte {
+	h := sha256.New()
+	h.Write([]byte("spawnindex_"))
+	h.Write(id.Slice())
+	return h.Sum(nil)
+}
+
+// spawnIndexStateChanges returns the state changes storing the index of the
+// block being built for the darc instances created by scs. Nothing is stored
+// before VersionSpawnIndex, so that the older blocks keep their trie root.
+func spawnIndexStateChanges(rst ReadOnlyStateTrie, instr Instruction, scs StateChanges) (StateChanges, error) {
+	if instr.version < VersionSpawnIndex {
+		return nil, nil
+	}
+
+	var darcIDs map[string]bool
+	var out StateChanges
+	for _, sc := range scs {
+		if sc.StateAction != Create {
+			continue
+		}
+		if darcIDs == nil {
+			// The state changes are already stored, so the config
+			// exists even in the genesis block.
+			config, err := LoadConfigFromTrie(rst)
+			if err != nil {
+				return nil, xerrors.Errorf("reading config: %v", err)
+			}
+			darcIDs = make(map[string]bool)
+			for _, id := range config.DarcContractIDs {
+				darcIDs[id] = true
+			}
+		}
+		if !darcIDs[sc.ContractID] {
+			continue
+		}
+
+		key := spawnIndexKey(NewInstanceID(sc.InstanceID))
+		action := Create
+		_, ver, _, _, err := rst.GetValues(key)
+		if err == nil {
+			// The darc has been removed and spawned again.
+			action = Update
+			ver++
+		} else if !xerrors.Is(err, errKeyNotSet) {
+			return nil, xerrors.Errorf("reading trie: %v", err)
+		}
+		indexBuf := make([]byte, 8)
+		binary.LittleEndian.PutUint64(indexBuf, uint64(rst.GetIndex()+1))
+		out = append(out, StateChange{
+			StateAction: action,
+			InstanceID:  key,
+			ContractID:  "",
+			Value:       indexBuf,
+			Version:     ver,
+			DarcID:      darc.ID([]byte{}),
+		})
+	}
+	return out, nil
+}
+
+// latestHeader returns the header of the latest block that has been applied to
+// the trie.
+func latestHeader(rst ReadOnlyStateTrie) (*DataHeader, error) {
+	sc, ok := rst.(ReadOnlySkipChain)
+	if !ok {
+		return nil, xerrors.New("the skipchain is not available")
+	}
+	sb, err := sc.GetBlockByIndex(rst.GetIndex())
+	if err != nil {
+		return nil, xerrors.Errorf("getting block: %v", err)
+	}
+	return decodeBlockHeader(sb)
+}
@@ -0,0 +1,64 @@
+package byzcoin
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3/darc"
+)
+
+func TestBuiltinAttrInterpreters(t *testing.T) {
+	s := newSer(t, 1, testInterval)
+	defer s.local.CloseAll()
+
+	// Spawn a new darc in block 2.
+	d := darc.NewDarc(darc.InitRules([]darc.Identity{s.signer.Identity()},
+		[]darc.Identity{s.signer.Identity()}), []byte("attributes"))
+	buf, err := d.ToProto()
+	require.NoError(t, err)
+	instr := createSpawnInstr(s.darc.GetBaseID(), ContractDarcID, "darc", buf)
+	instr.SignerCounter = []uint64{2}
+	ctx, err := combineInstrsAndSign(s.signer, instr)
+	require.NoError(t, err)
+	s.sendTxAndWait(t, ctx, 10)
+
+	scID := s.genesis.SkipChainID()
+	st, err := s.service().getStateTrie(scID)
+	require.NoError(t, err)
+	require.Equal(t, 2, st.GetIndex())
+	gs := globalState{st.MakeStagingStateTrie(), newROSkipChain(s.service().skService(), scID)}
+
+	genesisAttrs := BuiltinAttrInterpreters(gs, Instruction{InstanceID: NewInstanceID(s.darc.GetBaseID())})
+	attrs := BuiltinAttrInterpreters(gs, Instruction{InstanceID: NewInstanceID(d.GetBaseID())})
+
+	require.NoError(t, attrs[AttrBlock](""))
+	require.NoError(t, attrs[AttrBlock]("after=1&before=3"))
+	require.Error(t, attrs[AttrBlock]("after=2"))
+	require.Error(t, attrs[AttrBlock]("before=2"))
+	require.Error(t, attrs[AttrBlock]("after=abc"))
+
+	header, err := latestHeader(gs)
+	require.NoError(t, err)
+	ts := header.Timestamp / 1e9
+	require.NoError(t, attrs[AttrTime](""))
+	require.NoError(t, attrs[AttrTime](fmt.Sprintf("after=%d&before=%d", ts-10, ts+10)))
+	require.Error(t, attrs[AttrTime](fmt.Sprintf("before=%d", ts)))
+	require.Error(t, attrs[AttrTime](fmt.Sprintf("after=%d", ts)))
+
+	// The new darc is in the latest block, the genesis darc is in block 0.
+	require.NoError(t, attrs[AttrSpawned]("blocks=1"))
+	require.Error(t, genesisAttrs[AttrSpawned]("blocks=2"))
+	require.NoError(t, genesisAttrs[AttrSpawned]("blocks=3"))
+	require.Error(t, attrs[AttrSpawned]("blocks=0"))
+	require.Error(t, attrs[AttrSpawned](""))
+
+	require.NoError(t, genesisAttrs[AttrSpawned]("blocks=100000"))
+
+	// Without the skipchain, only the time attribute fails, as the others
+	// only depend on the trie.
+	noChain := BuiltinAttrInterpreters(st, Instruction{InstanceID: NewInstanceID(d.GetBaseID())})
+	require.NoError(t, noChain[AttrBlock](""))
+	require.Error(t, noChain[AttrTime](""))
+	require.NoError(t, noChain[AttrSpawned]("blocks=1"))
+}
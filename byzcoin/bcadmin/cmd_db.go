@@ -452,6 +452,29 @@ func dbCheck(c *cli.Context) error {
 	return nil
 }
 
+// dbCompact removes the unreachable nodes of the state trie and prunes the
+// old block bodies. The space is freed inside the db-file, but the file itself
+// doesn't shrink.
+func dbCompact(c *cli.Context) error {
+	fb, err := newFetchBlocks(c)
+	if err != nil {
+		return xerrors.Errorf("couldn't create fetchBlock: %+v", err)
+	}
+
+	bucket := []byte(fmt.Sprintf("%s_%x", byzcoin.ServiceName, *fb.bcID))
+	resp, err := byzcoin.CompactDB(fb.boltDB, bucket, fb.db, *fb.bcID,
+		c.Int("keep"))
+	if err != nil {
+		return xerrors.Errorf("couldn't compact db: %+v", err)
+	}
+	log.Infof("Removed %d trie nodes: %d bytes", resp.TrieNodes,
+		resp.TrieBytes)
+	log.Infof("Pruned %d block bodies: %d bytes", resp.PrunedBlocks,
+		resp.PrunedBytes)
+	log.Infof("Total reclaimed: %d bytes", resp.TrieBytes+resp.PrunedBytes)
+	return nil
+}
+
 // fetchBlocks is used by all db-related bcadmin commands.
 type fetchBlocks struct {
 	cl               *skipchain.Client
@@ -33,6 +33,22 @@ var cmds = cli.Commands{
 				Name:  "blockSize",
 				Usage: "adjust the maximum block size",
 			},
+			cli.StringFlag{
+				Name:  "txFee",
+				Usage: "the number of coins every instruction must pay, 0 removes the fees",
+			},
+			cli.StringFlag{
+				Name:  "feeAccount",
+				Usage: "the coin instance receiving the fees, in hex (the fees are burnt by default)",
+			},
+			cli.StringFlag{
+				Name:  "rateLimit",
+				Usage: "the maximum number of transactions per identity and per node, as N/duration (e.g. 10/1m), 0 removes the limit",
+			},
+			cli.StringFlag{
+				Name:  "leaderRotation",
+				Usage: "rotate the leader after a number of blocks and/or a duration, as N, duration or N/duration (e.g. 100/1h), 0 keeps the leader fixed",
+			},
 		},
 	},
 
@@ -577,6 +593,14 @@ var cmds = cli.Commands{
 						Name:  "minimum, M",
 						Usage: "if this flag is set, the rule is computed to be \"M out of N\" identities. Otherwise it uses ANDs",
 					},
+					cli.UintFlag{
+						Name:  "threshold, t",
+						Usage: "if this flag is set, the rule is a threshold expression that needs at least t of the identities",
+					},
+					cli.IntSliceFlag{
+						Name:  "weight",
+						Usage: "the weight of each identity in the threshold expression, in the same order as the identities (optional)",
+					},
 				},
 			},
 			{
@@ -609,6 +633,14 @@ var cmds = cli.Commands{
 						Name:  "minimum, M",
 						Usage: "if this flag is set, the rule is computed to be \"M out of N\" identities. Otherwise it uses ANDs",
 					},
+					cli.UintFlag{
+						Name:  "threshold, t",
+						Usage: "if this flag is set, the rule is a threshold expression that needs at least t of the identities",
+					},
+					cli.IntSliceFlag{
+						Name:  "weight",
+						Usage: "the weight of each identity in the threshold expression, in the same order as the identities (optional)",
+					},
 					cli.BoolFlag{
 						Name:  "replace",
 						Usage: "if this rule already exists, replace it with this new one",
@@ -623,6 +655,31 @@ var cmds = cli.Commands{
 					},
 				},
 			},
+			{
+				Name:   "analyze",
+				Usage:  "Show who can use the rules of a DARC, or which permissions changed since a given block",
+				Action: darcAnalyze,
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:   "bc",
+						EnvVar: "BC",
+						Usage:  "the ByzCoin config to use (required)",
+					},
+					cli.StringFlag{
+						Name:  "darc",
+						Usage: "the darc to analyze (admin darc by default)",
+					},
+					cli.StringFlag{
+						Name:  "rule",
+						Usage: "only analyze this rule (all the rules by default)",
+					},
+					cli.IntFlag{
+						Name:  "since",
+						Value: -1,
+						Usage: "show the permissions gained and lost since the darc as it was after the block with this index",
+					},
+				},
+			},
 		},
 	},
 
@@ -720,6 +777,19 @@ var cmds = cli.Commands{
 					},
 				},
 			},
+			{
+				Name: "compact",
+				Usage: "Remove unreachable nodes of the state trie and" +
+					" optionally prune old block bodies",
+				Action: dbCompact,
+				Flags: []cli.Flag{
+					cli.IntFlag{
+						Name: "keep",
+						Usage: "prune the bodies of all blocks but the" +
+							" genesis and the last n blocks - 0 keeps all",
+					},
+				},
+			},
 		},
 	},
 
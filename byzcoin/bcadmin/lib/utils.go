@@ -75,6 +75,46 @@ func GetDarcByID(cl *byzcoin.Client, id []byte) (*darc.Darc, error) {
 	return d, nil
 }
 
+// GetDarcAt returns a DARC given its ID as it was after the block at the
+// given index.
+func GetDarcAt(cl *byzcoin.Client, id []byte, index int) (*darc.Darc, error) {
+	pr, err := cl.GetHistoricalProof(id, index)
+	if err != nil {
+		return nil, err
+	}
+
+	vs, cid, _, err := pr.Proof.Get(id)
+	if err != nil {
+		return nil, xerrors.Errorf("could not find darc for %x at block %d", id, index)
+	}
+	if cid != byzcoin.ContractDarcID {
+		return nil, xerrors.Errorf("unexpected contract %v, expected a darc", cid)
+	}
+
+	return darc.NewFromProtobuf(vs)
+}
+
+// DarcGetter returns a GetDarc callback that fetches the latest version of
+// the DARCs from ByzCoin. Older versions are not supported, and the DARCs that
+// cannot be fetched are reported as nil.
+func DarcGetter(cl *byzcoin.Client) darc.GetDarc {
+	cache := make(map[string]*darc.Darc)
+	return func(s string, latest bool) *darc.Darc {
+		if !latest {
+			return nil
+		}
+		if d, ok := cache[s]; ok {
+			return d
+		}
+		d, err := GetDarcByString(cl, s)
+		if err != nil {
+			d = nil
+		}
+		cache[s] = d
+		return d
+	}
+}
+
 // ExportTransaction will redirect the transaction to stdout. It must be made
 // sure that no other print is done, else the stdout is not usable.
 func ExportTransaction(tx byzcoin.ClientTransaction) error {
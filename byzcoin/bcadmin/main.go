@@ -575,6 +575,72 @@ func config(c *cli.Context) error {
 		}
 		chainConfig.MaxBlockSize = blockSize
 	}
+	if txFee := c.String("txFee"); txFee != "" {
+		fee, err := strconv.ParseUint(txFee, 10, 64)
+		if err != nil {
+			return xerrors.Errorf("couldn't parse txFee: %v", err)
+		}
+		if fee == 0 {
+			chainConfig.TxFees = nil
+		} else {
+			chainConfig.TxFees = &byzcoin.TxFees{
+				Coin:        contracts.CoinName,
+				Instruction: fee,
+			}
+		}
+	}
+	if feeAccount := c.String("feeAccount"); feeAccount != "" {
+		if chainConfig.TxFees == nil {
+			return xerrors.New("--feeAccount needs fees")
+		}
+		buf, err := hex.DecodeString(feeAccount)
+		if err != nil {
+			return xerrors.Errorf("couldn't parse feeAccount: %v", err)
+		}
+		account := byzcoin.NewInstanceID(buf)
+		chainConfig.TxFees.Account = &account
+	}
+	if rateLimit := c.String("rateLimit"); rateLimit != "" {
+		if rateLimit == "0" {
+			chainConfig.RateLimit = nil
+		} else {
+			parts := strings.SplitN(rateLimit, "/", 2)
+			if len(parts) != 2 {
+				return xerrors.New("rateLimit must be N/duration")
+			}
+			txs, err := strconv.Atoi(parts[0])
+			if err != nil {
+				return xerrors.Errorf("couldn't parse rateLimit: %v", err)
+			}
+			window, err := time.ParseDuration(parts[1])
+			if err != nil {
+				return xerrors.Errorf("couldn't parse rateLimit: %v", err)
+			}
+			chainConfig.RateLimit = &byzcoin.RateLimit{
+				Transactions: txs,
+				Window:       window,
+			}
+		}
+	}
+
+	if rotation := c.String("leaderRotation"); rotation != "" {
+		if rotation == "0" {
+			chainConfig.LeaderRotation = nil
+		} else {
+			chainConfig.LeaderRotation = &byzcoin.LeaderRotation{}
+			for _, part := range strings.SplitN(rotation, "/", 2) {
+				if blocks, err := strconv.Atoi(part); err == nil {
+					chainConfig.LeaderRotation.Blocks = blocks
+					continue
+				}
+				interval, err := time.ParseDuration(part)
+				if err != nil {
+					return xerrors.Errorf("couldn't parse leaderRotation: %v", err)
+				}
+				chainConfig.LeaderRotation.Interval = interval
+			}
+		}
+	}
 
 	err = updateConfig(cl, signer, chainConfig)
 	if err != nil {
@@ -1497,13 +1563,9 @@ func darcRule(c *cli.Context) error {
 		}
 	}
 
-	var groupExpr expression.Expr
-	min := c.Uint("minimum")
-	if min == 0 {
-		groupExpr = expression.InitAndExpr(identities...)
-	} else {
-		andGroups := lib.CombinationAnds(identities, int(min))
-		groupExpr = expression.InitOrExpr(andGroups...)
+	groupExpr, err := ruleExpr(c, identities)
+	if err != nil {
+		return err
 	}
 
 	d2 := d.Copy()
@@ -1573,6 +1635,65 @@ func darcRule(c *cli.Context) error {
 	return lib.WaitPropagation(c, cl)
 }
 
+// ruleExpr combines the identities with ANDs, or in a "M out of N" rule if
+// --minimum is given, or in a threshold expression if --threshold is given.
+func ruleExpr(c *cli.Context, identities []string) (expression.Expr, error) {
+	min := c.Uint("minimum")
+	threshold := c.Uint("threshold")
+	weights := c.IntSlice("weight")
+
+	if min != 0 && threshold != 0 {
+		return nil, xerrors.New("--minimum and --threshold cannot be used together")
+	}
+	if len(weights) > 0 && threshold == 0 {
+		return nil, xerrors.New("--weight needs --threshold")
+	}
+
+	switch {
+	case threshold != 0 && len(weights) > 0:
+		if len(weights) != len(identities) {
+			return nil, xerrors.Errorf("got %d weights for %d identities",
+				len(weights), len(identities))
+		}
+		wids := make([]expression.WeightedID, len(identities))
+		for i, id := range identities {
+			if weights[i] <= 0 {
+				return nil, xerrors.New("weights must be positive")
+			}
+			wids[i] = expression.WeightedID{ID: ruleGroup(id), Weight: weights[i]}
+		}
+		return checkRuleExpr(expression.InitWeightedExpr(int(threshold), wids...))
+	case threshold != 0:
+		groups := make([]string, len(identities))
+		for i, id := range identities {
+			groups[i] = ruleGroup(id)
+		}
+		return checkRuleExpr(expression.InitThresholdExpr(int(threshold), groups...))
+	case min != 0:
+		andGroups := lib.CombinationAnds(identities, int(min))
+		return expression.InitOrExpr(andGroups...), nil
+	default:
+		return expression.InitAndExpr(identities...), nil
+	}
+}
+
+// ruleGroup puts the identity between parentheses if it is an expression.
+func ruleGroup(id string) string {
+	if strings.ContainsAny(id, " &|") {
+		return "(" + expression.ThresholdFactor(id) + ")"
+	}
+	return id
+}
+
+// checkRuleExpr makes sure the threshold can be reached.
+func checkRuleExpr(expr expression.Expr) (expression.Expr, error) {
+	Y := expression.InitParser(func(s string) bool { return true })
+	if _, err := expression.Evaluate(Y, expr); err != nil {
+		return nil, xerrors.Errorf("invalid threshold: %v", err)
+	}
+	return expr, nil
+}
+
 // print a rule based on the identities and the minimum given.
 func darcPrintRule(c *cli.Context) error {
 
@@ -1594,13 +1715,9 @@ func darcPrintRule(c *cli.Context) error {
 		}
 	}
 
-	var groupExpr expression.Expr
-	min := c.Uint("minimum")
-	if min == 0 {
-		groupExpr = expression.InitAndExpr(identities...)
-	} else {
-		andGroups := lib.CombinationAnds(identities, int(min))
-		groupExpr = expression.InitOrExpr(andGroups...)
+	groupExpr, err := ruleExpr(c, identities)
+	if err != nil {
+		return err
 	}
 
 	log.Infof("%s\n", groupExpr)
@@ -1608,6 +1725,76 @@ func darcPrintRule(c *cli.Context) error {
 	return nil
 }
 
+// darcAnalyze prints the minimal sets of identities that can use the rules
+// of a darc, or the permissions that changed since an earlier block.
+func darcAnalyze(c *cli.Context) error {
+	bcArg := c.String("bc")
+	if bcArg == "" {
+		return xerrors.New("--bc flag is required")
+	}
+
+	cfg, cl, err := lib.LoadConfig(bcArg)
+	if err != nil {
+		return err
+	}
+
+	dstr := c.String("darc")
+	if dstr == "" {
+		dstr = cfg.AdminDarc.GetIdentityString()
+	}
+	d, err := lib.GetDarcByString(cl, dstr)
+	if err != nil {
+		return err
+	}
+	getDarc := lib.DarcGetter(cl)
+
+	if since := c.Int("since"); since >= 0 {
+		old, err := lib.GetDarcAt(cl, d.GetBaseID(), since)
+		if err != nil {
+			return err
+		}
+		changes, err := darc.DiffDarcs(old, d, getDarc)
+		if err != nil {
+			return err
+		}
+		if len(changes) == 0 {
+			log.Infof("No permission changed since version %d", old.Version)
+			return nil
+		}
+		for _, change := range changes {
+			if rule := c.String("rule"); rule != "" && darc.Action(rule) != change.Action {
+				continue
+			}
+			log.Infof("%s:", change.Action)
+			for _, set := range change.Gained {
+				log.Infof("+ %s", strings.Join(set, " & "))
+			}
+			for _, set := range change.Lost {
+				log.Infof("- %s", strings.Join(set, " & "))
+			}
+		}
+		return nil
+	}
+
+	var analyses []darc.RuleAnalysis
+	if rule := c.String("rule"); rule != "" {
+		ra, err := darc.AnalyzeRule(d, darc.Action(rule), getDarc)
+		if err != nil {
+			return err
+		}
+		analyses = append(analyses, *ra)
+	} else {
+		analyses, err = darc.AnalyzeRules(d, getDarc)
+		if err != nil {
+			return err
+		}
+	}
+	for _, ra := range analyses {
+		log.Info(ra.String())
+	}
+	return nil
+}
+
 func qrcode(c *cli.Context) error {
 	type pair struct {
 		Priv string
@@ -384,6 +384,20 @@ testDarcAddRuleMinimum(){
   testOK runBA darc rule -rule test:contract --darc "$ID" -sign "$KEY" -id 'darc:A & ed25519:aef' -id darc:B -id darc:C -id darc:D --minimum 2 -replace
   testFGrep "test:contract - \"((darc:A & ed25519:aef) & (darc:B)) | ((darc:A & ed25519:aef) & (darc:C)) | ((darc:A & ed25519:aef) & (darc:D)) | ((darc:B) & (darc:C)) | ((darc:B) & (darc:D)) | ((darc:C) & (darc:D))\"" runBA darc show --darc "$ID"
 
+  # with a threshold
+  testOK runBA darc rule -rule test:contract --darc "$ID" -sign "$KEY" -id 'darc:A & ed25519:aef' -id darc:B -id darc:C --threshold 2 -replace
+  testFGrep "test:contract - \"[(darc:A & ed25519:aef), darc:B, darc:C]/2\"" runBA darc show --darc "$ID"
+  # the attr identities must not swallow the separators
+  testOK runBA darc rule -rule test:contract --darc "$ID" -sign "$KEY" -id 'darc:A & attr:a:x' -id attr:a:y -id darc:C --threshold 2 -replace
+  testFGrep "test:contract - \"[(darc:A & attr:a:x ), attr:a:y , darc:C]/2\"" runBA darc show --darc "$ID"
+
+  # with a weighted threshold
+  testOK runBA darc rule -rule test:contract --darc "$ID" -sign "$KEY" -id darc:A -id darc:B -id darc:C --threshold 3 --weight 2 --weight 1 --weight 1 -replace
+  testFGrep "test:contract - \"[2*darc:A, 1*darc:B, 1*darc:C]/3\"" runBA darc show --darc "$ID"
+  testFail runBA darc rule -rule test:contract --darc "$ID" -sign "$KEY" -id darc:A -id darc:B --threshold 3 -replace
+  testFail runBA darc rule -rule test:contract --darc "$ID" -sign "$KEY" -id darc:A -id darc:B --threshold 1 --weight 2 -replace
+  testFail runBA darc rule -rule test:contract --darc "$ID" -sign "$KEY" -id darc:A -id darc:B --threshold 1 --minimum 1 -replace
+
   # with some wrong identities
   testFail runBA darc rule -rule test:contract --darc "$ID" -sign "$KEY" -id 'xdarc:A & ed25519:aef' -id darc:B --minimum 2 -replace
   testFail runBA darc rule -rule test:contract --darc "$ID" -sign "$KEY" -id 'xdarc:A & ed25519:aef' -id darc:B -replace
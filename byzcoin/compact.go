@@ -0,0 +1,93 @@
+package byzcoin
+
+import (
+	"fmt"
+
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/byzcoin/trie"
+	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/kyber/v3/sign/schnorr"
+	"go.dedis.ch/onet/v3/log"
+	"go.etcd.io/bbolt"
+	"golang.org/x/xerrors"
+)
+
+// Compact removes the nodes of the state trie that are not reachable anymore
+// and, if req.KeepBlocks is not 0, prunes the bodies of all the blocks except
+// the genesis block and the last req.KeepBlocks blocks.
+// The request needs to be signed by the private key of the conode.
+func (s *Service) Compact(req *CompactRequest) (*CompactResponse, error) {
+	if err := schnorr.Verify(cothority.Suite, s.ServerIdentity().Public, req.ByzCoinID, req.Signature); err != nil {
+		log.Error("Signature failure:", err)
+		return nil, xerrors.Errorf("verifying signature: %v", err)
+	}
+	if !s.hasByzCoinVerification(req.ByzCoinID) {
+		return nil, xerrors.New("unknown byzcoin instance")
+	}
+
+	idStr := fmt.Sprintf("%x", req.ByzCoinID)
+	db, bucket := s.GetAdditionalBucket([]byte(idStr))
+	resp, err := CompactDB(db, bucket, s.db(), req.ByzCoinID, req.KeepBlocks)
+	if err != nil {
+		return nil, xerrors.Errorf("compacting: %v", err)
+	}
+	log.Lvlf2("%s: compacted %x: removed %d trie nodes (%d bytes) and "+
+		"%d block bodies (%d bytes)", s.ServerIdentity(), req.ByzCoinID,
+		resp.TrieNodes, resp.TrieBytes, resp.PrunedBlocks, resp.PrunedBytes)
+	return resp, nil
+}
+
+// CompactDB removes the unreachable nodes of the state trie stored in the
+// bucket of db. If keepBlocks is not 0, the bodies of the blocks of the chain
+// are removed from sbDB, except for the genesis block and the last keepBlocks
+// blocks. The headers and the forward links are kept, so the chain can still
+// be verified, but the transactions of the pruned blocks are lost.
+func CompactDB(db *bbolt.DB, bucket []byte, sbDB *skipchain.SkipBlockDB,
+	scID skipchain.SkipBlockID, keepBlocks int) (*CompactResponse, error) {
+	if keepBlocks < 0 {
+		return nil, xerrors.New("negative number of blocks to keep")
+	}
+	// The other nodes need the bodies of the latest blocks to catch up.
+	if keepBlocks > 0 && keepBlocks < catchupDownloadAll {
+		return nil, xerrors.Errorf("need to keep at least %d blocks",
+			catchupDownloadAll)
+	}
+
+	t, err := trie.LoadTrie(trie.NewDiskDB(db, bucket))
+	if err != nil {
+		return nil, xerrors.Errorf("loading trie: %v", err)
+	}
+	resp := &CompactResponse{}
+	resp.TrieNodes, resp.TrieBytes, err = t.Compact()
+	if err != nil {
+		return nil, xerrors.Errorf("compacting trie: %v", err)
+	}
+	if keepBlocks == 0 {
+		return resp, nil
+	}
+
+	latest, err := sbDB.GetLatestByID(scID)
+	if err != nil {
+		return nil, xerrors.Errorf("getting latest block: %v", err)
+	}
+	sb := sbDB.GetByID(scID)
+	if sb == nil {
+		return nil, xerrors.New("missing genesis block")
+	}
+	// The genesis block is never pruned, as it holds the nonce of the trie.
+	for len(sb.ForwardLink) > 0 {
+		sb = sbDB.GetByID(sb.ForwardLink[0].To)
+		if sb == nil || sb.Index > latest.Index-keepBlocks {
+			break
+		}
+		n, err := sbDB.PrunePayload(sb.Hash)
+		if err != nil {
+			return nil, xerrors.Errorf("pruning block %d: %v", sb.Index, err)
+		}
+		if n > 0 {
+			resp.PrunedBlocks++
+			resp.PrunedBytes += n
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,103 @@
+package byzcoin
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/kyber/v3/sign/schnorr"
+	"go.etcd.io/bbolt"
+)
+
+func TestService_Compact(t *testing.T) {
+	defer func(cda int) {
+		catchupDownloadAll = cda
+	}(catchupDownloadAll)
+	catchupDownloadAll = 2
+
+	s := newSer(t, 1, testInterval)
+	defer s.local.CloseAll()
+
+	n := 4
+	for i := 0; i < n; i++ {
+		ctx, err := createOneClientTxWithCounter(s.darc.GetBaseID(), dummyContract, []byte{byte(i)}, s.signer, uint64(i+1))
+		require.NoError(t, err)
+		s.sendTxAndWait(t, ctx, 10)
+	}
+
+	scID := s.genesis.SkipChainID()
+	sig, err := schnorr.Sign(cothority.Suite, s.service().ServerIdentity().GetPrivate(), scID)
+	require.NoError(t, err)
+	compact := func(keep int) (*CompactResponse, error) {
+		return s.service().Compact(&CompactRequest{
+			ByzCoinID:  scID,
+			KeepBlocks: keep,
+			Signature:  sig,
+		})
+	}
+
+	// Add a node that is not reachable from the root.
+	db, bucket := s.service().GetAdditionalBucket([]byte(fmt.Sprintf("%x", scID)))
+	err = db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(bucket).Put(make([]byte, 32), []byte("orphan"))
+	})
+	require.NoError(t, err)
+
+	st, err := s.service().getStateTrie(scID)
+	require.NoError(t, err)
+	root := st.GetRoot()
+
+	resp, err := compact(0)
+	require.NoError(t, err)
+	require.Equal(t, 1, resp.TrieNodes)
+	require.Equal(t, 32+len("orphan"), resp.TrieBytes)
+	require.Equal(t, 0, resp.PrunedBlocks)
+	require.Equal(t, root, st.GetRoot())
+	require.NoError(t, st.IsValid())
+
+	// Not enough blocks are kept for the other nodes to catch up.
+	_, err = compact(1)
+	require.Error(t, err)
+
+	// Wrong signature.
+	_, err = s.service().Compact(&CompactRequest{ByzCoinID: scID, Signature: []byte{}})
+	require.Error(t, err)
+
+	// The blocks 1 and 2 are pruned, the genesis block and the blocks 3 and
+	// 4 are kept.
+	resp, err = compact(2)
+	require.NoError(t, err)
+	require.Equal(t, 0, resp.TrieNodes)
+	require.Equal(t, 2, resp.PrunedBlocks)
+	require.True(t, resp.PrunedBytes > 0)
+
+	sb := s.service().db().GetByID(scID)
+	require.NotEmpty(t, sb.Payload)
+	for sb != nil && len(sb.ForwardLink) > 0 {
+		sb = s.service().db().GetByID(sb.ForwardLink[0].To)
+		require.Equal(t, sb.Index > n-2, len(sb.Payload) > 0)
+		require.True(t, sb.Hash.Equal(sb.CalculateHash()))
+	}
+
+	// The state before a pruned block cannot be rebuilt anymore, but the
+	// proofs of the current state still work.
+	_, err = s.service().GetHistoricalProof(&GetHistoricalProof{
+		Version:    CurrentVersion,
+		Key:        ConfigInstanceID.Slice(),
+		ID:         scID,
+		BlockIndex: n - 1,
+	})
+	require.Error(t, err)
+	proof, err := s.service().GetProof(&GetProof{
+		Version: CurrentVersion,
+		Key:     ConfigInstanceID.Slice(),
+		ID:      scID,
+	})
+	require.NoError(t, err)
+	require.NoError(t, proof.Proof.Verify(scID))
+
+	resp, err = compact(2)
+	require.NoError(t, err)
+	require.Equal(t, 0, resp.PrunedBlocks)
+}
@@ -0,0 +1,197 @@
+package byzcoin
+
+import (
+	"bytes"
+
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/kyber/v3/pairing"
+	"go.dedis.ch/onet/v3"
+	"go.dedis.ch/onet/v3/network"
+	"go.dedis.ch/protobuf"
+	"golang.org/x/xerrors"
+)
+
+// ContractForeignChainID is the ID of the contract following another ByzCoin
+// chain, so that the instructions of this chain can depend on the state of
+// the other one.
+//
+// Spawn expects the genesis block of the foreign chain in the "genesis"
+// argument, which is the first trusted block.
+//
+// Invoke offers two commands:
+//   - update follows the foreign chain with the forward links given in the
+//     "links" argument, as ForeignChainLinks. The links must start at the
+//     latest trusted block, and the signature of each link is verified with
+//     the roster of the block it comes from. The links of a proof starting at
+//     the trusted block, without the first one, can be used.
+//   - verify checks the Proof given in the "proof" argument, which must start
+//     at the latest trusted block. If the "key" argument is given, the key
+//     must exist in the proof, with the value of the "value" argument and
+//     the contract of the "contract" argument if they are given. It doesn't
+//     change the state, but the transaction is refused if the proof isn't
+//     verified, so it can be used as a condition for the other instructions
+//     of the transaction.
+//
+// Other contracts can verify the proofs of the foreign chain with
+// VerifyForeignProof.
+const ContractForeignChainID = "foreignchain"
+
+type contractForeignChain struct {
+	BasicContract
+	ForeignChain
+}
+
+func contractForeignChainFromBytes(in []byte) (Contract, error) {
+	c := &contractForeignChain{}
+	err := protobuf.DecodeWithConstructors(in, &c.ForeignChain, network.DefaultConstructors(cothority.Suite))
+	if err != nil {
+		return nil, xerrors.Errorf("decoding: %v", err)
+	}
+	return c, nil
+}
+
+func (c *contractForeignChain) Spawn(rst ReadOnlyStateTrie, inst Instruction, coins []Coin) ([]StateChange, []Coin, error) {
+	_, _, _, darcID, err := rst.GetValues(inst.InstanceID.Slice())
+	if err != nil {
+		return nil, nil, xerrors.Errorf("reading trie: %v", err)
+	}
+
+	var genesis skipchain.SkipBlock
+	err = protobuf.DecodeWithConstructors(inst.Spawn.Args.Search("genesis"), &genesis,
+		network.DefaultConstructors(cothority.Suite))
+	if err != nil {
+		return nil, nil, xerrors.Errorf("decoding genesis block: %v", err)
+	}
+	if genesis.SkipBlockFix == nil || genesis.Roster == nil || genesis.Index != 0 ||
+		!genesis.CalculateHash().Equal(genesis.Hash) {
+		return nil, nil, xerrors.New("invalid genesis block")
+	}
+
+	c.ForeignChain = ForeignChain{
+		Genesis:         genesis.Hash,
+		BlockID:         genesis.Hash,
+		Roster:          *genesis.Roster,
+		SignatureScheme: genesis.SignatureScheme,
+	}
+	buf, err := protobuf.Encode(&c.ForeignChain)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("encoding: %v", err)
+	}
+	sc := []StateChange{
+		NewStateChange(Create, inst.DeriveID(""), ContractForeignChainID, buf, darcID),
+	}
+	return sc, coins, nil
+}
+
+func (c *contractForeignChain) Invoke(rst ReadOnlyStateTrie, inst Instruction, coins []Coin) ([]StateChange, []Coin, error) {
+	_, _, _, darcID, err := rst.GetValues(inst.InstanceID.Slice())
+	if err != nil {
+		return nil, nil, xerrors.Errorf("reading trie: %v", err)
+	}
+
+	switch inst.Invoke.Command {
+	case "update":
+		var links ForeignChainLinks
+		err = protobuf.DecodeWithConstructors(inst.Invoke.Args.Search("links"), &links,
+			network.DefaultConstructors(cothority.Suite))
+		if err != nil {
+			return nil, nil, xerrors.Errorf("decoding links: %v", err)
+		}
+		if err = c.follow(links.Links); err != nil {
+			return nil, nil, xerrors.Errorf("following chain: %v", err)
+		}
+		buf, err := protobuf.Encode(&c.ForeignChain)
+		if err != nil {
+			return nil, nil, xerrors.Errorf("encoding: %v", err)
+		}
+		sc := []StateChange{
+			NewStateChange(Update, inst.InstanceID, ContractForeignChainID, buf, darcID),
+		}
+		return sc, coins, nil
+	case "verify":
+		var p Proof
+		err = protobuf.DecodeWithConstructors(inst.Invoke.Args.Search("proof"), &p,
+			network.DefaultConstructors(cothority.Suite))
+		if err != nil {
+			return nil, nil, xerrors.Errorf("decoding proof: %v", err)
+		}
+		if err = c.VerifyProof(p); err != nil {
+			return nil, nil, xerrors.Errorf("verifying proof: %v", err)
+		}
+		if key := inst.Invoke.Args.Search("key"); key != nil {
+			value, contractID, _, err := p.Get(key)
+			if err != nil {
+				return nil, nil, xerrors.Errorf("key not in proof: %v", err)
+			}
+			if v := inst.Invoke.Args.Search("value"); v != nil && !bytes.Equal(v, value) {
+				return nil, nil, xerrors.New("wrong value")
+			}
+			if cid := inst.Invoke.Args.Search("contract"); cid != nil && string(cid) != contractID {
+				return nil, nil, xerrors.New("wrong contract")
+			}
+		}
+		return nil, coins, nil
+	default:
+		return nil, nil, xerrors.New("invalid invoke command: " + inst.Invoke.Command)
+	}
+}
+
+// follow moves the trusted block along the forward links, and updates the
+// roster when it changes.
+func (fc *ForeignChain) follow(links []skipchain.ForwardLink) error {
+	if len(links) == 0 {
+		return xerrors.New("no forward link")
+	}
+	for _, l := range links {
+		if !l.From.Equal(fc.BlockID) {
+			return xerrors.New("link doesn't start at the trusted block")
+		}
+		publics := fc.Roster.ServicePublics(skipchain.ServiceName)
+		err := l.VerifyWithScheme(pairing.NewSuiteBn256(), publics, fc.SignatureScheme)
+		if err != nil {
+			return xerrors.Errorf("invalid forward link: %v", err)
+		}
+		if l.NewRoster != nil {
+			// The signature only covers the ID of the new roster.
+			if !onet.NewRoster(l.NewRoster.List).ID.Equal(l.NewRoster.ID) {
+				return xerrors.New("roster doesn't match its ID")
+			}
+			fc.Roster = *l.NewRoster
+		}
+		fc.BlockID = l.To
+	}
+	return nil
+}
+
+// VerifyProof checks that the proof comes from the foreign chain and starts
+// at the latest trusted block.
+func (fc ForeignChain) VerifyProof(p Proof) error {
+	if !p.Latest.SkipChainID().Equal(fc.Genesis) {
+		return xerrors.New("proof is from another chain")
+	}
+	trusted := &skipchain.SkipBlock{
+		SkipBlockFix: &skipchain.SkipBlockFix{Roster: &fc.Roster},
+		Hash:         fc.BlockID,
+	}
+	return cothority.ErrorOrNil(p.VerifyFromBlock(trusted), "verification failed")
+}
+
+// VerifyForeignProof checks that the proof comes from the chain followed by
+// the foreignchain instance. It is meant to be used by the contracts that
+// depend on the state of another chain.
+func VerifyForeignProof(rst ReadOnlyStateTrie, id InstanceID, p Proof) error {
+	buf, _, contractID, _, err := rst.GetValues(id.Slice())
+	if err != nil {
+		return xerrors.Errorf("reading trie: %v", err)
+	}
+	if contractID != ContractForeignChainID {
+		return xerrors.New("instance is not a foreign chain")
+	}
+	var fc ForeignChain
+	err = protobuf.DecodeWithConstructors(buf, &fc, network.DefaultConstructors(cothority.Suite))
+	if err != nil {
+		return xerrors.Errorf("decoding: %v", err)
+	}
+	return fc.VerifyProof(p)
+}
@@ -0,0 +1,148 @@
+package byzcoin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/onet/v3"
+	"go.dedis.ch/onet/v3/network"
+	"go.dedis.ch/protobuf"
+)
+
+// TestService_ForeignChain follows a foreign chain through a roster change and
+// verifies proofs of its config instance.
+func TestService_ForeignChain(t *testing.T) {
+	local := onet.NewTCPTest(cothority.Suite)
+	defer local.CloseAll()
+
+	signer := darc.NewSignerEd25519(nil, nil)
+	hosts, roster, _ := local.GenTree(4, true)
+	s := local.GetServices(hosts, ByzCoinID)[0].(*Service)
+
+	foreignMsg, err := DefaultGenesisMsg(CurrentVersion, roster, nil, signer.Identity())
+	require.NoError(t, err)
+	foreignMsg.BlockInterval = time.Second
+	foreignCl, foreignResp, err := NewLedger(foreignMsg, false)
+	require.NoError(t, err)
+
+	genesisMsg, err := DefaultGenesisMsg(CurrentVersion, roster, []string{
+		"spawn:" + ContractForeignChainID,
+		"invoke:" + ContractForeignChainID + ".update",
+		"invoke:" + ContractForeignChainID + ".verify",
+	}, signer.Identity())
+	require.NoError(t, err)
+	genesisMsg.BlockInterval = time.Second
+	cl, resp, err := NewLedger(genesisMsg, false)
+	require.NoError(t, err)
+
+	counter := uint64(0)
+	send := func(instr Instruction) (ClientTransaction, error) {
+		counter++
+		instr.SignerCounter = []uint64{counter}
+		ctx, err := cl.CreateTransaction(instr)
+		require.NoError(t, err)
+		require.NoError(t, ctx.FillSignersAndSignWith(signer))
+		_, err = cl.AddTransactionAndWait(ctx, 10)
+		if err != nil {
+			// A refused transaction doesn't increase the counter.
+			counter--
+		}
+		return ctx, err
+	}
+
+	// Spawn the instance trusting the genesis block of the foreign chain.
+	genesisBuf, err := protobuf.Encode(foreignResp.Skipblock)
+	require.NoError(t, err)
+	ctx, err := send(Instruction{
+		InstanceID: NewInstanceID(genesisMsg.GenesisDarc.GetBaseID()),
+		Spawn: &Spawn{
+			ContractID: ContractForeignChainID,
+			Args:       Arguments{{Name: "genesis", Value: genesisBuf}},
+		},
+	})
+	require.NoError(t, err)
+	fcID := ctx.Instructions[0].DeriveID("")
+
+	configID := NewInstanceID(nil).Slice()
+	verify := func(pr Proof, contractID string) error {
+		buf, err := protobuf.Encode(&pr)
+		require.NoError(t, err)
+		_, err = send(Instruction{
+			InstanceID: fcID,
+			Invoke: &Invoke{
+				ContractID: ContractForeignChainID,
+				Command:    "verify",
+				Args: Arguments{
+					{Name: "proof", Value: buf},
+					{Name: "key", Value: configID},
+					{Name: "contract", Value: []byte(contractID)},
+				},
+			},
+		})
+		return err
+	}
+
+	pr, err := foreignCl.GetProof(configID)
+	require.NoError(t, err)
+	require.NoError(t, verify(pr.Proof, ContractConfigID))
+	require.Error(t, verify(pr.Proof, ContractDarcID))
+
+	// A proof of our own chain is refused.
+	ownPr, err := cl.GetProof(configID)
+	require.NoError(t, err)
+	require.Error(t, verify(ownPr.Proof, ContractConfigID))
+
+	// Remove a node from the roster of the foreign chain.
+	buf, _, _, err := pr.Proof.Get(configID)
+	require.NoError(t, err)
+	var config ChainConfig
+	require.NoError(t, protobuf.DecodeWithConstructors(buf, &config,
+		network.DefaultConstructors(cothority.Suite)))
+	config.Roster = *onet.NewRoster(config.Roster.List[:3])
+	configBuf, err := protobuf.Encode(&config)
+	require.NoError(t, err)
+	ctx, err = foreignCl.CreateTransaction(Instruction{
+		InstanceID: NewInstanceID(nil),
+		Invoke: &Invoke{
+			ContractID: ContractConfigID,
+			Command:    "update_config",
+			Args:       Arguments{{Name: "config", Value: configBuf}},
+		},
+		SignerCounter: []uint64{1},
+	})
+	require.NoError(t, err)
+	require.NoError(t, ctx.FillSignersAndSignWith(signer))
+	_, err = foreignCl.AddTransactionAndWait(ctx, 10)
+	require.NoError(t, err)
+
+	pr, err = foreignCl.GetProof(configID)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(pr.Proof.Latest.Roster.List))
+
+	// Follow the foreign chain up to the latest block.
+	links, err := protobuf.Encode(&ForeignChainLinks{Links: pr.Proof.Links[1:]})
+	require.NoError(t, err)
+	_, err = send(Instruction{
+		InstanceID: fcID,
+		Invoke: &Invoke{
+			ContractID: ContractForeignChainID,
+			Command:    "update",
+			Args:       Arguments{{Name: "links", Value: links}},
+		},
+	})
+	require.NoError(t, err)
+
+	// The proofs must now start at the latest trusted block.
+	require.Error(t, verify(pr.Proof, ContractConfigID))
+	pr, err = foreignCl.GetProofFrom(configID, &pr.Proof.Latest)
+	require.NoError(t, err)
+	require.NoError(t, verify(pr.Proof, ContractConfigID))
+
+	rst, err := s.GetReadOnlyStateTrie(resp.Skipblock.SkipChainID())
+	require.NoError(t, err)
+	require.NoError(t, VerifyForeignProof(rst, fcID, pr.Proof))
+	require.Error(t, VerifyForeignProof(rst, NewInstanceID(nil), pr.Proof))
+}
@@ -3,7 +3,6 @@ package byzcoin
 import (
 	"encoding/binary"
 	"fmt"
-	"net/url"
 	"strconv"
 	"strings"
 	"sync"
@@ -211,48 +210,11 @@ func (b BasicContract) VerifyDeferredInstruction(rst ReadOnlyStateTrie, inst Ins
 	return notImpl("VerifyDeferredInstruction")
 }
 
-// MakeAttrInterpreters provides one default attribute verification which check
-// whether the transaction is sent after a certain block index and before
-// another block index.
+// MakeAttrInterpreters provides the built-in attributes, see
+// BuiltinAttrInterpreters. Contracts may add their own attributes to the
+// returned map.
 func (b BasicContract) MakeAttrInterpreters(rst ReadOnlyStateTrie, inst Instruction) darc.AttrInterpreters {
-	cb := func(attr string) error {
-		vals, err := url.ParseQuery(attr)
-		if err != nil {
-			return xerrors.Errorf("parsing query: %v", err)
-		}
-		beforeStr := vals.Get("before")
-		afterStr := vals.Get("after")
-
-		var before, after int
-
-		if len(beforeStr) == 0 {
-			// Set before to something higher than the current
-			// index so that it always passes.
-			before = rst.GetIndex() + 1
-		} else {
-			var err error
-			before, err = strconv.Atoi(beforeStr)
-			if err != nil {
-				return xerrors.Errorf("atoi: %v")
-			}
-		}
-
-		if len(afterStr) == 0 {
-			after = -1
-		} else {
-			var err error
-			after, err = strconv.Atoi(afterStr)
-			if err != nil {
-				return xerrors.Errorf("atoi: %v", err)
-			}
-		}
-
-		if after < rst.GetIndex() && rst.GetIndex() < before {
-			return nil
-		}
-		return xerrors.Errorf("the current block index is %d which does not fit in the interval (%d, %d)", rst.GetIndex(), after, before)
-	}
-	return darc.AttrInterpreters{"block": cb}
+	return BuiltinAttrInterpreters(rst, inst)
 }
 
 // Spawn is not implmented in a BasicContract. Types which embed BasicContract
@@ -0,0 +1,207 @@
+package byzcoin
+
+import (
+	"sync"
+	"time"
+
+	"go.dedis.ch/protobuf"
+	"golang.org/x/xerrors"
+)
+
+// sanityCheck makes sure the fees can be paid.
+func (f TxFees) sanityCheck() error {
+	if f.Instruction == 0 {
+		return xerrors.New("instruction fee is zero")
+	}
+	if f.Account != nil && f.Account.Equal(ConfigInstanceID) {
+		return xerrors.New("the fee account cannot be the config instance")
+	}
+	return nil
+}
+
+// sanityCheck makes sure the rate limit lets some transactions through.
+func (r RateLimit) sanityCheck() error {
+	if r.Transactions <= 0 {
+		return xerrors.New("rate limit must allow at least one transaction")
+	}
+	if r.Window <= 0 {
+		return xerrors.New("rate limit window is less or equal to zero")
+	}
+	return nil
+}
+
+// payTxFees makes sure that the coins left over at the end of a transaction
+// pay the fees of its instructions, and returns the state changes moving the
+// coins to the fee account, as well as the coins that are not used for the
+// fees.
+func payTxFees(rst ReadOnlyStateTrie, instrs Instructions, coins []Coin) (StateChanges, []Coin, error) {
+	// The genesis transaction creates the config, so there are no fees yet.
+	_, _, _, _, err := rst.GetValues(ConfigInstanceID.Slice())
+	if xerrors.Is(err, errKeyNotSet) {
+		return nil, coins, nil
+	}
+	config, err := LoadConfigFromTrie(rst)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("reading config: %v", err)
+	}
+	fees := config.TxFees
+	if fees == nil {
+		return nil, coins, nil
+	}
+
+	var due Coin
+	for _, instr := range instrs {
+		if instr.InstanceID.Equal(ConfigInstanceID) {
+			continue
+		}
+		if err := due.SafeAdd(fees.Instruction); err != nil {
+			return nil, nil, xerrors.Errorf("computing fees: %v", err)
+		}
+	}
+	if due.Value == 0 {
+		return nil, coins, nil
+	}
+
+	paid := Coin{Name: fees.Coin}
+	var rest []Coin
+	for _, c := range coins {
+		if !c.Name.Equal(fees.Coin) {
+			rest = append(rest, c)
+			continue
+		}
+		if err := paid.SafeAdd(c.Value); err != nil {
+			return nil, nil, xerrors.Errorf("adding coins: %v", err)
+		}
+	}
+	if paid.Value < due.Value {
+		return nil, nil, xerrors.Errorf("transaction pays %d coins of fees instead of %d",
+			paid.Value, due.Value)
+	}
+	if fees.Account == nil {
+		return nil, rest, nil
+	}
+
+	// All the fee coins go to the account, including the ones above the
+	// required fees.
+	val, ver, cid, darcID, err := rst.GetValues(fees.Account.Slice())
+	if err != nil {
+		return nil, nil, xerrors.Errorf("reading fee account: %v", err)
+	}
+	var account Coin
+	if err := protobuf.Decode(val, &account); err != nil {
+		return nil, nil, xerrors.Errorf("decoding fee account: %v", err)
+	}
+	if !account.Name.Equal(fees.Coin) {
+		return nil, nil, xerrors.New("the fee account holds other coins")
+	}
+	if err := account.SafeAdd(paid.Value); err != nil {
+		return nil, nil, xerrors.Errorf("paying fees: %v", err)
+	}
+	buf, err := protobuf.Encode(&account)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("encoding fee account: %v", err)
+	}
+	sc := NewStateChange(Update, *fees.Account, cid, buf, darcID)
+	sc.Version = ver + 1
+	return StateChanges{sc}, rest, nil
+}
+
+// rateLimiter remembers when the identities sent their last transactions to
+// this node.
+type rateLimiter struct {
+	sync.Mutex
+	// txs maps the skipchain ID and the identity to the times of the
+	// transactions.
+	txs map[string][]time.Time
+}
+
+// maxRateLimiterKeys is the number of identities after which the identities
+// without recent transactions are removed.
+const maxRateLimiterKeys = 10000
+
+// allow records a transaction of the identities if none of them is above the
+// limit, else it returns an error.
+func (rl *rateLimiter) allow(scID []byte, ids []string, limit RateLimit, now time.Time) error {
+	rl.Lock()
+	defer rl.Unlock()
+	if rl.txs == nil {
+		rl.txs = make(map[string][]time.Time)
+	}
+	if len(rl.txs) > maxRateLimiterKeys {
+		for key, times := range rl.txs {
+			if len(times) == 0 || now.Sub(times[len(times)-1]) >= limit.Window {
+				delete(rl.txs, key)
+			}
+		}
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = string(scID) + id
+		times := rl.txs[keys[i]]
+		// Only keep the transactions in the window.
+		for len(times) > 0 && now.Sub(times[0]) >= limit.Window {
+			times = times[1:]
+		}
+		rl.txs[keys[i]] = times
+		if len(times) >= limit.Transactions {
+			return xerrors.Errorf("identity %s sent more than %d transactions in %s",
+				id, limit.Transactions, limit.Window)
+		}
+	}
+	for _, key := range keys {
+		rl.txs[key] = append(rl.txs[key], now)
+	}
+	return nil
+}
+
+// forget removes the transaction recorded at the given time for the
+// identities, when it could not be added to the pool after all.
+func (rl *rateLimiter) forget(scID []byte, ids []string, at time.Time) {
+	rl.Lock()
+	defer rl.Unlock()
+	for _, id := range ids {
+		key := string(scID) + id
+		times := rl.txs[key]
+		for i := len(times) - 1; i >= 0; i-- {
+			if times[i].Equal(at) {
+				rl.txs[key] = append(times[:i], times[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// anonymousSigner is the identity under which the transactions without any
+// signer are counted.
+const anonymousSigner = "anonymous"
+
+// txSigners returns the identities signing the instructions of the
+// transaction, without duplicates. It must only be called once the
+// transaction has been executed, so that all the identities have a valid
+// signature and are authorized by the darcs, else anybody could use the quota
+// of somebody else, or get around the limit with new keys. The signers of the
+// instructions on the config instance are not counted, so that the chain can
+// always be fixed. The transactions without any signer all share the quota of
+// anonymousSigner.
+func txSigners(tx ClientTransaction) []string {
+	seen := make(map[string]bool)
+	var ids []string
+	signed := false
+	for _, instr := range tx.Instructions {
+		for _, id := range instr.SignerIdentities {
+			signed = true
+			if instr.InstanceID.Equal(ConfigInstanceID) {
+				continue
+			}
+			if !seen[id.String()] {
+				seen[id.String()] = true
+				ids = append(ids, id.String())
+			}
+		}
+	}
+	if !signed {
+		return []string{anonymousSigner}
+	}
+	return ids
+}
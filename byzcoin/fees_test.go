@@ -0,0 +1,123 @@
+package byzcoin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/protobuf"
+)
+
+func TestPayTxFees(t *testing.T) {
+	sst, err := newMemStagingStateTrie([]byte("fees"))
+	require.NoError(t, err)
+
+	instrs := Instructions{
+		{InstanceID: NewInstanceID([]byte("a"))},
+		{InstanceID: NewInstanceID([]byte("b"))},
+		{InstanceID: ConfigInstanceID},
+	}
+	feeCoin := NewInstanceID([]byte("feeCoin"))
+	otherCoin := NewInstanceID([]byte("otherCoin"))
+	coins := []Coin{{Name: feeCoin, Value: 25}, {Name: otherCoin, Value: 3}}
+
+	// Without config, the transaction is free.
+	scs, rest, err := payTxFees(sst, instrs, coins)
+	require.NoError(t, err)
+	require.Empty(t, scs)
+	require.Equal(t, coins, rest)
+
+	setConfig := func(fees *TxFees) {
+		buf, err := protobuf.Encode(&ChainConfig{TxFees: fees})
+		require.NoError(t, err)
+		require.NoError(t, sst.StoreAll(StateChanges{
+			NewStateChange(Create, ConfigInstanceID, ContractConfigID, buf, nil)}))
+	}
+	setConfig(&TxFees{Coin: feeCoin, Instruction: 10})
+
+	// Two instructions pay, the one on the config is free.
+	scs, rest, err = payTxFees(sst, instrs, coins)
+	require.NoError(t, err)
+	require.Empty(t, scs)
+	require.Equal(t, []Coin{coins[1]}, rest)
+
+	_, _, err = payTxFees(sst, instrs, []Coin{{Name: feeCoin, Value: 19}, coins[1]})
+	require.Error(t, err)
+	_, _, err = payTxFees(sst, instrs, []Coin{{Name: otherCoin, Value: 100}})
+	require.Error(t, err)
+	_, _, err = payTxFees(sst, instrs[2:], nil)
+	require.NoError(t, err)
+
+	// The fees go to the account.
+	account := NewInstanceID([]byte("account"))
+	darcID := darc.ID([]byte("darc"))
+	buf, err := protobuf.Encode(&Coin{Name: feeCoin, Value: 100})
+	require.NoError(t, err)
+	require.NoError(t, sst.StoreAll(StateChanges{NewStateChange(Create, account, "coin", buf, darcID)}))
+	setConfig(&TxFees{Coin: feeCoin, Instruction: 10, Account: &account})
+
+	scs, _, err = payTxFees(sst, instrs, coins)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(scs))
+	require.Equal(t, Update, scs[0].StateAction)
+	require.Equal(t, account.Slice(), scs[0].InstanceID)
+	require.Equal(t, "coin", scs[0].ContractID)
+	require.Equal(t, uint64(1), scs[0].Version)
+	var c Coin
+	require.NoError(t, protobuf.Decode(scs[0].Value, &c))
+	require.Equal(t, uint64(125), c.Value)
+}
+
+func TestRateLimiter(t *testing.T) {
+	rl := rateLimiter{}
+	limit := RateLimit{Transactions: 2, Window: time.Minute}
+	scID := []byte("chain")
+	now := time.Now()
+
+	require.NoError(t, rl.allow(scID, []string{"a"}, limit, now))
+	require.NoError(t, rl.allow(scID, []string{"a", "b"}, limit, now.Add(time.Second)))
+	require.Error(t, rl.allow(scID, []string{"a"}, limit, now.Add(2*time.Second)))
+	// The refused transaction is not counted for b.
+	require.Error(t, rl.allow(scID, []string{"b", "a"}, limit, now.Add(2*time.Second)))
+	require.NoError(t, rl.allow(scID, []string{"b"}, limit, now.Add(2*time.Second)))
+	require.Error(t, rl.allow(scID, []string{"b"}, limit, now.Add(3*time.Second)))
+
+	// Other chains are counted separately.
+	require.NoError(t, rl.allow([]byte("other"), []string{"a"}, limit, now.Add(2*time.Second)))
+
+	// Once the first transaction is out of the window, a can send again.
+	require.NoError(t, rl.allow(scID, []string{"a"}, limit, now.Add(time.Minute)))
+	require.Error(t, rl.allow(scID, []string{"a"}, limit, now.Add(time.Minute)))
+
+	// A forgotten transaction doesn't count anymore.
+	rl.forget(scID, []string{"a"}, now.Add(time.Minute))
+	require.NoError(t, rl.allow(scID, []string{"a"}, limit, now.Add(time.Minute)))
+}
+
+func TestTxSigners(t *testing.T) {
+	signer := darc.NewSignerEd25519(nil, nil)
+	other := darc.NewSignerEd25519(nil, nil)
+	id := signer.Identity().String()
+
+	ctx, err := combineInstrsAndSign(signer,
+		Instruction{InstanceID: NewInstanceID([]byte("a"))},
+		Instruction{InstanceID: NewInstanceID([]byte("b"))})
+	require.NoError(t, err)
+	require.Equal(t, []string{id}, txSigners(ctx))
+
+	ctx.Instructions[1].SignerIdentities = append(ctx.Instructions[1].SignerIdentities,
+		other.Identity())
+	require.Equal(t, []string{id, other.Identity().String()}, txSigners(ctx))
+
+	// The transactions without any signer share the same quota.
+	ctx.Instructions[0].SignerIdentities = nil
+	ctx.Instructions[1].SignerIdentities = nil
+	require.Equal(t, []string{anonymousSigner}, txSigners(ctx))
+
+	// The signers of the config instructions are not counted, but the
+	// transaction is signed.
+	ctx, err = combineInstrsAndSign(signer, Instruction{InstanceID: ConfigInstanceID})
+	require.NoError(t, err)
+	require.Empty(t, txSigners(ctx))
+}
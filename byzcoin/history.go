@@ -0,0 +1,96 @@
+package byzcoin
+
+import (
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/onet/v3/log"
+	"golang.org/x/xerrors"
+)
+
+// errHistoryUnavailable is returned when the state changes needed to rebuild
+// a past state are not stored by the node anymore, either because they have
+// been cleaned from the storage or because the node downloaded the state trie
+// instead of the blocks while catching up.
+var errHistoryUnavailable = xerrors.New("the state changes of the past blocks are not all stored by this node")
+
+// GetHistoricalProof returns the proof of a key in the state trie as it was
+// after the block with the given index. The state is rebuilt from the state
+// changes stored by the node.
+func (s *Service) GetHistoricalProof(req *GetHistoricalProof) (*GetProofResponse, error) {
+	sb := s.db().GetByID(req.ID)
+	if sb == nil {
+		return nil, xerrors.New("cannot find skipblock while getting proof")
+	}
+	if req.BlockIndex < sb.Index {
+		return nil, xerrors.New("block index is before the block of the proof")
+	}
+
+	st, err := s.getHistoricalStateTrie(sb.SkipChainID(), req.BlockIndex)
+	if err != nil {
+		return nil, xerrors.Errorf("getting historical trie: %v", err)
+	}
+	proof, err := NewProof(st, s.db(), req.ID, req.Key)
+	if err != nil {
+		return nil, xerrors.Errorf("making proof: %v", err)
+	}
+
+	log.Lvlf2("%s: Returning proof for %x from chain %x at index %v", s.ServerIdentity(), req.Key, sb.SkipChainID(), req.BlockIndex)
+	return &GetProofResponse{
+		Version: CurrentVersion,
+		Proof:   *proof,
+	}, nil
+}
+
+// getHistoricalStateTrie rebuilds the state trie of the chain as it was after
+// the block at the given index. The state changes stored for the blocks up to
+// this index are applied in order to an empty trie, and the root of the trie
+// is verified against the one of the block. Neither the bodies of the blocks
+// nor the contracts are needed.
+func (s *Service) getHistoricalStateTrie(scID skipchain.SkipBlockID, index int) (*stateTrie, error) {
+	current, err := s.getStateTrie(scID)
+	if err != nil {
+		return nil, xerrors.Errorf("getting trie: %v", err)
+	}
+	if index < 0 || index > current.GetIndex() {
+		return nil, xerrors.Errorf("block index %d is not in [0, %d]", index, current.GetIndex())
+	}
+
+	reply, err := s.skService().GetSingleBlockByIndex(&skipchain.GetSingleBlockByIndex{
+		Genesis: scID,
+		Index:   index,
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("getting block: %v", err)
+	}
+	header, err := decodeBlockHeader(reply.SkipBlock)
+	if err != nil {
+		return nil, xerrors.Errorf("decoding header: %v", err)
+	}
+
+	entries, err := s.stateChangeStorage.getUntilBlock(scID, index)
+	if err != nil {
+		return nil, xerrors.Errorf("reading state changes: %v", err)
+	}
+	if len(entries) == 0 || entries[0].BlockIndex != 0 {
+		return nil, cothority.WrapError(errHistoryUnavailable)
+	}
+	scs := make(StateChanges, len(entries))
+	for i, sce := range entries {
+		scs[i] = sce.StateChange
+	}
+
+	nonce, err := current.GetNonce()
+	if err != nil {
+		return nil, xerrors.Errorf("getting nonce: %v", err)
+	}
+	st, err := newMemStateTrie(nonce)
+	if err != nil {
+		return nil, xerrors.Errorf("creating trie: %v", err)
+	}
+	err = st.VerifiedStoreAll(scs, index, header.Version, header.TrieRoot)
+	if err != nil {
+		// Some state changes are missing, so the root doesn't match.
+		return nil, cothority.WrapError(errHistoryUnavailable)
+	}
+	return st, nil
+}
@@ -0,0 +1,68 @@
+package byzcoin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_GetHistoricalProof(t *testing.T) {
+	s := newSer(t, 1, testInterval)
+	defer s.local.CloseAll()
+
+	n := 3
+	keys := make([][]byte, n)
+	for i := 0; i < n; i++ {
+		ctx, err := createOneClientTxWithCounter(s.darc.GetBaseID(), dummyContract, []byte{byte(i)}, s.signer, uint64(i+1))
+		require.NoError(t, err)
+		s.sendTxAndWait(t, ctx, 10)
+		keys[i] = ctx.Instructions[0].Hash()
+	}
+
+	getProof := func(key []byte, index int) (*GetProofResponse, error) {
+		return s.service().GetHistoricalProof(&GetHistoricalProof{
+			Version:    CurrentVersion,
+			Key:        key,
+			ID:         s.genesis.SkipChainID(),
+			BlockIndex: index,
+		})
+	}
+
+	// The instance created in the block i+1 doesn't exist before.
+	for i := 0; i < n; i++ {
+		for index := 0; index <= n; index++ {
+			resp, err := getProof(keys[i], index)
+			require.NoError(t, err)
+			require.NoError(t, resp.Proof.Verify(s.genesis.SkipChainID()))
+			require.Equal(t, index, resp.Proof.Latest.Index)
+
+			match := resp.Proof.InclusionProof.Match(keys[i])
+			require.Equal(t, index > i, match)
+			if match {
+				_, v, _, _, err := resp.Proof.KeyValue()
+				require.NoError(t, err)
+				require.Equal(t, []byte{byte(i)}, v)
+			}
+		}
+	}
+
+	// The latest state is the same as the one of the service.
+	resp, err := getProof(keys[0], n)
+	require.NoError(t, err)
+	current, err := s.service().getStateTrie(s.genesis.SkipChainID())
+	require.NoError(t, err)
+	require.Equal(t, current.GetRoot(), resp.Proof.InclusionProof.GetRoot())
+
+	_, err = getProof(keys[0], n+1)
+	require.Error(t, err)
+
+	// Once the old state changes are cleaned, the past states can't be
+	// rebuilt anymore.
+	s.service().stateChangeStorage.setMaxNbrBlock(1)
+	ctx, err := createOneClientTxWithCounter(s.darc.GetBaseID(), dummyContract, []byte{byte(n)}, s.signer, uint64(n+1))
+	require.NoError(t, err)
+	s.sendTxAndWait(t, ctx, 10)
+	_, err = getProof(keys[0], 1)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), errHistoryUnavailable.Error())
+}
@@ -15,6 +15,8 @@ func init() {
 		&CreateGenesisBlock{}, &CreateGenesisBlockResponse{},
 		&AddTxRequest{}, &AddTxResponse{},
 		&GetSignerCounters{}, &GetSignerCountersResponse{},
+		&GetTxStatus{}, &GetTxStatusResponse{},
+		&GetTxReceipt{}, &GetTxReceiptResponse{},
 	)
 }
 
@@ -24,4 +26,12 @@ func init() {
 type Version int
 
 // CurrentVersion is what we're running now
-const CurrentVersion Version = 3
+const CurrentVersion Version = 5
+
+// VersionSpawnIndex is the first version storing in the trie the index of the
+// block in which each darc instance has been spawned.
+const VersionSpawnIndex Version = 4
+
+// VersionTxReceipts is the first version whose blocks must hold the hash of
+// the receipts of their transactions.
+const VersionTxReceipts Version = 5
@@ -6,6 +6,7 @@ import (
 	"golang.org/x/xerrors"
 
 	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/byzcoin/trie"
 	"go.dedis.ch/cothority/v3/darc"
 	"go.dedis.ch/cothority/v3/skipchain"
 	"go.dedis.ch/kyber/v3/pairing"
@@ -24,16 +25,65 @@ func NewProof(c ReadOnlyStateTrie, s *skipchain.SkipBlockDB, id skipchain.SkipBl
 		return nil, xerrors.Errorf("couldn't get proof: %+v", err)
 	}
 	p.InclusionProof = *pr
+	latest, links, err := proofLinks(s, id, c.GetIndex())
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't get links: %w", err)
+	}
+	p.Latest = *latest
+	p.Links = links
+	return
+}
+
+// NewMultiProof creates a proof for several keys in the skipchain with the
+// given id.
+func NewMultiProof(c *trie.Trie, index int, s *skipchain.SkipBlockDB,
+	id skipchain.SkipBlockID, keys [][]byte) (*MultiProof, error) {
+	pr, err := c.GetMultiProof(keys)
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't get proof: %+v", err)
+	}
+	return newMultiProof(pr, index, s, id)
+}
+
+// NewPrefixProof creates a proof for all the keys of which the path in the
+// trie starts with prefix, in the skipchain with the given id. If maxNodes is
+// bigger than 0, the proof can't hold more nodes than that.
+func NewPrefixProof(c *trie.Trie, index int, s *skipchain.SkipBlockDB,
+	id skipchain.SkipBlockID, prefix []byte, maxNodes int) (*MultiProof, error) {
+	pr, err := c.GetPrefixProof(prefix, maxNodes)
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't get proof: %+v", err)
+	}
+	return newMultiProof(pr, index, s, id)
+}
+
+func newMultiProof(pr *trie.MultiProof, index int, s *skipchain.SkipBlockDB,
+	id skipchain.SkipBlockID) (*MultiProof, error) {
+	latest, links, err := proofLinks(s, id, index)
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't get links: %w", err)
+	}
+	return &MultiProof{
+		InclusionProof: *pr,
+		Latest:         *latest,
+		Links:          links,
+	}, nil
+}
+
+// proofLinks returns the block at the given index and the forward links from
+// the block with the given id to it.
+func proofLinks(s *skipchain.SkipBlockDB, id skipchain.SkipBlockID,
+	index int) (*skipchain.SkipBlock, []skipchain.ForwardLink, error) {
 	sb := s.GetByID(id)
 	if sb == nil {
-		return nil, xerrors.New("didn't find skipchain")
+		return nil, nil, xerrors.New("didn't find skipchain")
 	}
-	p.Links = []skipchain.ForwardLink{{
+	links := []skipchain.ForwardLink{{
 		From:      []byte{},
 		To:        id,
 		NewRoster: sb.Roster,
 	}}
-	for len(sb.ForwardLink) > 0 && sb.Index < c.GetIndex() {
+	for len(sb.ForwardLink) > 0 && sb.Index < index {
 		var link *skipchain.ForwardLink
 		// Corner-case when the database is downloading blocks and a proof is
 		// requested before all blocks are stored - then we need to make sure that
@@ -43,23 +93,22 @@ func NewProof(c ReadOnlyStateTrie, s *skipchain.SkipBlockDB, id skipchain.SkipBl
 			link = sb.ForwardLink[height]
 			sbTemp := s.GetByID(link.To)
 			if sbTemp == nil {
-				return nil, xerrors.New("missing block in chain")
+				return nil, nil, xerrors.New("missing block in chain")
 			}
 			if sbTemp.Index <= sb.Index {
-				return nil, cothority.ErrorOrNil(skipchain.ErrorInconsistentForwardLink, "")
+				return nil, nil, cothority.ErrorOrNil(skipchain.ErrorInconsistentForwardLink, "")
 			}
-			if sbTemp.Index <= c.GetIndex() {
+			if sbTemp.Index <= index {
 				sb = sbTemp
 				break
 			}
 		}
-		p.Links = append(p.Links, *link)
+		links = append(links, *link)
 	}
-	if c.GetIndex() != sb.Index {
-		return nil, xerrors.New("didn't find skipblock with same index as state-trie")
+	if index != sb.Index {
+		return nil, nil, xerrors.New("didn't find skipblock with same index as state-trie")
 	}
-	p.Latest = *sb
-	return
+	return sb, links, nil
 }
 
 // ErrorVerifyTrie is returned if the proof itself is not properly set up.
@@ -118,19 +167,25 @@ func (p Proof) Verify(sbID skipchain.SkipBlockID) error {
 		return cothority.WrapError(err)
 	}
 
-	if len(p.Links) == 0 {
+	return verifyLinks(p.Links, &p.Latest, sbID)
+}
+
+// verifyLinks checks that the links go from the block with the given id to
+// the latest block.
+func verifyLinks(links []skipchain.ForwardLink, latest *skipchain.SkipBlock, sbID skipchain.SkipBlockID) error {
+	if len(links) == 0 {
 		return cothority.WrapError(ErrorMissingForwardLinks)
 	}
-	if p.Links[0].NewRoster == nil {
+	if links[0].NewRoster == nil {
 		return cothority.WrapError(ErrorMalformedForwardLink)
 	}
 
 	// Get the first from the synthetic link which is assumed to be verified
 	// before against the block with ID stored in the To field by the caller.
-	publics := p.Links[0].NewRoster.ServicePublics(skipchain.ServiceName)
+	publics := links[0].NewRoster.ServicePublics(skipchain.ServiceName)
 
-	for _, l := range p.Links[1:] {
-		if err = l.VerifyWithScheme(pairing.NewSuiteBn256(), publics, p.Latest.SignatureScheme); err != nil {
+	for _, l := range links[1:] {
+		if err := l.VerifyWithScheme(pairing.NewSuiteBn256(), publics, latest.SignatureScheme); err != nil {
 			return cothority.WrapError(ErrorVerifySkipchain)
 		}
 		if !l.From.Equal(sbID) {
@@ -143,7 +198,7 @@ func (p Proof) Verify(sbID skipchain.SkipBlockID) error {
 	}
 
 	// Check that the given latest block matches the last forward link target
-	if !p.Latest.CalculateHash().Equal(sbID) {
+	if !latest.CalculateHash().Equal(sbID) {
 		return cothority.WrapError(ErrorVerifyHash)
 	}
 
@@ -153,12 +208,18 @@ func (p Proof) Verify(sbID skipchain.SkipBlockID) error {
 // VerifyInclusionProof verifies that the inclusion proof matches the skipblock
 // given in parameter.
 func (p Proof) VerifyInclusionProof(latest *skipchain.SkipBlock) error {
+	return verifyTrieRoot(p.InclusionProof.GetRoot(), latest)
+}
+
+// verifyTrieRoot checks that the root is the one stored in the header of the
+// block.
+func verifyTrieRoot(root []byte, latest *skipchain.SkipBlock) error {
 	var header DataHeader
 	err := protobuf.Decode(latest.Data, &header)
 	if err != nil {
 		return xerrors.Errorf("decoding header: %v", err)
 	}
-	if !bytes.Equal(p.InclusionProof.GetRoot(), header.TrieRoot) {
+	if !bytes.Equal(root, header.TrieRoot) {
 		return cothority.WrapError(ErrorVerifyTrieRoot)
 	}
 
@@ -228,3 +289,126 @@ func (p Proof) VerifyAndDecode(suite network.Suite, cid string, value interface{
 	err = protobuf.DecodeWithConstructors(buf, value, network.DefaultConstructors(suite))
 	return cothority.ErrorOrNil(err, "decoding")
 }
+
+// VerifyFromBlock is the same as Proof.VerifyFromBlock for all the keys of the
+// proof.
+func (p MultiProof) VerifyFromBlock(verifiedBlock *skipchain.SkipBlock) error {
+	if len(p.Links) > 0 {
+		p.Links[0].NewRoster = verifiedBlock.Roster
+	}
+
+	err := p.Verify(verifiedBlock.Hash)
+	return cothority.ErrorOrNil(err, "verification failed")
+}
+
+// Verify is the same as Proof.Verify for all the keys of the proof. It does
+// not verify whether certain keys are in the proof.
+func (p MultiProof) Verify(sbID skipchain.SkipBlockID) error {
+	err := verifyTrieRoot(p.InclusionProof.GetRoot(), &p.Latest)
+	if err != nil {
+		return cothority.WrapError(err)
+	}
+
+	return verifyLinks(p.Links, &p.Latest, sbID)
+}
+
+// Get returns the values associated with the given key. If the key is not in
+// the proof, then an error is returned.
+func (p MultiProof) Get(k []byte) (value []byte, contractID string, darcID darc.ID, err error) {
+	vals := p.InclusionProof.Get(k)
+	if len(vals) == 0 {
+		err = xerrors.New("no value")
+		return
+	}
+	var s StateChangeBody
+	s, err = decodeStateChangeBody(vals)
+	if err != nil {
+		err = xerrors.Errorf("decoding body: %v", err)
+		return
+	}
+	value = s.Value
+	contractID = string(s.ContractID)
+	darcID = s.DarcID
+	return
+}
+
+// Instances returns the IDs and the bodies of all the instances under the
+// prefix. It returns an error if the proof doesn't prove that there are no
+// other instances under the prefix.
+func (p MultiProof) Instances(prefix []byte) ([]InstanceID, []StateChangeBody, error) {
+	keys, values, err := p.InclusionProof.KeyValues(prefix)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("incomplete proof: %v", err)
+	}
+	ids := make([]InstanceID, len(keys))
+	bodies := make([]StateChangeBody, len(keys))
+	for i := range keys {
+		ids[i] = NewInstanceID(keys[i])
+		bodies[i], err = decodeStateChangeBody(values[i])
+		if err != nil {
+			return nil, nil, xerrors.Errorf("decoding body: %v", err)
+		}
+	}
+	return ids, bodies, nil
+}
+
+// VerifyFromBlock is the same as Proof.VerifyFromBlock for the block of the
+// receipts.
+func (p TxReceiptProof) VerifyFromBlock(verifiedBlock *skipchain.SkipBlock) error {
+	if len(p.Links) > 0 {
+		p.Links[0].NewRoster = verifiedBlock.Roster
+	}
+
+	err := p.Verify(verifiedBlock.Hash)
+	return cothority.ErrorOrNil(err, "verification failed")
+}
+
+// Verify checks that the block is part of the skipchain and that its body
+// holds the transactions and the receipts stored in its header. The roster
+// of the first link must be verified before, see TxReceiptProof.VerifyFromBlock.
+func (p TxReceiptProof) Verify(sbID skipchain.SkipBlockID) error {
+	header, body, err := p.decode()
+	if err != nil {
+		return xerrors.Errorf("decoding block: %v", err)
+	}
+	if !bytes.Equal(header.ClientTransactionHash, body.TxResults.Hash()) {
+		return xerrors.New("transactions don't match the block header")
+	}
+	if len(header.TxReceiptsHash) == 0 {
+		return xerrors.New("block has no receipts")
+	}
+	if !bytes.Equal(header.TxReceiptsHash, body.TxResults.ReceiptsHash()) {
+		return xerrors.New("receipts don't match the block header")
+	}
+
+	return verifyLinks(p.Links, &p.Block, sbID)
+}
+
+// TxResult returns the transaction with the given hash and its receipt. The
+// proof must be verified before.
+func (p TxReceiptProof) TxResult(txHash []byte) (*TxResult, error) {
+	_, body, err := p.decode()
+	if err != nil {
+		return nil, xerrors.Errorf("decoding block: %v", err)
+	}
+	for _, tx := range body.TxResults {
+		if bytes.Equal(tx.ClientTransaction.Instructions.Hash(), txHash) {
+			return &tx, nil
+		}
+	}
+	return nil, xerrors.New("transaction is not in the block")
+}
+
+func (p TxReceiptProof) decode() (*DataHeader, *DataBody, error) {
+	header, err := decodeBlockHeader(&p.Block)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("decoding header: %v", err)
+	}
+	var body DataBody
+	err = protobuf.Decode(p.Block.Payload, &body)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("decoding body: %v", err)
+	}
+	body.TxResults.SetVersion(header.Version)
+	return header, &body, nil
+}
@@ -19,6 +19,7 @@ import (
 // type :TxResults:[]TxResult
 // type :InstanceID:bytes
 // type :Version:sint32
+// type :TxStatus:sint32
 // import "skipchain.proto";
 // import "onet.proto";
 // import "darc.proto";
@@ -50,6 +51,10 @@ type DataHeader struct {
 	Timestamp int64
 	// Version is the version of ByzCoin at the creation of the block.
 	Version Version `protobuf:"opt"`
+	// TxReceiptsHash is the sha256 hash of the receipts of the transactions
+	// in the body. It is required from VersionTxReceipts on, and might be
+	// empty for blocks of older versions.
+	TxReceiptsHash []byte `protobuf:"opt"`
 }
 
 // DataBody is stored in the body of the skipblock, and it's hash is stored
@@ -115,6 +120,128 @@ type AddTxResponse struct {
 	Proof *Proof `protobuf:"opt"`
 }
 
+// SimulateTxRequest asks to execute a transaction against the latest state of
+// the ledger without adding it to a block. The state of the ledger and the
+// signer counters are left unchanged.
+type SimulateTxRequest struct {
+	// Version of the protocol
+	Version Version
+	// SkipchainID is the hash of the first skipblock
+	SkipchainID skipchain.SkipBlockID
+	// Transaction to be simulated
+	Transaction ClientTransaction
+}
+
+// SimulateTxResponse holds the result of the simulation of a transaction.
+type SimulateTxResponse struct {
+	// Version of the protocol
+	Version Version
+	// Accepted is true if the transaction would have been accepted.
+	Accepted bool
+	// StateChanges holds all the state changes of the transaction, including
+	// the update of the signer counters. It is empty if the transaction is
+	// refused.
+	StateChanges StateChanges `protobuf:"opt"`
+	// Instructions holds the result of each instruction executed. If the
+	// transaction is refused, the last one holds the error of the instruction
+	// that failed.
+	Instructions []InstructionResult `protobuf:"opt"`
+	// Error message describes why the transaction failed.
+	Error string `protobuf:"opt"`
+	// BlockIndex is the index of the block of the state used for the
+	// simulation.
+	BlockIndex int
+}
+
+// InstructionResult is the outcome of the execution of one instruction.
+type InstructionResult struct {
+	StateChanges StateChanges `protobuf:"opt"`
+	// Coins are the coins returned by the contract, that are given to the
+	// next instruction.
+	Coins []Coin `protobuf:"opt"`
+	Error string `protobuf:"opt"`
+}
+
+// GetTxStatus asks a node how far a transaction went on its way into a
+// block.
+type GetTxStatus struct {
+	// Version of the protocol
+	Version Version
+	// SkipchainID is the hash of the first skipblock
+	SkipchainID skipchain.SkipBlockID
+	// TxHash is the hash of the instructions of the transaction, as returned
+	// by ClientTransaction.Instructions.Hash.
+	TxHash []byte
+}
+
+// GetTxStatusResponse holds the status of a transaction as known by the
+// node.
+type GetTxStatusResponse struct {
+	// Version of the protocol
+	Version Version
+	// Status of the transaction.
+	Status TxStatus
+	// BlockID is the block holding the transaction if it has been included
+	// or refused in a block.
+	BlockID skipchain.SkipBlockID `protobuf:"opt"`
+	// BlockIndex is the index of the block holding the transaction.
+	BlockIndex int `protobuf:"opt"`
+	// Error describes why the transaction has been rejected.
+	Error string `protobuf:"opt"`
+}
+
+// GetTxReceipt asks for the block holding the transaction with the given
+// hash, to read its receipt.
+type GetTxReceipt struct {
+	// Version of the protocol
+	Version Version
+	// SkipchainID is the hash of the first skipblock
+	SkipchainID skipchain.SkipBlockID
+	// TxHash is the hash of the instructions of the transaction, as returned
+	// by ClientTransaction.Instructions.Hash.
+	TxHash []byte
+}
+
+// GetTxReceiptResponse holds the proof that the transaction and its receipt
+// are in a block of the skipchain.
+type GetTxReceiptResponse struct {
+	// Version of the protocol
+	Version Version
+	// Proof contains the block of the transaction and the links from the
+	// genesis block to it.
+	Proof TxReceiptProof
+}
+
+// TxReceiptProof proves that the transactions of a block and their receipts
+// are in a skipchain.
+type TxReceiptProof struct {
+	// Block holds the transactions with their receipts in its body.
+	Block skipchain.SkipBlock
+	// Links are the forward links from the genesis block to Block.
+	Links []skipchain.ForwardLink
+}
+
+// ForeignChain is the data of a foreignchain instance. It follows another
+// ByzCoin chain from a trusted block, so that the proofs of that chain can be
+// verified.
+type ForeignChain struct {
+	// Genesis is the ID of the foreign chain.
+	Genesis skipchain.SkipBlockID
+	// BlockID is the latest trusted block of the foreign chain.
+	BlockID skipchain.SkipBlockID
+	// Roster is the roster of the trusted block.
+	Roster onet.Roster
+	// SignatureScheme is the scheme of the forward links of the foreign
+	// chain.
+	SignatureScheme uint32
+}
+
+// ForeignChainLinks are the forward links given to a foreignchain instance to
+// follow its chain.
+type ForeignChainLinks struct {
+	Links []skipchain.ForwardLink
+}
+
 // GetProof returns the proof that the given key is in the trie.
 type GetProof struct {
 	// Version of the protocol
@@ -139,6 +266,57 @@ type GetProofResponse struct {
 	Proof Proof
 }
 
+// GetMultiProof returns the proof that the given keys are in the trie. The
+// nodes shared by the keys are only sent once.
+type GetMultiProof struct {
+	// Version of the protocol
+	Version Version
+	// Keys are the keys we want to look up
+	Keys [][]byte
+	// ID is any block that is known to us in the skipchain, can be the genesis
+	// block or any later block. The proof returned will be starting at this block.
+	ID skipchain.SkipBlockID
+}
+
+// GetPrefixProof returns the proof of all the keys in the trie of which the
+// path starts with Prefix. The path of a key is its sha256 hash. The proof
+// also shows that there are no other keys under the prefix.
+type GetPrefixProof struct {
+	// Version of the protocol
+	Version Version
+	// Prefix of the path of the keys. It must have at least two bytes, and
+	// the request is refused if the proof holds too many nodes.
+	Prefix []byte `protobuf:"opt"`
+	// ID is any block that is known to us in the skipchain, can be the genesis
+	// block or any later block. The proof returned will be starting at this block.
+	ID skipchain.SkipBlockID
+}
+
+// GetMultiProofResponse can be used together with the Genesis block to proof
+// that the returned key/value pairs are in the trie.
+type GetMultiProofResponse struct {
+	// Version of the protocol
+	Version Version
+	// Proof contains everything necessary to prove the inclusion
+	// of the included key/value pairs given a genesis skipblock.
+	Proof MultiProof
+}
+
+// GetHistoricalProof returns the proof that the given key was in the trie
+// after the block at BlockIndex was applied. The response is a
+// GetProofResponse whose latest block is the block at BlockIndex.
+type GetHistoricalProof struct {
+	// Version of the protocol
+	Version Version
+	// Key is the key we want to look up
+	Key []byte
+	// ID is any block that is known to us in the skipchain, up to the block
+	// at BlockIndex. The proof returned will be starting at this block.
+	ID skipchain.SkipBlockID
+	// BlockIndex is the index of the block for which the state is proven.
+	BlockIndex int
+}
+
 // CheckAuthorization returns the list of actions that could be executed if the
 // signatures of the given identities are present and valid
 type CheckAuthorization struct {
@@ -166,6 +344,53 @@ type ChainConfig struct {
 	Roster          onet.Roster
 	MaxBlockSize    int
 	DarcContractIDs []string
+	// TxFees, if set, are the fees every transaction must pay.
+	TxFees *TxFees `protobuf:"opt"`
+	// RateLimit, if set, limits the number of transactions that an
+	// identity can send to a node. Every node counts the transactions it
+	// receives on its own, so an identity can send up to the limit to each
+	// node of the roster.
+	RateLimit *RateLimit `protobuf:"opt"`
+	// LeaderRotation, if set, regularly hands the leadership over to the
+	// next node of the roster. Without it, the leader only changes when it
+	// fails.
+	LeaderRotation *LeaderRotation `protobuf:"opt"`
+}
+
+// TxFees defines the fee every instruction of a transaction must pay. The fees
+// are paid with the coins left over at the end of the transaction, usually
+// fetched from a coin instance by the first instruction. The instructions on
+// the config instance are free, so that the chain can always be fixed. As a
+// refused transaction pays nothing, a node only accepts the transactions that
+// succeed on its latest state with the transactions of its pool applied.
+type TxFees struct {
+	// Coin is the name of the coins accepted for the fees.
+	Coin InstanceID
+	// Instruction is the fee of every instruction.
+	Instruction uint64
+	// Account is the coin instance receiving the fees. If it is not set, the
+	// fees are burnt.
+	Account *InstanceID `protobuf:"opt"`
+}
+
+// RateLimit defines how many transactions an identity can send to a node
+// during a time window. A node only accepts the transactions that succeed on
+// its latest state with the transactions of its pool applied, and a
+// transaction counts for all the identities signing its instructions, which
+// are then authorized by the darcs. The transactions without any signer share
+// one common limit.
+type RateLimit struct {
+	Transactions int
+	Window       time.Duration
+}
+
+// LeaderRotation defines when the leader must hand over to the next node of
+// the roster. The leader is replaced with a view-change once it created Blocks
+// blocks, or once it has been the leader for Interval, whichever comes first.
+// A zero value disables the corresponding rule.
+type LeaderRotation struct {
+	Blocks   int
+	Interval time.Duration
 }
 
 // Proof represents everything necessary to verify a given
@@ -188,6 +413,19 @@ type Proof struct {
 	Links []skipchain.ForwardLink
 }
 
+// MultiProof is the same as Proof, but for several keys or all the keys under
+// a prefix.
+type MultiProof struct {
+	// InclusionProof is the deserialized InclusionProof
+	InclusionProof trie.MultiProof
+	// Providing the latest skipblock to retrieve the Merkle tree root.
+	Latest skipchain.SkipBlock
+	// Proving the path to the latest skipblock. The first ForwardLink has an
+	// empty-sliced `From` and the genesis-block in `To`, together with the
+	// roster of the genesis-block in the `NewRoster`.
+	Links []skipchain.ForwardLink
+}
+
 // Instruction holds only one of Spawn, Invoke, or Delete
 type Instruction struct {
 	// InstanceID is either the instance that can spawn a new instance, or the instance
@@ -263,6 +501,23 @@ type ClientTransaction struct {
 type TxResult struct {
 	ClientTransaction ClientTransaction
 	Accepted          bool
+	// Receipt describes the execution of the transaction. It is missing in
+	// blocks created by nodes that don't write receipts.
+	Receipt *TxReceipt `protobuf:"opt"`
+}
+
+// TxReceipt describes the outcome of a transaction in a block.
+type TxReceipt struct {
+	// FailedInstruction is the index of the instruction that made the
+	// transaction fail, or -1 if the transaction has been accepted. It is
+	// equal to the number of instructions if the fees couldn't be paid.
+	FailedInstruction int
+	// Error describes why the transaction failed. It is the error returned
+	// to the leader and is not verified by the other nodes.
+	Error string `protobuf:"opt"`
+	// StateChangesHash is the hash of the state changes of the transaction
+	// if it has been accepted.
+	StateChangesHash []byte `protobuf:"opt"`
 }
 
 // StateChange is one new state that will be applied to the collection.
@@ -291,14 +546,37 @@ type Coin struct {
 }
 
 // StreamingRequest is a request asking the service to start streaming blocks
-// on the chain specified by ID.
+// on the chain specified by ID. If Filter is set, only the transactions and
+// the state changes matching the filter are sent. If ResumeFrom is set, the
+// blocks following this block are sent first so that a reconnecting client
+// does not miss any block. A client that falls too far behind the chain is
+// disconnected, and can resume from the last block it got.
 type StreamingRequest struct {
-	ID skipchain.SkipBlockID
-}
-
-// StreamingResponse is the reply (block) that is streamed back to the client
+	ID         skipchain.SkipBlockID
+	Filter     *StreamingFilter      `protobuf:"opt"`
+	ResumeFrom skipchain.SkipBlockID `protobuf:"opt"`
+}
+
+// StreamingFilter restricts the content of a stream. A transaction matches if
+// one of its instructions targets one of the instances, uses one of the
+// contracts or is governed by one of the darcs. A state change matches if its
+// instance, contract or darc is in the lists. Empty lists match everything.
+type StreamingFilter struct {
+	ContractIDs []string     `protobuf:"opt"`
+	InstanceIDs []InstanceID `protobuf:"opt"`
+	DarcIDs     []darc.ID    `protobuf:"opt"`
+	// OnlyAccepted drops the refused transactions.
+	OnlyAccepted bool
+}
+
+// StreamingResponse is the reply (block) that is streamed back to the client.
+// When the request has a filter, the payload of the block is removed and only
+// the matching transactions and state changes are given in TxResults and
+// StateChanges. Blocks without any matching transaction are not sent.
 type StreamingResponse struct {
-	Block *skipchain.SkipBlock
+	Block        *skipchain.SkipBlock
+	TxResults    TxResults    `protobuf:"opt"`
+	StateChanges StateChanges `protobuf:"opt"`
 }
 
 // PaginateRequest is a request to get NumPages times the consecutive list of
@@ -465,6 +743,42 @@ type ResolvedInstanceID struct {
 	InstanceID InstanceID
 }
 
+// GetInstances is a request to list the instances stored in the state trie of
+// a ByzCoin ledger. The result can be filtered by contract ID and/or by darc
+// ID, an empty filter matches every instance. The instances are returned
+// ordered by their instance ID and the response is paginated: the first page
+// is requested with an empty Cursor, the following pages with the NextCursor
+// of the previous response.
+type GetInstances struct {
+	SkipChainID skipchain.SkipBlockID
+	ContractID  string  `protobuf:"opt"`
+	DarcID      darc.ID `protobuf:"opt"`
+	Cursor      []byte  `protobuf:"opt"`
+	// PageSize is the maximum number of instances returned. If it is 0 or
+	// bigger than the maximum allowed by the service, the maximum is used.
+	PageSize int
+}
+
+// GetInstancesResponse holds one page of the instances matching the filters
+// of the request. NextCursor is empty once the last page has been returned.
+// BlockIndex is the index of the block corresponding to the state trie that
+// has been read.
+type GetInstancesResponse struct {
+	Instances  []InstanceEntry
+	NextCursor []byte `protobuf:"opt"`
+	BlockIndex int
+}
+
+// InstanceEntry is one instance of the state trie, as returned by
+// GetInstances.
+type InstanceEntry struct {
+	InstanceID InstanceID
+	Version    uint64
+	ContractID string
+	DarcID     darc.ID
+	Value      []byte
+}
+
 // DebugRequest returns the list of all byzcoins if byzcoinid is empty, else it returns
 // a dump of all instances if byzcoinid is given and exists.
 type DebugRequest struct {
@@ -499,3 +813,21 @@ type DebugRemoveRequest struct {
 	ByzCoinID []byte
 	Signature []byte
 }
+
+// CompactRequest asks the conode to remove the unreachable nodes of the state
+// trie of the given byzcoin-instance. If KeepBlocks is not 0, the bodies of
+// the blocks are pruned, except for the genesis block and the last KeepBlocks
+// blocks. It needs to be signed by the private key of the conode.
+type CompactRequest struct {
+	ByzCoinID  []byte
+	KeepBlocks int
+	Signature  []byte
+}
+
+// CompactResponse returns how much has been removed from the database.
+type CompactResponse struct {
+	TrieNodes    int
+	TrieBytes    int
+	PrunedBlocks int
+	PrunedBytes  int
+}
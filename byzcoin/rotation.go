@@ -0,0 +1,224 @@
+package byzcoin
+
+import (
+	"sync"
+	"time"
+
+	"go.dedis.ch/cothority/v3/byzcoin/viewchange"
+	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/onet/v3/network"
+	"golang.org/x/xerrors"
+)
+
+// sanityCheck makes sure the rotation has at least one rule.
+func (r LeaderRotation) sanityCheck() error {
+	if r.Blocks < 0 {
+		return xerrors.New("number of blocks is negative")
+	}
+	if r.Interval < 0 {
+		return xerrors.New("interval is negative")
+	}
+	if r.Blocks == 0 && r.Interval == 0 {
+		return xerrors.New("rotation needs a number of blocks or an interval")
+	}
+	return nil
+}
+
+// leaderTerm is the term of the leader of a chain: the number of blocks it
+// created since the last change of leader, and the timestamp of the first
+// one. It is cached so that the rotation is evaluated without walking the
+// chain back.
+type leaderTerm struct {
+	leader network.ServerIdentityID
+	latest int
+	blocks int
+	start  time.Time
+	rot    LeaderRotation
+	// retry is the delay before the rotation is requested again, while the
+	// leader is still in its term.
+	retry time.Duration
+	timer *time.Timer
+}
+
+// due returns true if the leader has been the leader for long enough.
+func (t *leaderTerm) due(now time.Time) bool {
+	if t.rot.Blocks > 0 && t.blocks >= t.rot.Blocks {
+		return true
+	}
+	return t.rot.Interval > 0 && now.Sub(t.start) >= t.rot.Interval
+}
+
+// startTimer calls f once the delay has elapsed, replacing the previous call
+// if any.
+func (t *leaderTerm) startTimer(delay time.Duration, f func()) {
+	t.stopTimer()
+	t.timer = time.AfterFunc(delay, f)
+}
+
+func (t *leaderTerm) stopTimer() {
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+}
+
+// loadLeaderTerm walks the chain back from the latest block to the first
+// block of the term of its leader. It stops earlier if the term is already
+// long enough for the rotation.
+func loadLeaderTerm(db *skipchain.SkipBlockDB, latest *skipchain.SkipBlock,
+	rot LeaderRotation, now time.Time) (*leaderTerm, error) {
+	term := &leaderTerm{
+		leader: latest.Roster.List[0].ID,
+		latest: latest.Index,
+		rot:    rot,
+	}
+	for sb := latest; sb != nil && sb.Roster.List[0].ID.Equal(term.leader); {
+		header, err := decodeBlockHeader(sb)
+		if err != nil {
+			return nil, xerrors.Errorf("decoding header: %v", err)
+		}
+		term.blocks++
+		term.start = time.Unix(0, header.Timestamp)
+		if term.due(now) || sb.Index == 0 {
+			break
+		}
+		sb = db.GetByID(sb.BackLinkIDs[0])
+	}
+	return term, nil
+}
+
+// leaderRotationDue returns true if the leader of the latest block has been
+// the leader for long enough. Its term starts with the first block of the
+// chain having it as the leader since the last change of leader.
+func leaderRotationDue(db *skipchain.SkipBlockDB, latest *skipchain.SkipBlock,
+	rot LeaderRotation, now time.Time) (bool, error) {
+	term, err := loadLeaderTerm(db, latest, rot, now)
+	if err != nil {
+		return false, err
+	}
+	return term.due(now), nil
+}
+
+// leaderTerms holds the term of the leader of each chain, with the timer
+// checking the rotation once the interval of the term has elapsed.
+type leaderTerms struct {
+	sync.Mutex
+	terms map[string]*leaderTerm
+}
+
+func newLeaderTerms() leaderTerms {
+	return leaderTerms{
+		terms: make(map[string]*leaderTerm),
+	}
+}
+
+// stop removes the term of a chain and stops its timer.
+func (lt *leaderTerms) stop(key string) {
+	lt.Lock()
+	defer lt.Unlock()
+	if term, ok := lt.terms[key]; ok {
+		term.stopTimer()
+		delete(lt.terms, key)
+	}
+}
+
+func (lt *leaderTerms) stopAll() {
+	lt.Lock()
+	defer lt.Unlock()
+	for key, term := range lt.terms {
+		term.stopTimer()
+		delete(lt.terms, key)
+	}
+}
+
+// updateLeaderTerm adds a new block to the term of the leader of its chain,
+// and starts a view-change to the next node of the roster if the rotation
+// policy of the chain asks for it. All the nodes of the roster take the same
+// decision, so the view-change gets the support it needs without any failure
+// of the leader. As a slow leader might create no block, a timer checks the
+// rotation again once the interval of the term has elapsed.
+func (s *Service) updateLeaderTerm(sb *skipchain.SkipBlock, rot *LeaderRotation,
+	retry time.Duration, now time.Time) {
+	key := string(sb.SkipChainID())
+	if rot == nil {
+		s.leaderTerms.stop(key)
+		return
+	}
+
+	s.leaderTerms.Lock()
+	defer s.leaderTerms.Unlock()
+
+	term := s.leaderTerms.terms[key]
+	if term != nil && term.latest+1 == sb.Index &&
+		term.leader.Equal(sb.Roster.List[0].ID) {
+		term.blocks++
+		term.latest = sb.Index
+		term.rot = *rot
+	} else {
+		newTerm, err := loadLeaderTerm(s.db(), sb, *rot, now)
+		if err != nil {
+			log.Errorf("%v: couldn't load the leader term: %v", s.ServerIdentity(), err)
+			return
+		}
+		if term != nil {
+			term.stopTimer()
+		}
+		term = newTerm
+		s.leaderTerms.terms[key] = term
+	}
+	term.retry = retry
+
+	timeout := func() { s.leaderTermTimeout(key) }
+	if term.due(now) {
+		s.requestLeaderRotation(sb)
+		term.startTimer(term.retry, timeout)
+	} else if term.rot.Interval > 0 {
+		term.startTimer(term.start.Add(term.rot.Interval).Sub(now), timeout)
+	} else {
+		term.stopTimer()
+	}
+}
+
+// leaderTermTimeout checks the rotation of the leader of a chain when the
+// timer of its term fires, and requests it again later while the leader is
+// still in its term.
+func (s *Service) leaderTermTimeout(key string) {
+	s.closedMutex.Lock()
+	if s.closed {
+		s.closedMutex.Unlock()
+		return
+	}
+	s.working.Add(1)
+	defer s.working.Done()
+	s.closedMutex.Unlock()
+
+	s.leaderTerms.Lock()
+	defer s.leaderTerms.Unlock()
+
+	term, ok := s.leaderTerms.terms[key]
+	if !ok || !term.due(time.Now()) {
+		return
+	}
+	latest, err := s.db().GetLatestByID(skipchain.SkipBlockID(key))
+	if err != nil {
+		log.Errorf("%v: couldn't get the latest block: %v", s.ServerIdentity(), err)
+		return
+	}
+	s.requestLeaderRotation(latest)
+	term.startTimer(term.retry, func() { s.leaderTermTimeout(key) })
+}
+
+// requestLeaderRotation starts a view-change to the next node of the roster.
+func (s *Service) requestLeaderRotation(latest *skipchain.SkipBlock) {
+	log.Lvlf2("%s: rotating the leader %s of %x", s.ServerIdentity(),
+		latest.Roster.List[0], latest.SkipChainID())
+	s.viewChangeMan.addReq(viewchange.InitReq{
+		SignerID: s.ServerIdentity().ID,
+		View: viewchange.View{
+			ID:          latest.Hash,
+			Gen:         latest.SkipChainID(),
+			LeaderIndex: 1,
+		},
+	})
+}
@@ -3,6 +3,7 @@ package byzcoin
 
 import (
 	"bytes"
+	"container/heap"
 	"crypto/sha256"
 	"encoding/binary"
 	"encoding/hex"
@@ -11,6 +12,7 @@ import (
 	"net"
 	"net/http"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -55,6 +57,19 @@ var catchupFetchBlocks = 10
 // How many DB-entries to download in one go.
 var catchupFetchDBEntries = 100
 
+// How many instances are returned at most in one page of GetInstances.
+const maxInstancesPageSize = 1000
+
+// How many keys are proven at most in one GetMultiProof request.
+const maxMultiProofKeys = 1000
+
+// How many bytes a prefix must have at least in a GetPrefixProof request, so
+// that a request doesn't cover a big part of the trie.
+var minPrefixProofLength = 2
+
+// How many nodes of the trie are sent at most in one GetPrefixProof request.
+var maxPrefixProofNodes = 10000
+
 const defaultRotationWindow time.Duration = 10
 
 const noTimeout time.Duration = 0
@@ -96,6 +111,10 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
+	err = RegisterGlobalContract(ContractForeignChainID, contractForeignChainFromBytes)
+	if err != nil {
+		panic(err)
+	}
 }
 
 // GenNonce returns a random nonce.
@@ -116,6 +135,8 @@ type Service struct {
 	// We need to store the state changes for keeping track
 	// of the history of an instance
 	stateChangeStorage *stateChangeStorage
+	// txIndex gives the block of the transactions to find their receipts
+	txIndex *txIndex
 	// notifications is used for client transaction and block notification
 	notifications bcNotifications
 
@@ -129,7 +150,7 @@ type Service struct {
 	// will slow down our service, an improvement is to go-routines to
 	// store transactions. But there is more management overhead, e.g.,
 	// restarting after shutdown, answer getTxs requests and so on.
-	txBuffer txBuffer
+	txPool txPool
 
 	heartbeats             heartbeats
 	heartbeatsTimeout      chan string
@@ -164,8 +185,15 @@ type Service struct {
 
 	rotationWindow time.Duration
 
+	// leaderTerms caches the term of the leader of each chain for the
+	// rotation policy.
+	leaderTerms leaderTerms
+
 	txErrorBuf ringBuf
 
+	// rateLimiter counts the transactions sent by each identity.
+	rateLimiter rateLimiter
+
 	// defaultVersion is the new version to use for new
 	// ByzCoin chains.
 	defaultVersion     Version
@@ -422,11 +450,25 @@ func (s *Service) AddTransaction(req *AddTxRequest) (*AddTxResponse, error) {
 		return nil, xerrors.New("transaction too large")
 	}
 
+	config, err := s.LoadConfig(req.SkipchainID)
+	if err != nil {
+		return nil, xerrors.Errorf("loading config: %v", err)
+	}
+	if config.TxFees != nil || config.RateLimit != nil {
+		// A refused transaction doesn't pay any fee, so it must not enter
+		// the pool, else it would fill the blocks for free. The rate limit
+		// only counts the signers authorized by the darcs, so it needs the
+		// transaction to be verified too.
+		if err := s.checkPoolTx(req.SkipchainID, req.Transaction); err != nil {
+			return nil, xerrors.Errorf("transaction refused: %v", err)
+		}
+	}
+
 	for i, instr := range req.Transaction.Instructions {
 		log.Lvlf2("Instruction[%d]: %s on instance ID %s", i, instr.Action(), instr.InstanceID.String())
 	}
 
-	// Note to my future self: s.txBuffer.add used to be out here. It used to work
+	// Note to my future self: s.txPool.add used to be out here. It used to work
 	// even. But while investigating other race conditions, we realized that
 	// IF there will be a wait channel, THEN it must exist before the call to add().
 	// If add() comes first, there's a race condition where the block could theoretically
@@ -448,7 +490,10 @@ func (s *Service) AddTransaction(req *AddTxRequest) (*AddTxResponse, error) {
 		ch := s.notifications.registerForBlocks()
 		defer s.notifications.unregisterForBlocks(ch)
 
-		s.txBuffer.add(string(req.SkipchainID), req.Transaction)
+		err = s.addToPool(req.SkipchainID, req.Transaction, config.RateLimit)
+		if err != nil {
+			return nil, err
+		}
 
 		// In case we don't have any blocks, because there are no transactions,
 		// have a hard timeout in twice the minimal expected time to create the
@@ -476,12 +521,106 @@ func (s *Service) AddTransaction(req *AddTxRequest) (*AddTxResponse, error) {
 			}
 		}
 	} else {
-		s.txBuffer.add(string(req.SkipchainID), req.Transaction)
+		err = s.addToPool(req.SkipchainID, req.Transaction, config.RateLimit)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return &AddTxResponse{Version: CurrentVersion}, nil
 }
 
+// checkPoolTx executes the transaction on the latest state with the
+// transactions waiting in the pool applied, so that a signer can send several
+// transactions without waiting for the blocks.
+func (s *Service) checkPoolTx(scID skipchain.SkipBlockID, tx ClientTransaction) error {
+	st, err := s.getStateTrie(scID)
+	if err != nil {
+		return xerrors.Errorf("getting trie: %v", err)
+	}
+	sst := st.MakeStagingStateTrie()
+	hash := tx.Instructions.Hash()
+	for _, ptx := range s.txPool.waiting(string(scID)) {
+		if bytes.Equal(ptx.Instructions.Hash(), hash) {
+			continue
+		}
+		// The transactions of the pool that fail will be refused by the
+		// leader too, so they don't change the state.
+		if _, next, _, err := s.executeOneTx(sst, ptx, scID); err == nil {
+			sst = next
+		}
+	}
+	_, _, _, err = s.executeOneTx(sst, tx, scID)
+	return err
+}
+
+// addToPool puts the transaction in the pool of the chain if its signers are
+// below the rate limit, which is only updated once the transaction is in the
+// pool.
+func (s *Service) addToPool(scID skipchain.SkipBlockID, tx ClientTransaction, limit *RateLimit) error {
+	now := time.Now()
+	var signers []string
+	if limit != nil {
+		signers = txSigners(tx)
+		if err := s.rateLimiter.allow(scID, signers, *limit, now); err != nil {
+			return xerrors.Errorf("rate limit: %v", err)
+		}
+	}
+	if err := s.txPool.add(string(scID), tx, now); err != nil {
+		if limit != nil {
+			s.rateLimiter.forget(scID, signers, now)
+		}
+		return xerrors.Errorf("adding transaction to the pool: %v", err)
+	}
+	return nil
+}
+
+// SimulateTransaction executes the transaction on a staging copy of the
+// latest state trie, the same way as when a block is created, and returns
+// its outcome without proposing it to the other nodes.
+func (s *Service) SimulateTransaction(req *SimulateTxRequest) (*SimulateTxResponse, error) {
+	if len(req.Transaction.Instructions) == 0 {
+		return nil, xerrors.New("no instructions to simulate")
+	}
+
+	gen := s.db().GetByID(req.SkipchainID)
+	if gen == nil || gen.Index != 0 {
+		return nil, xerrors.New("skipchain ID is does not exist")
+	}
+
+	latest, err := s.db().GetLatest(gen)
+	if err != nil {
+		return nil, xerrors.Errorf("reading latest block: %v", err)
+	}
+
+	header, err := decodeBlockHeader(latest)
+	if err != nil {
+		return nil, xerrors.Errorf("decoding header: %v", err)
+	}
+	req.Transaction.Instructions.SetVersion(header.Version)
+
+	st, err := s.getStateTrie(req.SkipchainID)
+	if err != nil {
+		return nil, xerrors.Errorf("getting trie: %v", err)
+	}
+	sst := st.MakeStagingStateTrie()
+
+	resp := &SimulateTxResponse{
+		Version:    CurrentVersion,
+		BlockIndex: sst.GetIndex(),
+	}
+	states, _, results, err := s.executeOneTx(sst, req.Transaction, req.SkipchainID)
+	resp.Instructions = results
+	if err != nil {
+		resp.Error = err.Error()
+		return resp, nil
+	}
+	resp.Accepted = true
+	resp.StateChanges = states
+
+	return resp, nil
+}
+
 // GetProof searches for a key and returns a proof of the
 // presence or the absence of this key.
 func (s *Service) GetProof(req *GetProof) (*GetProofResponse, error) {
@@ -531,6 +670,68 @@ func (s *Service) GetProof(req *GetProof) (*GetProofResponse, error) {
 	}, nil
 }
 
+// GetMultiProof searches for the keys and returns a proof of the
+// presence or the absence of all of them in the state trie.
+func (s *Service) GetMultiProof(req *GetMultiProof) (*GetMultiProofResponse, error) {
+	if len(req.Keys) == 0 || len(req.Keys) > maxMultiProofKeys {
+		return nil, xerrors.Errorf("number of keys must be between 1 and %d",
+			maxMultiProofKeys)
+	}
+	return s.getMultiProof(req.ID, func(st *stateTrie, sb *skipchain.SkipBlock) (*MultiProof, error) {
+		log.Lvlf2("%s: Returning proof for %d keys from chain %x at index %v", s.ServerIdentity(), len(req.Keys), sb.SkipChainID(), sb.Index)
+		return NewMultiProof(&st.Trie, st.GetIndex(), s.db(), req.ID, req.Keys)
+	})
+}
+
+// GetPrefixProof returns a proof of all the keys of which the path in the
+// state trie starts with the prefix.
+func (s *Service) GetPrefixProof(req *GetPrefixProof) (*GetMultiProofResponse, error) {
+	if len(req.Prefix) < minPrefixProofLength {
+		return nil, xerrors.Errorf("prefix must have at least %d bytes",
+			minPrefixProofLength)
+	}
+	return s.getMultiProof(req.ID, func(st *stateTrie, sb *skipchain.SkipBlock) (*MultiProof, error) {
+		log.Lvlf2("%s: Returning proof for prefix %x from chain %x at index %v", s.ServerIdentity(), req.Prefix, sb.SkipChainID(), sb.Index)
+		return NewPrefixProof(&st.Trie, st.GetIndex(), s.db(), req.ID, req.Prefix, maxPrefixProofNodes)
+	})
+}
+
+// getMultiProof takes the same locks as GetProof and calls newProof with the
+// state trie of the chain of the block.
+func (s *Service) getMultiProof(id skipchain.SkipBlockID,
+	newProof func(*stateTrie, *skipchain.SkipBlock) (*MultiProof, error)) (*GetMultiProofResponse, error) {
+	s.catchingLock.Lock()
+	s.updateTrieLock.Lock()
+
+	defer func() {
+		s.updateTrieLock.Unlock()
+		s.catchingLock.Unlock()
+	}()
+
+	s.closedMutex.Lock()
+	defer s.closedMutex.Unlock()
+	if s.closed {
+		return nil, xerrors.New("cannot get proof while in closed state")
+	}
+
+	sb := s.db().GetByID(id)
+	if sb == nil {
+		return nil, xerrors.New("cannot find skipblock while getting proof")
+	}
+	st, err := s.getStateTrie(sb.SkipChainID())
+	if err != nil {
+		return nil, xerrors.Errorf("getting state trie: %w", err)
+	}
+	proof, err := newProof(st, sb)
+	if err != nil {
+		return nil, xerrors.Errorf("making proof: %w", err)
+	}
+	return &GetMultiProofResponse{
+		Version: CurrentVersion,
+		Proof:   *proof,
+	}, nil
+}
+
 // CheckAuthorization verifies whether a given combination of identities can
 // fulfill a given rule of a given darc. Because all darcs are now used in
 // an online fashion, we need to offer this check.
@@ -604,6 +805,93 @@ func (s *Service) GetSignerCounters(req *GetSignerCounters) (*GetSignerCountersR
 	return &resp, nil
 }
 
+// GetTxStatus returns what the node knows about the given transaction: if it
+// is waiting for a block, if it is in a block or if it has been rejected.
+func (s *Service) GetTxStatus(req *GetTxStatus) (*GetTxStatusResponse, error) {
+	if s.db().GetByID(req.SkipchainID) == nil {
+		return nil, xerrors.New("unknown skipchain")
+	}
+
+	rec := s.txPool.status(string(req.SkipchainID), req.TxHash)
+	return &GetTxStatusResponse{
+		Version:    CurrentVersion,
+		Status:     rec.status,
+		BlockID:    rec.blockID,
+		BlockIndex: rec.blockIndex,
+		Error:      rec.err,
+	}, nil
+}
+
+// GetTxReceipt returns the block holding the transaction with its receipt,
+// and the links from the genesis block to it.
+func (s *Service) GetTxReceipt(req *GetTxReceipt) (*GetTxReceiptResponse, error) {
+	if s.db().GetByID(req.SkipchainID) == nil {
+		return nil, xerrors.New("unknown skipchain")
+	}
+
+	id, err := s.txIndex.get(req.SkipchainID, req.TxHash)
+	if err != nil {
+		return nil, xerrors.Errorf("looking up transaction: %v", err)
+	}
+	if id == nil {
+		return nil, xerrors.New("transaction not found")
+	}
+	sb := s.db().GetByID(id)
+	if sb == nil {
+		return nil, xerrors.New("missing block of the transaction")
+	}
+	if len(sb.Payload) == 0 {
+		return nil, cothority.WrapError(errPrunedBlock)
+	}
+
+	block, links, err := proofLinks(s.db(), req.SkipchainID, sb.Index)
+	if err != nil {
+		return nil, xerrors.Errorf("getting links: %v", err)
+	}
+	return &GetTxReceiptResponse{
+		Version: CurrentVersion,
+		Proof: TxReceiptProof{
+			Block: *block,
+			Links: links,
+		},
+	}, nil
+}
+
+// indexChain adds to the transaction index the blocks of the chain that have
+// not been added by updateTrieCallback, which are the blocks stored before the
+// index existed and the blocks stored by a catch-up. It follows the chain from
+// the latest block indexed in order, and stops at the first missing block.
+// The blocks whose transactions have been pruned cannot be indexed.
+func (s *Service) indexChain(scID skipchain.SkipBlockID) error {
+	from, err := s.txIndex.indexed(scID)
+	if err != nil {
+		return xerrors.Errorf("reading index: %v", err)
+	}
+	if from == nil {
+		from = scID
+	}
+	sb := s.db().GetByID(from)
+	for sb != nil {
+		if len(sb.Payload) > 0 {
+			var body DataBody
+			if err := protobuf.Decode(sb.Payload, &body); err != nil {
+				return xerrors.Errorf("decoding body: %v", err)
+			}
+			if err := s.txIndex.add(sb, body.TxResults); err != nil {
+				return xerrors.Errorf("indexing block %d: %v", sb.Index, err)
+			}
+		}
+		if err := s.txIndex.setIndexed(scID, sb.Hash); err != nil {
+			return xerrors.Errorf("storing index: %v", err)
+		}
+		if len(sb.ForwardLink) == 0 {
+			break
+		}
+		sb = s.db().GetByID(sb.ForwardLink[0].To)
+	}
+	return nil
+}
+
 // DownloadState creates a snapshot of the current state and then returns the
 // instances in small chunks.
 func (s *Service) DownloadState(req *DownloadState) (resp *DownloadStateResponse, err error) {
@@ -795,6 +1083,98 @@ func (s *Service) ResolveInstanceID(req *ResolveInstanceID) (*ResolvedInstanceID
 	return &ResolvedInstanceID{valStruct.IID}, nil
 }
 
+// GetInstances returns a page of the instances stored in the state trie,
+// filtered by contract ID and/or darc ID. The instances are ordered by their
+// ID so that the cursor of a page stays valid when the trie is updated in
+// between two requests.
+func (s *Service) GetInstances(req *GetInstances) (*GetInstancesResponse, error) {
+	st, err := s.GetReadOnlyStateTrie(req.SkipChainID)
+	if err != nil {
+		return nil, xerrors.Errorf("getting trie: %v", err)
+	}
+
+	pageSize := req.PageSize
+	if pageSize <= 0 || pageSize > maxInstancesPageSize {
+		pageSize = maxInstancesPageSize
+	}
+
+	// The trie isn't ordered by key, so every page needs a walk over the
+	// whole trie. Only the smallest keys above the cursor are kept, one
+	// more than the page size to know if there is a next page.
+	page := &instancePage{}
+	err = st.ForEach(func(k, v []byte) error {
+		if len(k) != 32 || bytes.Compare(k, req.Cursor) <= 0 {
+			return nil
+		}
+		if page.Len() > pageSize && bytes.Compare(k, page.entries[0].InstanceID[:]) >= 0 {
+			return nil
+		}
+		body, err := decodeStateChangeBody(v)
+		if err != nil {
+			return xerrors.Errorf("decoding instance %x: %v", k, err)
+		}
+		// Signer counters are stored without contract ID and are not
+		// instances.
+		if body.ContractID == "" {
+			return nil
+		}
+		if req.ContractID != "" && body.ContractID != req.ContractID {
+			return nil
+		}
+		if len(req.DarcID) > 0 && !body.DarcID.Equal(req.DarcID) {
+			return nil
+		}
+		heap.Push(page, InstanceEntry{
+			InstanceID: NewInstanceID(k),
+			Version:    body.Version,
+			ContractID: body.ContractID,
+			DarcID:     body.DarcID,
+			Value:      body.Value,
+		})
+		if page.Len() > pageSize+1 {
+			heap.Pop(page)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("iterating trie: %v", err)
+	}
+
+	entries := page.entries
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].InstanceID[:], entries[j].InstanceID[:]) < 0
+	})
+
+	resp := &GetInstancesResponse{BlockIndex: st.GetIndex()}
+	if len(entries) > pageSize {
+		entries = entries[:pageSize]
+		resp.NextCursor = append([]byte{}, entries[pageSize-1].InstanceID[:]...)
+	}
+	resp.Instances = entries
+
+	return resp, nil
+}
+
+// instancePage is a max-heap of instance entries ordered by instance ID, used
+// to keep the smallest IDs of a page.
+type instancePage struct {
+	entries []InstanceEntry
+}
+
+func (p *instancePage) Len() int { return len(p.entries) }
+func (p *instancePage) Less(i, j int) bool {
+	return bytes.Compare(p.entries[i].InstanceID[:], p.entries[j].InstanceID[:]) > 0
+}
+func (p *instancePage) Swap(i, j int) { p.entries[i], p.entries[j] = p.entries[j], p.entries[i] }
+func (p *instancePage) Push(x interface{}) {
+	p.entries = append(p.entries, x.(InstanceEntry))
+}
+func (p *instancePage) Pop() interface{} {
+	e := p.entries[len(p.entries)-1]
+	p.entries = p.entries[:len(p.entries)-1]
+	return e
+}
+
 type leafNode struct {
 	Prefix []bool
 	Key    []byte
@@ -913,6 +1293,9 @@ func (s *Service) DebugRemove(req *DebugRemoveRequest) (*DebugResponse, error) {
 			return nil, xerrors.Errorf("deleting bucket: %v", err)
 		}
 		delete(s.stateTries, idStr)
+		if err := s.txIndex.remove(req.ByzCoinID); err != nil {
+			log.Error("couldn't remove the transaction index:", err)
+		}
 		err = s.db().RemoveSkipchain(req.ByzCoinID)
 		if err != nil {
 			log.Error("couldn't remove the whole chain:", err)
@@ -1036,6 +1419,9 @@ func (s *Service) createNewBlock(scID skipchain.SkipBlockID, r *onet.Roster, tx
 		Timestamp:             time.Now().UnixNano(),
 		Version:               version,
 	}
+	if version >= VersionTxReceipts {
+		header.TxReceiptsHash = txRes.ReceiptsHash()
+	}
 	sb.Data, err = protobuf.Encode(header)
 	if err != nil {
 		return nil, xerrors.Errorf("Couldn't marshal data: %v", err)
@@ -1112,13 +1498,17 @@ func (s *Service) createUpgradeVersionBlock(scID skipchain.SkipBlockID, version
 		return nil, xerrors.Errorf("Couldn't marshal data: %v", err)
 	}
 
-	sb.Data, err = protobuf.Encode(&DataHeader{
+	header := &DataHeader{
 		TrieRoot:              mr,
 		ClientTransactionHash: txRes.Hash(),
 		StateChangesHash:      scs.Hash(),
 		Timestamp:             time.Now().UnixNano(),
 		Version:               version,
-	})
+	}
+	if version >= VersionTxReceipts {
+		header.TxReceiptsHash = txRes.ReceiptsHash()
+	}
+	sb.Data, err = protobuf.Encode(header)
 	if err != nil {
 		return nil, xerrors.Errorf("Couldn't marshal data: %v", err)
 	}
@@ -1238,6 +1628,12 @@ func (s *Service) downloadDB(sb *skipchain.SkipBlock) error {
 		for _, sb := range chain.Update {
 			log.Lvlf2("Storing block %d: %x", sb.Index, sb.CalculateHash())
 			s.db().Store(sb)
+			var body DataBody
+			if err := protobuf.Decode(sb.Payload, &body); err == nil {
+				if err := s.txIndex.add(sb, body.TxResults); err != nil {
+					log.Error(s.ServerIdentity(), "couldn't index the transactions:", err)
+				}
+			}
 		}
 		log.Lvlf1("%s: successfully downloaded database for chain %s up to block %d/%d", s.ServerIdentity(),
 			idStr, sb.Index, st.GetIndex())
@@ -1595,6 +1991,10 @@ func (s *Service) updateTrieCallback(sbID skipchain.SkipBlockID) error {
 			"mean that the db is broken.")
 	}
 
+	if err = s.txIndex.add(sb, body.TxResults); err != nil {
+		log.Error(s.ServerIdentity(), "couldn't index the transactions:", err)
+	}
+
 	// If we are adding a genesis block, then look into it for the darc ID
 	// and add it to the darcToSc hash map.
 	if sb.Index == 0 {
@@ -1678,22 +2078,31 @@ func (s *Service) updateTrieCallback(sbID skipchain.SkipBlockID) error {
 			s.viewChangeMan.add(s.sendViewChangeReq, s.sendNewView, s.isLeader, string(sb.SkipChainID()))
 			s.viewChangeMan.start(s.ServerIdentity().ID, sb.SkipChainID(), initialDur, s.getFaultThreshold(sb.Hash))
 		}
+
+		// The leader might have to hand over even if it is alive.
+		s.updateLeaderTerm(sb, bcConfig.LeaderRotation, interval, time.Now())
 	} else {
 		if s.heartbeats.exists(scIDstr) {
 			log.Lvlf2("%s stopping heartbeat monitor for %x with window %v", s.ServerIdentity(), sb.SkipChainID(), interval*s.rotationWindow)
 			s.heartbeats.stop(scIDstr)
 		}
+		s.leaderTerms.stop(scIDstr)
 	}
 	if !nodeInNew && s.viewChangeMan.started(sb.SkipChainID()) {
 		log.Lvlf2("%s not in roster, but viewChangeMonitor started - stopping now for %x", s.ServerIdentity(), sb.SkipChainID())
 		s.viewChangeMan.stop(sb.SkipChainID())
 	}
 
+	// Record the status of the transactions before notifying the waiting
+	// channels, so that it is up-to-date when the clients ask for it.
+	s.txPool.blockAdded(sb, body.TxResults, isViewChangeTx(body.TxResults) != nil,
+		s.txRefusalReason, time.Now())
+
 	// Notify all waiting channels for processed ClientTransactions.
 	s.notifications.informBlock(sb, body.TxResults)
 
 	// At this point everything should be stored.
-	s.streamingMan.notify(string(sb.SkipChainID()), sb)
+	s.streamingMan.notify(string(sb.SkipChainID()), sb, body.TxResults, scs)
 
 	log.Lvlf2("%s updated trie for %x with root %x", s.ServerIdentity(), sb.SkipChainID(), st.GetRoot())
 	return nil
@@ -1997,6 +2406,25 @@ func (s *Service) verifySkipBlock(newID []byte, newSB *skipchain.SkipBlock) bool
 		}
 	}
 
+	// Blocks of older versions might have no receipts, else the receipts
+	// must match the local execution of the transactions.
+	if header.Version >= VersionTxReceipts && len(header.TxReceiptsHash) == 0 {
+		log.Lvl2(s.ServerIdentity(), "Transaction receipts hash is missing")
+		return false
+	}
+	if len(header.TxReceiptsHash) > 0 {
+		if !bytes.Equal(header.TxReceiptsHash, body.TxResults.ReceiptsHash()) {
+			log.Lvl2(s.ServerIdentity(), "Transaction receipts hash doesn't verify")
+			return false
+		}
+		for i := range txOut {
+			if !txOut[i].Receipt.sameOutcome(body.TxResults[i].Receipt) {
+				log.Lvl2(s.ServerIdentity(), "Transaction receipt mismatch on tx", i)
+				return false
+			}
+		}
+	}
+
 	// Check that the hashes in DataHeader are right.
 	if bytes.Compare(header.ClientTransactionHash, txOut.Hash()) != 0 {
 		log.Lvl2(s.ServerIdentity(), "Client Transaction Hash doesn't verify")
@@ -2109,8 +2537,11 @@ func (s *Service) createStateChanges(sst *stagingStateTrie, scID skipchain.SkipB
 
 		var sstTempC *stagingStateTrie
 		var statesTemp StateChanges
-		statesTemp, sstTempC, err = s.processOneTx(sstTemp, tx.ClientTransaction, scID)
+		var results []InstructionResult
+		statesTemp, sstTempC, results, err = s.executeOneTx(sstTemp, tx.ClientTransaction, scID)
+		tx.Receipt = newTxReceipt(statesTemp, results, err)
 		if err != nil {
+			s.addError(tx.ClientTransaction, err)
 			tx.Accepted = false
 			txOut = append(txOut, tx)
 			log.Error(s.ServerIdentity(), err)
@@ -2168,11 +2599,35 @@ func (s *Service) addError(tx ClientTransaction, err error) {
 	s.txErrorBuf.add(tx.Instructions.HashWithSignatures(), err.Error())
 }
 
+// txRefusalReason returns the error stored for a transaction refused in a
+// block.
+func (s *Service) txRefusalReason(tx ClientTransaction) string {
+	errMsg, exists := s.txErrorBuf.get(tx.Instructions.HashWithSignatures())
+	if !exists {
+		return "transaction is in block, but got refused for unknown error"
+	}
+	return errMsg
+}
+
 // processOneTx takes one transaction and creates a set of StateChanges. It
 // also returns the temporary StateTrie with the StateChanges applied. Any data
 // from the trie should be read from sst and not the service.
 func (s *Service) processOneTx(sst *stagingStateTrie, tx ClientTransaction,
 	scID skipchain.SkipBlockID) (StateChanges, *stagingStateTrie, error) {
+	states, sst, _, err := s.executeOneTx(sst, tx, scID)
+	if err != nil {
+		s.addError(tx, err)
+		return nil, nil, err
+	}
+	return states, sst, nil
+}
+
+// executeOneTx does the work of processOneTx and also returns the result of
+// each instruction. In case of error, the last result is the one of the
+// instruction that failed. The error is not stored, so that it can be used
+// to simulate a transaction.
+func (s *Service) executeOneTx(sst *stagingStateTrie, tx ClientTransaction,
+	scID skipchain.SkipBlockID) (StateChanges, *stagingStateTrie, []InstructionResult, error) {
 
 	// Make a new trie for each instruction. If the instruction is
 	// sucessfully implemented and changes applied, then keep it
@@ -2180,7 +2635,12 @@ func (s *Service) processOneTx(sst *stagingStateTrie, tx ClientTransaction,
 	sst = sst.Clone()
 	h := tx.Instructions.Hash()
 	var statesTemp StateChanges
+	var results []InstructionResult
 	var cin []Coin
+	fail := func(err error) (StateChanges, *stagingStateTrie, []InstructionResult, error) {
+		results = append(results, InstructionResult{Error: err.Error()})
+		return nil, nil, results, err
+	}
 	for _, instr := range tx.Instructions {
 		scs, cout, err := s.executeInstruction(sst, cin, instr, h, scID)
 		if err != nil {
@@ -2188,18 +2648,14 @@ func (s *Service) processOneTx(sst *stagingStateTrie, tx ClientTransaction,
 			if err2 != nil {
 				err = xerrors.Errorf("%v - while getting value: %v", err, err2)
 			}
-			err = xerrors.Errorf("%s Contract %s got %x and returned error: %v",
-				s.ServerIdentity(), cid, instr.Hash(), err)
-			s.addError(tx, err)
-			return nil, nil, err
+			return fail(xerrors.Errorf("%s Contract %s got %x and returned error: %v",
+				s.ServerIdentity(), cid, instr.Hash(), err))
 		}
 
 		counterScs, err := incrementSignerCounters(sst, instr.SignerIdentities)
 		if err != nil {
-			err = xerrors.Errorf("%s failed to update signature counters: %v",
-				s.ServerIdentity(), err)
-			s.addError(tx, err)
-			return nil, nil, err
+			return fail(xerrors.Errorf("%s failed to update signature counters: %v",
+				s.ServerIdentity(), err))
 		}
 
 		// Verify the validity of the state-changes:
@@ -2226,41 +2682,58 @@ func (s *Service) processOneTx(sst *stagingStateTrie, tx ClientTransaction,
 				var contractID string
 				_, _, contractID, _, err = sst.GetValues(instr.InstanceID.Slice())
 				if err != nil {
-					err = xerrors.Errorf("%s couldn't get contractID from the "+
+					return fail(xerrors.Errorf("%s couldn't get contractID from the "+
 						"following instruction: %x (with instanceID %x)",
-						s.ServerIdentity(), instr.Hash(), instr.InstanceID.Slice())
-					s.addError(tx, err)
-					return nil, nil, err
+						s.ServerIdentity(), instr.Hash(), instr.InstanceID.Slice()))
 				}
-				err = xerrors.Errorf("%s: contract %s %s %x", s.ServerIdentity(),
-					contractID, reason, sc.InstanceID)
-				s.addError(tx, err)
-				return nil, nil, err
+				return fail(xerrors.Errorf("%s: contract %s %s %x", s.ServerIdentity(),
+					contractID, reason, sc.InstanceID))
 			}
 			log.Lvlf2("StateChange %s for id %x - contract: %s", sc.StateAction,
 				sc.InstanceID, sc.ContractID)
 			err = sst.StoreAll(StateChanges{sc})
 			if err != nil {
-				err = xerrors.Errorf("%s StoreAll failed: %v", s.ServerIdentity(), err)
-				s.addError(tx, err)
-				return nil, nil, err
+				return fail(xerrors.Errorf("%s StoreAll failed: %v", s.ServerIdentity(), err))
 			}
 		}
 		if err = sst.StoreAll(counterScs); err != nil {
-			err = xerrors.Errorf("%s StoreAll failed to add counter changes: %v",
-				s.ServerIdentity(), err)
-			s.addError(tx, err)
-			return nil, nil, err
+			return fail(xerrors.Errorf("%s StoreAll failed to add counter changes: %v",
+				s.ServerIdentity(), err))
+		}
+		spawnScs, err := spawnIndexStateChanges(sst, instr, scs)
+		if err != nil {
+			return fail(xerrors.Errorf("%s failed to store the spawn index: %v",
+				s.ServerIdentity(), err))
 		}
+		if err = sst.StoreAll(spawnScs); err != nil {
+			return fail(xerrors.Errorf("%s StoreAll failed to add spawn index changes: %v",
+				s.ServerIdentity(), err))
+		}
+		counterScs = append(counterScs, spawnScs...)
 		statesTemp = append(statesTemp, scs...)
 		statesTemp = append(statesTemp, counterScs...)
+		results = append(results, InstructionResult{
+			StateChanges: append(scs, counterScs...),
+			Coins:        cout,
+		})
 		cin = cout
 	}
+
+	feeScs, cin, err := payTxFees(sst, tx.Instructions, cin)
+	if err != nil {
+		return fail(xerrors.Errorf("%s failed to pay the fees: %v", s.ServerIdentity(), err))
+	}
+	if err = sst.StoreAll(feeScs); err != nil {
+		return fail(xerrors.Errorf("%s StoreAll failed to add fee changes: %v",
+			s.ServerIdentity(), err))
+	}
+	statesTemp = append(statesTemp, feeScs...)
+
 	if len(cin) != 0 {
 		log.Lvl2(s.ServerIdentity(), "Leftover coins detected, discarding.")
 	}
 
-	return statesTemp, sst, nil
+	return statesTemp, sst, results, nil
 }
 
 // GetContractConstructor gets the contract constructor of the contract
@@ -2451,7 +2924,7 @@ func (s *Service) getTxs(leader *network.ServerIdentity, roster *onet.Roster, sc
 
 	s.heartbeats.beat(string(scID))
 
-	return s.txBuffer.take(string(scID), maxNumTxs)
+	return s.txPool.take(string(scID), maxNumTxs, time.Now())
 }
 
 // loadNonceFromTxs gets the nonce from a TxResults. This only works for the genesis-block.
@@ -2492,6 +2965,7 @@ func (s *Service) cleanupGoroutines() {
 	log.Lvl1(s.ServerIdentity(), "closing go-routines")
 	s.heartbeats.closeAll()
 	s.closeLeaderMonitorChan <- true
+	s.leaderTerms.stopAll()
 	s.viewChangeMan.closeAll()
 	s.streamingMan.stopAll()
 
@@ -2617,6 +3091,17 @@ func (s *Service) startAllChains() error {
 			}
 		}
 
+		// The blocks stored before the transaction index existed, or by
+		// a catch-up, are added to the index.
+		for _, gen := range gasr.IDs {
+			if !s.hasByzCoinVerification(gen) {
+				continue
+			}
+			if err := s.indexChain(gen); err != nil {
+				log.Error(s.ServerIdentity(), "couldn't index the transactions:", err)
+			}
+		}
+
 		go s.monitorLeaderFailure()
 	}()
 
@@ -2690,6 +3175,13 @@ func (s *Service) startChain(genesisID skipchain.SkipBlockID) error {
 	s.viewChangeMan.add(s.sendViewChangeReq, s.sendNewView, s.isLeader, string(genesisID))
 	s.viewChangeMan.start(s.ServerIdentity().ID, genesisID, initialDur, s.getFaultThreshold(genesisID))
 
+	// resume the term of the leader
+	config, err := s.LoadConfig(genesisID)
+	if err != nil {
+		return xerrors.Errorf("loading config: %v", err)
+	}
+	s.updateLeaderTerm(latest, config.LeaderRotation, interval, time.Now())
+
 	return nil
 }
 
@@ -2822,6 +3314,9 @@ func (s *Service) repairStateTrie(from *skipchain.SkipBlock, st *stateTrie) erro
 		if err := st.VerifiedStoreAll(scs, from.Index, header.Version, header.TrieRoot); err != nil {
 			return xerrors.Errorf("storing state changes: %v", err)
 		}
+		if err := s.txIndex.add(from, body.TxResults); err != nil {
+			log.Error(s.ServerIdentity(), "couldn't index the transactions:", err)
+		}
 		cnt++
 	}
 
@@ -2850,11 +3345,12 @@ func newService(c *onet.Context) (onet.Service, error) {
 	s := &Service{
 		ServiceProcessor:       onet.NewServiceProcessor(c),
 		contracts:              globalContractRegistry.clone(),
-		txBuffer:               newTxBuffer(),
+		txPool:                 newTxPool(),
 		storage:                &bcStorage{},
 		darcToSc:               make(map[string]skipchain.SkipBlockID),
 		stateChangeCache:       newStateChangeCache(),
 		stateChangeStorage:     newStateChangeStorage(c),
+		txIndex:                newTxIndex(c),
 		heartbeatsTimeout:      make(chan string, 1),
 		closeLeaderMonitorChan: make(chan bool, 1),
 		heartbeats:             newHeartbeats(),
@@ -2863,6 +3359,7 @@ func newService(c *onet.Context) (onet.Service, error) {
 		closed:                 true,
 		catchingUpHistory:      make(map[string]time.Time),
 		rotationWindow:         defaultRotationWindow,
+		leaderTerms:            newLeaderTerms(),
 		defaultVersion:         CurrentVersion,
 		// We need a large enough buffer for all errors in 2 blocks
 		// where each block might be 1 MB in size and each tx is 1 KB.
@@ -2873,17 +3370,25 @@ func newService(c *onet.Context) (onet.Service, error) {
 		s.GetAllByzCoinIDs,
 		s.CreateGenesisBlock,
 		s.AddTransaction,
+		s.SimulateTransaction,
 		s.GetProof,
+		s.GetHistoricalProof,
+		s.GetMultiProof,
+		s.GetPrefixProof,
 		s.CheckAuthorization,
 		s.GetSignerCounters,
+		s.GetTxStatus,
+		s.GetTxReceipt,
 		s.DownloadState,
 		s.GetInstanceVersion,
 		s.GetLastInstanceVersion,
 		s.GetAllInstanceVersion,
 		s.CheckStateChangeValidity,
 		s.ResolveInstanceID,
+		s.GetInstances,
 		s.Debug,
-		s.DebugRemove)
+		s.DebugRemove,
+		s.Compact)
 	if err != nil {
 		return nil, err
 	}
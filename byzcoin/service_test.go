@@ -585,6 +585,317 @@ func TestService_AutomaticVersionUpgrade(t *testing.T) {
 	t.Fail()
 }
 
+func TestService_SimulateTransaction(t *testing.T) {
+	s := newSer(t, 1, testInterval)
+	defer s.local.CloseAll()
+
+	tx, err := createOneClientTx(s.darc.GetBaseID(), dummyContract, s.value, s.signer)
+	require.NoError(t, err)
+
+	resp, err := s.service().SimulateTransaction(&SimulateTxRequest{
+		Version:     CurrentVersion,
+		SkipchainID: s.genesis.SkipChainID(),
+		Transaction: tx,
+	})
+	require.NoError(t, err)
+	require.True(t, resp.Accepted)
+	require.Empty(t, resp.Error)
+	require.Equal(t, 0, resp.BlockIndex)
+	// One state change for the instance and one for the signer counter.
+	require.Equal(t, 2, len(resp.StateChanges))
+	require.Equal(t, 1, len(resp.Instructions))
+	require.Equal(t, resp.StateChanges, resp.Instructions[0].StateChanges)
+	require.Equal(t, Create, resp.StateChanges[0].StateAction)
+	require.Equal(t, s.value, resp.StateChanges[0].Value)
+
+	// Nothing has been stored and the counter is still available.
+	st, err := s.service().getStateTrie(s.genesis.SkipChainID())
+	require.NoError(t, err)
+	_, _, _, _, err = st.GetValues(tx.Instructions[0].Hash())
+	require.True(t, xerrors.Is(err, errKeyNotSet))
+	counters, err := s.service().GetSignerCounters(&GetSignerCounters{
+		SignerIDs:   []string{s.signer.Identity().String()},
+		SkipchainID: s.genesis.SkipChainID(),
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), counters.Counters[0])
+
+	// A wrong counter makes the transaction fail.
+	tx, err = createOneClientTxWithCounter(s.darc.GetBaseID(), dummyContract, s.value, s.signer, 2)
+	require.NoError(t, err)
+	resp, err = s.service().SimulateTransaction(&SimulateTxRequest{
+		Version:     CurrentVersion,
+		SkipchainID: s.genesis.SkipChainID(),
+		Transaction: tx,
+	})
+	require.NoError(t, err)
+	require.False(t, resp.Accepted)
+	require.Contains(t, resp.Error, "counter")
+	require.Empty(t, resp.StateChanges)
+	require.Equal(t, 1, len(resp.Instructions))
+	require.Equal(t, resp.Error, resp.Instructions[0].Error)
+
+	_, err = s.service().SimulateTransaction(&SimulateTxRequest{
+		Version:     CurrentVersion,
+		SkipchainID: s.genesis.SkipChainID(),
+	})
+	require.Error(t, err)
+}
+
+// TestService_AddTransactionFees checks that a transaction that cannot pay its
+// fees doesn't enter the pool.
+func TestService_AddTransactionFees(t *testing.T) {
+	s := newSer(t, 1, testInterval)
+	defer s.local.CloseAll()
+
+	config, err := s.service().LoadConfig(s.genesis.SkipChainID())
+	require.NoError(t, err)
+	config.TxFees = &TxFees{Coin: NewInstanceID([]byte("fees")), Instruction: 10}
+	configBuf, err := protobuf.Encode(config)
+	require.NoError(t, err)
+	ctx, err := combineInstrsAndSign(s.signer, Instruction{
+		InstanceID: ConfigInstanceID,
+		Invoke: &Invoke{
+			ContractID: ContractConfigID,
+			Command:    "update_config",
+			Args:       Arguments{{Name: "config", Value: configBuf}},
+		},
+		SignerCounter: []uint64{1},
+	})
+	require.NoError(t, err)
+	s.sendTxAndWait(t, ctx, 10)
+
+	tx, err := createOneClientTxWithCounter(s.darc.GetBaseID(), dummyContract, s.value, s.signer, 2)
+	require.NoError(t, err)
+	_, err = s.service().AddTransaction(&AddTxRequest{
+		Version:     CurrentVersion,
+		SkipchainID: s.genesis.SkipChainID(),
+		Transaction: tx,
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "fees")
+	rec := s.service().txPool.status(string(s.genesis.SkipChainID()), tx.Instructions.Hash())
+	require.Equal(t, TxStatusUnknown, rec.status)
+}
+
+// TestService_AddTransactionRateLimit checks that a signer can send several
+// transactions without waiting for the blocks, and that only the accepted
+// transactions count for the rate limit.
+func TestService_AddTransactionRateLimit(t *testing.T) {
+	s := newSer(t, 1, testInterval)
+	defer s.local.CloseAll()
+
+	config, err := s.service().LoadConfig(s.genesis.SkipChainID())
+	require.NoError(t, err)
+	config.RateLimit = &RateLimit{Transactions: 2, Window: time.Hour}
+	configBuf, err := protobuf.Encode(config)
+	require.NoError(t, err)
+	ctx, err := combineInstrsAndSign(s.signer, Instruction{
+		InstanceID: ConfigInstanceID,
+		Invoke: &Invoke{
+			ContractID: ContractConfigID,
+			Command:    "update_config",
+			Args:       Arguments{{Name: "config", Value: configBuf}},
+		},
+		SignerCounter: []uint64{1},
+	})
+	require.NoError(t, err)
+	s.sendTxAndWait(t, ctx, 10)
+
+	send := func(counter uint64) error {
+		tx, err := createOneClientTxWithCounter(s.darc.GetBaseID(), dummyContract,
+			[]byte{byte(counter)}, s.signer, counter)
+		require.NoError(t, err)
+		_, err = s.service().AddTransaction(&AddTxRequest{
+			Version:     CurrentVersion,
+			SkipchainID: s.genesis.SkipChainID(),
+			Transaction: tx,
+		})
+		return err
+	}
+
+	require.NoError(t, send(2))
+	// A refused transaction doesn't use the quota of the signer.
+	err = send(5)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "refused")
+	// The second transaction follows the one in the pool.
+	require.NoError(t, send(3))
+	err = send(4)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "rate limit")
+}
+
+func TestService_GetTxStatus(t *testing.T) {
+	s := newSer(t, 1, testInterval)
+	defer s.local.CloseAll()
+
+	tx, err := createOneClientTx(s.darc.GetBaseID(), dummyContract, s.value, s.signer)
+	require.NoError(t, err)
+
+	resp, err := s.service().GetTxStatus(&GetTxStatus{
+		Version:     CurrentVersion,
+		SkipchainID: s.genesis.SkipChainID(),
+		TxHash:      tx.Instructions.Hash(),
+	})
+	require.NoError(t, err)
+	require.Equal(t, TxStatusUnknown, resp.Status)
+
+	akvresp, err := s.service().AddTransaction(&AddTxRequest{
+		Version:       CurrentVersion,
+		SkipchainID:   s.genesis.SkipChainID(),
+		Transaction:   tx,
+		InclusionWait: 10,
+	})
+	transactionOK(t, akvresp, err)
+
+	resp, err = s.service().GetTxStatus(&GetTxStatus{
+		Version:     CurrentVersion,
+		SkipchainID: s.genesis.SkipChainID(),
+		TxHash:      tx.Instructions.Hash(),
+	})
+	require.NoError(t, err)
+	require.Equal(t, TxStatusIncluded, resp.Status)
+	require.Empty(t, resp.Error)
+	sb := s.service().db().GetByID(resp.BlockID)
+	require.NotNil(t, sb)
+	require.Equal(t, resp.BlockIndex, sb.Index)
+
+	// A wrong counter gets the transaction rejected with the reason.
+	tx, err = createOneClientTxWithCounter(s.darc.GetBaseID(), dummyContract, s.value, s.signer, 5)
+	require.NoError(t, err)
+	akvresp, err = s.service().AddTransaction(&AddTxRequest{
+		Version:       CurrentVersion,
+		SkipchainID:   s.genesis.SkipChainID(),
+		Transaction:   tx,
+		InclusionWait: 10,
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, akvresp.Error)
+
+	resp, err = s.service().GetTxStatus(&GetTxStatus{
+		Version:     CurrentVersion,
+		SkipchainID: s.genesis.SkipChainID(),
+		TxHash:      tx.Instructions.Hash(),
+	})
+	require.NoError(t, err)
+	require.Equal(t, TxStatusRejected, resp.Status)
+	require.Equal(t, akvresp.Error, resp.Error)
+
+	_, err = s.service().GetTxStatus(&GetTxStatus{
+		Version: CurrentVersion,
+		TxHash:  tx.Instructions.Hash(),
+	})
+	require.Error(t, err)
+}
+
+func TestService_GetTxReceipt(t *testing.T) {
+	s := newSer(t, 2, testInterval)
+	defer s.local.CloseAll()
+
+	tx, err := createOneClientTx(s.darc.GetBaseID(), dummyContract, s.value, s.signer)
+	require.NoError(t, err)
+	akvresp, err := s.service().AddTransaction(&AddTxRequest{
+		Version:       CurrentVersion,
+		SkipchainID:   s.genesis.SkipChainID(),
+		Transaction:   tx,
+		InclusionWait: 10,
+	})
+	transactionOK(t, akvresp, err)
+
+	resp, err := s.service().GetTxReceipt(&GetTxReceipt{
+		Version:     CurrentVersion,
+		SkipchainID: s.genesis.SkipChainID(),
+		TxHash:      tx.Instructions.Hash(),
+	})
+	require.NoError(t, err)
+	require.NoError(t, resp.Proof.VerifyFromBlock(s.genesis))
+	txr, err := resp.Proof.TxResult(tx.Instructions.Hash())
+	require.NoError(t, err)
+	require.True(t, txr.Accepted)
+	require.Equal(t, -1, txr.Receipt.FailedInstruction)
+	require.Empty(t, txr.Receipt.Error)
+	require.NotEmpty(t, txr.Receipt.StateChangesHash)
+
+	// A refused transaction has the index of the failing instruction and
+	// the error.
+	instr1 := createSpawnInstr(s.darc.GetBaseID(), dummyContract, "data", s.value)
+	instr1.SignerCounter = []uint64{2}
+	instr2 := createSpawnInstr(s.darc.GetBaseID(), "unknown", "data", s.value)
+	instr2.SignerCounter = []uint64{3}
+	tx, err = combineInstrsAndSign(s.signer, instr1, instr2)
+	require.NoError(t, err)
+	akvresp, err = s.service().AddTransaction(&AddTxRequest{
+		Version:       CurrentVersion,
+		SkipchainID:   s.genesis.SkipChainID(),
+		Transaction:   tx,
+		InclusionWait: 10,
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, akvresp.Error)
+
+	resp, err = s.service().GetTxReceipt(&GetTxReceipt{
+		Version:     CurrentVersion,
+		SkipchainID: s.genesis.SkipChainID(),
+		TxHash:      tx.Instructions.Hash(),
+	})
+	require.NoError(t, err)
+	require.NoError(t, resp.Proof.VerifyFromBlock(s.genesis))
+	txr, err = resp.Proof.TxResult(tx.Instructions.Hash())
+	require.NoError(t, err)
+	require.False(t, txr.Accepted)
+	require.Equal(t, 1, txr.Receipt.FailedInstruction)
+	require.Equal(t, akvresp.Error, txr.Receipt.Error)
+	require.Empty(t, txr.Receipt.StateChangesHash)
+
+	// A modified receipt doesn't verify.
+	var body DataBody
+	require.NoError(t, protobuf.Decode(resp.Proof.Block.Payload, &body))
+	for i := range body.TxResults {
+		body.TxResults[i].Receipt.Error = "fake"
+	}
+	resp.Proof.Block.Payload, err = protobuf.Encode(&body)
+	require.NoError(t, err)
+	require.Error(t, resp.Proof.VerifyFromBlock(s.genesis))
+
+	_, err = s.service().GetTxReceipt(&GetTxReceipt{
+		Version:     CurrentVersion,
+		SkipchainID: s.genesis.SkipChainID(),
+		TxHash:      []byte("unknown"),
+	})
+	require.Error(t, err)
+
+	// The blocks that are not in the index are added by indexChain.
+	scID := s.genesis.SkipChainID()
+	require.NoError(t, s.service().txIndex.remove(scID))
+	_, err = s.service().GetTxReceipt(&GetTxReceipt{
+		Version:     CurrentVersion,
+		SkipchainID: scID,
+		TxHash:      tx.Instructions.Hash(),
+	})
+	require.Error(t, err)
+	require.NoError(t, s.service().indexChain(scID))
+	resp, err = s.service().GetTxReceipt(&GetTxReceipt{
+		Version:     CurrentVersion,
+		SkipchainID: scID,
+		TxHash:      tx.Instructions.Hash(),
+	})
+	require.NoError(t, err)
+	require.NoError(t, resp.Proof.VerifyFromBlock(s.genesis))
+
+	// Once the transactions of the block are pruned, the receipt is not
+	// available anymore.
+	_, err = s.service().db().PrunePayload(resp.Proof.Block.Hash)
+	require.NoError(t, err)
+	_, err = s.service().GetTxReceipt(&GetTxReceipt{
+		Version:     CurrentVersion,
+		SkipchainID: scID,
+		TxHash:      tx.Instructions.Hash(),
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), errPrunedBlock.Error())
+}
+
 func TestService_GetProof(t *testing.T) {
 	s := newSer(t, 2, testInterval)
 	defer s.local.CloseAll()
@@ -818,6 +1129,39 @@ func TestService_BadDataHeader(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestService_MissingTxReceipts(t *testing.T) {
+	s := newSer(t, 1, testInterval)
+	defer s.local.CloseAll()
+
+	ser := s.services[0]
+	c := ser.Context
+	err := skipchain.RegisterVerification(c, Verify, func(newID []byte, newSB *skipchain.SkipBlock) bool {
+		// Leave the receipts out of a block of the current version.
+		var header DataHeader
+		err := protobuf.DecodeWithConstructors(newSB.Data, &header, network.DefaultConstructors(cothority.Suite))
+		if err != nil {
+			t.Fatal(err)
+		}
+		require.Equal(t, CurrentVersion, header.Version)
+		require.NotEmpty(t, header.TxReceiptsHash)
+		header.TxReceiptsHash = nil
+		newSB.Data, _ = protobuf.Encode(&header)
+
+		return ser.verifySkipBlock(newID, newSB)
+	})
+	require.NoError(t, err)
+
+	tx, err := createOneClientTx(s.darc.GetBaseID(), dummyContract, s.value, s.signer)
+	require.NoError(t, err)
+	_, err = ser.AddTransaction(&AddTxRequest{
+		Version:       CurrentVersion,
+		SkipchainID:   s.genesis.SkipChainID(),
+		Transaction:   tx,
+		InclusionWait: 5,
+	})
+	require.Error(t, err)
+}
+
 func txResultsFromBlock(sb *skipchain.SkipBlock) (TxResults, error) {
 	var body DataBody
 	err := protobuf.DecodeWithConstructors(sb.Payload, &body, network.DefaultConstructors(cothority.Suite))
@@ -2528,6 +2872,165 @@ func TestService_Repair(t *testing.T) {
 	require.Equal(t, finalRoot, newRoot)
 }
 
+func TestService_GetInstances(t *testing.T) {
+	s := newSer(t, 1, testInterval)
+	defer s.local.CloseAll()
+
+	n := 5
+	for i := 0; i < n; i++ {
+		ctx, err := createOneClientTxWithCounter(s.darc.GetBaseID(), dummyContract, []byte{byte(i)}, s.signer, uint64(i+1))
+		require.NoError(t, err)
+		s.sendTxAndWait(t, ctx, 10)
+	}
+
+	// Iterate over all the pages of dummy instances.
+	var cursor []byte
+	var iids []InstanceID
+	for {
+		resp, err := s.service().GetInstances(&GetInstances{
+			SkipChainID: s.genesis.SkipChainID(),
+			ContractID:  dummyContract,
+			Cursor:      cursor,
+			PageSize:    2,
+		})
+		require.NoError(t, err)
+		require.True(t, len(resp.Instances) <= 2)
+		for _, inst := range resp.Instances {
+			require.Equal(t, dummyContract, inst.ContractID)
+			require.Equal(t, s.darc.GetBaseID(), inst.DarcID)
+			require.Equal(t, 1, len(inst.Value))
+			iids = append(iids, inst.InstanceID)
+		}
+		if len(resp.NextCursor) == 0 {
+			break
+		}
+		cursor = resp.NextCursor
+	}
+	require.Equal(t, n, len(iids))
+	for i := 1; i < n; i++ {
+		require.True(t, bytes.Compare(iids[i-1][:], iids[i][:]) < 0)
+	}
+
+	// The config instance is the only one of its kind.
+	resp, err := s.service().GetInstances(&GetInstances{
+		SkipChainID: s.genesis.SkipChainID(),
+		ContractID:  ContractConfigID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(resp.Instances))
+	require.Equal(t, ConfigInstanceID, resp.Instances[0].InstanceID)
+	require.Empty(t, resp.NextCursor)
+
+	// All the instances are governed by the genesis darc.
+	resp, err = s.service().GetInstances(&GetInstances{
+		SkipChainID: s.genesis.SkipChainID(),
+		DarcID:      s.darc.GetBaseID(),
+	})
+	require.NoError(t, err)
+	require.True(t, len(resp.Instances) > n)
+
+	// Unknown darc.
+	resp, err = s.service().GetInstances(&GetInstances{
+		SkipChainID: s.genesis.SkipChainID(),
+		DarcID:      darc.ID(make([]byte, 32)),
+	})
+	require.NoError(t, err)
+	require.Equal(t, 0, len(resp.Instances))
+
+	_, err = s.service().GetInstances(&GetInstances{})
+	require.Error(t, err)
+}
+
+func TestService_GetMultiProof(t *testing.T) {
+	s := newSer(t, 1, testInterval)
+	defer s.local.CloseAll()
+
+	n := 3
+	keys := make([][]byte, n)
+	for i := 0; i < n; i++ {
+		ctx, err := createOneClientTxWithCounter(s.darc.GetBaseID(), dummyContract, []byte{byte(i)}, s.signer, uint64(i+1))
+		require.NoError(t, err)
+		s.sendTxAndWait(t, ctx, 10)
+		keys[i] = ctx.Instructions[0].Hash()
+	}
+	absent := make([]byte, 32)
+
+	resp, err := s.service().GetMultiProof(&GetMultiProof{
+		Version: CurrentVersion,
+		Keys:    append(keys, absent),
+		ID:      s.genesis.SkipChainID(),
+	})
+	require.NoError(t, err)
+	require.NoError(t, resp.Proof.Verify(s.genesis.SkipChainID()))
+	require.Equal(t, n, resp.Proof.Latest.Index)
+	for i, key := range keys {
+		v, cid, did, err := resp.Proof.Get(key)
+		require.NoError(t, err)
+		require.Equal(t, []byte{byte(i)}, v)
+		require.Equal(t, dummyContract, cid)
+		require.Equal(t, s.darc.GetBaseID(), did)
+	}
+	ok, err := resp.Proof.InclusionProof.Exists(absent)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// A wrong root is detected.
+	resp.Proof.InclusionProof.Interiors = resp.Proof.InclusionProof.Interiors[1:]
+	require.Error(t, resp.Proof.Verify(s.genesis.SkipChainID()))
+
+	_, err = s.service().GetMultiProof(&GetMultiProof{
+		Version: CurrentVersion,
+		ID:      s.genesis.SkipChainID(),
+	})
+	require.Error(t, err)
+
+	// All the instances of which the hash starts with the same bytes as the
+	// first dummy instance.
+	h := sha256.Sum256(keys[0])
+	prefix := h[:minPrefixProofLength]
+	resp, err = s.service().GetPrefixProof(&GetPrefixProof{
+		Version: CurrentVersion,
+		Prefix:  prefix,
+		ID:      s.genesis.SkipChainID(),
+	})
+	require.NoError(t, err)
+	require.NoError(t, resp.Proof.Verify(s.genesis.SkipChainID()))
+	ids, bodies, err := resp.Proof.Instances(prefix)
+	require.NoError(t, err)
+	require.True(t, len(ids) >= 1)
+	found := false
+	for i, id := range ids {
+		h := sha256.Sum256(id[:])
+		require.True(t, bytes.HasPrefix(h[:], prefix))
+		if bytes.Equal(id[:], keys[0]) {
+			found = true
+			require.Equal(t, []byte{0}, bodies[i].Value)
+		}
+	}
+	require.True(t, found)
+
+	// The prefix is too short.
+	_, err = s.service().GetPrefixProof(&GetPrefixProof{
+		Version: CurrentVersion,
+		Prefix:  prefix[:minPrefixProofLength-1],
+		ID:      s.genesis.SkipChainID(),
+	})
+	require.Error(t, err)
+
+	// The proof holds too many nodes.
+	defer func(max int) {
+		maxPrefixProofNodes = max
+	}(maxPrefixProofNodes)
+	maxPrefixProofNodes = 1
+	_, err = s.service().GetPrefixProof(&GetPrefixProof{
+		Version: CurrentVersion,
+		Prefix:  prefix,
+		ID:      s.genesis.SkipChainID(),
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), trie.ErrTooManyNodes.Error())
+}
+
 func createBadConfigTx(t *testing.T, s *ser, intervalBad, szBad bool) (ClientTransaction, ChainConfig) {
 	switch {
 	case intervalBad:
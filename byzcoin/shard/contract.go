@@ -0,0 +1,478 @@
+package shard
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/byzcoin/contracts"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/onet/v3/network"
+	"go.dedis.ch/protobuf"
+	"golang.org/x/xerrors"
+)
+
+// ContractCrossShardID is the ID of the contract running the cross-shard
+// transactions.
+const ContractCrossShardID = "crossshard"
+
+// LockState is the state of a transaction on one shard.
+type LockState int
+
+const (
+	// StateLocked means the coins of the inputs are kept until the
+	// decision is known.
+	StateLocked LockState = iota + 1
+	// StateCommitted means the outputs have been paid.
+	StateCommitted
+	// StateAborted means the inputs have been paid back, or that the
+	// transaction cannot lock coins on this shard anymore.
+	StateAborted
+)
+
+// ConfigInstanceID is the instance holding the Config on every shard.
+var ConfigInstanceID = newInstanceID("crossshard config")
+
+func init() {
+	log.ErrFatal(byzcoin.RegisterGlobalContract(ContractCrossShardID,
+		contractCrossShardFromBytes))
+}
+
+func newInstanceID(what string, data ...[]byte) byzcoin.InstanceID {
+	h := sha256.New()
+	h.Write([]byte(what))
+	for _, d := range data {
+		h.Write(d)
+	}
+	return byzcoin.NewInstanceID(h.Sum(nil))
+}
+
+// LockID returns the instance holding the lock of the transaction on a shard.
+func LockID(txID []byte) byzcoin.InstanceID {
+	return newInstanceID("crossshard lock", txID)
+}
+
+// DecisionID returns the instance holding the decision of the transaction.
+// It lives on the shard of this instance, which is the coordinator of the
+// transaction.
+func DecisionID(txID []byte) byzcoin.InstanceID {
+	return newInstanceID("crossshard decision", txID)
+}
+
+// ShardOf returns the shard, out of n, where the instance lives. The mapping
+// only depends on the ID, so all the clients and shards agree on it.
+func ShardOf(id byzcoin.InstanceID, n int) int {
+	return int(binary.BigEndian.Uint64(id[:8]) % uint64(n))
+}
+
+// ID returns the hash identifying the transaction.
+func (tx Tx) ID() ([]byte, error) {
+	buf, err := protobuf.Encode(&tx)
+	if err != nil {
+		return nil, xerrors.Errorf("encoding transaction: %v", err)
+	}
+	h := sha256.Sum256(buf)
+	return h[:], nil
+}
+
+// check makes sure the outputs add up to the inputs.
+func (tx Tx) check() error {
+	if len(tx.Inputs) == 0 || len(tx.Outputs) == 0 {
+		return xerrors.New("transaction needs inputs and outputs")
+	}
+	in, err := sum(tx.Inputs)
+	if err != nil {
+		return xerrors.Errorf("inputs: %v", err)
+	}
+	out, err := sum(tx.Outputs)
+	if err != nil {
+		return xerrors.Errorf("outputs: %v", err)
+	}
+	if in != out {
+		return xerrors.New("outputs don't add up to the inputs")
+	}
+	return nil
+}
+
+func sum(transfers []Transfer) (uint64, error) {
+	c := byzcoin.Coin{}
+	for _, t := range transfers {
+		if t.Value == 0 {
+			return 0, xerrors.New("transfer of zero coins")
+		}
+		if err := c.SafeAdd(t.Value); err != nil {
+			return 0, err
+		}
+	}
+	return c.Value, nil
+}
+
+// local returns the transfers of the accounts living on the shard.
+func local(transfers []Transfer, config Config) []Transfer {
+	var res []Transfer
+	for _, t := range transfers {
+		if ShardOf(t.Account, len(config.Shards)) == config.Index {
+			res = append(res, t)
+		}
+	}
+	return res
+}
+
+// contractCrossShard runs cross-shard transactions with a two-phase commit.
+// Every step is a spawn with the "action" argument, all the steps but "config"
+// also need the transaction in the "tx" argument:
+//   - config stores the Config given in the "config" argument
+//   - lock keeps the coins given to the instruction for the inputs of this
+//     shard
+//   - commit decides to commit the transaction, given the proofs of the locks
+//     of all the shards holding inputs in the "proofs" argument. It is only
+//     accepted by the coordinator shard
+//   - abort decides to abort the transaction, if it is not committed yet. It
+//     is only accepted by the coordinator shard
+//   - apply pays the outputs or gives back the inputs of this shard, given
+//     the proof of the decision in the "decision" argument
+//
+// Once a shard applied the decision of a transaction, it cannot lock coins
+// for it anymore. The output accounts must exist when the decision is
+// applied.
+type contractCrossShard struct {
+	byzcoin.BasicContract
+}
+
+func contractCrossShardFromBytes(in []byte) (byzcoin.Contract, error) {
+	return &contractCrossShard{}, nil
+}
+
+func (c *contractCrossShard) Spawn(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, coins []byzcoin.Coin) (sc []byzcoin.StateChange, cout []byzcoin.Coin, err error) {
+	cout = coins
+
+	var darcID darc.ID
+	_, _, _, darcID, err = rst.GetValues(inst.InstanceID.Slice())
+	if err != nil {
+		return nil, nil, xerrors.Errorf("reading darc: %v", err)
+	}
+
+	action := string(inst.Spawn.Args.Search("action"))
+	if action == "config" {
+		sc, err = spawnConfig(rst, inst.Spawn.Args.Search("config"), darcID)
+		return
+	}
+
+	config, err := loadConfig(rst)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("loading config: %v", err)
+	}
+	var tx Tx
+	err = protobuf.Decode(inst.Spawn.Args.Search("tx"), &tx)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("decoding transaction: %v", err)
+	}
+	if err = tx.check(); err != nil {
+		return nil, nil, xerrors.Errorf("invalid transaction: %v", err)
+	}
+	txID, err := tx.ID()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch action {
+	case "lock":
+		sc, err = lock(config, tx, txID, darcID, cout)
+	case "commit", "abort":
+		if ShardOf(DecisionID(txID), len(config.Shards)) != config.Index {
+			return nil, nil, xerrors.New("this shard is not the coordinator of the transaction")
+		}
+		if action == "commit" {
+			err = checkLocks(config, tx, txID, inst.Spawn.Args.Search("proofs"))
+			if err != nil {
+				return nil, nil, xerrors.Errorf("checking locks: %v", err)
+			}
+		}
+		var buf []byte
+		buf, err = protobuf.Encode(&Decision{TxID: txID, Commit: action == "commit"})
+		if err != nil {
+			return nil, nil, xerrors.Errorf("encoding decision: %v", err)
+		}
+		sc = []byzcoin.StateChange{byzcoin.NewStateChange(byzcoin.Create,
+			DecisionID(txID), ContractCrossShardID, buf, darcID)}
+	case "apply":
+		sc, err = apply(rst, config, tx, txID, darcID, inst.Spawn.Args.Search("decision"))
+	default:
+		err = xerrors.New("unknown action: " + action)
+	}
+	if err != nil {
+		return nil, nil, xerrors.Errorf("%s: %v", action, err)
+	}
+	return
+}
+
+func spawnConfig(rst byzcoin.ReadOnlyStateTrie, buf []byte, darcID darc.ID) ([]byzcoin.StateChange, error) {
+	var config Config
+	err := protobuf.DecodeWithConstructors(buf, &config, network.DefaultConstructors(cothority.Suite))
+	if err != nil {
+		return nil, xerrors.Errorf("decoding config: %v", err)
+	}
+	if config.Index < 0 || config.Index >= len(config.Shards) {
+		return nil, xerrors.New("index is not in the shards")
+	}
+	for _, sb := range config.Shards {
+		if sb.Index != 0 || !sb.CalculateHash().Equal(sb.Hash) {
+			return nil, xerrors.New("shards must be given by their genesis block")
+		}
+	}
+	sc, ok := rst.(byzcoin.ReadOnlySkipChain)
+	if !ok {
+		return nil, xerrors.New("the skipchain is not available")
+	}
+	genesis, err := sc.GetGenesisBlock()
+	if err != nil {
+		return nil, xerrors.Errorf("getting genesis block: %v", err)
+	}
+	if !config.Shards[config.Index].Hash.Equal(genesis.Hash) {
+		return nil, xerrors.New("index is not the one of this shard")
+	}
+	return []byzcoin.StateChange{byzcoin.NewStateChange(byzcoin.Create,
+		ConfigInstanceID, ContractCrossShardID, buf, darcID)}, nil
+}
+
+func loadConfig(rst byzcoin.ReadOnlyStateTrie) (Config, error) {
+	var config Config
+	buf, _, cid, _, err := rst.GetValues(ConfigInstanceID.Slice())
+	if err != nil {
+		return config, xerrors.Errorf("reading trie: %v", err)
+	}
+	if cid != ContractCrossShardID {
+		return config, xerrors.New("config has the wrong contract")
+	}
+	err = protobuf.DecodeWithConstructors(buf, &config, network.DefaultConstructors(cothority.Suite))
+	if err != nil {
+		return config, xerrors.Errorf("decoding config: %v", err)
+	}
+	return config, nil
+}
+
+func lock(config Config, tx Tx, txID []byte, darcID darc.ID, coins []byzcoin.Coin) ([]byzcoin.StateChange, error) {
+	value, err := sum(local(tx.Inputs, config))
+	if err != nil {
+		return nil, err
+	}
+	if value == 0 {
+		return nil, xerrors.New("no input on this shard")
+	}
+	// Every fetch adds its own entry to the coins, so they are added up
+	// before being taken.
+	available := byzcoin.Coin{Name: tx.Coin}
+	for _, c := range coins {
+		if c.Name.Equal(tx.Coin) {
+			if err := available.SafeAdd(c.Value); err != nil {
+				return nil, xerrors.Errorf("adding coins: %v", err)
+			}
+		}
+	}
+	if available.Value < value {
+		return nil, xerrors.New("not enough coins for the inputs")
+	}
+	for i := range coins {
+		if value == 0 {
+			break
+		}
+		if coins[i].Name.Equal(tx.Coin) {
+			taken := coins[i].Value
+			if taken > value {
+				taken = value
+			}
+			coins[i].Value -= taken
+			value -= taken
+		}
+	}
+
+	buf, err := protobuf.Encode(&Lock{TxID: txID, State: StateLocked})
+	if err != nil {
+		return nil, xerrors.Errorf("encoding lock: %v", err)
+	}
+	return []byzcoin.StateChange{byzcoin.NewStateChange(byzcoin.Create,
+		LockID(txID), ContractCrossShardID, buf, darcID)}, nil
+}
+
+// verifyProof checks that the proof comes from the given shard and returns
+// the value of the instance, which must belong to this contract.
+func verifyProof(p byzcoin.Proof, config Config, shard int, id byzcoin.InstanceID) ([]byte, error) {
+	genesis := config.Shards[shard]
+	if !p.Latest.SkipChainID().Equal(genesis.Hash) {
+		return nil, xerrors.Errorf("proof is not from shard %d", shard)
+	}
+	if err := p.VerifyFromBlock(&genesis); err != nil {
+		return nil, xerrors.Errorf("verifying proof: %v", err)
+	}
+	value, cid, _, err := p.Get(id.Slice())
+	if err != nil {
+		return nil, xerrors.Errorf("reading proof: %v", err)
+	}
+	if cid != ContractCrossShardID {
+		return nil, xerrors.New("instance has the wrong contract")
+	}
+	return value, nil
+}
+
+// checkLocks makes sure all the shards holding inputs locked them.
+func checkLocks(config Config, tx Tx, txID []byte, buf []byte) error {
+	var proofs Proofs
+	err := protobuf.DecodeWithConstructors(buf, &proofs, network.DefaultConstructors(cothority.Suite))
+	if err != nil {
+		return xerrors.Errorf("decoding proofs: %v", err)
+	}
+
+	locked := make(map[int]bool)
+	for _, t := range tx.Inputs {
+		locked[ShardOf(t.Account, len(config.Shards))] = false
+	}
+	for _, p := range proofs.List {
+		shard := -1
+		for i, sb := range config.Shards {
+			if p.Latest.SkipChainID().Equal(sb.Hash) {
+				shard = i
+			}
+		}
+		if _, ok := locked[shard]; !ok {
+			continue
+		}
+		value, err := verifyProof(p, config, shard, LockID(txID))
+		if err != nil {
+			return xerrors.Errorf("shard %d: %v", shard, err)
+		}
+		var l Lock
+		if err := protobuf.Decode(value, &l); err != nil {
+			return xerrors.Errorf("decoding lock: %v", err)
+		}
+		if !bytes.Equal(l.TxID, txID) || l.State != StateLocked {
+			return xerrors.Errorf("shard %d didn't lock the inputs", shard)
+		}
+		locked[shard] = true
+	}
+	for shard, ok := range locked {
+		if !ok {
+			return xerrors.Errorf("missing lock of shard %d", shard)
+		}
+	}
+	return nil
+}
+
+func apply(rst byzcoin.ReadOnlyStateTrie, config Config, tx Tx, txID []byte, darcID darc.ID, buf []byte) ([]byzcoin.StateChange, error) {
+	var p byzcoin.Proof
+	err := protobuf.DecodeWithConstructors(buf, &p, network.DefaultConstructors(cothority.Suite))
+	if err != nil {
+		return nil, xerrors.Errorf("decoding proof: %v", err)
+	}
+	value, err := verifyProof(p, config,
+		ShardOf(DecisionID(txID), len(config.Shards)), DecisionID(txID))
+	if err != nil {
+		return nil, xerrors.Errorf("decision: %v", err)
+	}
+	var d Decision
+	if err := protobuf.Decode(value, &d); err != nil {
+		return nil, xerrors.Errorf("decoding decision: %v", err)
+	}
+	if !bytes.Equal(d.TxID, txID) {
+		return nil, xerrors.New("decision is for another transaction")
+	}
+
+	lockID := LockID(txID)
+	pr, err := rst.GetProof(lockID.Slice())
+	if err != nil {
+		return nil, xerrors.Errorf("reading trie: %v", err)
+	}
+	locked, err := pr.Exists(lockID.Slice())
+	if err != nil {
+		return nil, xerrors.Errorf("reading trie: %v", err)
+	}
+	if locked {
+		buf, _, _, _, err := rst.GetValues(lockID.Slice())
+		if err != nil {
+			return nil, xerrors.Errorf("reading trie: %v", err)
+		}
+		var l Lock
+		if err := protobuf.Decode(buf, &l); err != nil {
+			return nil, xerrors.Errorf("decoding lock: %v", err)
+		}
+		if l.State != StateLocked {
+			return nil, xerrors.New("decision already applied")
+		}
+	}
+
+	var sc []byzcoin.StateChange
+	l := Lock{TxID: txID}
+	if d.Commit {
+		if !locked && len(local(tx.Inputs, config)) > 0 {
+			return nil, xerrors.New("inputs are not locked")
+		}
+		l.State = StateCommitted
+		sc, err = pay(rst, tx.Coin, local(tx.Outputs, config))
+	} else {
+		// The inputs are only given back if they have been locked,
+		// without a lock the Aborted state makes sure they never will
+		// be.
+		l.State = StateAborted
+		if locked {
+			sc, err = pay(rst, tx.Coin, local(tx.Inputs, config))
+		}
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("paying: %v", err)
+	}
+
+	lockBuf, err := protobuf.Encode(&l)
+	if err != nil {
+		return nil, xerrors.Errorf("encoding lock: %v", err)
+	}
+	action := byzcoin.Create
+	if locked {
+		action = byzcoin.Update
+	}
+	return append(sc, byzcoin.NewStateChange(action, lockID,
+		ContractCrossShardID, lockBuf, darcID)), nil
+}
+
+// pay adds the coins of the transfers to their accounts.
+func pay(rst byzcoin.ReadOnlyStateTrie, name byzcoin.InstanceID, transfers []Transfer) ([]byzcoin.StateChange, error) {
+	// The same account can appear more than once, so its coins are added up
+	// before creating the state changes.
+	var accounts []byzcoin.InstanceID
+	values := make(map[byzcoin.InstanceID]uint64)
+	for _, t := range transfers {
+		if _, ok := values[t.Account]; !ok {
+			accounts = append(accounts, t.Account)
+		}
+		values[t.Account] += t.Value
+	}
+
+	var sc []byzcoin.StateChange
+	for _, account := range accounts {
+		buf, _, cid, accountDarc, err := rst.GetValues(account.Slice())
+		if err != nil {
+			return nil, xerrors.Errorf("reading account: %v", err)
+		}
+		if cid != contracts.ContractCoinID {
+			return nil, xerrors.New("account is not a coin instance")
+		}
+		var coin byzcoin.Coin
+		if err := protobuf.Decode(buf, &coin); err != nil {
+			return nil, xerrors.Errorf("decoding account: %v", err)
+		}
+		if !coin.Name.Equal(name) {
+			return nil, xerrors.New("account holds other coins")
+		}
+		if err := coin.SafeAdd(values[account]); err != nil {
+			return nil, xerrors.Errorf("adding coins: %v", err)
+		}
+		buf, err = protobuf.Encode(&coin)
+		if err != nil {
+			return nil, xerrors.Errorf("encoding account: %v", err)
+		}
+		sc = append(sc, byzcoin.NewStateChange(byzcoin.Update, account,
+			contracts.ContractCoinID, buf, accountDarc))
+	}
+	return sc, nil
+}
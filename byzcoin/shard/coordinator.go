@@ -0,0 +1,240 @@
+package shard
+
+import (
+	"encoding/binary"
+	"sort"
+
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/byzcoin/contracts"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/protobuf"
+	"golang.org/x/xerrors"
+)
+
+// ErrAborted is returned when a transaction has been aborted. The coins of
+// the inputs are given back to their accounts.
+var ErrAborted = xerrors.New("transaction aborted")
+
+// Coordinator runs transactions over several ByzCoin chains used as shards.
+// An instance lives on the shard given by ShardOf, so the instances must be
+// created on the right shard, for example by choosing the coinID of the coin
+// accounts.
+type Coordinator struct {
+	shards []*byzcoin.Client
+	darcs  []darc.ID
+}
+
+// NewCoordinator returns a coordinator for the shards, in this order. The
+// darcs, one per shard, are used to spawn the instances of the cross-shard
+// contract and must allow "spawn:crossshard".
+func NewCoordinator(shards []*byzcoin.Client, darcs []darc.ID) (*Coordinator, error) {
+	if len(shards) == 0 {
+		return nil, xerrors.New("need at least one shard")
+	}
+	if len(shards) != len(darcs) {
+		return nil, xerrors.New("need one darc per shard")
+	}
+	return &Coordinator{shards: shards, darcs: darcs}, nil
+}
+
+// ShardOf returns the index of the shard where the instance lives.
+func (c *Coordinator) ShardOf(id byzcoin.InstanceID) int {
+	return ShardOf(id, len(c.shards))
+}
+
+// Shard returns the client of the shard where the instance lives.
+func (c *Coordinator) Shard(id byzcoin.InstanceID) *byzcoin.Client {
+	return c.shards[c.ShardOf(id)]
+}
+
+// Setup stores the config of the cross-shard contract on every shard. It must
+// be called once, before the first transaction.
+func (c *Coordinator) Setup(signer darc.Signer, wait int) error {
+	config := Config{}
+	for i, cl := range c.shards {
+		if cl.Genesis == nil {
+			// Getting a proof fetches the genesis block.
+			if _, err := cl.GetProof(ConfigInstanceID.Slice()); err != nil {
+				return xerrors.Errorf("shard %d: %v", i, err)
+			}
+		}
+		config.Shards = append(config.Shards, *cl.Genesis)
+	}
+
+	for i := range c.shards {
+		config.Index = i
+		buf, err := protobuf.Encode(&config)
+		if err != nil {
+			return xerrors.Errorf("encoding config: %v", err)
+		}
+		err = c.send(i, signer, wait, c.spawn(i, "config",
+			byzcoin.Argument{Name: "config", Value: buf}))
+		if err != nil {
+			return xerrors.Errorf("shard %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// Transfer runs the transaction with a two-phase commit. First, every shard
+// holding inputs locks their coins. Then the coordinator shard of the
+// transaction decides to commit if all the locks are proven, or to abort
+// otherwise. Finally, every shard applies the decision, by paying the outputs
+// or giving back the inputs. The signer must be able to fetch the coins of
+// the inputs. If the transaction is aborted, ErrAborted is returned.
+func (c *Coordinator) Transfer(tx Tx, signer darc.Signer, wait int) error {
+	if err := tx.check(); err != nil {
+		return xerrors.Errorf("invalid transaction: %v", err)
+	}
+	txID, err := tx.ID()
+	if err != nil {
+		return err
+	}
+	txBuf, err := protobuf.Encode(&tx)
+	if err != nil {
+		return xerrors.Errorf("encoding transaction: %v", err)
+	}
+	txArg := byzcoin.Argument{Name: "tx", Value: txBuf}
+
+	inputs := c.shardsOf(tx.Inputs)
+	commit := true
+	for _, s := range inputs {
+		var instrs []byzcoin.Instruction
+		for _, t := range tx.Inputs {
+			if c.ShardOf(t.Account) != s {
+				continue
+			}
+			coins := make([]byte, 8)
+			binary.LittleEndian.PutUint64(coins, t.Value)
+			instrs = append(instrs, byzcoin.Instruction{
+				InstanceID: t.Account,
+				Invoke: &byzcoin.Invoke{
+					ContractID: contracts.ContractCoinID,
+					Command:    "fetch",
+					Args:       byzcoin.Arguments{{Name: "coins", Value: coins}},
+				},
+			})
+		}
+		instrs = append(instrs, c.spawn(s, "lock", txArg))
+		if err := c.send(s, signer, wait, instrs...); err != nil {
+			log.Warnf("couldn't lock the inputs on shard %d: %v", s, err)
+			commit = false
+			break
+		}
+	}
+
+	coordinator := c.ShardOf(DecisionID(txID))
+	if commit {
+		var proofs Proofs
+		for _, s := range inputs {
+			pr, err := c.shards[s].GetProof(LockID(txID).Slice())
+			if err != nil {
+				return xerrors.Errorf("getting lock of shard %d: %v", s, err)
+			}
+			proofs.List = append(proofs.List, pr.Proof)
+		}
+		buf, err := protobuf.Encode(&proofs)
+		if err != nil {
+			return xerrors.Errorf("encoding proofs: %v", err)
+		}
+		err = c.send(coordinator, signer, wait, c.spawn(coordinator, "commit",
+			txArg, byzcoin.Argument{Name: "proofs", Value: buf}))
+		if err != nil {
+			log.Warnf("couldn't commit: %v", err)
+			commit = false
+		}
+	}
+	if !commit {
+		// The abort is refused if the transaction has already been
+		// decided, in which case the decision below is the one to
+		// apply.
+		if err := c.send(coordinator, signer, wait, c.spawn(coordinator, "abort", txArg)); err != nil {
+			log.Warnf("couldn't abort: %v", err)
+		}
+	}
+
+	pr, err := c.shards[coordinator].GetProof(DecisionID(txID).Slice())
+	if err != nil {
+		return xerrors.Errorf("getting decision: %v", err)
+	}
+	buf, _, _, err := pr.Proof.Get(DecisionID(txID).Slice())
+	if err != nil {
+		return xerrors.Errorf("missing decision: %v", err)
+	}
+	var d Decision
+	if err := protobuf.Decode(buf, &d); err != nil {
+		return xerrors.Errorf("decoding decision: %v", err)
+	}
+	proofBuf, err := protobuf.Encode(&pr.Proof)
+	if err != nil {
+		return xerrors.Errorf("encoding decision: %v", err)
+	}
+
+	shards := inputs
+	if d.Commit {
+		shards = c.shardsOf(append(append([]Transfer{}, tx.Inputs...), tx.Outputs...))
+	}
+	for _, s := range shards {
+		err := c.send(s, signer, wait, c.spawn(s, "apply", txArg,
+			byzcoin.Argument{Name: "decision", Value: proofBuf}))
+		if err != nil {
+			return xerrors.Errorf("applying decision on shard %d: %v", s, err)
+		}
+	}
+	if !d.Commit {
+		return ErrAborted
+	}
+	return nil
+}
+
+// shardsOf returns the sorted list of the shards of the accounts.
+func (c *Coordinator) shardsOf(transfers []Transfer) []int {
+	found := make(map[int]bool)
+	var shards []int
+	for _, t := range transfers {
+		s := c.ShardOf(t.Account)
+		if !found[s] {
+			found[s] = true
+			shards = append(shards, s)
+		}
+	}
+	sort.Ints(shards)
+	return shards
+}
+
+func (c *Coordinator) spawn(shard int, action string, args ...byzcoin.Argument) byzcoin.Instruction {
+	return byzcoin.Instruction{
+		InstanceID: byzcoin.NewInstanceID(c.darcs[shard]),
+		Spawn: &byzcoin.Spawn{
+			ContractID: ContractCrossShardID,
+			Args: append(byzcoin.Arguments{{Name: "action", Value: []byte(action)}},
+				args...),
+		},
+	}
+}
+
+// send signs the instructions and waits for them to be accepted by the shard.
+func (c *Coordinator) send(shard int, signer darc.Signer, wait int, instrs ...byzcoin.Instruction) error {
+	cl := c.shards[shard]
+	counters, err := cl.GetSignerCounters(signer.Identity().String())
+	if err != nil {
+		return xerrors.Errorf("getting counters: %v", err)
+	}
+	if len(counters.Counters) != 1 {
+		return xerrors.New("wrong number of counters")
+	}
+	for i := range instrs {
+		instrs[i].SignerCounter = []uint64{counters.Counters[0] + uint64(i) + 1}
+	}
+	ctx, err := cl.CreateTransaction(instrs...)
+	if err != nil {
+		return xerrors.Errorf("creating transaction: %v", err)
+	}
+	if err := ctx.FillSignersAndSignWith(signer); err != nil {
+		return xerrors.Errorf("signing: %v", err)
+	}
+	_, err = cl.AddTransactionAndWait(ctx, wait)
+	return cothority.ErrorOrNil(err, "adding transaction")
+}
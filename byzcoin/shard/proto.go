@@ -0,0 +1,67 @@
+package shard
+
+import (
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/skipchain"
+)
+
+// PROTOSTART
+// type :byzcoin.InstanceID:bytes
+// type :LockState:sint32
+// package shard;
+//
+// import "byzcoin.proto";
+// import "skipchain.proto";
+//
+// option java_package = "ch.epfl.dedis.lib.proto";
+// option java_outer_classname = "Shard";
+
+// Config is stored on every shard and lists the shards, in order, with their
+// genesis block, so that the proofs of the other shards can be verified.
+type Config struct {
+	// Shards are the genesis blocks of the shards.
+	Shards []skipchain.SkipBlock
+	// Index is the position of this chain in Shards.
+	Index int
+}
+
+// Tx is an atomic transfer of coins between accounts living on different
+// shards.
+type Tx struct {
+	// Nonce makes the ID of the transaction unique.
+	Nonce []byte
+	// Coin is the name of the coins transferred.
+	Coin byzcoin.InstanceID
+	// Inputs are the coins taken from the accounts.
+	Inputs []Transfer
+	// Outputs are the coins given to the accounts. They must add up to the
+	// inputs.
+	Outputs []Transfer
+}
+
+// Transfer is a number of coins taken from or given to an account.
+type Transfer struct {
+	Account byzcoin.InstanceID
+	Value   uint64
+}
+
+// Lock is stored on a shard once it took part in a transaction. On the
+// shards holding inputs, it keeps the coins of the inputs until the decision
+// is applied.
+type Lock struct {
+	TxID  []byte
+	State LockState
+}
+
+// Decision is stored on the coordinator shard of a transaction, and tells
+// all the shards whether the transaction commits or aborts.
+type Decision struct {
+	TxID   []byte
+	Commit bool
+}
+
+// Proofs holds the proofs of the locks of a transaction, one per shard holding
+// inputs.
+type Proofs struct {
+	List []byzcoin.Proof
+}
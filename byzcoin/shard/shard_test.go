@@ -0,0 +1,211 @@
+package shard
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/byzcoin/contracts"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/onet/v3"
+	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/protobuf"
+)
+
+func TestMain(m *testing.M) {
+	log.MainTest(m)
+}
+
+func TestShardOf(t *testing.T) {
+	counts := make([]int, 3)
+	for i := 0; i < 300; i++ {
+		id := newInstanceID("test", []byte{byte(i)})
+		s := ShardOf(id, 3)
+		require.Equal(t, s, ShardOf(id, 3))
+		counts[s]++
+	}
+	for _, c := range counts {
+		require.True(t, c > 0)
+	}
+}
+
+func TestTx_Check(t *testing.T) {
+	a := newInstanceID("a")
+	b := newInstanceID("b")
+	require.NoError(t, Tx{Inputs: []Transfer{{a, 10}}, Outputs: []Transfer{{b, 4}, {a, 6}}}.check())
+	require.Error(t, Tx{Inputs: []Transfer{{a, 10}}}.check())
+	require.Error(t, Tx{Inputs: []Transfer{{a, 10}}, Outputs: []Transfer{{b, 9}}}.check())
+	require.Error(t, Tx{Inputs: []Transfer{{a, 10}, {a, 0}}, Outputs: []Transfer{{b, 10}}}.check())
+	require.Error(t, Tx{Inputs: []Transfer{{a, 1 << 63}, {a, 1 << 63}}, Outputs: []Transfer{{b, 1}}}.check())
+}
+
+type testShards struct {
+	local   *onet.LocalTest
+	signer  darc.Signer
+	clients []*byzcoin.Client
+	darcs   []darc.ID
+	coord   *Coordinator
+	// accounts is the number of accounts created, so that every account
+	// gets its own coinID.
+	accounts int
+}
+
+func newTestShards(t *testing.T, n int) *testShards {
+	ts := &testShards{
+		local:  onet.NewTCPTest(cothority.Suite),
+		signer: darc.NewSignerEd25519(nil, nil),
+	}
+	for i := 0; i < n; i++ {
+		_, roster, _ := ts.local.GenTree(3, true)
+		msg, err := byzcoin.DefaultGenesisMsg(byzcoin.CurrentVersion, roster,
+			[]string{"spawn:coin", "invoke:coin.mint", "invoke:coin.fetch",
+				"spawn:" + ContractCrossShardID}, ts.signer.Identity())
+		require.NoError(t, err)
+		msg.BlockInterval = 500 * time.Millisecond
+		cl, _, err := byzcoin.NewLedger(msg, false)
+		require.NoError(t, err)
+		ts.clients = append(ts.clients, cl)
+		ts.darcs = append(ts.darcs, msg.GenesisDarc.GetBaseID())
+	}
+
+	var err error
+	ts.coord, err = NewCoordinator(ts.clients, ts.darcs)
+	require.NoError(t, err)
+	return ts
+}
+
+// newAccount creates a coin account living on the given shard, by trying
+// coinIDs until the ID of the account maps to the shard.
+func (ts *testShards) newAccount(t *testing.T, shard int, coins uint64) byzcoin.InstanceID {
+	var coinID []byte
+	var id byzcoin.InstanceID
+	ts.accounts++
+	for i := 0; ; i++ {
+		coinID = []byte{byte(ts.accounts), byte(i)}
+		h := sha256.New()
+		h.Write([]byte(contracts.ContractCoinID))
+		h.Write(coinID)
+		id = byzcoin.NewInstanceID(h.Sum(nil))
+		if ts.coord.ShardOf(id) == shard {
+			break
+		}
+	}
+
+	coinsBuf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(coinsBuf, coins)
+	require.NoError(t, ts.coord.send(shard, ts.signer, 10,
+		byzcoin.Instruction{
+			InstanceID: byzcoin.NewInstanceID(ts.darcs[shard]),
+			Spawn: &byzcoin.Spawn{
+				ContractID: contracts.ContractCoinID,
+				Args:       byzcoin.Arguments{{Name: "coinID", Value: coinID}},
+			},
+		},
+		byzcoin.Instruction{
+			InstanceID: id,
+			Invoke: &byzcoin.Invoke{
+				ContractID: contracts.ContractCoinID,
+				Command:    "mint",
+				Args:       byzcoin.Arguments{{Name: "coins", Value: coinsBuf}},
+			},
+		}))
+	return id
+}
+
+func (ts *testShards) balance(t *testing.T, id byzcoin.InstanceID) uint64 {
+	pr, err := ts.coord.Shard(id).GetProof(id.Slice())
+	require.NoError(t, err)
+	buf, _, _, err := pr.Proof.Get(id.Slice())
+	require.NoError(t, err)
+	var coin byzcoin.Coin
+	require.NoError(t, protobuf.Decode(buf, &coin))
+	return coin.Value
+}
+
+func TestCoordinator_Setup(t *testing.T) {
+	ts := newTestShards(t, 2)
+	defer ts.local.CloseAll()
+
+	// With the shards swapped, every shard gets the index of the other one.
+	swapped, err := NewCoordinator(
+		[]*byzcoin.Client{ts.clients[1], ts.clients[0]},
+		[]darc.ID{ts.darcs[1], ts.darcs[0]})
+	require.NoError(t, err)
+	require.Error(t, swapped.Setup(ts.signer, 10))
+
+	require.NoError(t, ts.coord.Setup(ts.signer, 10))
+	require.Error(t, ts.coord.Setup(ts.signer, 10))
+}
+
+func TestCoordinator_Transfer(t *testing.T) {
+	ts := newTestShards(t, 2)
+	defer ts.local.CloseAll()
+	require.NoError(t, ts.coord.Setup(ts.signer, 10))
+
+	a := ts.newAccount(t, 0, 100)
+	b := ts.newAccount(t, 1, 0)
+
+	tx := Tx{
+		Nonce:   []byte("1"),
+		Coin:    contracts.CoinName,
+		Inputs:  []Transfer{{Account: a, Value: 60}},
+		Outputs: []Transfer{{Account: b, Value: 60}},
+	}
+	require.NoError(t, ts.coord.Transfer(tx, ts.signer, 10))
+	require.Equal(t, uint64(40), ts.balance(t, a))
+	require.Equal(t, uint64(60), ts.balance(t, b))
+
+	// The same transaction can't be run twice.
+	require.Error(t, ts.coord.Transfer(tx, ts.signer, 10))
+	require.Equal(t, uint64(40), ts.balance(t, a))
+	require.Equal(t, uint64(60), ts.balance(t, b))
+
+	// Without enough coins, the transaction is aborted and can't be
+	// locked anymore.
+	tx = Tx{
+		Nonce:   []byte("2"),
+		Coin:    contracts.CoinName,
+		Inputs:  []Transfer{{Account: a, Value: 10}, {Account: b, Value: 70}},
+		Outputs: []Transfer{{Account: b, Value: 80}},
+	}
+	require.Equal(t, ErrAborted, ts.coord.Transfer(tx, ts.signer, 10))
+	require.Equal(t, uint64(40), ts.balance(t, a))
+	require.Equal(t, uint64(60), ts.balance(t, b))
+
+	txID, err := tx.ID()
+	require.NoError(t, err)
+	pr, err := ts.clients[1].GetProof(LockID(txID).Slice())
+	require.NoError(t, err)
+	buf, _, _, err := pr.Proof.Get(LockID(txID).Slice())
+	require.NoError(t, err)
+	var l Lock
+	require.NoError(t, protobuf.Decode(buf, &l))
+	require.Equal(t, StateAborted, l.State)
+}
+
+func TestCoordinator_TransferInputs(t *testing.T) {
+	ts := newTestShards(t, 2)
+	defer ts.local.CloseAll()
+	require.NoError(t, ts.coord.Setup(ts.signer, 10))
+
+	a := ts.newAccount(t, 0, 100)
+	c := ts.newAccount(t, 0, 50)
+	b := ts.newAccount(t, 1, 0)
+
+	// Every input is fetched on its own, so the lock needs to add up the
+	// coins of all the fetches of the shard.
+	tx := Tx{
+		Nonce:   []byte("1"),
+		Coin:    contracts.CoinName,
+		Inputs:  []Transfer{{Account: a, Value: 30}, {Account: a, Value: 30}, {Account: c, Value: 50}},
+		Outputs: []Transfer{{Account: b, Value: 110}},
+	}
+	require.NoError(t, ts.coord.Transfer(tx, ts.signer, 10))
+	require.Equal(t, uint64(40), ts.balance(t, a))
+	require.Equal(t, uint64(0), ts.balance(t, c))
+	require.Equal(t, uint64(110), ts.balance(t, b))
+}
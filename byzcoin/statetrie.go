@@ -116,10 +116,14 @@ func (t *stagingStateTrie) Commit() error {
 	return cothority.ErrorOrNil(t.StagingTrie.Commit(), "commit failed")
 }
 
-// GetIndex returns the index of the current trie.
+// GetIndex returns the index of the current trie, or -1 before the genesis
+// block is stored.
 func (t *stagingStateTrie) GetIndex() int {
-	index := binary.LittleEndian.Uint32(t.StagingTrie.GetMetadata([]byte(trieIndexKey)))
-	return int(index)
+	indexBuf := t.StagingTrie.GetMetadata([]byte(trieIndexKey))
+	if indexBuf == nil {
+		return -1
+	}
+	return int(binary.LittleEndian.Uint32(indexBuf))
 }
 
 // StoreAllToReplica creates a copy of the read-only trie and applies the state
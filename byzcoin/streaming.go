@@ -1,11 +1,16 @@
 package byzcoin
 
 import (
+	"bytes"
 	"fmt"
 	"sync"
 
+	"go.dedis.ch/cothority/v3/darc"
 	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/onet/v3/log"
 	"go.dedis.ch/onet/v3/network"
+	"go.dedis.ch/protobuf"
+	"golang.org/x/xerrors"
 )
 
 const (
@@ -24,18 +29,28 @@ const (
 	PaginateGetBlockFailed = 6
 )
 
+// streamingBufferSize is the number of blocks kept for a listener that is
+// slower than the chain. Once it is full, the listener is dropped so that it
+// doesn't hold back the creation of the blocks.
+const streamingBufferSize = 100
+
 func init() {
 	network.RegisterMessages(&StreamingRequest{}, &StreamingResponse{},
 		&PaginateRequest{}, &PaginateResponse{})
 }
 
+type streamingListener struct {
+	out    chan *StreamingResponse
+	filter *StreamingFilter
+}
+
 type streamingManager struct {
 	sync.Mutex
 	// key: skipchain ID, value: slice of listeners
-	listeners map[string][]chan *StreamingResponse
+	listeners map[string][]streamingListener
 }
 
-func (s *streamingManager) notify(scID string, block *skipchain.SkipBlock) {
+func (s *streamingManager) notify(scID string, block *skipchain.SkipBlock, txs TxResults, scs StateChanges) {
 	s.Lock()
 	defer s.Unlock()
 
@@ -44,24 +59,35 @@ func (s *streamingManager) notify(scID string, block *skipchain.SkipBlock) {
 		return
 	}
 
-	for _, c := range ls {
-		c <- &StreamingResponse{
-			Block: block,
+	kept := ls[:0]
+	for _, l := range ls {
+		resp := newStreamingResponse(block, txs, scs, l.filter)
+		if resp != nil {
+			select {
+			case l.out <- resp:
+			default:
+				log.Warnf("dropping a streaming listener of %x that is %d blocks behind",
+					scID, streamingBufferSize)
+				close(l.out)
+				continue
+			}
 		}
+		kept = append(kept, l)
 	}
+	s.listeners[scID] = kept
 }
 
-func (s *streamingManager) newListener(scID string) chan *StreamingResponse {
+func (s *streamingManager) newListener(scID string, filter *StreamingFilter) chan *StreamingResponse {
 	s.Lock()
 	defer s.Unlock()
 
 	if s.listeners == nil {
-		s.listeners = make(map[string][]chan *StreamingResponse)
+		s.listeners = make(map[string][]streamingListener)
 	}
 
 	ls := s.listeners[scID]
-	outChan := make(chan *StreamingResponse)
-	ls = append(ls, outChan)
+	outChan := make(chan *StreamingResponse, streamingBufferSize)
+	ls = append(ls, streamingListener{out: outChan, filter: filter})
 	s.listeners[scID] = ls
 	return outChan
 }
@@ -76,8 +102,8 @@ func (s *streamingManager) stopListener(scID string, outChan chan *StreamingResp
 	}
 
 	for i, listener := range ls {
-		if listener == outChan {
-			close(listener)
+		if listener.out == outChan {
+			close(listener.out)
 			s.listeners[scID] = append(ls[:i], ls[i+1:]...)
 			return
 		}
@@ -91,19 +117,114 @@ func (s *streamingManager) stopAll() {
 	for key, l := range s.listeners {
 		for _, c := range l {
 			// Force the streaming connection in Onet to close.
-			close(c)
+			close(c.out)
 		}
 
 		delete(s.listeners, key)
 	}
 }
 
+// newStreamingResponse creates the response sent to a listener for the given
+// block. If the filter is nil, the whole block is sent, else only the matching
+// transactions and state changes are sent along with the block without its
+// payload. It returns nil if nothing in the block matches the filter.
+func newStreamingResponse(block *skipchain.SkipBlock, txs TxResults, scs StateChanges, filter *StreamingFilter) *StreamingResponse {
+	if filter == nil {
+		return &StreamingResponse{Block: block}
+	}
+
+	resp := &StreamingResponse{}
+	// Instances governed by one of the darcs of the filter that are
+	// modified in this block.
+	governed := make(map[string]bool)
+	for _, sc := range scs {
+		if filter.matchStateChange(sc) {
+			resp.StateChanges = append(resp.StateChanges, sc)
+		}
+		if filter.matchDarc(sc.DarcID) {
+			governed[string(sc.InstanceID)] = true
+		}
+	}
+
+	for _, tx := range txs {
+		if filter.OnlyAccepted && !tx.Accepted {
+			continue
+		}
+		for _, instr := range tx.ClientTransaction.Instructions {
+			if filter.matchInstruction(instr, governed) {
+				resp.TxResults = append(resp.TxResults, tx)
+				break
+			}
+		}
+	}
+
+	if len(resp.TxResults) == 0 && len(resp.StateChanges) == 0 {
+		return nil
+	}
+
+	resp.Block = block.Copy()
+	resp.Block.Payload = nil
+	return resp
+}
+
+func (f *StreamingFilter) isEmpty() bool {
+	return len(f.ContractIDs) == 0 && len(f.InstanceIDs) == 0 && len(f.DarcIDs) == 0
+}
+
+func (f *StreamingFilter) matchContract(contractID string) bool {
+	for _, cid := range f.ContractIDs {
+		if cid == contractID {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *StreamingFilter) matchInstance(iid []byte) bool {
+	for _, id := range f.InstanceIDs {
+		if bytes.Equal(id[:], iid) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *StreamingFilter) matchDarc(darcID darc.ID) bool {
+	for _, id := range f.DarcIDs {
+		if id.Equal(darcID) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *StreamingFilter) matchStateChange(sc StateChange) bool {
+	return f.isEmpty() || f.matchContract(sc.ContractID) ||
+		f.matchInstance(sc.InstanceID) || f.matchDarc(sc.DarcID)
+}
+
+// matchInstruction checks if the instruction matches the filter. A spawn is
+// governed by the darc it is sent to, while the other instructions are
+// governed by the darc of the instance they modify.
+func (f *StreamingFilter) matchInstruction(instr Instruction, governed map[string]bool) bool {
+	return f.isEmpty() || f.matchContract(instr.ContractID()) ||
+		f.matchInstance(instr.InstanceID[:]) ||
+		f.matchDarc(darc.ID(instr.InstanceID[:])) ||
+		governed[string(instr.InstanceID[:])]
+}
+
 // StreamTransactions will stream all transactions IDs to the client until the
-// client closes the connection.
+// client closes the connection. If the request has a filter, only the
+// matching transactions and state changes are streamed. If the request has a
+// block to resume from, the blocks after it are streamed before the new ones.
 func (s *Service) StreamTransactions(msg *StreamingRequest) (chan *StreamingResponse, chan bool, error) {
+	if msg.ResumeFrom != nil {
+		return s.resumeStreamTransactions(msg)
+	}
+
 	stopChan := make(chan bool)
 	key := string(msg.ID)
-	outChan := s.streamingMan.newListener(key)
+	outChan := s.streamingMan.newListener(key, msg.Filter)
 
 	go func() {
 		s.closedMutex.Lock()
@@ -124,6 +245,122 @@ func (s *Service) StreamTransactions(msg *StreamingRequest) (chan *StreamingResp
 	return outChan, stopChan, nil
 }
 
+// resumeStreamTransactions first sends the blocks after msg.ResumeFrom that
+// are already stored, and then relays the new blocks. The blocks are relayed
+// through an intermediate listener so that the new blocks can be dropped if
+// they have already been sent while catching up.
+func (s *Service) resumeStreamTransactions(msg *StreamingRequest) (chan *StreamingResponse, chan bool, error) {
+	from := s.db().GetByID(msg.ResumeFrom)
+	if from == nil || !from.SkipChainID().Equal(msg.ID) {
+		return nil, nil, xerrors.New("unknown block to resume from")
+	}
+
+	stopChan := make(chan bool)
+	outChan := make(chan *StreamingResponse)
+	key := string(msg.ID)
+
+	go func() {
+		defer close(outChan)
+
+		s.closedMutex.Lock()
+		if s.closed {
+			s.closedMutex.Unlock()
+			return
+		}
+		s.working.Add(1)
+		defer s.working.Done()
+		s.closedMutex.Unlock()
+
+		// The bulk of the missing blocks is sent before listening so that
+		// the new blocks are not held back.
+		last, ok := s.streamBlocksAfter(from, msg.Filter, outChan, stopChan)
+		if !ok {
+			return
+		}
+
+		// The listener keeps the new blocks while catching up, and it is
+		// dropped if too many of them come in before the end.
+		liveChan := s.streamingMan.newListener(key, msg.Filter)
+		defer s.streamingMan.stopListener(key, liveChan)
+
+		// Blocks added between the first catch up and the creation of
+		// the listener.
+		last, ok = s.streamBlocksAfter(last, msg.Filter, outChan, stopChan)
+		if !ok {
+			return
+		}
+
+		for {
+			select {
+			case resp, ok := <-liveChan:
+				if !ok {
+					return
+				}
+				if resp.Block.Index <= last.Index {
+					continue
+				}
+				select {
+				case outChan <- resp:
+				case <-stopChan:
+					return
+				}
+			case <-stopChan:
+				return
+			}
+		}
+	}()
+	return outChan, stopChan, nil
+}
+
+// streamBlocksAfter sends the blocks following sb that are already applied to
+// the state trie. It returns the last block sent, or sb if there was none,
+// and false if the connection has been stopped.
+func (s *Service) streamBlocksAfter(sb *skipchain.SkipBlock, filter *StreamingFilter,
+	outChan chan *StreamingResponse, stopChan chan bool) (*skipchain.SkipBlock, bool) {
+	st, err := s.getStateTrie(sb.SkipChainID())
+	if err != nil {
+		log.Error(s.ServerIdentity(), "couldn't get trie to resume stream:", err)
+		return sb, false
+	}
+	trieIndex := st.GetIndex()
+
+	for len(sb.ForwardLink) > 0 {
+		next := s.db().GetByID(sb.ForwardLink[0].To)
+		if next == nil || next.Index > trieIndex {
+			break
+		}
+
+		var body DataBody
+		err := protobuf.Decode(next.Payload, &body)
+		if err != nil {
+			log.Error(s.ServerIdentity(), "couldn't decode body to resume stream:", err)
+			return sb, false
+		}
+		// The state changes are only available while the storage keeps
+		// them.
+		entries, err := s.stateChangeStorage.getByBlock(next.SkipChainID(), next.Index)
+		if err != nil {
+			log.Error(s.ServerIdentity(), "couldn't get state changes to resume stream:", err)
+			return sb, false
+		}
+		scs := make(StateChanges, len(entries))
+		for i, e := range entries {
+			scs[i] = e.StateChange
+		}
+
+		resp := newStreamingResponse(next, body.TxResults, scs, filter)
+		if resp != nil {
+			select {
+			case outChan <- resp:
+			case <-stopChan:
+				return sb, false
+			}
+		}
+		sb = next
+	}
+	return sb, true
+}
+
 // PaginateBlocks return blocks with pagination, ie. N asynchounous requests
 // that contain each K consecutive block. The caller is responsible for closing
 // the close chan when the caller wants to close the connection.
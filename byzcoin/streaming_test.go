@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3/darc"
 	"go.dedis.ch/cothority/v3/skipchain"
 )
 
@@ -393,3 +394,137 @@ func TestStreamingService_PaginateBlocks(t *testing.T) {
 
 	close(closeChan)
 }
+
+func TestStreamingFilter_Response(t *testing.T) {
+	darcID := darc.ID(NewInstanceID([]byte("darc")).Slice())
+	other := darc.ID(NewInstanceID([]byte("other")).Slice())
+	spawn := createSpawnInstr(darcID, dummyContract, "data", []byte{1})
+	invoke := createInvokeInstr(NewInstanceID([]byte("coin")), "coin", "mint", "data", []byte{2})
+	refused := createSpawnInstr(other, invalidContract, "data", []byte{3})
+
+	txs := TxResults{
+		{ClientTransaction: ClientTransaction{Instructions: Instructions{spawn}}, Accepted: true},
+		{ClientTransaction: ClientTransaction{Instructions: Instructions{invoke}}, Accepted: true},
+		{ClientTransaction: ClientTransaction{Instructions: Instructions{refused}}, Accepted: false},
+	}
+	scs := StateChanges{
+		NewStateChange(Create, NewInstanceID(spawn.Hash()), dummyContract, []byte{1}, darcID),
+		NewStateChange(Update, NewInstanceID([]byte("coin")), "coin", []byte{2}, darcID),
+	}
+	block := skipchain.NewSkipBlock()
+	block.Payload = []byte("payload")
+
+	// Without a filter, the whole block is sent.
+	resp := newStreamingResponse(block, txs, scs, nil)
+	require.Equal(t, block, resp.Block)
+	require.Nil(t, resp.TxResults)
+
+	// Empty filter to drop the refused transactions.
+	resp = newStreamingResponse(block, txs, scs, &StreamingFilter{OnlyAccepted: true})
+	require.Equal(t, 2, len(resp.TxResults))
+	require.Equal(t, 2, len(resp.StateChanges))
+	require.Nil(t, resp.Block.Payload)
+	require.Equal(t, []byte("payload"), block.Payload)
+
+	// Filter by contract.
+	resp = newStreamingResponse(block, txs, scs, &StreamingFilter{ContractIDs: []string{"coin"}})
+	require.Equal(t, 1, len(resp.TxResults))
+	require.Equal(t, invoke, resp.TxResults[0].ClientTransaction.Instructions[0])
+	require.Equal(t, 1, len(resp.StateChanges))
+
+	// Filter by instance.
+	resp = newStreamingResponse(block, txs, scs, &StreamingFilter{InstanceIDs: []InstanceID{NewInstanceID(spawn.Hash())}})
+	require.Equal(t, 0, len(resp.TxResults))
+	require.Equal(t, 1, len(resp.StateChanges))
+
+	// Filter by darc: the spawn is sent to the darc and the invoke updates
+	// an instance governed by the darc.
+	resp = newStreamingResponse(block, txs, scs, &StreamingFilter{DarcIDs: []darc.ID{darcID}})
+	require.Equal(t, 2, len(resp.TxResults))
+	require.Equal(t, 2, len(resp.StateChanges))
+
+	resp = newStreamingResponse(block, txs, scs, &StreamingFilter{DarcIDs: []darc.ID{other}, OnlyAccepted: true})
+	require.Nil(t, resp)
+}
+
+// TestStreamingManager_SlowListener checks that a listener that doesn't read
+// its blocks is dropped instead of blocking the other ones.
+func TestStreamingManager_SlowListener(t *testing.T) {
+	sm := streamingManager{}
+	slow := sm.newListener("chain", nil)
+	fast := sm.newListener("chain", nil)
+
+	block := skipchain.NewSkipBlock()
+	for i := 0; i <= streamingBufferSize; i++ {
+		sm.notify("chain", block, nil, nil)
+		<-fast
+	}
+	for i := 0; i < streamingBufferSize; i++ {
+		<-slow
+	}
+	_, ok := <-slow
+	require.False(t, ok)
+	require.Equal(t, 1, len(sm.listeners["chain"]))
+
+	sm.notify("chain", block, nil, nil)
+	<-fast
+	// Stopping a dropped listener does nothing.
+	sm.stopListener("chain", slow)
+	require.Equal(t, 1, len(sm.listeners["chain"]))
+}
+
+func TestStreamingService_Resume(t *testing.T) {
+	s := newSer(t, 1, testInterval)
+	defer s.local.CloseAll()
+	service := s.service()
+
+	n := 2
+	for i := 0; i < n; i++ {
+		_, _, _, err, err2 := sendTransactionWithCounter(t, s, 0, dummyContract, 10, uint64(i+1))
+		require.NoError(t, err)
+		require.NoError(t, err2)
+	}
+
+	_, _, err := service.StreamTransactions(&StreamingRequest{
+		ID:         s.genesis.SkipChainID(),
+		ResumeFrom: skipchain.SkipBlockID(make([]byte, 32)),
+	})
+	require.Error(t, err)
+
+	out, stop, err := service.StreamTransactions(&StreamingRequest{
+		ID:         s.genesis.SkipChainID(),
+		ResumeFrom: s.genesis.Hash,
+		Filter: &StreamingFilter{
+			ContractIDs:  []string{dummyContract},
+			OnlyAccepted: true,
+		},
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < n; i++ {
+		select {
+		case resp := <-out:
+			require.Equal(t, i+1, resp.Block.Index)
+			require.Nil(t, resp.Block.Payload)
+			require.Equal(t, 1, len(resp.TxResults))
+			require.Equal(t, 1, len(resp.StateChanges))
+			require.Equal(t, dummyContract, resp.StateChanges[0].ContractID)
+		case <-time.After(10 * s.interval):
+			t.Fatal("didn't get the missing blocks")
+		}
+	}
+
+	// A new block is relayed after the missing ones.
+	_, _, _, err, err2 := sendTransactionWithCounter(t, s, 0, dummyContract, 0, uint64(n+1))
+	require.NoError(t, err)
+	require.NoError(t, err2)
+	select {
+	case resp := <-out:
+		require.Equal(t, n+1, resp.Block.Index)
+		require.Equal(t, 1, len(resp.TxResults))
+	case <-time.After(10 * s.interval):
+		t.Fatal("didn't get the new block")
+	}
+
+	close(stop)
+}
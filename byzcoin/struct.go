@@ -505,6 +505,45 @@ func (s *stateChangeStorage) getByBlock(sid skipchain.SkipBlockID, idx int) (ent
 	return
 }
 
+// getUntilBlock returns the state changes of all the blocks up to the given
+// index, in the order they have been applied to the state trie.
+func (s *stateChangeStorage) getUntilBlock(sid skipchain.SkipBlockID, idx int) (entries StateChangeEntries, err error) {
+	s.Lock()
+	defer s.Unlock()
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		b := s.getBucket(tx, sid)
+		if b == nil {
+			return nil
+		}
+
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			// The block index is at the end of the key.
+			if int64(binary.BigEndian.Uint64(k[len(k)-8:])) > int64(idx) {
+				continue
+			}
+			var sce StateChangeEntry
+			err = protobuf.Decode(v, &sce)
+			if err != nil {
+				return xerrors.Errorf("decoding: %v", err)
+			}
+
+			entries = append(entries, sce)
+		}
+
+		return nil
+	})
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].BlockIndex != entries[j].BlockIndex {
+			return entries[i].BlockIndex < entries[j].BlockIndex
+		}
+		return entries[i].TxIndex < entries[j].TxIndex
+	})
+	err = cothority.ErrorOrNil(err, "tx error")
+	return
+}
+
 // getLast looks for the last version of a given instance and return the entry. Use
 // the bool value to know if there is a hit or not.
 func (s *stateChangeStorage) getLast(iid []byte, sid skipchain.SkipBlockID) (sce StateChangeEntry, ok bool, err error) {
@@ -645,6 +684,25 @@ func (c ChainConfig) sanityCheck(old *ChainConfig) error {
 	if len(c.Roster.List) < 3 {
 		return xerrors.New("need at least 3 nodes to have a majority")
 	}
+	if c.TxFees != nil {
+		if err := c.TxFees.sanityCheck(); err != nil {
+			return xerrors.Errorf("fees: %v", err)
+		}
+	}
+	if c.RateLimit != nil {
+		if err := c.RateLimit.sanityCheck(); err != nil {
+			return xerrors.Errorf("rate limit: %v", err)
+		}
+	}
+	if c.LeaderRotation != nil {
+		if err := c.LeaderRotation.sanityCheck(); err != nil {
+			return xerrors.Errorf("leader rotation: %v", err)
+		}
+		// A view-change block can only be created with 4 nodes or more.
+		if len(c.Roster.List) < 4 {
+			return xerrors.New("need at least 4 nodes to rotate the leader")
+		}
+	}
 	if old != nil {
 		return cothority.ErrorOrNil(old.checkNewRoster(c.Roster), "roster check: %v")
 	}
@@ -701,5 +759,20 @@ func (c ChainConfig) String() string {
 	for i, darcID := range c.DarcContractIDs {
 		fmt.Fprintf(res, "--- darc contract ID %d: %s\n", i, darcID)
 	}
+	if c.TxFees != nil {
+		fmt.Fprintf(res, "-- TxFees: %d coins of %x per instruction\n",
+			c.TxFees.Instruction, c.TxFees.Coin[:])
+		if c.TxFees.Account != nil {
+			fmt.Fprintf(res, "--- paid to %x\n", c.TxFees.Account[:])
+		}
+	}
+	if c.RateLimit != nil {
+		fmt.Fprintf(res, "-- RateLimit: %d transactions per %s\n",
+			c.RateLimit.Transactions, c.RateLimit.Window)
+	}
+	if c.LeaderRotation != nil {
+		fmt.Fprintf(res, "-- LeaderRotation: %d blocks / %s\n",
+			c.LeaderRotation.Blocks, c.LeaderRotation.Interval)
+	}
 	return res.String()
 }
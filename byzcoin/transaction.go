@@ -9,7 +9,6 @@ import (
 	"hash"
 	"regexp"
 	"strings"
-	"sync"
 
 	"go.dedis.ch/cothority/v3"
 	"go.dedis.ch/cothority/v3/byzcoin/trie"
@@ -414,11 +413,13 @@ func (instr Instruction) VerifyWithOption(st ReadOnlyStateTrie, msg []byte, ops
 		return d
 	}
 
-	if ops.EvalAttr != nil {
-		err := darc.EvalExprAttr(d.Rules.Get(darc.Action(instr.Action())), getDarc, ops.EvalAttr, identitiesWithCorrectSignatures...)
-		return cothority.ErrorOrNil(err, "evaluating darc")
+	// The built-in attributes are always available, but the contract can
+	// replace them with its own interpreters.
+	attrFuncs := BuiltinAttrInterpreters(st, instr)
+	for name, f := range ops.EvalAttr {
+		attrFuncs[name] = f
 	}
-	err = darc.EvalExpr(d.Rules.Get(darc.Action(instr.Action())), getDarc, identitiesWithCorrectSignatures...)
+	err = darc.EvalExprAttr(d.Rules.Get(darc.Action(instr.Action())), getDarc, attrFuncs, identitiesWithCorrectSignatures...)
 	return cothority.ErrorOrNil(err, "evaluating darc")
 }
 
@@ -512,6 +513,50 @@ func (txr TxResults) Hash() []byte {
 	return h.Sum(nil)
 }
 
+// ReceiptsHash returns the sha256 hash of the receipts of the transactions,
+// or nil if one of the transactions has no receipt.
+func (txr TxResults) ReceiptsHash() []byte {
+	h := sha256.New()
+	for _, tx := range txr {
+		r := tx.Receipt
+		if r == nil {
+			return nil
+		}
+		binary.Write(h, binary.LittleEndian, int64(r.FailedInstruction))
+		binary.Write(h, binary.LittleEndian, uint64(len(r.Error)))
+		h.Write([]byte(r.Error))
+		binary.Write(h, binary.LittleEndian, uint64(len(r.StateChangesHash)))
+		h.Write(r.StateChangesHash)
+	}
+	return h.Sum(nil)
+}
+
+// newTxReceipt creates the receipt of a transaction from the result of its
+// execution.
+func newTxReceipt(states StateChanges, results []InstructionResult, err error) *TxReceipt {
+	if err != nil {
+		return &TxReceipt{
+			FailedInstruction: len(results) - 1,
+			Error:             err.Error(),
+		}
+	}
+	return &TxReceipt{
+		FailedInstruction: -1,
+		StateChangesHash:  states.Hash(),
+	}
+}
+
+// sameOutcome returns true if both receipts describe the same execution of a
+// transaction. The error messages are not compared as they depend on the
+// node that ran the transaction.
+func (r *TxReceipt) sameOutcome(other *TxReceipt) bool {
+	if r == nil || other == nil {
+		return r == other
+	}
+	return r.FailedInstruction == other.FailedInstruction &&
+		bytes.Equal(r.StateChangesHash, other.StateChangesHash)
+}
+
 // SetVersion makes sure the underlying data will use the implementation
 // of the given version.
 func (txr TxResults) SetVersion(version Version) {
@@ -645,64 +690,3 @@ func (sc StateAction) String() string {
 		return "Invalid stateChange"
 	}
 }
-
-const defaultMaxBufferSize = 1000
-
-// txBuffer is thread-safe data structure that store client transactions.
-type txBuffer struct {
-	sync.Mutex
-	txsMap map[string][]ClientTransaction
-}
-
-func newTxBuffer() txBuffer {
-	return txBuffer{
-		txsMap: make(map[string][]ClientTransaction),
-	}
-}
-
-func (r *txBuffer) take(key string, max int) []ClientTransaction {
-	r.Lock()
-	defer r.Unlock()
-
-	txs, ok := r.txsMap[key]
-	if !ok {
-		return []ClientTransaction{}
-	}
-
-	out := len(txs)
-	if max >= 0 && out > max {
-		// Take only up to the maximum required transactions.
-		out = max
-	}
-
-	ret := make([]ClientTransaction, out)
-	copy(ret, txs)
-
-	if out == max {
-		// Keep the overflow for the next collection.
-		r.txsMap[key] = txs[max:]
-	} else {
-		delete(r.txsMap, key)
-	}
-
-	return ret
-}
-
-func (r *txBuffer) add(key string, newTx ClientTransaction) {
-	r.Lock()
-	defer r.Unlock()
-
-	if txs, ok := r.txsMap[key]; !ok {
-		r.txsMap[key] = []ClientTransaction{newTx}
-	} else {
-		if len(txs) >= defaultMaxBufferSize {
-			// Drop transactions if the buffer is full. We cannot drop earlier
-			// transactions because an attacker could send multiple ones to
-			// replace legit transactions.
-			return
-		}
-
-		txs = append(txs, newTx)
-		r.txsMap[key] = txs
-	}
-}
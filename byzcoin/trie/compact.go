@@ -0,0 +1,72 @@
+package trie
+
+import (
+	"golang.org/x/xerrors"
+)
+
+// nodeKeyLen is the length of the keys of the nodes, i.e., the length of the
+// hashes. The metadata keys are always shorter.
+const nodeKeyLen = 32
+
+type reachableNodeProcessor struct {
+	keys map[string]bool
+}
+
+func (p *reachableNodeProcessor) OnEmpty(n emptyNode, k, v []byte) error {
+	p.keys[string(k)] = true
+	return nil
+}
+
+func (p *reachableNodeProcessor) OnLeaf(n leafNode, k, v []byte) error {
+	p.keys[string(k)] = true
+	return nil
+}
+
+func (p *reachableNodeProcessor) OnInterior(n interiorNode, k, v []byte) error {
+	p.keys[string(k)] = true
+	return nil
+}
+
+// Compact removes the nodes that cannot be reached from the root of the trie.
+// It returns how many nodes have been removed and the number of bytes of
+// their keys and values. The metadata is not modified.
+func (t *Trie) Compact() (nodes int, size int, err error) {
+	err = t.db.Update(func(b Bucket) error {
+		nodes, size, err = t.CompactWithBucket(b)
+		return err
+	})
+	return
+}
+
+// CompactWithBucket is the same as Compact but it must be called in a
+// DB.Update transaction.
+func (t *Trie) CompactWithBucket(b Bucket) (nodes int, size int, err error) {
+	rootKey := t.GetRootWithBucket(b)
+	if rootKey == nil {
+		return 0, 0, xerrors.New("no root key")
+	}
+	p := reachableNodeProcessor{keys: make(map[string]bool)}
+	if err = t.dfs(&p, rootKey, b); err != nil {
+		return 0, 0, err
+	}
+
+	// The bucket must not be modified while iterating over it, so the
+	// keys are collected first.
+	var unreachable [][]byte
+	err = b.ForEach(func(k, v []byte) error {
+		if len(k) == nodeKeyLen && !p.keys[string(k)] {
+			unreachable = append(unreachable, clone(k))
+			size += len(k) + len(v)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, k := range unreachable {
+		if err = b.Delete(k); err != nil {
+			return 0, 0, err
+		}
+	}
+	return len(unreachable), size, nil
+}
@@ -0,0 +1,64 @@
+package trie
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompact(t *testing.T) {
+	testMemAndDisk(t, testCompact)
+}
+
+func testCompact(t *testing.T, db DB) {
+	testTrie, err := NewTrie(db, genNonce())
+	require.NoError(t, err)
+
+	for i := 0; i < 20; i++ {
+		k := []byte{byte(i)}
+		require.NoError(t, testTrie.Set(k, k))
+	}
+	for i := 0; i < 5; i++ {
+		require.NoError(t, testTrie.Delete([]byte{byte(i)}))
+	}
+	root := testTrie.GetRoot()
+
+	// Nothing to remove in a consistent trie.
+	nodes, size, err := testTrie.Compact()
+	require.NoError(t, err)
+	require.Equal(t, 0, nodes)
+	require.Equal(t, 0, size)
+
+	// Add dangling nodes.
+	err = testTrie.DB().Update(func(b Bucket) error {
+		for i := 0; i < 3; i++ {
+			k := make([]byte, nodeKeyLen)
+			k[0] = byte(i)
+			if err := b.Put(k, []byte("dangling")); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Error(t, testTrie.IsValid())
+
+	nodes, size, err = testTrie.Compact()
+	require.NoError(t, err)
+	require.Equal(t, 3, nodes)
+	require.Equal(t, 3*(nodeKeyLen+len("dangling")), size)
+	require.NoError(t, testTrie.IsValid())
+	require.Equal(t, root, testTrie.GetRoot())
+
+	for i := 5; i < 20; i++ {
+		val, err := testTrie.Get([]byte{byte(i)})
+		require.NoError(t, err)
+		require.Equal(t, []byte{byte(i)}, val)
+	}
+
+	// The metadata is kept.
+	require.NoError(t, testTrie.SetMetadata([]byte("meta"), []byte("data")))
+	_, _, err = testTrie.Compact()
+	require.NoError(t, err)
+	require.Equal(t, []byte("data"), testTrie.GetMetadata([]byte("meta")))
+}
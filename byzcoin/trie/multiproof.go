@@ -0,0 +1,321 @@
+package trie
+
+import (
+	"bytes"
+	"crypto/sha256"
+
+	"golang.org/x/xerrors"
+)
+
+// ErrTooManyNodes is returned when a proof would hold more nodes than
+// allowed.
+var ErrTooManyNodes = xerrors.New("too many nodes in the proof")
+
+// multiProofBuilder adds the nodes to the proof, skipping the ones that are
+// already in it. If max is bigger than 0, the building stops with
+// ErrTooManyNodes once the proof holds more than max nodes.
+type multiProofBuilder struct {
+	p    *MultiProof
+	seen map[string]bool
+	max  int
+}
+
+func (mb *multiProofBuilder) add(k []byte) (bool, error) {
+	if mb.seen[string(k)] {
+		return false, nil
+	}
+	mb.seen[string(k)] = true
+	if mb.max > 0 && len(mb.seen) > mb.max {
+		return false, ErrTooManyNodes
+	}
+	return true, nil
+}
+
+func (mb *multiProofBuilder) OnEmpty(n emptyNode, k, v []byte) error {
+	ok, err := mb.add(k)
+	if ok {
+		mb.p.Empties = append(mb.p.Empties, n)
+	}
+	return err
+}
+
+func (mb *multiProofBuilder) OnLeaf(n leafNode, k, v []byte) error {
+	ok, err := mb.add(k)
+	if ok {
+		mb.p.Leaves = append(mb.p.Leaves, n)
+	}
+	return err
+}
+
+func (mb *multiProofBuilder) OnInterior(n interiorNode, k, v []byte) error {
+	ok, err := mb.add(k)
+	if ok {
+		mb.p.Interiors = append(mb.p.Interiors, n)
+	}
+	return err
+}
+
+// GetMultiProof gets the inclusion/absence proof for all the given keys.
+func (t *Trie) GetMultiProof(keys [][]byte) (*MultiProof, error) {
+	p := &MultiProof{noHashKey: t.noHashKey}
+	err := t.db.View(func(b Bucket) error {
+		rootKey := t.GetRootWithBucket(b)
+		if rootKey == nil {
+			return xerrors.New("no root key")
+		}
+		p.Nonce = clone(t.nonce)
+		mb := &multiProofBuilder{p: p, seen: make(map[string]bool)}
+		for _, key := range keys {
+			if key == nil {
+				return xerrors.New("key is nil")
+			}
+			if err := t.getMultiProof(0, rootKey, t.binSlice(key), mb, b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return p, err
+}
+
+// GetPrefixProof gets the proof of all the keys whose path in the trie starts
+// with the given prefix. The path of a key is the hash of the key, so the
+// prefix is not related to the content of the key. The proof also shows that
+// there are no other keys under the prefix. An empty prefix returns the
+// whole trie. If maxNodes is bigger than 0 and the proof would hold more
+// nodes, ErrTooManyNodes is returned.
+func (t *Trie) GetPrefixProof(prefix []byte, maxNodes int) (*MultiProof, error) {
+	p := &MultiProof{noHashKey: t.noHashKey}
+	err := t.db.View(func(b Bucket) error {
+		rootKey := t.GetRootWithBucket(b)
+		if rootKey == nil {
+			return xerrors.New("no root key")
+		}
+		p.Nonce = clone(t.nonce)
+		mb := &multiProofBuilder{p: p, seen: make(map[string]bool), max: maxNodes}
+		return t.getMultiProof(0, rootKey, toBinSlice(prefix), mb, b)
+	})
+	return p, err
+}
+
+// getMultiProof adds the nodes on the path of the bits to the proof. Once all
+// the bits are used, the whole subtree is added to the proof.
+func (t *Trie) getMultiProof(depth int, nodeKey []byte, bits []bool, mb *multiProofBuilder, b Bucket) error {
+	if depth >= len(bits) {
+		return t.dfs(mb, nodeKey, b)
+	}
+	nodeVal := b.Get(nodeKey)
+	if len(nodeVal) == 0 {
+		return xerrors.New("invalid node key")
+	}
+	switch nodeType(nodeVal[0]) {
+	case typeEmpty:
+		node, err := decodeEmptyNode(nodeVal)
+		if err != nil {
+			return err
+		}
+		return mb.OnEmpty(node, nodeKey, nodeVal)
+	case typeLeaf:
+		node, err := decodeLeafNode(nodeVal)
+		if err != nil {
+			return err
+		}
+		return mb.OnLeaf(node, nodeKey, nodeVal)
+	case typeInterior:
+		node, err := decodeInteriorNode(nodeVal)
+		if err != nil {
+			return err
+		}
+		if err := mb.OnInterior(node, nodeKey, nodeVal); err != nil {
+			return err
+		}
+		if bits[depth] {
+			return t.getMultiProof(depth+1, node.Left, bits, mb, b)
+		}
+		// look right
+		return t.getMultiProof(depth+1, node.Right, bits, mb, b)
+	}
+	return xerrors.New("invalid node type")
+}
+
+// multiProofIndex holds the nodes of the proof indexed by their hash.
+type multiProofIndex struct {
+	interiors map[string]*interiorNode
+	leaves    map[string]*leafNode
+	empties   map[string]*emptyNode
+}
+
+func (p *MultiProof) index() multiProofIndex {
+	idx := multiProofIndex{
+		interiors: make(map[string]*interiorNode),
+		leaves:    make(map[string]*leafNode),
+		empties:   make(map[string]*emptyNode),
+	}
+	for i := range p.Interiors {
+		idx.interiors[string(p.Interiors[i].hash())] = &p.Interiors[i]
+	}
+	for i := range p.Leaves {
+		idx.leaves[string(p.Leaves[i].hash(p.Nonce))] = &p.Leaves[i]
+	}
+	for i := range p.Empties {
+		idx.empties[string(p.Empties[i].hash(p.Nonce))] = &p.Empties[i]
+	}
+	return idx
+}
+
+// GetRoot returns the Merkle root.
+func (p *MultiProof) GetRoot() []byte {
+	if len(p.Interiors) == 0 {
+		return nil
+	}
+	return p.Interiors[0].hash()
+}
+
+// Exists checks the proof for inclusion/absence of the key.
+func (p *MultiProof) Exists(key []byte) (bool, error) {
+	leaf, err := p.getLeaf(key)
+	if err != nil {
+		return false, err
+	}
+	return leaf != nil, nil
+}
+
+// Match returns true if the proof is an existence proof for the given key, any
+// error during the process of verifying the proof or if the key is absent then
+// it returns false.
+func (p *MultiProof) Match(key []byte) bool {
+	ok, err := p.Exists(key)
+	if err != nil {
+		return false
+	}
+	return ok
+}
+
+// Get returns the value associated with the given key in the proof. If the key
+// does not exist or is not proven by the proof, nil is returned.
+func (p *MultiProof) Get(key []byte) []byte {
+	leaf, err := p.getLeaf(key)
+	if err != nil || leaf == nil {
+		return nil
+	}
+	return leaf.Value
+}
+
+// getLeaf follows the path of the key from the root and returns the leaf of
+// the key, or nil if the key is absent.
+func (p *MultiProof) getLeaf(key []byte) (*leafNode, error) {
+	if key == nil {
+		return nil, xerrors.New("key is nil")
+	}
+	if len(p.Interiors) == 0 {
+		return nil, xerrors.New("no interior nodes")
+	}
+
+	idx := p.index()
+	bits := p.binSlice(key)
+	expectedHash := p.GetRoot()
+	for depth := 0; ; depth++ {
+		if interior, ok := idx.interiors[string(expectedHash)]; ok {
+			if depth >= len(bits) {
+				return nil, xerrors.New("path is too long")
+			}
+			if bits[depth] {
+				expectedHash = interior.Left
+			} else {
+				expectedHash = interior.Right
+			}
+			continue
+		}
+		if leaf, ok := idx.leaves[string(expectedHash)]; ok {
+			if !equal(bits[:depth], leaf.Prefix) {
+				return nil, xerrors.New("invalid prefix in leaf node")
+			}
+			if !bytes.Equal(leaf.Key, key) {
+				return nil, nil
+			}
+			return leaf, nil
+		}
+		if empty, ok := idx.empties[string(expectedHash)]; ok {
+			if !equal(bits[:depth], empty.Prefix) {
+				return nil, xerrors.New("invalid prefix in empty node")
+			}
+			return nil, nil
+		}
+		return nil, xerrors.New("missing node in proof")
+	}
+}
+
+// KeyValues returns all the keys and values under the prefix, as proven by
+// the proof. An error is returned if the proof doesn't contain all the nodes
+// under the prefix, so the caller can be sure that there are no other keys.
+func (p *MultiProof) KeyValues(prefix []byte) (keys [][]byte, values [][]byte, err error) {
+	if len(p.Interiors) == 0 {
+		return nil, nil, xerrors.New("no interior nodes")
+	}
+
+	idx := p.index()
+	bits := toBinSlice(prefix)
+	expectedHash := p.GetRoot()
+	depth := 0
+	for ; depth < len(bits); depth++ {
+		interior, ok := idx.interiors[string(expectedHash)]
+		if !ok {
+			break
+		}
+		if bits[depth] {
+			expectedHash = interior.Left
+		} else {
+			expectedHash = interior.Right
+		}
+	}
+
+	err = p.collect(idx, expectedHash, bits[:depth], bits, func(leaf *leafNode) {
+		keys = append(keys, leaf.Key)
+		values = append(values, leaf.Value)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return keys, values, nil
+}
+
+// collect calls cb on all the leaves under the node with the given hash and
+// path, if their path starts with the prefix.
+func (p *MultiProof) collect(idx multiProofIndex, nodeHash []byte, path []bool, prefix []bool, cb func(*leafNode)) error {
+	if interior, ok := idx.interiors[string(nodeHash)]; ok {
+		left := append(append([]bool{}, path...), true)
+		if err := p.collect(idx, interior.Left, left, prefix, cb); err != nil {
+			return err
+		}
+		right := append(append([]bool{}, path...), false)
+		return p.collect(idx, interior.Right, right, prefix, cb)
+	}
+	if leaf, ok := idx.leaves[string(nodeHash)]; ok {
+		if !equal(path, leaf.Prefix) {
+			return xerrors.New("invalid prefix in leaf node")
+		}
+		bits := p.binSlice(leaf.Key)
+		if len(bits) < len(path) || !equal(bits[:len(path)], path) {
+			return xerrors.New("leaf node in wrong place")
+		}
+		if len(bits) >= len(prefix) && equal(bits[:len(prefix)], prefix) {
+			cb(leaf)
+		}
+		return nil
+	}
+	if empty, ok := idx.empties[string(nodeHash)]; ok {
+		if !equal(path, empty.Prefix) {
+			return xerrors.New("invalid prefix in empty node")
+		}
+		return nil
+	}
+	return xerrors.New("missing node in proof")
+}
+
+func (p *MultiProof) binSlice(buf []byte) []bool {
+	if p.noHashKey {
+		return toBinSlice(buf)
+	}
+	hashKey := sha256.Sum256(buf)
+	return toBinSlice(hashKey[:])
+}
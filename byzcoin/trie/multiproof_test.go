@@ -0,0 +1,132 @@
+package trie
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiProof(t *testing.T) {
+	testMemAndDisk(t, testMultiProof)
+}
+
+func testMultiProof(t *testing.T, db DB) {
+	testTrie, err := NewTrie(db, genNonce())
+	require.NoError(t, err)
+
+	n := 50
+	for i := 0; i < n; i++ {
+		k := []byte{byte(i)}
+		require.NoError(t, testTrie.Set(k, k))
+	}
+
+	keys := [][]byte{{1}, {5}, {10}, {byte(n + 1)}}
+	p, err := testTrie.GetMultiProof(keys)
+	require.NoError(t, err)
+	require.Equal(t, testTrie.GetRoot(), p.GetRoot())
+
+	interiors := 0
+	for i, k := range keys {
+		ok, err := p.Exists(k)
+		require.NoError(t, err)
+		require.Equal(t, i < 3, ok)
+		if ok {
+			require.Equal(t, k, p.Get(k))
+		} else {
+			require.Nil(t, p.Get(k))
+		}
+
+		single, err := testTrie.GetProof(k)
+		require.NoError(t, err)
+		interiors += len(single.Interiors)
+	}
+	// At least the root is shared.
+	require.True(t, len(p.Interiors) <= interiors-len(keys)+1)
+
+	// Keys that are not in the proof cannot be verified.
+	_, err = p.Exists([]byte{20})
+	require.Error(t, err)
+	require.False(t, p.Match([]byte{20}))
+	require.Nil(t, p.Get([]byte{20}))
+
+	// A proof with a missing leaf is rejected.
+	p.Leaves = p.Leaves[1:]
+	missing := 0
+	for _, k := range keys[:3] {
+		if _, err := p.Exists(k); err != nil {
+			missing++
+		}
+	}
+	require.Equal(t, 1, missing)
+
+	_, err = testTrie.GetMultiProof([][]byte{nil})
+	require.Error(t, err)
+}
+
+func TestPrefixProof(t *testing.T) {
+	testMemAndDisk(t, testPrefixProof)
+}
+
+func testPrefixProof(t *testing.T, db DB) {
+	testTrie, err := NewTrie(db, genNonce())
+	require.NoError(t, err)
+
+	n := 200
+	for i := 0; i < n; i++ {
+		k := []byte{byte(i)}
+		require.NoError(t, testTrie.Set(k, k))
+	}
+
+	// The whole trie.
+	p, err := testTrie.GetPrefixProof(nil, 0)
+	require.NoError(t, err)
+	keys, values, err := p.KeyValues(nil)
+	require.NoError(t, err)
+	require.Equal(t, n, len(keys))
+	require.Equal(t, keys, values)
+
+	// Too many nodes for the limit.
+	_, err = testTrie.GetPrefixProof(nil, n)
+	require.Equal(t, ErrTooManyNodes, err)
+
+	// Only the keys of which the hash starts with the given byte.
+	h := sha256.Sum256([]byte{3})
+	prefix := h[:1]
+	expected := make(map[string]bool)
+	for i := 0; i < n; i++ {
+		h := sha256.Sum256([]byte{byte(i)})
+		if h[0] == prefix[0] {
+			expected[string([]byte{byte(i)})] = true
+		}
+	}
+
+	p, err = testTrie.GetPrefixProof(prefix, 0)
+	require.NoError(t, err)
+	require.Equal(t, testTrie.GetRoot(), p.GetRoot())
+	keys, _, err = p.KeyValues(prefix)
+	require.NoError(t, err)
+	require.Equal(t, len(expected), len(keys))
+	for _, k := range keys {
+		require.True(t, expected[string(k)])
+		require.True(t, p.Match(k))
+	}
+
+	// The proof doesn't cover the other prefixes.
+	_, _, err = p.KeyValues([]byte{prefix[0] + 1})
+	require.Error(t, err)
+
+	// Removing a leaf makes the proof incomplete.
+	p.Leaves = p.Leaves[1:]
+	_, _, err = p.KeyValues(prefix)
+	require.Error(t, err)
+
+	// Prefix without any key.
+	empty, err := NewTrie(NewMemDB(), genNonce())
+	require.NoError(t, err)
+	p, err = empty.GetPrefixProof(prefix, 0)
+	require.NoError(t, err)
+	keys, _, err = p.KeyValues(prefix)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+}
@@ -28,3 +28,14 @@ type Proof struct {
 	Nonce     []byte
 	noHashKey bool
 }
+
+// MultiProof contains an inclusion/absence proof for several keys, or for all
+// the keys under a prefix. The nodes shared between the paths are only
+// included once and the first interior node is the root.
+type MultiProof struct {
+	Interiors []interiorNode
+	Leaves    []leafNode
+	Empties   []emptyNode
+	Nonce     []byte
+	noHashKey bool
+}
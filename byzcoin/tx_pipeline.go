@@ -220,14 +220,14 @@ func (s *defaultTxProcessor) ProcessTx(tx ClientTransaction, inState *txProcesso
 			return &txProcessorState{
 				inState.sst,
 				inState.scs,
-				append(inState.txs, TxResult{tx, false}),
+				append(inState.txs, TxResult{ClientTransaction: tx, Accepted: false}),
 				0,
 			}
 		}
 		return &txProcessorState{
 			sstOut,
 			append(inState.scs, scsOut...),
-			append(inState.txs, TxResult{tx, true}),
+			append(inState.txs, TxResult{ClientTransaction: tx, Accepted: true}),
 			0,
 		}
 	}()
@@ -243,14 +243,14 @@ func (s *defaultTxProcessor) ProcessTx(tx ClientTransaction, inState *txProcesso
 		newStates = append(newStates, &txProcessorState{
 			inState.sst,
 			inState.scs,
-			[]TxResult{{tx, false}},
+			[]TxResult{{ClientTransaction: tx, Accepted: false}},
 			0,
 		})
 	} else {
 		newStates = append(newStates, &txProcessorState{
 			sstOut,
 			scsOut,
-			[]TxResult{{tx, true}},
+			[]TxResult{{ClientTransaction: tx, Accepted: true}},
 			0,
 		})
 	}
@@ -427,6 +427,10 @@ func (p *txPipeline) processTxs(initialState *txProcessorState) {
 					if err != nil {
 						log.Error("reverting to last known state because proposal refused:", err)
 						currentState = []*txProcessorState{p.processor.GetLatestGoodState()}
+						// The transactions of the refused block are lost, so
+						// they must be accepted again when the followers
+						// resubmit them.
+						txHashes = nil
 						break
 					}
 				}
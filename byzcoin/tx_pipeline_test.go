@@ -67,7 +67,7 @@ func (p *defaultMockTxProc) ProcessTx(tx ClientTransaction, inState *txProcessor
 	return []*txProcessorState{{
 		sst: inState.sst,
 		scs: append(inState.scs, sc),
-		txs: append(inState.txs, TxResult{tx, true}),
+		txs: append(inState.txs, TxResult{ClientTransaction: tx, Accepted: true}),
 	}}, nil
 }
 
@@ -338,7 +338,7 @@ func (p *bigMockTxProc) ProcessTx(tx ClientTransaction, inState *txProcessorStat
 			{
 				newState,
 				[]StateChange{sc},
-				[]TxResult{{tx, true}},
+				[]TxResult{{ClientTransaction: tx, Accepted: true}},
 				0,
 			},
 		}, nil
@@ -346,7 +346,7 @@ func (p *bigMockTxProc) ProcessTx(tx ClientTransaction, inState *txProcessorStat
 	return []*txProcessorState{{
 		newState,
 		append(inState.scs, sc),
-		append(inState.txs, TxResult{tx, true}),
+		append(inState.txs, TxResult{ClientTransaction: tx, Accepted: true}),
 		0,
 	}}, nil
 }
@@ -0,0 +1,147 @@
+package byzcoin
+
+import (
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/onet/v3"
+	bbolt "go.etcd.io/bbolt"
+	"golang.org/x/xerrors"
+)
+
+var bucketTxIndex = []byte("txindex")
+
+// errPrunedBlock is returned when the block of a transaction doesn't hold its
+// transactions anymore.
+var errPrunedBlock = xerrors.New("the transactions of the block have been pruned")
+
+// keyTxIndexed is the key of the ID of the latest block up to which the
+// blocks of the chain have been indexed in order. It cannot be the hash of a
+// transaction, as it is shorter.
+var keyTxIndexed = []byte("indexed")
+
+// txIndex stores the ID of the block holding each transaction, using the
+// hash of its instructions as the key, so that the receipt of a transaction
+// can be found without going through the chain.
+type txIndex struct {
+	db     *bbolt.DB
+	bucket []byte
+}
+
+func newTxIndex(c *onet.Context) *txIndex {
+	db, name := c.GetAdditionalBucket(bucketTxIndex)
+	return &txIndex{
+		db:     db,
+		bucket: name,
+	}
+}
+
+// add stores the block of each transaction. The value starts with a byte
+// telling if the transaction has been accepted, so that a copy of the
+// transaction refused in a later block doesn't replace the accepted one.
+func (ti *txIndex) add(sb *skipchain.SkipBlock, txs TxResults) error {
+	return ti.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(ti.bucket)
+		if b == nil {
+			return xerrors.New("missing bucket")
+		}
+		scb, err := b.CreateBucketIfNotExists(sb.SkipChainID())
+		if err != nil {
+			return xerrors.Errorf("creating bucket: %v", err)
+		}
+
+		for _, txr := range txs {
+			key := txr.ClientTransaction.Instructions.Hash()
+			if old := scb.Get(key); len(old) > 0 && old[0] == 1 {
+				continue
+			}
+
+			value := append([]byte{0}, sb.Hash...)
+			if txr.Accepted {
+				value[0] = 1
+			}
+			if err := scb.Put(key, value); err != nil {
+				return xerrors.Errorf("storing transaction: %v", err)
+			}
+		}
+		return nil
+	})
+}
+
+// get returns the ID of the block holding the transaction, or nil if the
+// transaction is unknown.
+func (ti *txIndex) get(scID skipchain.SkipBlockID, txHash []byte) (skipchain.SkipBlockID, error) {
+	var id skipchain.SkipBlockID
+	err := ti.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(ti.bucket)
+		if b == nil {
+			return xerrors.New("missing bucket")
+		}
+		scb := b.Bucket(scID)
+		if scb == nil {
+			return nil
+		}
+		if value := scb.Get(txHash); len(value) > 1 {
+			id = append(skipchain.SkipBlockID{}, value[1:]...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("reading index: %v", err)
+	}
+	return id, nil
+}
+
+// indexed returns the ID of the latest block up to which the blocks of the
+// chain have been indexed in order, or nil if there is none.
+func (ti *txIndex) indexed(scID skipchain.SkipBlockID) (skipchain.SkipBlockID, error) {
+	var id skipchain.SkipBlockID
+	err := ti.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(ti.bucket)
+		if b == nil {
+			return xerrors.New("missing bucket")
+		}
+		scb := b.Bucket(scID)
+		if scb == nil {
+			return nil
+		}
+		if value := scb.Get(keyTxIndexed); len(value) > 0 {
+			id = append(skipchain.SkipBlockID{}, value...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("reading index: %v", err)
+	}
+	return id, nil
+}
+
+// setIndexed stores the ID of the latest block up to which the blocks of the
+// chain have been indexed in order.
+func (ti *txIndex) setIndexed(scID skipchain.SkipBlockID, id skipchain.SkipBlockID) error {
+	return ti.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(ti.bucket)
+		if b == nil {
+			return xerrors.New("missing bucket")
+		}
+		scb, err := b.CreateBucketIfNotExists(scID)
+		if err != nil {
+			return xerrors.Errorf("creating bucket: %v", err)
+		}
+		return cothority.ErrorOrNil(scb.Put(keyTxIndexed, id), "storing block")
+	})
+}
+
+// remove deletes the index of the chain.
+func (ti *txIndex) remove(scID skipchain.SkipBlockID) error {
+	return ti.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(ti.bucket)
+		if b == nil {
+			return xerrors.New("missing bucket")
+		}
+		err := b.DeleteBucket(scID)
+		if err != nil && err != bbolt.ErrBucketNotFound {
+			return xerrors.Errorf("deleting bucket: %v", err)
+		}
+		return nil
+	})
+}
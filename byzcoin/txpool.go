@@ -0,0 +1,407 @@
+package byzcoin
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"go.dedis.ch/cothority/v3/skipchain"
+	"golang.org/x/xerrors"
+)
+
+// TxStatus describes how far a transaction went on its way into a block.
+type TxStatus int
+
+const (
+	// TxStatusUnknown means the node never saw the transaction, or forgot
+	// about it.
+	TxStatusUnknown TxStatus = iota
+	// TxStatusPending means the transaction waits in the pool of the node or
+	// has been handed over to the leader.
+	TxStatusPending
+	// TxStatusRejected means the transaction has been refused in a block, or
+	// has been dropped from the pool.
+	TxStatusRejected
+	// TxStatusIncluded means the transaction has been accepted in a block.
+	TxStatusIncluded
+)
+
+func (s TxStatus) String() string {
+	switch s {
+	case TxStatusPending:
+		return "pending"
+	case TxStatusRejected:
+		return "rejected"
+	case TxStatusIncluded:
+		return "included"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	// defaultMaxPoolSize is the maximum number of transactions waiting for
+	// a block in the pool of one chain.
+	defaultMaxPoolSize = 1000
+	// defaultMaxSignerTxs is the maximum number of transactions of the same
+	// signer waiting for a block in the pool of one chain.
+	defaultMaxSignerTxs = 100
+	// txPoolExpiration is how long a transaction waits for a leader to
+	// collect it before it is dropped.
+	txPoolExpiration = 10 * time.Minute
+	// txPoolResubmitBlocks is the number of blocks after which a transaction
+	// collected by the leader, but not in a block, is put back in the pool.
+	txPoolResubmitBlocks = 2
+	// txPoolMaxResubmissions is how many times a transaction is put back in
+	// the pool before it is dropped.
+	txPoolMaxResubmissions = 3
+	// txPoolHistorySize is the number of transaction statuses remembered.
+	txPoolHistorySize = 10000
+)
+
+// poolTx is a transaction waiting in the pool.
+type poolTx struct {
+	tx   ClientTransaction
+	hash string
+	// signer and counter are the first signer of the transaction and its
+	// counter, used to give the transactions of a signer in order.
+	signer  string
+	counter uint64
+	arrival time.Time
+	// blocks is the number of blocks created since the leader collected the
+	// transaction.
+	blocks        int
+	resubmissions int
+}
+
+// txPoolChain holds the transactions of one chain.
+type txPoolChain struct {
+	// pending are the transactions that are not yet collected by a leader,
+	// in order of arrival.
+	pending []*poolTx
+	// inFlight are the transactions collected by a leader but not yet in a
+	// block.
+	inFlight map[string]*poolTx
+}
+
+func (c *txPoolChain) contains(hash string) bool {
+	if _, ok := c.inFlight[hash]; ok {
+		return true
+	}
+	for _, ptx := range c.pending {
+		if ptx.hash == hash {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *txPoolChain) signerTxs(signer string) int {
+	n := 0
+	for _, ptx := range c.pending {
+		if ptx.signer == signer {
+			n++
+		}
+	}
+	for _, ptx := range c.inFlight {
+		if ptx.signer == signer {
+			n++
+		}
+	}
+	return n
+}
+
+// txRecord is the status of a transaction as remembered by the pool.
+type txRecord struct {
+	status     TxStatus
+	blockID    skipchain.SkipBlockID
+	blockIndex int
+	err        string
+}
+
+// txPool is a thread-safe data structure that stores the client transactions
+// of each chain until they are in a block, and remembers what happened to
+// them.
+type txPool struct {
+	sync.Mutex
+	chains map[string]*txPoolChain
+	// history maps the skipchain ID and the transaction hash to the last
+	// status of the transaction. historyKeys holds the keys in order of
+	// insertion so the oldest ones can be removed.
+	history     map[string]txRecord
+	historyKeys []string
+}
+
+func newTxPool() txPool {
+	return txPool{
+		chains:  make(map[string]*txPoolChain),
+		history: make(map[string]txRecord),
+	}
+}
+
+// add puts the transaction in the pool of the chain. A transaction that is
+// already waiting in the pool is ignored. An error is returned if the pool or
+// the share of the signer is full.
+func (p *txPool) add(key string, newTx ClientTransaction, now time.Time) error {
+	p.Lock()
+	defer p.Unlock()
+
+	p.expire(key, now)
+
+	chain, ok := p.chains[key]
+	if !ok {
+		chain = &txPoolChain{inFlight: make(map[string]*poolTx)}
+		p.chains[key] = chain
+	}
+
+	ptx := &poolTx{
+		tx:      newTx,
+		hash:    string(newTx.Instructions.Hash()),
+		arrival: now,
+	}
+	if chain.contains(ptx.hash) {
+		return nil
+	}
+
+	// We cannot drop earlier transactions when the pool is full because an
+	// attacker could send multiple ones to replace legit transactions.
+	if len(chain.pending)+len(chain.inFlight) >= defaultMaxPoolSize {
+		return xerrors.New("transaction pool is full")
+	}
+
+	for _, instr := range newTx.Instructions {
+		if len(instr.SignerIdentities) > 0 && len(instr.SignerCounter) > 0 {
+			ptx.signer = instr.SignerIdentities[0].String()
+			ptx.counter = instr.SignerCounter[0]
+			break
+		}
+	}
+	if ptx.signer != "" && chain.signerTxs(ptx.signer) >= defaultMaxSignerTxs {
+		return xerrors.Errorf("signer %s has already %d transactions in the pool",
+			ptx.signer, defaultMaxSignerTxs)
+	}
+
+	chain.pending = append(chain.pending, ptx)
+	p.setStatus(key, ptx.hash, txRecord{status: TxStatusPending})
+	return nil
+}
+
+// take returns up to max transactions of the chain (or all of them if max is
+// negative) and keeps them in flight until they are found in a block. The
+// transactions are given in order of arrival, except that the transactions
+// of the same signer are sorted by counter.
+func (p *txPool) take(key string, max int, now time.Time) []ClientTransaction {
+	p.Lock()
+	defer p.Unlock()
+
+	p.expire(key, now)
+
+	chain, ok := p.chains[key]
+	if !ok {
+		return []ClientTransaction{}
+	}
+
+	sortBySignerCounter(chain.pending)
+
+	out := len(chain.pending)
+	if max >= 0 && out > max {
+		// Take only up to the maximum required transactions and keep the
+		// overflow for the next collection.
+		out = max
+	}
+
+	ret := make([]ClientTransaction, out)
+	for i, ptx := range chain.pending[:out] {
+		ret[i] = ptx.tx
+		ptx.blocks = 0
+		chain.inFlight[ptx.hash] = ptx
+	}
+	chain.pending = chain.pending[out:]
+	p.cleanup(key)
+
+	return ret
+}
+
+// waiting returns the transactions of the chain that are not yet in a block,
+// starting with the ones collected by a leader, without changing the pool.
+func (p *txPool) waiting(key string) []ClientTransaction {
+	p.Lock()
+	defer p.Unlock()
+
+	chain, ok := p.chains[key]
+	if !ok {
+		return nil
+	}
+
+	inFlight := make([]*poolTx, 0, len(chain.inFlight))
+	for _, ptx := range chain.inFlight {
+		inFlight = append(inFlight, ptx)
+	}
+	sort.SliceStable(inFlight, func(i, j int) bool {
+		return inFlight[i].arrival.Before(inFlight[j].arrival)
+	})
+	sortBySignerCounter(inFlight)
+	pending := append([]*poolTx{}, chain.pending...)
+	sortBySignerCounter(pending)
+
+	ret := make([]ClientTransaction, 0, len(inFlight)+len(pending))
+	for _, ptx := range append(inFlight, pending...) {
+		ret = append(ret, ptx.tx)
+	}
+	return ret
+}
+
+// blockAdded records the status of the transactions of the block. The
+// transactions collected by a leader that are still missing after
+// txPoolResubmitBlocks blocks, or when the block is a view-change, are put
+// back in the pool. The reason function returns why a transaction has been
+// refused.
+func (p *txPool) blockAdded(sb *skipchain.SkipBlock, txs TxResults, viewChange bool,
+	reason func(ClientTransaction) string, now time.Time) {
+	p.Lock()
+	defer p.Unlock()
+
+	key := string(sb.SkipChainID())
+	chain, ok := p.chains[key]
+
+	for _, tx := range txs {
+		hash := string(tx.ClientTransaction.Instructions.Hash())
+		rec := txRecord{
+			status:     TxStatusIncluded,
+			blockID:    sb.Hash,
+			blockIndex: sb.Index,
+		}
+		if !tx.Accepted {
+			rec.status = TxStatusRejected
+			rec.err = reason(tx.ClientTransaction)
+		}
+		p.setStatus(key, hash, rec)
+
+		if ok {
+			delete(chain.inFlight, hash)
+			for i, ptx := range chain.pending {
+				if ptx.hash == hash {
+					chain.pending = append(chain.pending[:i], chain.pending[i+1:]...)
+					break
+				}
+			}
+		}
+	}
+
+	if ok {
+		var requeue []*poolTx
+		for hash, ptx := range chain.inFlight {
+			ptx.blocks++
+			if !viewChange && ptx.blocks < txPoolResubmitBlocks {
+				continue
+			}
+
+			delete(chain.inFlight, hash)
+			if ptx.resubmissions >= txPoolMaxResubmissions {
+				p.setStatus(key, hash, txRecord{
+					status: TxStatusRejected,
+					err: xerrors.Errorf("not in a block after %d resubmissions",
+						ptx.resubmissions).Error(),
+				})
+				continue
+			}
+			ptx.resubmissions++
+			requeue = append(requeue, ptx)
+		}
+
+		// The transactions put back are older than the pending ones, so
+		// they are collected first.
+		sort.SliceStable(requeue, func(i, j int) bool {
+			return requeue[i].arrival.Before(requeue[j].arrival)
+		})
+		chain.pending = append(requeue, chain.pending...)
+	}
+
+	p.expire(key, now)
+}
+
+// status returns the last known status of the transaction.
+func (p *txPool) status(key string, hash []byte) txRecord {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.history[key+string(hash)]
+}
+
+// expire drops the pending transactions of the chain that have not been
+// collected by a leader in time. The caller must hold the lock.
+func (p *txPool) expire(key string, now time.Time) {
+	chain, ok := p.chains[key]
+	if !ok {
+		return
+	}
+
+	pending := chain.pending[:0]
+	for _, ptx := range chain.pending {
+		if now.Sub(ptx.arrival) < txPoolExpiration {
+			pending = append(pending, ptx)
+			continue
+		}
+		p.setStatus(key, ptx.hash, txRecord{
+			status: TxStatusRejected,
+			err:    xerrors.Errorf("not collected by a leader after %v", txPoolExpiration).Error(),
+		})
+	}
+	chain.pending = pending
+	p.cleanup(key)
+}
+
+// cleanup removes the chain if it has no transactions left. The caller must
+// hold the lock.
+func (p *txPool) cleanup(key string) {
+	chain, ok := p.chains[key]
+	if ok && len(chain.pending) == 0 && len(chain.inFlight) == 0 {
+		delete(p.chains, key)
+	}
+}
+
+// setStatus records the status of a transaction. A transaction included in a
+// block keeps this status even if a copy of it is refused or resubmitted
+// later on. The caller must hold the lock.
+func (p *txPool) setStatus(key string, hash string, rec txRecord) {
+	hkey := key + hash
+	old, ok := p.history[hkey]
+	if !ok {
+		p.historyKeys = append(p.historyKeys, hkey)
+		if len(p.historyKeys) > txPoolHistorySize {
+			delete(p.history, p.historyKeys[0])
+			p.historyKeys = p.historyKeys[1:]
+		}
+	} else if old.status == TxStatusIncluded {
+		return
+	}
+	p.history[hkey] = rec
+}
+
+// sortBySignerCounter sorts the transactions of each signer by counter while
+// keeping the positions used by the signer, so that the transactions of
+// different signers stay in order of arrival.
+func sortBySignerCounter(txs []*poolTx) {
+	positions := make(map[string][]int)
+	for i, ptx := range txs {
+		if ptx.signer != "" {
+			positions[ptx.signer] = append(positions[ptx.signer], i)
+		}
+	}
+
+	for _, pos := range positions {
+		if len(pos) < 2 {
+			continue
+		}
+		sorted := make([]*poolTx, len(pos))
+		for i, p := range pos {
+			sorted[i] = txs[p]
+		}
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return sorted[i].counter < sorted[j].counter
+		})
+		for i, p := range pos {
+			txs[p] = sorted[i]
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package byzcoin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/cothority/v3/skipchain"
+)
+
+func createPoolTx(t *testing.T, signer darc.Signer, counter uint64) ClientTransaction {
+	tx, err := createOneClientTxWithCounter(darc.ID{}, "dummy", []byte{byte(counter)}, signer, counter)
+	require.NoError(t, err)
+	return tx
+}
+
+func createPoolBlock(scID skipchain.SkipBlockID, index int) *skipchain.SkipBlock {
+	sb := skipchain.NewSkipBlock()
+	sb.GenesisID = scID
+	sb.Index = index
+	sb.Hash = []byte{byte(index)}
+	return sb
+}
+
+func noReason(ClientTransaction) string {
+	return "refused"
+}
+
+func TestTxPool_Add(t *testing.T) {
+	p := newTxPool()
+	key := "abc"
+	key2 := "abcd"
+	now := time.Now()
+
+	signers := make([]darc.Signer, defaultMaxPoolSize/defaultMaxSignerTxs)
+	for i := range signers {
+		signers[i] = darc.NewSignerEd25519(nil, nil)
+		for j := 0; j < defaultMaxSignerTxs; j++ {
+			tx := createPoolTx(t, signers[i], uint64(j+1))
+			require.NoError(t, p.add(key, tx, now))
+		}
+	}
+	require.Equal(t, defaultMaxPoolSize, len(p.chains[key].pending))
+
+	// The pool of the chain is full.
+	signer := darc.NewSignerEd25519(nil, nil)
+	require.Error(t, p.add(key, createPoolTx(t, signer, 1), now))
+
+	// The share of a signer in the pool of a chain is limited.
+	for j := 0; j < defaultMaxSignerTxs; j++ {
+		require.NoError(t, p.add(key2, createPoolTx(t, signers[0], uint64(j+1)), now))
+	}
+	require.Error(t, p.add(key2, createPoolTx(t, signers[0], defaultMaxSignerTxs+1), now))
+	require.NoError(t, p.add(key2, createPoolTx(t, signer, 1), now))
+
+	// Duplicates are ignored.
+	tx := createPoolTx(t, signer, 1)
+	require.NoError(t, p.add(key2, tx, now))
+	require.Equal(t, defaultMaxSignerTxs+1, len(p.chains[key2].pending))
+	p.take(key2, -1, now)
+	require.NoError(t, p.add(key2, tx, now))
+	require.Equal(t, 0, len(p.chains[key2].pending))
+}
+
+func TestTxPool_Take(t *testing.T) {
+	p := newTxPool()
+	key := "abc"
+	now := time.Now()
+	signer := darc.NewSignerEd25519(nil, nil)
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, p.add(key, createPoolTx(t, signer, uint64(i+1)), now))
+	}
+
+	txs := p.take(key, 12, now)
+	require.Equal(t, 12, len(txs))
+	require.Equal(t, 88, len(p.chains[key].pending))
+	require.Equal(t, 12, len(p.chains[key].inFlight))
+
+	txs = p.take(key, 100, now)
+	require.Equal(t, 88, len(txs))
+	require.Equal(t, 0, len(p.chains[key].pending))
+
+	txs = p.take(key, 100, now)
+	require.Equal(t, 0, len(txs))
+
+	txs = p.take("unknown", -1, now)
+	require.Equal(t, 0, len(txs))
+}
+
+func TestTxPool_TakeDisabled(t *testing.T) {
+	p := newTxPool()
+	key := "abc"
+	now := time.Now()
+	signer := darc.NewSignerEd25519(nil, nil)
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, p.add(key, createPoolTx(t, signer, uint64(i+1)), now))
+	}
+
+	txs := p.take(key, -1, now)
+	require.Equal(t, 10, len(txs))
+	require.Equal(t, 0, len(p.chains[key].pending))
+}
+
+func TestTxPool_Order(t *testing.T) {
+	p := newTxPool()
+	key := "abc"
+	now := time.Now()
+	signerA := darc.NewSignerEd25519(nil, nil)
+	signerB := darc.NewSignerEd25519(nil, nil)
+
+	a3 := createPoolTx(t, signerA, 3)
+	b1 := createPoolTx(t, signerB, 1)
+	a1 := createPoolTx(t, signerA, 1)
+	a2 := createPoolTx(t, signerA, 2)
+	for _, tx := range []ClientTransaction{a3, b1, a1, a2} {
+		require.NoError(t, p.add(key, tx, now))
+	}
+
+	// The transactions of A are sorted by counter in the positions used by A.
+	txs := p.take(key, -1, now)
+	require.Equal(t, 4, len(txs))
+	require.Equal(t, a1.Instructions.Hash(), txs[0].Instructions.Hash())
+	require.Equal(t, b1.Instructions.Hash(), txs[1].Instructions.Hash())
+	require.Equal(t, a2.Instructions.Hash(), txs[2].Instructions.Hash())
+	require.Equal(t, a3.Instructions.Hash(), txs[3].Instructions.Hash())
+}
+
+func TestTxPool_Status(t *testing.T) {
+	p := newTxPool()
+	scID := skipchain.SkipBlockID("abc")
+	key := string(scID)
+	now := time.Now()
+	signer := darc.NewSignerEd25519(nil, nil)
+
+	tx1 := createPoolTx(t, signer, 1)
+	tx2 := createPoolTx(t, signer, 2)
+	require.Equal(t, TxStatusUnknown, p.status(key, tx1.Instructions.Hash()).status)
+
+	require.NoError(t, p.add(key, tx1, now))
+	require.NoError(t, p.add(key, tx2, now))
+	require.Equal(t, TxStatusPending, p.status(key, tx1.Instructions.Hash()).status)
+
+	p.take(key, -1, now)
+	require.Equal(t, TxStatusPending, p.status(key, tx1.Instructions.Hash()).status)
+
+	txs := NewTxResults(tx1, tx2)
+	txs[0].Accepted = true
+	sb := createPoolBlock(scID, 1)
+	p.blockAdded(sb, txs, false, noReason, now)
+
+	rec := p.status(key, tx1.Instructions.Hash())
+	require.Equal(t, TxStatusIncluded, rec.status)
+	require.Equal(t, sb.Hash, rec.blockID)
+	require.Equal(t, 1, rec.blockIndex)
+	rec = p.status(key, tx2.Instructions.Hash())
+	require.Equal(t, TxStatusRejected, rec.status)
+	require.Equal(t, "refused", rec.err)
+	_, ok := p.chains[key]
+	require.False(t, ok)
+
+	// An included transaction stays included.
+	txs[0].Accepted = false
+	p.blockAdded(createPoolBlock(scID, 2), txs, false, noReason, now)
+	require.Equal(t, TxStatusIncluded, p.status(key, tx1.Instructions.Hash()).status)
+	require.NoError(t, p.add(key, tx1, now))
+	require.Equal(t, TxStatusIncluded, p.status(key, tx1.Instructions.Hash()).status)
+}
+
+func TestTxPool_Resubmit(t *testing.T) {
+	p := newTxPool()
+	scID := skipchain.SkipBlockID("abc")
+	key := string(scID)
+	now := time.Now()
+	signer := darc.NewSignerEd25519(nil, nil)
+
+	tx := createPoolTx(t, signer, 1)
+	require.NoError(t, p.add(key, tx, now))
+
+	index := 1
+	for i := 0; i < txPoolMaxResubmissions; i++ {
+		require.Equal(t, 1, len(p.take(key, -1, now)))
+		for j := 0; j < txPoolResubmitBlocks; j++ {
+			require.Equal(t, 0, len(p.chains[key].pending))
+			p.blockAdded(createPoolBlock(scID, index), nil, false, noReason, now)
+			index++
+		}
+		require.Equal(t, 1, len(p.chains[key].pending))
+	}
+
+	// A view-change puts the transaction back in the pool immediately.
+	p.chains[key].pending[0].resubmissions = 0
+	require.Equal(t, 1, len(p.take(key, -1, now)))
+	p.blockAdded(createPoolBlock(scID, index), nil, true, noReason, now)
+	index++
+	require.Equal(t, 1, len(p.chains[key].pending))
+	require.Equal(t, TxStatusPending, p.status(key, tx.Instructions.Hash()).status)
+
+	// The transaction is dropped after too many resubmissions.
+	p.chains[key].pending[0].resubmissions = txPoolMaxResubmissions
+	require.Equal(t, 1, len(p.take(key, -1, now)))
+	for j := 0; j < txPoolResubmitBlocks; j++ {
+		p.blockAdded(createPoolBlock(scID, index), nil, false, noReason, now)
+		index++
+	}
+	_, ok := p.chains[key]
+	require.False(t, ok)
+	require.Equal(t, TxStatusRejected, p.status(key, tx.Instructions.Hash()).status)
+}
+
+func TestTxPool_Expire(t *testing.T) {
+	p := newTxPool()
+	key := "abc"
+	now := time.Now()
+	signer := darc.NewSignerEd25519(nil, nil)
+
+	tx1 := createPoolTx(t, signer, 1)
+	tx2 := createPoolTx(t, signer, 2)
+	require.NoError(t, p.add(key, tx1, now))
+	require.NoError(t, p.add(key, tx2, now.Add(txPoolExpiration/2)))
+
+	txs := p.take(key, -1, now.Add(txPoolExpiration))
+	require.Equal(t, 1, len(txs))
+	require.Equal(t, tx2.Instructions.Hash(), txs[0].Instructions.Hash())
+	require.Equal(t, TxStatusRejected, p.status(key, tx1.Instructions.Hash()).status)
+}
@@ -444,7 +444,7 @@ func (s *Service) createViewChangeBlock(req viewchange.NewViewReq, multisig []by
 		return xerrors.Errorf("signing tx: %v", err)
 	}
 
-	_, err = s.createNewBlock(req.GetGen(), rotateRoster(sb.Roster, req.GetView().LeaderIndex), []TxResult{TxResult{ctx, false}})
+	_, err = s.createNewBlock(req.GetGen(), rotateRoster(sb.Roster, req.GetView().LeaderIndex), []TxResult{{ClientTransaction: ctx, Accepted: false}})
 	return cothority.ErrorOrNil(err, "creating block")
 }
 
@@ -8,7 +8,10 @@ import (
 
 	"github.com/stretchr/testify/require"
 	"go.dedis.ch/cothority/v3/byzcoin/viewchange"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/onet/v3"
 	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/protobuf"
 )
 
 // TestService_ViewChange is an end-to-end test for view-change. We kill the
@@ -318,3 +321,107 @@ func TestViewChange_NeedCatchUp(t *testing.T) {
 	require.NotNil(t, leader)
 	require.False(t, leader.Equal(s.services[0].ServerIdentity()))
 }
+
+// Test that the leader hands over to the next node of the roster once it
+// created the number of blocks asked by the rotation policy.
+func TestViewChange_LeaderRotation(t *testing.T) {
+	s := newSerN(t, 1, testInterval, 4, defaultRotationWindow)
+	defer s.local.CloseAll()
+
+	config, err := s.service().LoadConfig(s.genesis.SkipChainID())
+	require.NoError(t, err)
+	config.LeaderRotation = &LeaderRotation{Blocks: 3}
+	configBuf, err := protobuf.Encode(config)
+	require.NoError(t, err)
+	ctx, err := combineInstrsAndSign(s.signer, Instruction{
+		InstanceID: NewInstanceID(nil),
+		Invoke: &Invoke{
+			ContractID: ContractConfigID,
+			Command:    "update_config",
+			Args:       []Argument{{Name: "config", Value: configBuf}},
+		},
+		SignerIdentities: []darc.Identity{s.signer.Identity()},
+		SignerCounter:    []uint64{1},
+		version:          CurrentVersion,
+	})
+	require.NoError(t, err)
+	s.sendTxAndWait(t, ctx, 10)
+
+	waitLeader := func(idx int) {
+		for i := 0; i < 20; i++ {
+			leader, err := s.service().getLeader(s.genesis.SkipChainID())
+			require.NoError(t, err)
+			if leader.Equal(s.services[idx].ServerIdentity()) {
+				return
+			}
+			time.Sleep(s.interval)
+		}
+		require.Fail(t, "leader has not been rotated")
+	}
+
+	// The first leader created the genesis and the config blocks, so it
+	// hands over with the next one.
+	tx, err := createOneClientTxWithCounter(s.darc.GetBaseID(), dummyContract, s.value, s.signer, 2)
+	require.NoError(t, err)
+	s.sendTxAndWait(t, tx, 10)
+	waitLeader(1)
+
+	latest, err := s.service().db().GetLatestByID(s.genesis.SkipChainID())
+	require.NoError(t, err)
+	due, err := leaderRotationDue(s.service().db(), latest, LeaderRotation{Interval: time.Hour}, time.Now())
+	require.NoError(t, err)
+	require.False(t, due)
+	due, err = leaderRotationDue(s.service().db(), latest, LeaderRotation{Interval: time.Hour}, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.True(t, due)
+
+	// The view-change block is the first of the term of the new leader.
+	for i := uint64(3); i < 5; i++ {
+		tx, err := createOneClientTxWithCounter(s.darc.GetBaseID(), dummyContract, s.value, s.signer, i)
+		require.NoError(t, err)
+		s.sendTxAndWait(t, tx, 10)
+	}
+	waitLeader(2)
+
+	config.LeaderRotation = &LeaderRotation{}
+	require.Error(t, config.sanityCheck(nil))
+	config.LeaderRotation = &LeaderRotation{Blocks: 1}
+	config.Roster = *onet.NewRoster(config.Roster.List[:3])
+	require.Error(t, config.sanityCheck(nil))
+}
+
+// Test that the leader hands over once the interval of the rotation policy
+// has elapsed, even if it doesn't create any block.
+func TestViewChange_LeaderRotationInterval(t *testing.T) {
+	s := newSerN(t, 1, testInterval, 4, defaultRotationWindow)
+	defer s.local.CloseAll()
+
+	config, err := s.service().LoadConfig(s.genesis.SkipChainID())
+	require.NoError(t, err)
+	config.LeaderRotation = &LeaderRotation{Interval: 5 * testInterval}
+	configBuf, err := protobuf.Encode(config)
+	require.NoError(t, err)
+	ctx, err := combineInstrsAndSign(s.signer, Instruction{
+		InstanceID: NewInstanceID(nil),
+		Invoke: &Invoke{
+			ContractID: ContractConfigID,
+			Command:    "update_config",
+			Args:       []Argument{{Name: "config", Value: configBuf}},
+		},
+		SignerIdentities: []darc.Identity{s.signer.Identity()},
+		SignerCounter:    []uint64{1},
+		version:          CurrentVersion,
+	})
+	require.NoError(t, err)
+	s.sendTxAndWait(t, ctx, 10)
+
+	for i := 0; i < 20; i++ {
+		leader, err := s.service().getLeader(s.genesis.SkipChainID())
+		require.NoError(t, err)
+		if !leader.Equal(s.services[0].ServerIdentity()) {
+			return
+		}
+		time.Sleep(s.interval)
+	}
+	require.Fail(t, "leader has not been rotated")
+}
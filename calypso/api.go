@@ -13,6 +13,7 @@ import (
 	"go.dedis.ch/cothority/v3/skipchain"
 	"go.dedis.ch/kyber/v3"
 	"go.dedis.ch/onet/v3"
+	"go.dedis.ch/onet/v3/log"
 	"go.dedis.ch/onet/v3/network"
 	"go.dedis.ch/protobuf"
 )
@@ -49,7 +50,7 @@ func NewClient(byzcoin *byzcoin.Client) *Client {
 // then it asks the Calypso cothority to start the DKG.
 func (c *Client) CreateLTS(ltsRoster *onet.Roster, darcID darc.ID, signers []darc.Signer, counters []uint64) (reply *CreateLTSReply, err error) {
 	// Make the transaction and get its proof
-	buf, err := protobuf.Encode(&LtsInstanceInfo{*ltsRoster})
+	buf, err := protobuf.Encode(&LtsInstanceInfo{Roster: *ltsRoster})
 	if err != nil {
 		return nil, xerrors.Errorf("encoding roster: %v", err)
 	}
@@ -93,6 +94,64 @@ func (c *Client) CreateLTS(ltsRoster *onet.Roster, darcID darc.ID, signers []dar
 	return reply, nil
 }
 
+// RefreshLTS re-randomizes the shares of the LTS, keeping the same roster and
+// the same public key. It first sends a transaction to ByzCoin to increase the
+// epoch of the LTS instance, then it asks the Calypso cothority to reshare the
+// secret. The shares of the previous epochs are useless afterwards.
+func (c *Client) RefreshLTS(ltsID byzcoin.InstanceID, signers []darc.Signer, counters []uint64) error {
+	inst := byzcoin.Instruction{
+		InstanceID: ltsID,
+		Invoke: &byzcoin.Invoke{
+			ContractID: ContractLongTermSecretID,
+			Command:    "refresh",
+		},
+		SignerCounter: counters,
+	}
+	tx := byzcoin.NewClientTransaction(byzcoin.CurrentVersion, inst)
+	if err := tx.FillSignersAndSignWith(signers...); err != nil {
+		return xerrors.Errorf("signing txn: %v", err)
+	}
+
+	atr, err := c.bcClient.AddTransactionAndWait(tx, 10)
+	if err != nil {
+		return xerrors.Errorf("adding transaction: %v", err)
+	}
+	resp, err := c.bcClient.GetProofAfter(ltsID.Slice(), true, &atr.Proof.Latest)
+	if err != nil {
+		return xerrors.Errorf("getting txn proof: %v", err)
+	}
+	var info LtsInstanceInfo
+	if err := resp.Proof.VerifyAndDecode(cothority.Suite, ContractLongTermSecretID, &info); err != nil {
+		return xerrors.Errorf("decoding LTS instance: %v", err)
+	}
+
+	err = c.c.SendProtobuf(info.Roster.List[0], &ReshareLTS{
+		Proof: resp.Proof,
+	}, &ReshareLTSReply{})
+	if err != nil {
+		return xerrors.Errorf("send ReshareLTS message: %v", err)
+	}
+	return nil
+}
+
+// LTSStatus asks every node of the roster for the epoch of its shares of the
+// LTS. The epochs are returned in the order of the roster, with -1 for the
+// nodes that didn't reply or don't hold shares of the LTS.
+func (c *Client) LTSStatus(roster *onet.Roster, ltsID byzcoin.InstanceID) []int {
+	epochs := make([]int, len(roster.List))
+	for i, si := range roster.List {
+		reply := &LTSStatusReply{}
+		err := c.c.SendProtobuf(si, &LTSStatus{LTSID: ltsID}, reply)
+		if err != nil {
+			log.Warnf("couldn't get the status of %v: %v", si, err)
+			epochs[i] = -1
+			continue
+		}
+		epochs[i] = reply.Epoch
+	}
+	return epochs
+}
+
 // Authorise adds a ByzCoinID to the list of authorized IDs. It can only be called
 // from localhost, except if the COTHORITY_ALLOW_INSECURE_ADMIN is set to 'true'.
 // Deprecated: please use Authorize.
@@ -141,6 +200,16 @@ func (c *Client) DecryptKey(dkr *DecryptKey) (reply *DecryptKeyReply, err error)
 	return reply, cothority.ErrorOrNil(err, "sending DecryptKey message")
 }
 
+// DecryptKeys takes as input many pairs of Read- and Write- Proofs and
+// re-encrypts all the secrets in a single round per LTS. The results are
+// returned in the same order as the requests, and hold an error for the
+// requests that failed.
+func (c *Client) DecryptKeys(dks *DecryptKeys) (reply *DecryptKeysReply, err error) {
+	reply = &DecryptKeysReply{}
+	err = c.c.SendProtobuf(c.bcClient.Roster.List[0], dks, reply)
+	return reply, cothority.ErrorOrNil(err, "sending DecryptKeys message")
+}
+
 // WaitProof calls the byzcoin client's wait proof
 func (c *Client) WaitProof(id byzcoin.InstanceID, interval time.Duration,
 	value []byte) (*byzcoin.Proof, error) {
@@ -237,6 +306,42 @@ func (c *Client) AddRead(proof *byzcoin.Proof, signer darc.Signer, signerCtr uin
 	return reply, nil
 }
 
+// RevokeWrite revokes a Write Instance, so that no new Read Instances can be
+// created and the key is not re-encrypted anymore.
+//
+// Input:
+//   - writeID - The instance id of the Write Instance
+//   - signer - The signer with an invoke:calypsoWrite.revoke rule on the darc
+//     of the Write Instance
+//   - signerCtr - A monotonically increasing counter for the signer
+//   - wait - The number of blocks to wait -- 0 means no wait
+//
+// Output:
+//   - reply - AddTxResponse containing the transaction response
+//   - err - Error if any, nil otherwise.
+func (c *Client) RevokeWrite(writeID byzcoin.InstanceID, signer darc.Signer,
+	signerCtr uint64, wait int) (reply *byzcoin.AddTxResponse, err error) {
+	ctx := byzcoin.NewClientTransaction(byzcoin.CurrentVersion,
+		byzcoin.Instruction{
+			InstanceID: writeID,
+			Invoke: &byzcoin.Invoke{
+				ContractID: ContractWriteID,
+				Command:    "revoke",
+			},
+			SignerCounter: []uint64{signerCtr},
+		},
+	)
+	err = ctx.FillSignersAndSignWith(signer)
+	if err != nil {
+		return nil, xerrors.Errorf("signing txn: %v", err)
+	}
+	reply, err = c.bcClient.AddTransactionAndWait(ctx, wait)
+	if err != nil {
+		return nil, xerrors.Errorf("adding txn: %v", err)
+	}
+	return reply, nil
+}
+
 // SpawnDarc spawns a Darc Instance by adding a transaction on the byzcoin client.
 // Input:
 //   - signer - The signer authorizing the spawn of this darc (calypso "admin")
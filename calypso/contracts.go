@@ -36,6 +36,9 @@ func (w Write) String() string {
 	fmt.Fprintf(out, "-- ExtraData: %s\n", w.ExtraData)
 	fmt.Fprintf(out, "-- LTSID: %s\n", w.LTSID)
 	fmt.Fprintf(out, "-- Cost: %x\n", w.Cost)
+	if w.Policy != nil {
+		fmt.Fprintf(out, "-- Policy: %+v\n", *w.Policy)
+	}
 
 	return out.String()
 }
@@ -95,6 +98,23 @@ func (c ContractWrite) Spawn(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruc
 		if !rd.Write.Equal(inst.InstanceID) {
 			return nil, nil, xerrors.New("the read request doesn't reference this write-instance")
 		}
+		var writeSC []byzcoin.StateChange
+		if c.Policy != nil {
+			index, timestamp, err := latestBlock(rst)
+			if err != nil {
+				return nil, nil, xerrors.Errorf("getting latest block: %v", err)
+			}
+			if err := c.Policy.checkRead(index, timestamp); err != nil {
+				return nil, nil, xerrors.Errorf("read refused: %v", err)
+			}
+			if c.Policy.MaxReads > 0 {
+				c.Policy.Reads++
+				writeSC, err = c.updateWrite(rst, inst.InstanceID)
+				if err != nil {
+					return nil, nil, err
+				}
+			}
+		}
 		if c.Cost.Value > 0 {
 			for i, coin := range cout {
 				if coin.Name.Equal(c.Cost.Name) {
@@ -108,12 +128,66 @@ func (c ContractWrite) Spawn(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruc
 			}
 		}
 		sc = byzcoin.StateChanges{byzcoin.NewStateChange(byzcoin.Create, inst.DeriveID(""), ContractReadID, r, darcID)}
+		sc = append(sc, writeSC...)
 	default:
 		err = xerrors.New("can only spawn writes and reads")
 	}
 	return
 }
 
+// Invoke is used to revoke a write-instance. Once revoked, no new read
+// instances can be spawned, and the key of the existing read instances is not
+// re-encrypted anymore.
+func (c ContractWrite) Invoke(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, coins []byzcoin.Coin) ([]byzcoin.StateChange, []byzcoin.Coin, error) {
+	if inst.Invoke.Command != "revoke" {
+		return nil, nil, xerrors.New("can only revoke writes")
+	}
+	if c.Policy == nil {
+		c.Policy = &WritePolicy{}
+	}
+	if c.Policy.Revoked {
+		return nil, nil, xerrors.New("the write is already revoked")
+	}
+	c.Policy.Revoked = true
+	sc, err := c.updateWrite(rst, inst.InstanceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sc, coins, nil
+}
+
+// updateWrite returns the state change storing the current write in the
+// instance.
+func (c ContractWrite) updateWrite(rst byzcoin.ReadOnlyStateTrie, id byzcoin.InstanceID) ([]byzcoin.StateChange, error) {
+	_, _, _, darcID, err := rst.GetValues(id.Slice())
+	if err != nil {
+		return nil, xerrors.Errorf("getting values: %v", err)
+	}
+	buf, err := protobuf.Encode(&c.Write)
+	if err != nil {
+		return nil, xerrors.Errorf("encoding write: %v", err)
+	}
+	return byzcoin.StateChanges{byzcoin.NewStateChange(byzcoin.Update, id, ContractWriteID, buf, darcID)}, nil
+}
+
+// latestBlock returns the index and the timestamp, in seconds, of the latest
+// block applied to the trie.
+func latestBlock(rst byzcoin.ReadOnlyStateTrie) (int, int64, error) {
+	sc, ok := rst.(byzcoin.ReadOnlySkipChain)
+	if !ok {
+		return 0, 0, xerrors.New("the skipchain is not available")
+	}
+	sb, err := sc.GetBlockByIndex(rst.GetIndex())
+	if err != nil {
+		return 0, 0, xerrors.Errorf("getting block: %v", err)
+	}
+	timestamp, err := blockTimestamp(sb)
+	if err != nil {
+		return 0, 0, err
+	}
+	return sb.Index, timestamp, nil
+}
+
 // ContractReadID references a read contract system-wide.
 const ContractReadID = "calypsoRead"
 
@@ -171,33 +245,43 @@ func (c *contractLTS) Invoke(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruc
 		return nil, nil, xerrors.Errorf("getting values: %v", err)
 	}
 
-	if inst.Invoke.Command != "reshare" {
-		return nil, nil, xerrors.New("can only reshare long-term secrets")
-	}
-	infoBuf := inst.Invoke.Args.Search("lts_instance_info")
-	if infoBuf == nil || len(infoBuf) == 0 {
-		return nil, nil, xerrors.New("need a lts_instance_info argument")
-	}
-
 	var curInfo, newInfo LtsInstanceInfo
-	err = protobuf.DecodeWithConstructors(infoBuf, &newInfo, network.DefaultConstructors(cothority.Suite))
-	if err != nil {
-		return nil, nil, xerrors.Errorf("passed lts_instance_info argument is invalid: %v", err)
-	}
 	err = protobuf.DecodeWithConstructors(curBuf, &curInfo, network.DefaultConstructors(cothority.Suite))
 	if err != nil {
 		return nil, nil, xerrors.Errorf("current info is invalid: %v", err)
 	}
 
-	// Verify the intersection between new roster and the old one. There must be
-	// at least a threshold of nodes in the intersection.
-	n := len(curInfo.Roster.List)
-	overlap := intersectRosters(&curInfo.Roster, &newInfo.Roster)
-	thr := n - (n-1)/3
-	if overlap < thr {
-		return nil, nil, xerrors.New("new roster does not overlap enough with current roster")
+	switch inst.Invoke.Command {
+	case "reshare":
+		infoBuf := inst.Invoke.Args.Search("lts_instance_info")
+		if infoBuf == nil || len(infoBuf) == 0 {
+			return nil, nil, xerrors.New("need a lts_instance_info argument")
+		}
+		err = protobuf.DecodeWithConstructors(infoBuf, &newInfo, network.DefaultConstructors(cothority.Suite))
+		if err != nil {
+			return nil, nil, xerrors.Errorf("passed lts_instance_info argument is invalid: %v", err)
+		}
+
+		// Verify the intersection between new roster and the old one. There must be
+		// at least a threshold of nodes in the intersection.
+		n := len(curInfo.Roster.List)
+		overlap := intersectRosters(&curInfo.Roster, &newInfo.Roster)
+		thr := n - (n-1)/3
+		if overlap < thr {
+			return nil, nil, xerrors.New("new roster does not overlap enough with current roster")
+		}
+	case "refresh":
+		// The shares are re-randomized between the same nodes.
+		newInfo.Roster = curInfo.Roster
+	default:
+		return nil, nil, xerrors.New("can only reshare or refresh long-term secrets")
 	}
 
+	newInfo.Epoch = curInfo.Epoch + 1
+	infoBuf, err := protobuf.Encode(&newInfo)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("encoding lts_instance_info: %v", err)
+	}
 	return byzcoin.StateChanges{byzcoin.NewStateChange(byzcoin.Update, inst.InstanceID, ContractLongTermSecretID, infoBuf, darcID)}, coins, nil
 }
 
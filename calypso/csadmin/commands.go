@@ -37,6 +37,42 @@ var cmds = cli.Commands{
 					},
 				},
 			},
+			{
+				Name:   "refresh",
+				Usage:  "re-randomizes the shares of an LTS, keeping the same roster and public key",
+				Action: dkgRefresh,
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:   "bc",
+						EnvVar: "BC",
+						Usage:  "the ByzCoin config to use (required)",
+					},
+					cli.StringFlag{
+						Name:  "instid, i",
+						Usage: "the instance id of the spawned LTS contract",
+					},
+					cli.StringFlag{
+						Name:  "sign, s",
+						Usage: "public key of the signing entity (default is the admin)",
+					},
+				},
+			},
+			{
+				Name:   "status",
+				Usage:  "prints the epoch of the shares held by every node of an LTS",
+				Action: dkgStatus,
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:   "bc",
+						EnvVar: "BC",
+						Usage:  "the ByzCoin config to use (required)",
+					},
+					cli.StringFlag{
+						Name:  "instid, i",
+						Usage: "the instance id of the spawned LTS contract",
+					},
+				},
+			},
 			{
 				Name:   "info",
 				Usage:  "prints info about an lts instance",
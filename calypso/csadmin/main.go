@@ -216,6 +216,106 @@ func dkgInfo(c *cli.Context) error {
 		return xerrors.New("couldn't decode info: " + err.Error())
 	}
 	log.Info("lts-roster is: ", ltsInfo.Roster.List)
+	log.Info("lts-epoch is: ", ltsInfo.Epoch)
+	return nil
+}
+
+// dkgRefresh increases the epoch of the LTS instance, then asks the nodes to
+// re-randomize their shares.
+func dkgRefresh(c *cli.Context) error {
+	bcArg := c.String("bc")
+	if bcArg == "" {
+		return xerrors.New("--bc flag is required")
+	}
+
+	cfg, cl, err := lib.LoadConfig(bcArg)
+	if err != nil {
+		return xerrors.Errorf("failed to load config: %v", err)
+	}
+
+	instidstr := c.String("instid")
+	if instidstr == "" {
+		return xerrors.New("please provide an LTS instance ID with --instid")
+	}
+	instid, err := hex.DecodeString(instidstr)
+	if err != nil {
+		return xerrors.Errorf("failed to decode LTS instance id: %v", err)
+	}
+
+	var signer *darc.Signer
+	sstr := c.String("sign")
+	if sstr == "" {
+		signer, err = lib.LoadKey(cfg.AdminIdentity)
+	} else {
+		signer, err = lib.LoadKeyFromString(sstr)
+	}
+	if err != nil {
+		return xerrors.Errorf("failed to parse the signer: %v", err)
+	}
+	counters, err := cl.GetSignerCounters(signer.Identity().String())
+	if err != nil {
+		return xerrors.Errorf("failed to get the signer counters: %v", err)
+	}
+
+	err = calypso.NewClient(cl).RefreshLTS(byzcoin.NewInstanceID(instid),
+		[]darc.Signer{*signer}, []uint64{counters.Counters[0] + 1})
+	if err != nil {
+		return xerrors.Errorf("failed to refresh the LTS: %v", err)
+	}
+
+	err = lib.WaitPropagation(c, cl)
+	if err != nil {
+		return xerrors.Errorf("waiting for blocks to be propagated: %v", err)
+	}
+
+	log.Info("refreshed the shares of the LTS")
+	return nil
+}
+
+// dkgStatus prints the epoch of the LTS instance and the epoch of the shares
+// of every node of its roster.
+func dkgStatus(c *cli.Context) error {
+	bcArg := c.String("bc")
+	if bcArg == "" {
+		return xerrors.New("--bc flag is required")
+	}
+
+	_, cl, err := lib.LoadConfig(bcArg)
+	if err != nil {
+		return xerrors.Errorf("failed to load config: %v", err)
+	}
+
+	instidstr := c.String("instid")
+	if instidstr == "" {
+		return xerrors.New("please provide an LTS instance ID with --instid")
+	}
+	instid, err := hex.DecodeString(instidstr)
+	if err != nil {
+		return xerrors.Errorf("failed to decode LTS instance id: %v", err)
+	}
+
+	resp, err := cl.GetProofFromLatest(instid)
+	if err != nil {
+		return xerrors.Errorf("failed to get proof: %v", err)
+	}
+	var ltsInfo calypso.LtsInstanceInfo
+	err = resp.Proof.VerifyAndDecode(cothority.Suite, calypso.ContractLongTermSecretID, &ltsInfo)
+	if err != nil {
+		return xerrors.Errorf("couldn't get the LTS instance: %v", err)
+	}
+
+	epochs := calypso.NewClient(cl).LTSStatus(&ltsInfo.Roster, byzcoin.NewInstanceID(instid))
+	log.Infof("lts-epoch is: %d", ltsInfo.Epoch)
+	for i, si := range ltsInfo.Roster.List {
+		switch {
+		case epochs[i] < 0:
+			log.Infof("- %s: unreachable", si.Address)
+		case epochs[i] < ltsInfo.Epoch:
+			log.Infof("- %s: epoch %d (outdated)", si.Address, epochs[i])
+		default:
+			log.Infof("- %s: epoch %d", si.Address, epochs[i])
+		}
+	}
 	return nil
 }
 
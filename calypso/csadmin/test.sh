@@ -30,6 +30,7 @@ main(){
     run testAuth
     run testContractLTS
     run testDkgStart
+    run testDkgRefresh
     run testContractWrite
     run testContractRead
     run testReencrypt
@@ -120,6 +121,43 @@ testDkgStart(){
     testGrep "[0-9a-f]{64}$" runCA dkg start --instid "$LTS_ID" -x
 }
 
+# Rely on:
+# - csadmin contract lts spawn
+# - csadmin authorize
+# - csadmin dkg start
+testDkgRefresh(){
+    rm -f config/*
+    runCoBG 1 2 3
+    runGrepSed "export BC=" "" runBA create --roster public.toml --interval .5s
+    eval $SED
+    [ -z "$BC" ] && exit 1
+
+    testOK runBA darc add -out_id ./darc_id.txt -out_key ./darc_key.txt -unrestricted
+    ID=`cat ./darc_id.txt`
+    KEY=`cat ./darc_key.txt`
+    testOK runBA darc rule -rule "spawn:longTermSecret" --darc $ID --sign $KEY --identity $KEY
+    testOK runBA darc rule -rule "invoke:longTermSecret.refresh" --darc $ID --sign $KEY --identity $KEY
+
+    OUTRES=`runCA0 contract lts spawn --darc "$ID" --sign "$KEY"`
+    LTS_ID=`echo "$OUTRES" | sed -n '2p'`
+    matchOK $LTS_ID ^[0-9a-f]{64}$
+
+    bcID=$( ls config/bc-* | sed -e "s/.*bc-\(.*\).cfg/\1/" )
+    testOK runCA authorize co1/private.toml $bcID
+    testOK runCA authorize co2/private.toml $bcID
+    testOK runCA authorize co3/private.toml $bcID
+    testOK runCA dkg start --instid "$LTS_ID"
+    testGrep "tls://localhost:2002: epoch 0" runCA dkg status --instid "$LTS_ID"
+
+    # no --instid
+    testFail runCA dkg refresh --sign "$KEY"
+    # the admin cannot refresh
+    testFail runCA dkg refresh --instid "$LTS_ID"
+    testOK runCA dkg refresh --instid "$LTS_ID" --sign "$KEY"
+    testGrep "lts-epoch is: 1" runCA dkg status --instid "$LTS_ID"
+    testGrep "tls://localhost:2002: epoch 1" runCA dkg status --instid "$LTS_ID"
+}
+
 # rely on:
 # - csadmin contract lts spawn
 # - csadmin authorize
@@ -27,6 +27,9 @@ type storage struct {
 	Rosters map[byzcoin.InstanceID]*onet.Roster
 	Replies map[byzcoin.InstanceID]*CreateLTSReply
 	DKS     map[byzcoin.InstanceID]*dkg.DistKeyShare
+	// Epochs holds the epoch of the LTS instance when the shares have been
+	// created or last reshared.
+	Epochs map[byzcoin.InstanceID]int
 
 	sync.Mutex
 }
@@ -69,6 +72,9 @@ func (s *Service) tryLoad() error {
 		if len(s.storage.DKS) == 0 {
 			s.storage.DKS = make(map[byzcoin.InstanceID]*dkg.DistKeyShare)
 		}
+		if len(s.storage.Epochs) == 0 {
+			s.storage.Epochs = make(map[byzcoin.InstanceID]int)
+		}
 		if len(s.storage.AuthorisedByzCoinIDs) == 0 {
 			s.storage.AuthorisedByzCoinIDs = make(map[string]bool)
 		}
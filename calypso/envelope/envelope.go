@@ -0,0 +1,248 @@
+// Package envelope encrypts the data protected by calypso on the client side.
+//
+// The data is encrypted with AES-GCM under a random key, which is small enough
+// to be stored in a calypso write instance. The ciphertext is split in chunks
+// that are kept in a Store, either in value instances on ByzCoin or off-chain,
+// and the Manifest listing the chunks is stored in the ExtraData of the write
+// instance. Once a reader got the key re-encrypted, Open fetches the chunks,
+// verifies them, and decrypts the data.
+package envelope
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/calypso"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/protobuf"
+	"golang.org/x/xerrors"
+)
+
+// KeySize is the size of the AES key, which fits in a calypso write.
+const KeySize = 16
+
+// DefaultChunkSize is the size of the plaintext of a chunk, if none is given.
+const DefaultChunkSize = 128 * 1024
+
+// MaxChunkSize is the biggest chunk accepted when decrypting, so that a
+// manifest cannot make the reader allocate too much memory.
+const MaxChunkSize = 16 * 1024 * 1024
+
+// Manifest describes how the encrypted data is split in chunks.
+type Manifest struct {
+	// ChunkSize is the size of the plaintext of every chunk, except the
+	// last one which can be smaller.
+	ChunkSize int
+	// Size is the size of the plaintext.
+	Size int64
+	// Chunks holds the encrypted chunks, in order.
+	Chunks []Chunk
+}
+
+// Chunk points to one encrypted chunk in the store.
+type Chunk struct {
+	// ID is returned by the store when the chunk is stored.
+	ID []byte
+	// Hash is the sha256 hash of the encrypted chunk.
+	Hash []byte
+}
+
+// Encode returns the protobuf representation of the manifest.
+func (m *Manifest) Encode() ([]byte, error) {
+	buf, err := protobuf.Encode(m)
+	if err != nil {
+		return nil, xerrors.Errorf("encoding manifest: %v", err)
+	}
+	return buf, nil
+}
+
+// DecodeManifest returns the manifest from its protobuf representation.
+func DecodeManifest(buf []byte) (*Manifest, error) {
+	m := &Manifest{}
+	if err := protobuf.Decode(buf, m); err != nil {
+		return nil, xerrors.Errorf("decoding manifest: %v", err)
+	}
+	return m, nil
+}
+
+// Encrypt reads the plaintext until EOF, encrypts it in chunks of chunkSize
+// bytes under a new random key, and puts the chunks in the store. It returns
+// the key and the manifest of the chunks. If chunkSize is 0, DefaultChunkSize
+// is used.
+func Encrypt(r io.Reader, store Store, chunkSize int) ([]byte, *Manifest, error) {
+	if chunkSize == 0 {
+		chunkSize = DefaultChunkSize
+	}
+	if chunkSize < 0 || chunkSize > MaxChunkSize {
+		return nil, nil, xerrors.Errorf("chunk size must be between 1 and %d", MaxChunkSize)
+	}
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, xerrors.Errorf("creating key: %v", err)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m := &Manifest{ChunkSize: chunkSize}
+	// A chunk is only sealed once the next one has been read, to know
+	// whether it is the last one.
+	plain, next := make([]byte, chunkSize), make([]byte, chunkSize)
+	n, err := readChunk(r, plain)
+	if err != nil {
+		return nil, nil, err
+	}
+	for n > 0 {
+		nextN := 0
+		if n == chunkSize {
+			nextN, err = readChunk(r, next)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		index := uint64(len(m.Chunks))
+		sealed := aead.Seal(nil, nonce(index), plain[:n], additionalData(nextN == 0))
+		id, err := store.Put(sealed)
+		if err != nil {
+			return nil, nil, xerrors.Errorf("storing chunk %d: %v", index, err)
+		}
+		hash := sha256.Sum256(sealed)
+		m.Chunks = append(m.Chunks, Chunk{ID: id, Hash: hash[:]})
+		m.Size += int64(n)
+		plain, next, n = next, plain, nextN
+	}
+	return key, m, nil
+}
+
+// Decrypt fetches the chunks of the manifest from the store, verifies and
+// decrypts them, and writes the plaintext to w. Nothing of a chunk is written
+// before it has been verified.
+func Decrypt(w io.Writer, store Store, key []byte, m *Manifest) error {
+	if m.ChunkSize <= 0 || m.ChunkSize > MaxChunkSize {
+		return xerrors.Errorf("invalid chunk size %d", m.ChunkSize)
+	}
+	if m.Size < 0 || m.Size > int64(len(m.Chunks))*int64(m.ChunkSize) ||
+		(len(m.Chunks) > 0 && m.Size <= int64(len(m.Chunks)-1)*int64(m.ChunkSize)) {
+		return xerrors.New("the size doesn't match the number of chunks")
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+
+	for i, c := range m.Chunks {
+		sealed, err := store.Get(c.ID)
+		if err != nil {
+			return xerrors.Errorf("fetching chunk %d: %v", i, err)
+		}
+		hash := sha256.Sum256(sealed)
+		if !bytes.Equal(hash[:], c.Hash) {
+			return xerrors.Errorf("chunk %d doesn't match its hash", i)
+		}
+		last := i == len(m.Chunks)-1
+		plain, err := aead.Open(nil, nonce(uint64(i)), sealed, additionalData(last))
+		if err != nil {
+			return xerrors.Errorf("decrypting chunk %d: %v", i, err)
+		}
+		expected := m.ChunkSize
+		if last {
+			expected = int(m.Size - int64(i)*int64(m.ChunkSize))
+		}
+		if len(plain) != expected {
+			return xerrors.Errorf("chunk %d has %d bytes instead of %d", i, len(plain), expected)
+		}
+		if _, err := w.Write(plain); err != nil {
+			return xerrors.Errorf("writing chunk %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// NewWrite encrypts the data and returns a calypso write holding the key, with
+// the manifest in its ExtraData. The write can then be spawned as usual, for
+// example with calypso.Client.AddWrite.
+func NewWrite(r io.Reader, store Store, chunkSize int, ltsID byzcoin.InstanceID,
+	writeDarc darc.ID, X kyber.Point) (*calypso.Write, error) {
+	key, m, err := Encrypt(r, store, chunkSize)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := m.Encode()
+	if err != nil {
+		return nil, err
+	}
+	write := calypso.NewWrite(cothority.Suite, ltsID, writeDarc, X, key)
+	if write == nil {
+		return nil, xerrors.New("couldn't embed the key in the write")
+	}
+	write.ExtraData = buf
+	return write, nil
+}
+
+// Open recovers the key of the write from the re-encrypted reply, using the
+// private key of the reader, then decrypts the data of the write and writes it
+// to w.
+func Open(w io.Writer, store Store, write *calypso.Write, reply *calypso.DecryptKeyReply,
+	xc kyber.Scalar) error {
+	key, err := reply.RecoverKey(xc)
+	if err != nil {
+		return xerrors.Errorf("recovering key: %v", err)
+	}
+	m, err := DecodeManifest(write.ExtraData)
+	if err != nil {
+		return err
+	}
+	return Decrypt(w, store, key, m)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, xerrors.Errorf("key must be %d bytes long", KeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, xerrors.Errorf("creating cipher: %v", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, xerrors.Errorf("creating AEAD: %v", err)
+	}
+	return aead, nil
+}
+
+// readChunk fills the buffer with the data and returns the number of bytes
+// read, which is smaller than the buffer only at the end of the data.
+func readChunk(r io.Reader, buf []byte) (int, error) {
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return 0, xerrors.Errorf("reading data: %v", err)
+	}
+	return n, nil
+}
+
+// additionalData marks the last chunk, so that the data cannot be truncated
+// at a chunk boundary.
+func additionalData(last bool) []byte {
+	if last {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// nonce returns the nonce of a chunk. As every write uses a new key, the
+// index of the chunk is enough to never reuse a nonce, and it prevents the
+// chunks from being reordered.
+func nonce(index uint64) []byte {
+	n := make([]byte, 12)
+	binary.BigEndian.PutUint64(n[4:], index)
+	return n
+}
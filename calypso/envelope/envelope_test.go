@@ -0,0 +1,187 @@
+package envelope
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/byzcoin/contracts"
+	"go.dedis.ch/cothority/v3/calypso"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/onet/v3"
+	"golang.org/x/xerrors"
+)
+
+type memStore map[string][]byte
+
+func (ms memStore) Put(chunk []byte) ([]byte, error) {
+	id := sha256.Sum256(chunk)
+	ms[string(id[:])] = append([]byte{}, chunk...)
+	return id[:], nil
+}
+
+func (ms memStore) Get(id []byte) ([]byte, error) {
+	chunk, ok := ms[string(id)]
+	if !ok {
+		return nil, xerrors.New("no such chunk")
+	}
+	return chunk, nil
+}
+
+func randomData(t *testing.T, size int) []byte {
+	data := make([]byte, size)
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+	return data
+}
+
+func TestEnvelope_RoundTrip(t *testing.T) {
+	for _, size := range []int{0, 1, 99, 100, 101, 1000, 1234} {
+		store := memStore{}
+		data := randomData(t, size)
+		key, m, err := Encrypt(bytes.NewReader(data), store, 100)
+		require.NoError(t, err)
+		require.Equal(t, KeySize, len(key))
+		require.Equal(t, int64(size), m.Size)
+		require.Equal(t, (size+99)/100, len(m.Chunks))
+
+		buf, err := m.Encode()
+		require.NoError(t, err)
+		m, err = DecodeManifest(buf)
+		require.NoError(t, err)
+
+		out := &bytes.Buffer{}
+		require.NoError(t, Decrypt(out, store, key, m))
+		require.Equal(t, data, out.Bytes())
+	}
+}
+
+func TestEnvelope_DiskStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "envelope")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	store, err := NewDiskStore(dir)
+	require.NoError(t, err)
+
+	data := randomData(t, 3*DefaultChunkSize+10)
+	key, m, err := Encrypt(bytes.NewReader(data), store, 0)
+	require.NoError(t, err)
+	require.Equal(t, DefaultChunkSize, m.ChunkSize)
+	require.Equal(t, 4, len(m.Chunks))
+
+	out := &bytes.Buffer{}
+	require.NoError(t, Decrypt(out, store, key, m))
+	require.Equal(t, data, out.Bytes())
+
+	_, err = store.Get([]byte("short"))
+	require.Error(t, err)
+}
+
+func TestEnvelope_Tampering(t *testing.T) {
+	store := memStore{}
+	data := randomData(t, 250)
+	key, m, err := Encrypt(bytes.NewReader(data), store, 100)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(m.Chunks))
+	out := &bytes.Buffer{}
+
+	// Wrong key
+	wrong := append([]byte{}, key...)
+	wrong[0] ^= 1
+	require.Error(t, Decrypt(out, store, wrong, m))
+
+	// Swapped chunks
+	swapped := *m
+	swapped.Chunks = []Chunk{m.Chunks[1], m.Chunks[0], m.Chunks[2]}
+	require.Error(t, Decrypt(out, store, key, &swapped))
+
+	// Truncated data
+	truncated := *m
+	truncated.Chunks = m.Chunks[:2]
+	require.Error(t, Decrypt(out, store, key, &truncated))
+	truncated.Size = 200
+	require.Error(t, Decrypt(out, store, key, &truncated))
+
+	// Modified chunk
+	id := string(m.Chunks[1].ID)
+	store[id][0] ^= 1
+	out.Reset()
+	require.Error(t, Decrypt(out, store, key, m))
+	require.Equal(t, 100, out.Len())
+	store[id][0] ^= 1
+
+	// Modified chunk with its hash
+	store[id][0] ^= 1
+	hash := sha256.Sum256(store[id])
+	m.Chunks[1].Hash = hash[:]
+	require.Error(t, Decrypt(out, store, key, m))
+}
+
+func TestEnvelope_WriteOpen(t *testing.T) {
+	// Simulates the LTS with a single private key.
+	x := cothority.Suite.Scalar().Pick(cothority.Suite.RandomStream())
+	X := cothority.Suite.Point().Mul(x, nil)
+	xc := cothority.Suite.Scalar().Pick(cothority.Suite.RandomStream())
+	Xc := cothority.Suite.Point().Mul(xc, nil)
+
+	store := memStore{}
+	data := randomData(t, 1000)
+	ltsID := byzcoin.NewInstanceID([]byte("lts"))
+	write, err := NewWrite(bytes.NewReader(data), store, 300, ltsID, darc.ID{}, X)
+	require.NoError(t, err)
+	require.NoError(t, write.CheckProof(cothority.Suite, darc.ID{}))
+
+	xU := cothority.Suite.Point().Mul(x, write.U)
+	xXc := cothority.Suite.Point().Mul(x, Xc)
+	reply := &calypso.DecryptKeyReply{
+		C:       write.C,
+		XhatEnc: cothority.Suite.Point().Add(xU, xXc),
+		X:       X,
+	}
+	out := &bytes.Buffer{}
+	require.NoError(t, Open(out, store, write, reply, xc))
+	require.Equal(t, data, out.Bytes())
+
+	wrong := cothority.Suite.Scalar().Pick(cothority.Suite.RandomStream())
+	require.Error(t, Open(out, store, write, reply, wrong))
+}
+
+func TestEnvelope_ValueStore(t *testing.T) {
+	local := onet.NewTCPTest(cothority.Suite)
+	defer local.CloseAll()
+	_, roster, _ := local.GenTree(3, true)
+
+	signer := darc.NewSignerEd25519(nil, nil)
+	msg, err := byzcoin.DefaultGenesisMsg(byzcoin.CurrentVersion, roster,
+		[]string{"spawn:" + contracts.ContractValueID}, signer.Identity())
+	require.NoError(t, err)
+	msg.BlockInterval = 500 * time.Millisecond
+	cl, _, err := byzcoin.NewLedger(msg, false)
+	require.NoError(t, err)
+	store := NewValueStore(cl, msg.GenesisDarc.GetBaseID(), signer)
+
+	data := randomData(t, 210)
+	key, m, err := Encrypt(bytes.NewReader(data), store, 100)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(m.Chunks))
+
+	// A store that only gets the chunks doesn't need the darc and the
+	// signer.
+	reader := NewValueStore(cl, nil, darc.Signer{})
+	out := &bytes.Buffer{}
+	require.NoError(t, Decrypt(out, reader, key, m))
+	require.Equal(t, data, out.Bytes())
+
+	_, err = reader.Get(byzcoin.NewInstanceID([]byte("missing")).Slice())
+	require.Error(t, err)
+	// The genesis darc exists, but it is not a chunk.
+	_, err = reader.Get(msg.GenesisDarc.GetBaseID())
+	require.Error(t, err)
+}
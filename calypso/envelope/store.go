@@ -0,0 +1,131 @@
+package envelope
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/byzcoin/contracts"
+	"go.dedis.ch/cothority/v3/darc"
+	"golang.org/x/xerrors"
+)
+
+// Store keeps the encrypted chunks. The chunks are verified when they are
+// fetched, so the store doesn't need to be trusted.
+type Store interface {
+	// Put stores the chunk and returns its ID.
+	Put(chunk []byte) ([]byte, error)
+	// Get returns the chunk with the given ID.
+	Get(id []byte) ([]byte, error)
+}
+
+// DiskStore keeps the chunks as files in a directory of the local disk. The
+// ID of a chunk is its sha256 hash.
+type DiskStore struct {
+	Dir string
+}
+
+// NewDiskStore returns a store in the directory, which is created if needed.
+func NewDiskStore(dir string) (*DiskStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, xerrors.Errorf("creating directory: %v", err)
+	}
+	return &DiskStore{Dir: dir}, nil
+}
+
+// Put implements Store.
+func (ds *DiskStore) Put(chunk []byte) ([]byte, error) {
+	id := sha256.Sum256(chunk)
+	err := ioutil.WriteFile(ds.path(id[:]), chunk, 0600)
+	if err != nil {
+		return nil, xerrors.Errorf("writing chunk: %v", err)
+	}
+	return id[:], nil
+}
+
+// Get implements Store.
+func (ds *DiskStore) Get(id []byte) ([]byte, error) {
+	if len(id) != sha256.Size {
+		return nil, xerrors.New("invalid chunk id")
+	}
+	chunk, err := ioutil.ReadFile(ds.path(id))
+	if err != nil {
+		return nil, xerrors.Errorf("reading chunk: %v", err)
+	}
+	return chunk, nil
+}
+
+func (ds *DiskStore) path(id []byte) string {
+	return filepath.Join(ds.Dir, hex.EncodeToString(id))
+}
+
+// ValueStore keeps the chunks in value instances on ByzCoin. The ID of a
+// chunk is the ID of its instance.
+type ValueStore struct {
+	cl      *byzcoin.Client
+	darcID  darc.ID
+	signer  darc.Signer
+	counter uint64
+	// Wait is the number of blocks to wait for every chunk to be stored.
+	Wait int
+}
+
+// NewValueStore returns a store spawning the value instances from the darc,
+// which must have a spawn:value rule for the signer. A store that is only used
+// to get the chunks doesn't need the darc and the signer.
+func NewValueStore(cl *byzcoin.Client, darcID darc.ID, signer darc.Signer) *ValueStore {
+	return &ValueStore{
+		cl:     cl,
+		darcID: darcID,
+		signer: signer,
+		Wait:   10,
+	}
+}
+
+// Put implements Store.
+func (vs *ValueStore) Put(chunk []byte) ([]byte, error) {
+	if vs.counter == 0 {
+		counters, err := vs.cl.GetSignerCounters(vs.signer.Identity().String())
+		if err != nil {
+			return nil, xerrors.Errorf("getting signer counters: %v", err)
+		}
+		vs.counter = counters.Counters[0]
+	}
+	ctx := byzcoin.NewClientTransaction(byzcoin.CurrentVersion,
+		byzcoin.Instruction{
+			InstanceID: byzcoin.NewInstanceID(vs.darcID),
+			Spawn: &byzcoin.Spawn{
+				ContractID: contracts.ContractValueID,
+				Args:       byzcoin.Arguments{{Name: "value", Value: chunk}},
+			},
+			SignerCounter: []uint64{vs.counter + 1},
+		},
+	)
+	if err := ctx.FillSignersAndSignWith(vs.signer); err != nil {
+		return nil, xerrors.Errorf("signing txn: %v", err)
+	}
+	if _, err := vs.cl.AddTransactionAndWait(ctx, vs.Wait); err != nil {
+		return nil, xerrors.Errorf("adding txn: %v", err)
+	}
+	vs.counter++
+	return ctx.Instructions[0].DeriveID("").Slice(), nil
+}
+
+// Get implements Store.
+func (vs *ValueStore) Get(id []byte) ([]byte, error) {
+	reply, err := vs.cl.GetProofFromLatest(id)
+	if err != nil {
+		return nil, xerrors.Errorf("getting proof: %v", err)
+	}
+	chunk, cid, _, err := reply.Proof.Get(id)
+	if err != nil {
+		return nil, xerrors.Errorf("getting chunk from proof: %v", err)
+	}
+	if cid != contracts.ContractValueID {
+		return nil, xerrors.New("the chunk is not in a value instance")
+	}
+	return chunk, nil
+}
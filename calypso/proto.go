@@ -47,6 +47,27 @@ type Write struct {
 	LTSID byzcoin.InstanceID
 	// Cost reflects how many coins you'll have to pay for a read-request
 	Cost byzcoin.Coin `protobuf:"opt"`
+	// Policy, if set, restricts the reads of this write instance.
+	Policy *WritePolicy `protobuf:"opt"`
+}
+
+// WritePolicy restricts the reads of a write instance. It is enforced when a
+// read instance is spawned and when the key is re-encrypted.
+type WritePolicy struct {
+	// MaxReads is the maximum number of read instances that can be spawned
+	// from the write instance, 0 means no limit.
+	MaxReads int `protobuf:"opt"`
+	// Reads is the number of read instances spawned so far. It is only
+	// counted if MaxReads is set.
+	Reads int `protobuf:"opt"`
+	// ExpiryBlock, if set, refuses the reads once the latest block has this
+	// index or a bigger one.
+	ExpiryBlock int `protobuf:"opt"`
+	// ExpiryTime, if set, refuses the reads once the timestamp of the latest
+	// block, in seconds since the epoch, reaches this value.
+	ExpiryTime int64 `protobuf:"opt"`
+	// Revoked refuses all the reads. It is set by the revoke command.
+	Revoked bool `protobuf:"opt"`
 }
 
 // Read is the data stored in a read instance. It has a pointer to the write
@@ -132,13 +153,46 @@ type DecryptKeyReply struct {
 	X kyber.Point
 }
 
+// DecryptKeys holds many decryption requests. All the requests for the same
+// LTS are re-encrypted in a single round.
+type DecryptKeys struct {
+	Keys []DecryptKey
+}
+
+// DecryptKeysReply holds the result of every request of DecryptKeys, in the
+// same order.
+type DecryptKeysReply struct {
+	Results []DecryptKeyResult
+}
+
+// DecryptKeyResult is the result of one decryption request. Either Reply or
+// Error is set.
+type DecryptKeyResult struct {
+	Reply *DecryptKeyReply `protobuf:"opt"`
+	Error string           `protobuf:"opt"`
+}
+
 // GetLTSReply asks for the shared public key of the corresponding LTSID
 type GetLTSReply struct {
 	// LTSID is the id of the LTS instance created.
 	LTSID byzcoin.InstanceID
 }
 
+// LTSStatus asks a node for the status of its shares of an LTS.
+type LTSStatus struct {
+	LTSID byzcoin.InstanceID
+}
+
+// LTSStatusReply holds the epoch of the LTS instance when the shares of the
+// node have been created or last reshared. A node holding shares of an older
+// epoch than the instance has missed a refresh.
+type LTSStatusReply struct {
+	Epoch int
+}
+
 // LtsInstanceInfo is the information stored in an LTS instance.
 type LtsInstanceInfo struct {
 	Roster onet.Roster
+	// Epoch is increased every time the shares are reshared or refreshed.
+	Epoch int `protobuf:"opt"`
 }
@@ -106,8 +106,6 @@ func (o *OCS) reencrypt(r structReencrypt) error {
 	log.Lvl3(o.Name() + ": starting reencrypt")
 	defer o.Done()
 
-	ui := o.getUI(r.U, r.Xc)
-
 	if o.Verify != nil {
 		if !o.Verify(&r.Reencrypt) {
 			log.Lvl2(o.ServerIdentity(), "refused to reencrypt")
@@ -116,22 +114,8 @@ func (o *OCS) reencrypt(r structReencrypt) error {
 		}
 	}
 
-	// Calculating proofs
-	si := cothority.Suite.Scalar().Pick(o.Suite().RandomStream())
-	uiHat := cothority.Suite.Point().Mul(si, cothority.Suite.Point().Add(r.U, r.Xc))
-	hiHat := cothority.Suite.Point().Mul(si, nil)
-	hash := sha256.New()
-	ui.V.MarshalTo(hash)
-	uiHat.MarshalTo(hash)
-	hiHat.MarshalTo(hash)
-	ei := cothority.Suite.Scalar().SetBytes(hash.Sum(nil))
-
 	return cothority.ErrorOrNil(
-		o.SendToParent(&ReencryptReply{
-			Ui: ui,
-			Ei: ei,
-			Fi: cothority.Suite.Scalar().Add(si, cothority.Suite.Scalar().Mul(ei, o.Shared.V)),
-		}),
+		o.SendToParent(reencryptShare(o.Shared, r.U, r.Xc)),
 		"sending ReencryptReply to parent",
 	)
 }
@@ -153,24 +137,10 @@ func (o *OCS) reencryptReply(rr structReencryptReply) error {
 	// minus one to exclude the root
 	if len(o.replies) >= int(o.Threshold-1) {
 		o.Uis = make([]*share.PubShare, len(o.List()))
-		o.Uis[0] = o.getUI(o.U, o.Xc)
+		o.Uis[0] = getUI(o.Shared, o.U, o.Xc)
 
 		for _, r := range o.replies {
-			// Verify proofs
-			ufi := cothority.Suite.Point().Mul(r.Fi, cothority.Suite.Point().Add(o.U, o.Xc))
-			uiei := cothority.Suite.Point().Mul(cothority.Suite.Scalar().Neg(r.Ei), r.Ui.V)
-			uiHat := cothority.Suite.Point().Add(ufi, uiei)
-
-			gfi := cothority.Suite.Point().Mul(r.Fi, nil)
-			gxi := o.Poly.Eval(r.Ui.I).V
-			hiei := cothority.Suite.Point().Mul(cothority.Suite.Scalar().Neg(r.Ei), gxi)
-			hiHat := cothority.Suite.Point().Add(gfi, hiei)
-			hash := sha256.New()
-			r.Ui.V.MarshalTo(hash)
-			uiHat.MarshalTo(hash)
-			hiHat.MarshalTo(hash)
-			e := cothority.Suite.Scalar().SetBytes(hash.Sum(nil))
-			if e.Equal(r.Ei) {
+			if verifyShare(o.Poly, o.U, o.Xc, r) {
 				o.Uis[r.Ui.I] = r.Ui
 			} else {
 				log.Lvl1("Received invalid share from node", r.Ui.I)
@@ -189,15 +159,56 @@ func (o *OCS) reencryptReply(rr structReencryptReply) error {
 	return nil
 }
 
-func (o *OCS) getUI(U, Xc kyber.Point) *share.PubShare {
-	v := cothority.Suite.Point().Mul(o.Shared.V, U)
-	v.Add(v, cothority.Suite.Point().Mul(o.Shared.V, Xc))
+func getUI(shared *dkgprotocol.SharedSecret, U, Xc kyber.Point) *share.PubShare {
+	v := cothority.Suite.Point().Mul(shared.V, U)
+	v.Add(v, cothority.Suite.Point().Mul(shared.V, Xc))
 	return &share.PubShare{
-		I: o.Shared.Index,
+		I: shared.Index,
 		V: v,
 	}
 }
 
+// reencryptShare returns the share of the node to re-encrypt U under Xc,
+// together with the proof that the share is correct.
+func reencryptShare(shared *dkgprotocol.SharedSecret, U, Xc kyber.Point) *ReencryptReply {
+	ui := getUI(shared, U, Xc)
+
+	// Calculating proofs
+	si := cothority.Suite.Scalar().Pick(cothority.Suite.RandomStream())
+	uiHat := cothority.Suite.Point().Mul(si, cothority.Suite.Point().Add(U, Xc))
+	hiHat := cothority.Suite.Point().Mul(si, nil)
+	hash := sha256.New()
+	ui.V.MarshalTo(hash)
+	uiHat.MarshalTo(hash)
+	hiHat.MarshalTo(hash)
+	ei := cothority.Suite.Scalar().SetBytes(hash.Sum(nil))
+
+	return &ReencryptReply{
+		Ui: ui,
+		Ei: ei,
+		Fi: cothority.Suite.Scalar().Add(si, cothority.Suite.Scalar().Mul(ei, shared.V)),
+	}
+}
+
+// verifyShare returns true if the proof of the share to re-encrypt U under Xc
+// is correct.
+func verifyShare(poly *share.PubPoly, U, Xc kyber.Point, r ReencryptReply) bool {
+	ufi := cothority.Suite.Point().Mul(r.Fi, cothority.Suite.Point().Add(U, Xc))
+	uiei := cothority.Suite.Point().Mul(cothority.Suite.Scalar().Neg(r.Ei), r.Ui.V)
+	uiHat := cothority.Suite.Point().Add(ufi, uiei)
+
+	gfi := cothority.Suite.Point().Mul(r.Fi, nil)
+	gxi := poly.Eval(r.Ui.I).V
+	hiei := cothority.Suite.Point().Mul(cothority.Suite.Scalar().Neg(r.Ei), gxi)
+	hiHat := cothority.Suite.Point().Add(gfi, hiei)
+	hash := sha256.New()
+	r.Ui.V.MarshalTo(hash)
+	uiHat.MarshalTo(hash)
+	hiHat.MarshalTo(hash)
+	e := cothority.Suite.Scalar().SetBytes(hash.Sum(nil))
+	return e.Equal(r.Ei)
+}
+
 func (o *OCS) finish(result bool) {
 	o.timeout.Stop()
 	select {
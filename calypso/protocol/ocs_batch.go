@@ -0,0 +1,210 @@
+package protocol
+
+import (
+	"sync"
+	"time"
+
+	"go.dedis.ch/cothority/v3"
+	dkgprotocol "go.dedis.ch/cothority/v3/dkg/pedersen"
+	"go.dedis.ch/kyber/v3/share"
+	"go.dedis.ch/onet/v3"
+	"go.dedis.ch/onet/v3/log"
+	"golang.org/x/xerrors"
+)
+
+func init() {
+	onet.GlobalProtocolRegister(NameOCSBatch, NewOCSBatch)
+}
+
+// OCSBatch re-encrypts many public points in one round. Every request is
+// verified and re-encrypted separately by the nodes, so that a refused request
+// doesn't make the others fail. Before calling `Start`, Shared, Poly and
+// Requests must be initialized by the caller.
+type OCSBatch struct {
+	*onet.TreeNodeInstance
+	Shared    *dkgprotocol.SharedSecret // Shared represents the private key
+	Poly      *share.PubPoly            // Represents all public keys
+	Threshold int                       // How many replies are needed to re-create the secret
+	// Requests holds the points to re-encrypt and the client's public keys,
+	// as well as the data to verify every request.
+	Requests []Reencrypt
+	// Can be set by the service to decide whether or not to
+	// do the reencryption of each request
+	Verify VerifyRequest
+	// Reencrypted receives a 'true'-value when the protocol finished, even
+	// if some of the requests failed, or 'false' if it could not start.
+	Reencrypted chan bool
+	// Uis holds the re-encrypted shares of every request. They are nil for
+	// the requests that didn't get enough shares.
+	Uis [][]*share.PubShare
+	// private fields
+	mutex    sync.Mutex
+	replies  int
+	failures []int
+	timeout  *time.Timer
+	doneOnce sync.Once
+	finished bool
+}
+
+// NewOCSBatch initialises the structure for use in one round
+func NewOCSBatch(n *onet.TreeNodeInstance) (onet.ProtocolInstance, error) {
+	o := &OCSBatch{
+		TreeNodeInstance: n,
+		Reencrypted:      make(chan bool, 1),
+		Threshold:        len(n.Roster().List) - (len(n.Roster().List)-1)/3,
+	}
+
+	err := o.RegisterHandlers(o.reencryptBatch, o.reencryptBatchReply)
+	if err != nil {
+		return nil, xerrors.Errorf("registring handlers: %v", err)
+	}
+	return o, nil
+}
+
+// Start asks all children to reply with the shared reencryptions of all the
+// requests.
+func (o *OCSBatch) Start() error {
+	log.Lvl3("Starting batch protocol with", len(o.Requests), "requests")
+	if o.Shared == nil {
+		o.finish(false)
+		return xerrors.New("please initialize Shared first")
+	}
+	if len(o.Requests) == 0 {
+		o.finish(false)
+		return xerrors.New("please initialize Requests first")
+	}
+
+	o.mutex.Lock()
+	o.Uis = make([][]*share.PubShare, len(o.Requests))
+	o.failures = make([]int, len(o.Requests))
+	for i := range o.Requests {
+		if o.Verify != nil && !o.Verify(&o.Requests[i]) {
+			log.Lvl2(o.ServerIdentity(), "refused to reencrypt request", i)
+			continue
+		}
+		o.Uis[i] = make([]*share.PubShare, len(o.List()))
+		ui := getUI(o.Shared, o.Requests[i].U, o.Requests[i].Xc)
+		o.Uis[i][ui.I] = ui
+	}
+	// The timer is set under the lock, as the replies can finish the
+	// protocol as soon as they arrive.
+	o.timeout = time.AfterFunc(1*time.Minute, func() {
+		log.Lvl1("OCS batch protocol timeout")
+		o.mutex.Lock()
+		o.done()
+		o.mutex.Unlock()
+	})
+	o.mutex.Unlock()
+
+	errs := o.Broadcast(&ReencryptBatch{Requests: o.Requests})
+	if len(errs) > (len(o.Roster().List)-1)/3 {
+		log.Errorf("Some nodes failed with error(s) %v", errs)
+		return xerrors.New("too many nodes failed in broadcast")
+	}
+	return nil
+}
+
+// reencryptBatch is received by every node to give its shares of all the
+// requests.
+func (o *OCSBatch) reencryptBatch(r structReencryptBatch) error {
+	log.Lvl3(o.Name() + ": starting reencrypt batch")
+	defer o.Done()
+
+	reply := &ReencryptBatchReply{
+		Replies: make([]ReencryptReply, len(r.Requests)),
+	}
+	for i := range r.Requests {
+		if o.Verify != nil && !o.Verify(&r.Requests[i]) {
+			log.Lvl2(o.ServerIdentity(), "refused to reencrypt request", i)
+			continue
+		}
+		reply.Replies[i] = *reencryptShare(o.Shared, r.Requests[i].U, r.Requests[i].Xc)
+	}
+	return cothority.ErrorOrNil(o.SendToParent(reply),
+		"sending ReencryptBatchReply to parent")
+}
+
+// reencryptBatchReply is the root-node collecting the shares of all the
+// requests. It finishes once every request has enough shares, or cannot get
+// enough of them anymore.
+func (o *OCSBatch) reencryptBatchReply(rr structReencryptBatchReply) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.finished {
+		return nil
+	}
+	o.replies++
+
+	replies := rr.ReencryptBatchReply.Replies
+	for i := range o.Requests {
+		if o.Uis[i] == nil {
+			continue
+		}
+		if i >= len(replies) || replies[i].Ui == nil {
+			o.failures[i]++
+			continue
+		}
+		r := replies[i]
+		if r.Ui.I < 0 || r.Ui.I >= len(o.Uis[i]) || o.Uis[i][r.Ui.I] != nil ||
+			!verifyShare(o.Poly, o.Requests[i].U, o.Requests[i].Xc, r) {
+			log.Lvl1("Received invalid share from node", r.Ui.I)
+			o.failures[i]++
+			continue
+		}
+		o.Uis[i][r.Ui.I] = r.Ui
+	}
+
+	// The root is not part of the replies.
+	pending := len(o.List()) - 1 - o.replies
+	for i := range o.Requests {
+		if o.Uis[i] == nil {
+			continue
+		}
+		shares := len(o.List()) - pending - o.failures[i]
+		if shares < o.Threshold && shares+pending >= o.Threshold {
+			// We must wait for more replies, or for the timeout.
+			return nil
+		}
+	}
+	o.done()
+	return nil
+}
+
+// done drops the requests without enough shares and finishes the protocol.
+// It must be called with the lock held.
+func (o *OCSBatch) done() {
+	if o.finished {
+		return
+	}
+	for i := range o.Uis {
+		if o.Uis[i] == nil {
+			continue
+		}
+		shares := 0
+		for _, ui := range o.Uis[i] {
+			if ui != nil {
+				shares++
+			}
+		}
+		if shares < o.Threshold {
+			log.Lvl2("Request", i, "couldn't get enough shares")
+			o.Uis[i] = nil
+		}
+	}
+	o.finish(true)
+}
+
+func (o *OCSBatch) finish(result bool) {
+	o.finished = true
+	if o.timeout != nil {
+		o.timeout.Stop()
+	}
+	select {
+	case o.Reencrypted <- result:
+		// suceeded
+	default:
+		// would have blocked because some other call to finish()
+		// beat us.
+	}
+	o.doneOnce.Do(func() { o.Done() })
+}
@@ -14,8 +14,13 @@ import (
 // NameOCS can be used from other packages to refer to this protocol.
 const NameOCS = "OCS"
 
+// NameOCSBatch can be used from other packages to refer to the batched
+// version of the protocol.
+const NameOCSBatch = "OCSBatch"
+
 func init() {
-	network.RegisterMessages(&Reencrypt{}, &ReencryptReply{})
+	network.RegisterMessages(&Reencrypt{}, &ReencryptReply{},
+		&ReencryptBatch{}, &ReencryptBatchReply{})
 }
 
 // VerifyRequest is a callback-function that can be set by a service.
@@ -51,3 +56,25 @@ type structReencryptReply struct {
 	*onet.TreeNode
 	ReencryptReply
 }
+
+// ReencryptBatch asks for the re-encryption shares of many requests from a
+// node.
+type ReencryptBatch struct {
+	Requests []Reencrypt
+}
+
+type structReencryptBatch struct {
+	*onet.TreeNode
+	ReencryptBatch
+}
+
+// ReencryptBatchReply returns one share for every request of the batch, in the
+// same order. The shares of the refused requests are empty.
+type ReencryptBatchReply struct {
+	Replies []ReencryptReply
+}
+
+type structReencryptBatchReply struct {
+	*onet.TreeNode
+	ReencryptBatchReply
+}
@@ -139,6 +139,71 @@ func ocs(t *testing.T, nbrNodes, threshold, keylen, fail int, refuse bool) {
 	require.Equal(t, k, keyHat)
 }
 
+// Tests a batch where one of the requests is refused by the nodes.
+func TestOCSBatch(t *testing.T) {
+	nbrNodes, threshold := 4, 3
+	local := onet.NewLocalTest(tSuite)
+	defer local.CloseAll()
+	servers, _, tree := local.GenBigTree(nbrNodes, nbrNodes, nbrNodes, true)
+
+	dkgs, err := CreateDKGs(tSuite.(dkg.Suite), nbrNodes, threshold)
+	require.NoError(t, err)
+	services := local.GetServices(servers, testServiceID)
+	for i := range services {
+		services[i].(*testService).Shared, _, err = dkgprotocol.NewSharedSecret(dkgs[i])
+		require.NoError(t, err)
+	}
+	dks, err := dkgs[0].DistKeyShare()
+	require.NoError(t, err)
+	X := dks.Public()
+
+	var keys [][]byte
+	var Css [][]kyber.Point
+	var xcs []*key.Pair
+	var requests []Reencrypt
+	for i := 0; i < 3; i++ {
+		k := make([]byte, 32)
+		random.Bytes(k, random.New())
+		U, Cs := EncodeKey(tSuite, X, k)
+		xc := key.NewKeyPair(cothority.Suite)
+		rc := Reencrypt{U: U, Xc: xc.Public}
+		// The second request is refused by the nodes.
+		if i != 1 {
+			vd := []byte("correct block")
+			rc.VerificationData = &vd
+		}
+		keys = append(keys, k)
+		Css = append(Css, Cs)
+		xcs = append(xcs, xc)
+		requests = append(requests, rc)
+	}
+
+	pi, err := services[0].(*testService).CreateProtocol(NameOCSBatch, tree)
+	require.NoError(t, err)
+	protocol := pi.(*OCSBatch)
+	protocol.Shared = services[0].(*testService).Shared
+	protocol.Poly = share.NewPubPoly(suite, suite.Point().Base(), dks.Commits)
+	protocol.Threshold = threshold
+	protocol.Requests = requests
+	require.NoError(t, protocol.Start())
+	select {
+	case ok := <-protocol.Reencrypted:
+		require.True(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("Didn't finish in time")
+	}
+
+	require.Nil(t, protocol.Uis[1])
+	for _, i := range []int{0, 2} {
+		require.NotNil(t, protocol.Uis[i])
+		XhatEnc, err := share.RecoverCommit(suite, protocol.Uis[i], threshold, nbrNodes)
+		require.NoError(t, err)
+		keyHat, err := DecodeKey(suite, X, Css[i], XhatEnc, xcs[i].Private)
+		require.NoError(t, err)
+		require.Equal(t, keys[i], keyHat)
+	}
+}
+
 // testService allows setting the dkg-field of the protocol.
 type testService struct {
 	// We need to embed the ServiceProcessor, so that incoming messages
@@ -173,6 +238,17 @@ func (s *testService) NewProtocol(tn *onet.TreeNodeInstance, conf *onet.GenericC
 			return rc.VerificationData != nil
 		}
 		return ocs, nil
+	case NameOCSBatch:
+		pi, err := NewOCSBatch(tn)
+		if err != nil {
+			return nil, xerrors.Errorf("creating new OCS batch instance: %v", err)
+		}
+		ocs := pi.(*OCSBatch)
+		ocs.Shared = s.Shared
+		ocs.Verify = func(rc *Reencrypt) bool {
+			return rc.VerificationData != nil
+		}
+		return ocs, nil
 	default:
 		return nil, xerrors.New("unknown protocol for this service")
 	}
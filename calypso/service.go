@@ -28,6 +28,7 @@
 package calypso
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"math"
@@ -123,6 +124,10 @@ type Service struct {
 	// blocks are only used to insure that proofs start with the expected roster.
 	genesisBlocks     map[string]*skipchain.SkipBlock
 	genesisBlocksLock sync.Mutex
+	// latestIndexes holds the index of the latest verified block of every
+	// ByzCoin chain.
+	latestIndexes     map[string]int
+	latestIndexesLock sync.Mutex
 	// for use by testing only
 	afterReshare func()
 }
@@ -253,10 +258,11 @@ func (s *Service) CreateLTS(req *CreateLTS) (reply *CreateLTSReply, err error) {
 		return nil, xerrors.Errorf("verifying proof: %v", err)
 	}
 
-	roster, instID, err := s.getLtsRoster(&req.Proof)
+	info, instID, err := s.getLtsInfo(&req.Proof)
 	if err != nil {
 		return nil, xerrors.Errorf("get roster: %v", err)
 	}
+	roster := &info.Roster
 
 	// NOTE: the roster stored in ByzCoin must have myself.
 	tree := roster.GenerateNaryTreeWithRoot(len(roster.List), s.ServerIdentity())
@@ -305,6 +311,7 @@ func (s *Service) CreateLTS(req *CreateLTS) (reply *CreateLTSReply, err error) {
 		s.storage.Rosters[instID] = roster
 		s.storage.Replies[instID] = reply
 		s.storage.DKS[instID] = dks
+		s.storage.Epochs[instID] = info.Epoch
 		s.storage.Unlock()
 		err = s.save()
 		if err != nil {
@@ -322,10 +329,11 @@ func (s *Service) CreateLTS(req *CreateLTS) (reply *CreateLTSReply, err error) {
 // All hosts must be online in this step.
 func (s *Service) ReshareLTS(req *ReshareLTS) (*ReshareLTSReply, error) {
 	// Verify the request
-	roster, id, err := s.getLtsRoster(&req.Proof)
+	info, id, err := s.getLtsInfo(&req.Proof)
 	if err != nil {
 		return nil, xerrors.Errorf("get roster: %v", err)
 	}
+	roster := &info.Roster
 	if err := s.verifyProof(&req.Proof); err != nil {
 		return nil, xerrors.Errorf("verifying proof: %v", err)
 	}
@@ -339,6 +347,10 @@ func (s *Service) ReshareLTS(req *ReshareLTS) (*ReshareLTSReply, error) {
 		if s.storage.Shared[id] == nil || s.storage.DKS[id] == nil {
 			return nil, xerrors.New("cannot start resharing without an LTS")
 		}
+		if info.Epoch <= s.storage.Epochs[id] {
+			return nil, xerrors.Errorf("the proof is for epoch %d, but the shares are at epoch %d",
+				info.Epoch, s.storage.Epochs[id])
+		}
 
 		// NOTE: the roster stored in ByzCoin must have myself.
 		tree := roster.GenerateNaryTreeWithRoot(len(roster.List), s.ServerIdentity())
@@ -417,6 +429,7 @@ func (s *Service) ReshareLTS(req *ReshareLTS) (*ReshareLTSReply, error) {
 		s.storage.Polys[id] = &pubPoly{s.Suite().Point().Base(), dks.Commits}
 		s.storage.Rosters[id] = roster
 		s.storage.DKS[id] = dks
+		s.storage.Epochs[id] = info.Epoch
 		s.storage.Unlock()
 		err = s.save()
 		if err != nil {
@@ -447,8 +460,110 @@ func (s *Service) verifyProof(proof *byzcoin.Proof) error {
 		return xerrors.Errorf("fetching genesis block: %v", err)
 	}
 
-	return cothority.ErrorOrNil(proof.VerifyFromBlock(sb),
-		"verifying proof from block")
+	if err := proof.VerifyFromBlock(sb); err != nil {
+		return xerrors.Errorf("verifying proof from block: %v", err)
+	}
+	s.seenBlock(&proof.Latest)
+	return nil
+}
+
+// writeProofs caches the latest proofs of the write instances during one
+// request, so that the keys of the same write instance don't fetch it again.
+type writeProofs struct {
+	sync.Mutex
+	proofs map[string]*byzcoin.Proof
+}
+
+func newWriteProofs() *writeProofs {
+	return &writeProofs{proofs: make(map[string]*byzcoin.Proof)}
+}
+
+// checkWritePolicy gets the latest version of the write instance, as the
+// given proof might be older than its revocation, and makes sure that the
+// policy still allows the key to be re-encrypted.
+func (s *Service) checkWritePolicy(proof *byzcoin.Proof, cache *writeProofs) error {
+	key := string(proof.InclusionProof.Key())
+	cache.Lock()
+	latest := cache.proofs[key]
+	cache.Unlock()
+	if latest == nil || latest.Latest.Index < proof.Latest.Index {
+		var err error
+		latest, err = s.latestWriteProof(proof)
+		if err != nil {
+			return err
+		}
+		cache.Lock()
+		cache.proofs[key] = latest
+		cache.Unlock()
+	}
+
+	if !bytes.Equal(latest.InclusionProof.Key(), proof.InclusionProof.Key()) {
+		return xerrors.New("latest write proof is for another instance")
+	}
+	if latest.Latest.Index < proof.Latest.Index {
+		return xerrors.New("latest write proof is older than the given one")
+	}
+	var write Write
+	if err := latest.VerifyAndDecode(cothority.Suite, ContractWriteID, &write); err != nil {
+		return xerrors.Errorf("decoding latest write: %v", err)
+	}
+	if write.Policy == nil {
+		return nil
+	}
+	timestamp, err := blockTimestamp(&latest.Latest)
+	if err != nil {
+		return err
+	}
+	return write.Policy.checkExpiry(latest.Latest.Index, timestamp)
+}
+
+// latestWriteProof returns a proof of the write instance starting at the
+// latest block of the given proof. If this node follows the ByzCoin chain, the
+// proof comes from its own state. Else it is fetched from the roster of the
+// chain, and the answer must not be older than the blocks already seen by this
+// node, so that a lagging node cannot hide a revocation.
+func (s *Service) latestWriteProof(proof *byzcoin.Proof) (*byzcoin.Proof, error) {
+	key := proof.InclusionProof.Key()
+	bc := s.Service(byzcoin.ServiceName).(*byzcoin.Service)
+	reply, err := bc.GetProof(&byzcoin.GetProof{
+		Version:          byzcoin.CurrentVersion,
+		Key:              key,
+		ID:               proof.Latest.Hash,
+		MustContainBlock: proof.Latest.Hash,
+	})
+	if err == nil {
+		return &reply.Proof, nil
+	}
+	log.Lvl3(s.ServerIdentity(), "fetching the write proof from the roster:", err)
+
+	cl := byzcoin.NewClient(proof.Latest.SkipChainID(), *proof.Latest.Roster)
+	cl.Latest = &proof.Latest
+	reply, err = cl.GetProofAfter(key, false, &proof.Latest)
+	if err != nil {
+		return nil, xerrors.Errorf("getting latest write proof: %v", err)
+	}
+	if reply.Proof.Latest.Index < s.latestIndex(proof.Latest.SkipChainID()) {
+		return nil, xerrors.New("latest write proof is older than the blocks known by this node")
+	}
+	s.seenBlock(&reply.Proof.Latest)
+	return &reply.Proof, nil
+}
+
+// seenBlock records the index of a verified block, so that the answers older
+// than this block are refused.
+func (s *Service) seenBlock(sb *skipchain.SkipBlock) {
+	s.latestIndexesLock.Lock()
+	defer s.latestIndexesLock.Unlock()
+	if s.latestIndexes[string(sb.SkipChainID())] < sb.Index {
+		s.latestIndexes[string(sb.SkipChainID())] = sb.Index
+	}
+}
+
+// latestIndex returns the index of the latest verified block of the chain.
+func (s *Service) latestIndex(scID skipchain.SkipBlockID) int {
+	s.latestIndexesLock.Lock()
+	defer s.latestIndexesLock.Unlock()
+	return s.latestIndexes[string(scID)]
 }
 
 func (s *Service) fetchGenesisBlock(scID skipchain.SkipBlockID, roster *onet.Roster) (*skipchain.SkipBlock, error) {
@@ -472,6 +587,14 @@ func (s *Service) fetchGenesisBlock(scID skipchain.SkipBlockID, roster *onet.Ros
 }
 
 func (s *Service) getLtsRoster(proof *byzcoin.Proof) (*onet.Roster, byzcoin.InstanceID, error) {
+	info, instanceID, err := s.getLtsInfo(proof)
+	if err != nil {
+		return nil, byzcoin.InstanceID{}, err
+	}
+	return &info.Roster, instanceID, nil
+}
+
+func (s *Service) getLtsInfo(proof *byzcoin.Proof) (*LtsInstanceInfo, byzcoin.InstanceID, error) {
 	instanceID, buf, _, _, err := proof.KeyValue()
 	if err != nil {
 		return nil, byzcoin.InstanceID{},
@@ -484,51 +607,69 @@ func (s *Service) getLtsRoster(proof *byzcoin.Proof) (*onet.Roster, byzcoin.Inst
 		return nil, byzcoin.InstanceID{},
 			xerrors.Errorf("decoding roster: %v", err)
 	}
-	return &info.Roster, byzcoin.NewInstanceID(instanceID), nil
+	return &info, byzcoin.NewInstanceID(instanceID), nil
 }
 
-// DecryptKey takes as an input a Read- and a Write-proof. Proofs contain
-// everything necessary to verify that a given instance is correct and
-// stored in ByzCoin.
-// Using the Read and the Write-instance, this method verifies that the
-// requests match and then re-encrypts the secret to the public key given
-// in the Read-instance.
-func (s *Service) DecryptKey(dkr *DecryptKey) (reply *DecryptKeyReply, err error) {
-	reply = &DecryptKeyReply{}
-	log.Lvl2(s.ServerIdentity(), "Re-encrypt the key to the public key of the reader")
-
+// verifyDecryptKey verifies that the read and the write proofs of the request
+// come from an authorised ByzCoin, that they match, and that the write
+// instance can still be read. The latest proofs of the write instances are
+// cached in writes.
+func (s *Service) verifyDecryptKey(dkr *DecryptKey, writes *writeProofs) (*Read, *Write, error) {
 	var read Read
 	if err := dkr.Read.VerifyAndDecode(cothority.Suite, ContractReadID, &read); err != nil {
-		return nil, xerrors.New("didn't get a read instance: " + err.Error())
+		return nil, nil, xerrors.New("didn't get a read instance: " + err.Error())
 	}
 
 	var write Write
 	if err := dkr.Write.VerifyAndDecode(cothority.Suite, ContractWriteID, &write); err != nil {
-		return nil, xerrors.New("didn't get a write instance: " + err.Error())
+		return nil, nil, xerrors.New("didn't get a write instance: " + err.Error())
 	}
 	if !read.Write.Equal(byzcoin.NewInstanceID(dkr.Write.InclusionProof.Key())) {
-		return nil, xerrors.New("read doesn't point to passed write")
+		return nil, nil, xerrors.New("read doesn't point to passed write")
 	}
 	s.storage.Lock()
 	id := write.LTSID
 	roster := s.storage.Rosters[id]
+	s.storage.Unlock()
 	if roster == nil {
-		s.storage.Unlock()
-		return nil,
+		return nil, nil,
 			xerrors.Errorf("don't know the LTSID '%v' stored in write", id)
 	}
-	s.storage.Unlock()
 
-	if err = s.verifyProof(&dkr.Read); err != nil {
-		return nil, xerrors.Errorf(
+	if err := s.verifyProof(&dkr.Read); err != nil {
+		return nil, nil, xerrors.Errorf(
 			"read proof cannot be verified to come from scID: %v",
 			err)
 	}
-	if err = s.verifyProof(&dkr.Write); err != nil {
-		return nil, xerrors.Errorf(
+	if err := s.verifyProof(&dkr.Write); err != nil {
+		return nil, nil, xerrors.Errorf(
 			"write proof cannot be verified to come from scID: %v",
 			err)
 	}
+	if err := s.checkWritePolicy(&dkr.Write, writes); err != nil {
+		return nil, nil, xerrors.Errorf("write policy: %v", err)
+	}
+	return &read, &write, nil
+}
+
+// DecryptKey takes as an input a Read- and a Write-proof. Proofs contain
+// everything necessary to verify that a given instance is correct and
+// stored in ByzCoin.
+// Using the Read and the Write-instance, this method verifies that the
+// requests match and then re-encrypts the secret to the public key given
+// in the Read-instance.
+func (s *Service) DecryptKey(dkr *DecryptKey) (reply *DecryptKeyReply, err error) {
+	reply = &DecryptKeyReply{}
+	log.Lvl2(s.ServerIdentity(), "Re-encrypt the key to the public key of the reader")
+
+	read, write, err := s.verifyDecryptKey(dkr, newWriteProofs())
+	if err != nil {
+		return nil, err
+	}
+	id := write.LTSID
+	s.storage.Lock()
+	roster := s.storage.Rosters[id]
+	s.storage.Unlock()
 
 	// Start ocs-protocol to re-encrypt the file's symmetric key under the
 	// reader's public key.
@@ -552,18 +693,7 @@ func (s *Service) DecryptKey(dkr *DecryptKey) (reply *DecryptKeyReply, err error
 			xerrors.Errorf("couldn't marshal verification data: %v", err)
 	}
 
-	// Make sure everything used from the s.Storage structure is copied, so
-	// there will be no races.
-	s.storage.Lock()
-	ocsProto.Shared = s.storage.Shared[id]
-	pp := s.storage.Polys[id]
-	reply.X = s.storage.Shared[id].X.Clone()
-	var commits []kyber.Point
-	for _, c := range pp.Commits {
-		commits = append(commits, c.Clone())
-	}
-	ocsProto.Poly = share.NewPubPoly(s.Suite(), pp.B.Clone(), commits)
-	s.storage.Unlock()
+	ocsProto.Shared, ocsProto.Poly, reply.X = s.ltsKeys(id)
 
 	log.Lvl3("Starting reencryption protocol")
 	err = ocsProto.SetConfig(&onet.GenericConfig{Data: id.Slice()})
@@ -589,6 +719,158 @@ func (s *Service) DecryptKey(dkr *DecryptKey) (reply *DecryptKeyReply, err error
 	return
 }
 
+// LTSStatus returns the epoch of the shares of the LTS held by this node.
+func (s *Service) LTSStatus(req *LTSStatus) (*LTSStatusReply, error) {
+	s.storage.Lock()
+	defer s.storage.Unlock()
+	if s.storage.Shared[req.LTSID] == nil {
+		return nil, xerrors.Errorf("didn't find this LTS: %v", req.LTSID)
+	}
+	return &LTSStatusReply{Epoch: s.storage.Epochs[req.LTSID]}, nil
+}
+
+// maxDecryptKeys is the maximum number of requests in one DecryptKeys message.
+const maxDecryptKeys = 1000
+
+// maxParallelVerifications is the number of requests of DecryptKeys that are
+// verified in parallel, as the verification may fetch the latest write proofs.
+const maxParallelVerifications = 16
+
+// DecryptKeys verifies every request the same way as DecryptKey, then
+// re-encrypts the keys of all the valid requests in a single protocol round
+// per LTS. A request that fails doesn't make the others fail, its error is
+// returned in its result.
+func (s *Service) DecryptKeys(req *DecryptKeys) (*DecryptKeysReply, error) {
+	if len(req.Keys) == 0 {
+		return nil, xerrors.New("no decryption requests")
+	}
+	if len(req.Keys) > maxDecryptKeys {
+		return nil, xerrors.Errorf("more than %d decryption requests", maxDecryptKeys)
+	}
+	log.Lvl2(s.ServerIdentity(), "Re-encrypt", len(req.Keys), "keys")
+
+	reply := &DecryptKeysReply{Results: make([]DecryptKeyResult, len(req.Keys))}
+	reads := make([]*Read, len(req.Keys))
+	writes := make([]*Write, len(req.Keys))
+	latestWrites := newWriteProofs()
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxParallelVerifications)
+	for i := range req.Keys {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			reads[i], writes[i], err = s.verifyDecryptKey(&req.Keys[i], latestWrites)
+			if err != nil {
+				reply.Results[i].Error = err.Error()
+			}
+			<-sem
+		}(i)
+	}
+	wg.Wait()
+
+	// batches holds the indexes of the valid requests of every LTS.
+	batches := make(map[string][]int)
+	for i, write := range writes {
+		if write != nil {
+			batches[string(write.LTSID.Slice())] = append(batches[string(write.LTSID.Slice())], i)
+		}
+	}
+	for _, indexes := range batches {
+		wg.Add(1)
+		go func(indexes []int) {
+			defer wg.Done()
+			err := s.reencryptBatch(req, reads, writes, indexes, reply.Results)
+			if err != nil {
+				for _, i := range indexes {
+					reply.Results[i].Error = err.Error()
+				}
+			}
+		}(indexes)
+	}
+	wg.Wait()
+	return reply, nil
+}
+
+// reencryptBatch runs the batched ocs-protocol for the requests at the given
+// indexes, which all use the same LTS, and stores their results.
+func (s *Service) reencryptBatch(req *DecryptKeys, reads []*Read, writes []*Write,
+	indexes []int, results []DecryptKeyResult) error {
+	id := writes[indexes[0]].LTSID
+	s.storage.Lock()
+	roster := s.storage.Rosters[id]
+	s.storage.Unlock()
+
+	nodes := len(roster.List)
+	tree := roster.GenerateNaryTreeWithRoot(nodes, s.ServerIdentity())
+	pi, err := s.CreateProtocol(protocol.NameOCSBatch, tree)
+	if err != nil {
+		return xerrors.Errorf("failed to create ocs-protocol: %v", err)
+	}
+	ocsProto := pi.(*protocol.OCSBatch)
+	for _, i := range indexes {
+		verificationData, err := protobuf.Encode(&vData{
+			Proof: req.Keys[i].Read,
+		})
+		if err != nil {
+			return xerrors.Errorf("couldn't marshal verification data: %v", err)
+		}
+		ocsProto.Requests = append(ocsProto.Requests, protocol.Reencrypt{
+			U:                writes[i].U,
+			Xc:               reads[i].Xc,
+			VerificationData: &verificationData,
+		})
+	}
+	var X kyber.Point
+	ocsProto.Shared, ocsProto.Poly, X = s.ltsKeys(id)
+
+	err = ocsProto.SetConfig(&onet.GenericConfig{Data: id.Slice()})
+	if err != nil {
+		return xerrors.Errorf("failed to set config for ocs-protocol: %v", err)
+	}
+	err = ocsProto.Start()
+	if err != nil {
+		return xerrors.Errorf("failed to start ocs-protocol: %v", err)
+	}
+	if !<-ocsProto.Reencrypted {
+		return xerrors.New("reencryption got refused")
+	}
+
+	for j, i := range indexes {
+		if ocsProto.Uis[j] == nil {
+			results[i].Error = "reencryption got refused"
+			continue
+		}
+		XhatEnc, err := share.RecoverCommit(cothority.Suite, ocsProto.Uis[j],
+			ocsProto.Threshold, nodes)
+		if err != nil {
+			results[i].Error = fmt.Sprintf("failed to recover commit: %v", err)
+			continue
+		}
+		results[i].Reply = &DecryptKeyReply{
+			C:       writes[i].C,
+			XhatEnc: XhatEnc,
+			X:       X.Clone(),
+		}
+	}
+	return nil
+}
+
+// ltsKeys returns copies of the shared secret, the public polynomial and the
+// public key of the LTS, so that there will be no races.
+func (s *Service) ltsKeys(id byzcoin.InstanceID) (*dkgprotocol.SharedSecret, *share.PubPoly, kyber.Point) {
+	s.storage.Lock()
+	defer s.storage.Unlock()
+	shared := s.storage.Shared[id]
+	pp := s.storage.Polys[id]
+	var commits []kyber.Point
+	for _, c := range pp.Commits {
+		commits = append(commits, c.Clone())
+	}
+	return shared, share.NewPubPoly(s.Suite(), pp.B.Clone(), commits), shared.X.Clone()
+}
+
 // GetLTSReply returns the CreateLTSReply message of a previous LTS.
 func (s *Service) GetLTSReply(req *GetLTSReply) (*CreateLTSReply, error) {
 	log.Lvlf2("Getting LTS Reply for ID: %v", req.LTSID)
@@ -624,11 +906,10 @@ func (s *Service) NewProtocol(tn *onet.TreeNodeInstance, conf *onet.GenericConfi
 		if err := s.verifyProof(&cfg.Proof); err != nil {
 			return nil, xerrors.Errorf("verifying proof: %v", err)
 		}
-		inst, _, _, _, err := cfg.KeyValue()
+		info, instID, err := s.getLtsInfo(&cfg.Proof)
 		if err != nil {
-			return nil, xerrors.Errorf("getting key value from proof: %v", err)
+			return nil, xerrors.Errorf("getting LTS info from proof: %v", err)
 		}
-		instID := byzcoin.NewInstanceID(inst)
 
 		pi, err := dkgprotocol.NewSetup(tn)
 		if err != nil {
@@ -655,6 +936,7 @@ func (s *Service) NewProtocol(tn *onet.TreeNodeInstance, conf *onet.GenericConfi
 			s.storage.DKS[id] = dks
 			s.storage.Replies[id] = reply
 			s.storage.Rosters[id] = tn.Roster()
+			s.storage.Epochs[id] = info.Epoch
 			s.storage.Unlock()
 			err = s.save()
 			if err != nil {
@@ -672,7 +954,17 @@ func (s *Service) NewProtocol(tn *onet.TreeNodeInstance, conf *onet.GenericConfi
 			return nil, xerrors.Errorf("verifying proof: %v", err)
 		}
 
-		_, id, err := s.getLtsRoster(&cfg.Proof)
+		info, id, err := s.getLtsInfo(&cfg.Proof)
+		if err != nil {
+			return nil, xerrors.Errorf("getting LTS info from proof: %v", err)
+		}
+		s.storage.Lock()
+		epoch := s.storage.Epochs[id]
+		s.storage.Unlock()
+		if info.Epoch <= epoch {
+			return nil, xerrors.Errorf("the proof is for epoch %d, but the shares are at epoch %d",
+				info.Epoch, epoch)
+		}
 
 		// Set up the protocol
 		pi, err := dkgprotocol.NewSetup(tn)
@@ -737,6 +1029,7 @@ func (s *Service) NewProtocol(tn *onet.TreeNodeInstance, conf *onet.GenericConfi
 			}
 			s.storage.Shared[id] = shared
 			s.storage.DKS[id] = dks
+			s.storage.Epochs[id] = info.Epoch
 			s.storage.Unlock()
 			err = s.save()
 			if err != nil {
@@ -764,6 +1057,23 @@ func (s *Service) NewProtocol(tn *onet.TreeNodeInstance, conf *onet.GenericConfi
 		ocs.Shared = shared
 		ocs.Verify = s.verifyReencryption
 		return ocs, nil
+	case protocol.NameOCSBatch:
+		id := byzcoin.NewInstanceID(conf.Data)
+		s.storage.Lock()
+		shared, ok := s.storage.Shared[id]
+		shared = shared.Clone()
+		s.storage.Unlock()
+		if !ok {
+			return nil, fmt.Errorf("didn't find LTSID %v", id)
+		}
+		pi, err := protocol.NewOCSBatch(tn)
+		if err != nil {
+			return nil, xerrors.Errorf("creating OCS batch protocol instance: %v", err)
+		}
+		ocs := pi.(*protocol.OCSBatch)
+		ocs.Shared = shared
+		ocs.Verify = s.verifyReencryption
+		return ocs, nil
 	}
 	return nil, nil
 }
@@ -819,9 +1129,10 @@ func newService(c *onet.Context) (onet.Service, error) {
 	s := &Service{
 		ServiceProcessor: onet.NewServiceProcessor(c),
 		genesisBlocks:    make(map[string]*skipchain.SkipBlock),
+		latestIndexes:    make(map[string]int),
 	}
 	if err := s.RegisterHandlers(s.CreateLTS, s.ReshareLTS, s.DecryptKey,
-		s.GetLTSReply, s.Authorise, s.Authorize); err != nil {
+		s.DecryptKeys, s.GetLTSReply, s.LTSStatus, s.Authorise, s.Authorize); err != nil {
 		return nil, xerrors.New("couldn't register messages")
 	}
 	if err := s.tryLoad(); err != nil {
@@ -1,6 +1,7 @@
 package calypso
 
 import (
+	"fmt"
 	"sync"
 	"testing"
 	"time"
@@ -54,7 +55,7 @@ func TestService_ReshareLTS_Different(t *testing.T) {
 	// The current DKG is on List[0:nodes], and this new roster will
 	// be on List[nodes:], thus entirely disjoint.
 	otherRoster := onet.NewRoster(s.allRoster.List[nodes:])
-	ltsInstInfoBuf, err := protobuf.Encode(&LtsInstanceInfo{*otherRoster})
+	ltsInstInfoBuf, err := protobuf.Encode(&LtsInstanceInfo{Roster: *otherRoster})
 	require.NoError(t, err)
 
 	ctx := byzcoin.NewClientTransaction(byzcoin.CurrentVersion,
@@ -92,7 +93,7 @@ func TestService_ReshareLTS_Same(t *testing.T) {
 			require.NotNil(t, s.ltsReply.X)
 			sec1 := s.reconstructKey(t)
 
-			ltsInstInfoBuf, err := protobuf.Encode(&LtsInstanceInfo{*s.ltsRoster})
+			ltsInstInfoBuf, err := protobuf.Encode(&LtsInstanceInfo{Roster: *s.ltsRoster})
 			require.NoError(t, err)
 
 			ctx, err := s.cl.CreateTransaction(byzcoin.Instruction{
@@ -143,6 +144,73 @@ func TestService_ReshareLTS_Same(t *testing.T) {
 	}
 }
 
+// TestService_RefreshLTS re-randomizes the shares with the same roster and
+// makes sure that the older epochs are refused.
+func TestService_RefreshLTS(t *testing.T) {
+	s := newTS(t, 4)
+	defer s.closeAll(t)
+	sec1 := s.reconstructKey(t)
+	priShare := func() *share.PriShare {
+		s.services[0].storage.Lock()
+		defer s.services[0].storage.Unlock()
+		return s.services[0].storage.DKS[s.ltsReply.InstanceID].PriShare()
+	}
+	oldShare := priShare()
+
+	oldProof, err := s.cl.GetProof(s.ltsReply.InstanceID.Slice())
+	require.NoError(t, err)
+	var oldInfo LtsInstanceInfo
+	require.NoError(t, oldProof.Proof.VerifyAndDecode(cothority.Suite, ContractLongTermSecretID, &oldInfo))
+	require.Equal(t, 0, oldInfo.Epoch)
+	for _, svc := range s.services {
+		reply, err := svc.LTSStatus(&LTSStatus{LTSID: s.ltsReply.InstanceID})
+		require.NoError(t, err)
+		require.Equal(t, 0, reply.Epoch)
+	}
+
+	ctx, err := s.cl.CreateTransaction(byzcoin.Instruction{
+		InstanceID: s.ltsReply.InstanceID,
+		Invoke: &byzcoin.Invoke{
+			ContractID: ContractLongTermSecretID,
+			Command:    "refresh",
+		},
+		SignerCounter: []uint64{2},
+	})
+	require.NoError(t, err)
+	require.NoError(t, ctx.FillSignersAndSignWith(s.signer))
+	_, err = s.cl.AddTransactionAndWait(ctx, 4)
+	require.NoError(t, err)
+
+	proof, err := s.cl.GetProof(s.ltsReply.InstanceID.Slice())
+	require.NoError(t, err)
+	var info LtsInstanceInfo
+	require.NoError(t, proof.Proof.VerifyAndDecode(cothority.Suite, ContractLongTermSecretID, &info))
+	require.Equal(t, 1, info.Epoch)
+	require.True(t, info.Roster.ID.Equal(s.ltsRoster.ID))
+
+	var wg sync.WaitGroup
+	wg.Add(len(s.ltsRoster.List))
+	s.afterReshare(func() { wg.Done() })
+	_, err = s.services[0].ReshareLTS(&ReshareLTS{Proof: proof.Proof})
+	require.NoError(t, err)
+	wg.Wait()
+	require.True(t, s.reconstructKey(t).Equal(sec1))
+	require.False(t, priShare().V.Equal(oldShare.V))
+
+	for _, svc := range s.services {
+		reply, err := svc.LTSStatus(&LTSStatus{LTSID: s.ltsReply.InstanceID})
+		require.NoError(t, err)
+		require.Equal(t, 1, reply.Epoch)
+	}
+
+	_, err = s.services[0].ReshareLTS(&ReshareLTS{Proof: oldProof.Proof})
+	require.Error(t, err)
+	// The epoch that has just been applied cannot be replayed.
+	_, err = s.services[0].ReshareLTS(&ReshareLTS{Proof: proof.Proof})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "epoch")
+}
+
 func TestService_ReshareLTS_OneMore(t *testing.T) {
 	for _, nodes := range []int{4, 7} {
 		func(nodes int) {
@@ -160,7 +228,7 @@ func TestService_ReshareLTS_OneMore(t *testing.T) {
 			// Create a new roster that has one more node than
 			// before
 			s.ltsRoster = onet.NewRoster(s.allRoster.List[:nodes+1])
-			ltsInstInfoBuf, err := protobuf.Encode(&LtsInstanceInfo{*s.ltsRoster})
+			ltsInstInfoBuf, err := protobuf.Encode(&LtsInstanceInfo{Roster: *s.ltsRoster})
 			require.NoError(t, err)
 
 			ctx, err := s.cl.CreateTransaction(byzcoin.Instruction{
@@ -302,6 +370,76 @@ func TestService_DecryptKey(t *testing.T) {
 	require.Equal(t, key2, keyCopy2)
 }
 
+// TestService_DecryptKeys re-encrypts many keys in one request, one of the
+// requests being invalid.
+func TestService_DecryptKeys(t *testing.T) {
+	s := newTS(t, 4)
+	defer s.closeAll(t)
+
+	var keys [][]byte
+	var req DecryptKeys
+	for i := 0; i < 3; i++ {
+		key := []byte(fmt.Sprintf("secret key %d", i))
+		prWr := s.addWriteAndWait(t, key)
+		prRe := s.addReadAndWait(t, prWr, s.signer.Ed25519.Point)
+		keys = append(keys, key)
+		req.Keys = append(req.Keys, DecryptKey{Read: *prRe, Write: *prWr})
+	}
+	// The read doesn't point to the write.
+	req.Keys = append(req.Keys, DecryptKey{Read: req.Keys[0].Read, Write: req.Keys[1].Write})
+
+	reply, err := s.services[0].DecryptKeys(&req)
+	require.NoError(t, err)
+	require.Equal(t, len(req.Keys), len(reply.Results))
+	for i, key := range keys {
+		require.Empty(t, reply.Results[i].Error)
+		require.True(t, reply.Results[i].Reply.X.Equal(s.ltsReply.X))
+		keyCopy, err := reply.Results[i].Reply.RecoverKey(s.signer.Ed25519.Secret)
+		require.NoError(t, err)
+		require.Equal(t, key, keyCopy)
+	}
+	require.Nil(t, reply.Results[3].Reply)
+	require.Contains(t, reply.Results[3].Error, "read doesn't point to passed write")
+}
+
+// TestService_WritePolicy makes sure that the reads of a write instance are
+// refused once it expired, once the maximum number of reads is reached, or once
+// it has been revoked.
+func TestService_WritePolicy(t *testing.T) {
+	s := newTS(t, 4)
+	defer s.closeAll(t)
+	cl := NewClient(s.cl)
+
+	// The LTS has been spawned in block 1, so the write is already expired.
+	prExpired := s.addWritePolicyAndWait(t, []byte("expired key"), &WritePolicy{ExpiryBlock: 1})
+	ctr, err := s.cl.GetSignerCounters(s.signer.Identity().String())
+	require.NoError(t, err)
+	_, err = cl.AddRead(prExpired, s.signer, ctr.Counters[0]+1, 10)
+	require.Error(t, err)
+
+	key := []byte("secret key")
+	prWr := s.addWritePolicyAndWait(t, key, &WritePolicy{MaxReads: 1})
+	prRe := s.addReadAndWait(t, prWr, s.signer.Ed25519.Point)
+	ctr, err = s.cl.GetSignerCounters(s.signer.Identity().String())
+	require.NoError(t, err)
+	_, err = cl.AddRead(prWr, s.signer, ctr.Counters[0]+1, 10)
+	require.Error(t, err)
+
+	dk, err := s.services[0].DecryptKey(&DecryptKey{Read: *prRe, Write: *prWr})
+	require.NoError(t, err)
+	keyCopy, err := dk.RecoverKey(s.signer.Ed25519.Secret)
+	require.NoError(t, err)
+	require.Equal(t, key, keyCopy)
+
+	_, err = cl.RevokeWrite(byzcoin.NewInstanceID(prWr.InclusionProof.Key()),
+		s.signer, ctr.Counters[0]+1, 10)
+	require.NoError(t, err)
+
+	// The proof of the write from before the revocation must not be enough.
+	_, err = s.services[0].DecryptKey(&DecryptKey{Read: *prRe, Write: *prWr})
+	require.Error(t, err)
+}
+
 // TestService_DecryptEphemeralKey requests a read to a different key than the
 // readers.
 func TestService_DecryptEphemeralKey(t *testing.T) {
@@ -395,7 +533,7 @@ func newTSWithExtras(t *testing.T, nodes int, extras int) ts {
 	s.createGenesis(t)
 
 	// Create LTS instance
-	ltsInstInfoBuf, err := protobuf.Encode(&LtsInstanceInfo{*s.ltsRoster})
+	ltsInstInfoBuf, err := protobuf.Encode(&LtsInstanceInfo{Roster: *s.ltsRoster})
 	require.NoError(t, err)
 	inst := byzcoin.Instruction{
 		InstanceID: byzcoin.NewInstanceID(s.gDarc.GetBaseID()),
@@ -442,7 +580,9 @@ func (s *ts) createGenesis(t *testing.T) {
 		[]string{"spawn:" + ContractWriteID,
 			"spawn:" + ContractReadID,
 			"spawn:" + ContractLongTermSecretID,
-			"invoke:" + ContractLongTermSecretID + ".reshare"},
+			"invoke:" + ContractWriteID + ".revoke",
+			"invoke:" + ContractLongTermSecretID + ".reshare",
+			"invoke:" + ContractLongTermSecretID + ".refresh"},
 		s.signer.Identity())
 	require.NoError(t, err)
 	s.gDarc = &s.genesisMsg.GenesisDarc
@@ -489,7 +629,20 @@ func (s *ts) addWriteAndWait(t *testing.T, key []byte) *byzcoin.Proof {
 }
 
 func (s *ts) addWrite(t *testing.T, key []byte, ctr uint64) byzcoin.InstanceID {
+	return s.addWritePolicy(t, key, nil, ctr)
+}
+
+func (s *ts) addWritePolicyAndWait(t *testing.T, key []byte, policy *WritePolicy) *byzcoin.Proof {
+	ctr, err := s.cl.GetSignerCounters(s.signer.Identity().String())
+	require.NoError(t, err)
+
+	instID := s.addWritePolicy(t, key, policy, ctr.Counters[0]+1)
+	return s.waitInstID(t, instID)
+}
+
+func (s *ts) addWritePolicy(t *testing.T, key []byte, policy *WritePolicy, ctr uint64) byzcoin.InstanceID {
 	write := NewWrite(cothority.Suite, s.ltsReply.InstanceID, s.gDarc.GetBaseID(), s.ltsReply.X, key)
+	write.Policy = policy
 	writeBuf, err := protobuf.Encode(write)
 	require.NoError(t, err)
 
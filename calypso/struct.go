@@ -6,10 +6,13 @@ import (
 
 	"go.dedis.ch/cothority/v3/byzcoin"
 	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/cothority/v3/skipchain"
 	"go.dedis.ch/kyber/v3"
 	"go.dedis.ch/kyber/v3/suites"
 	"go.dedis.ch/kyber/v3/xof/keccak"
 	"go.dedis.ch/onet/v3/network"
+	"go.dedis.ch/protobuf"
+	"golang.org/x/xerrors"
 )
 
 func init() {
@@ -97,6 +100,40 @@ func (wr *Write) CheckProof(suite suite, writeID darc.ID) error {
 		"%s\n%s", e.String(), wr.E.String())
 }
 
+// checkRead returns an error if no more read instances can be spawned, given
+// the state of the write instance and the latest block.
+func (p *WritePolicy) checkRead(index int, timestamp int64) error {
+	if p.MaxReads > 0 && p.Reads >= p.MaxReads {
+		return xerrors.Errorf("the write instance has already been read %d times", p.Reads)
+	}
+	return p.checkExpiry(index, timestamp)
+}
+
+// checkExpiry returns an error if the latest block, given by its index and
+// timestamp in seconds, is past one of the expiries.
+func (p *WritePolicy) checkExpiry(index int, timestamp int64) error {
+	if p.Revoked {
+		return xerrors.New("the write instance has been revoked")
+	}
+	if p.ExpiryBlock > 0 && index >= p.ExpiryBlock {
+		return xerrors.Errorf("the write instance expired at block %d", p.ExpiryBlock)
+	}
+	if p.ExpiryTime > 0 && timestamp >= p.ExpiryTime {
+		return xerrors.Errorf("the write instance expired at time %d", p.ExpiryTime)
+	}
+	return nil
+}
+
+// blockTimestamp returns the timestamp of the block in seconds since the
+// epoch.
+func blockTimestamp(sb *skipchain.SkipBlock) (int64, error) {
+	var header byzcoin.DataHeader
+	if err := protobuf.Decode(sb.Data, &header); err != nil {
+		return 0, xerrors.Errorf("decoding header: %v", err)
+	}
+	return header.Timestamp / 1e9, nil
+}
+
 type newLtsConfig struct {
 	byzcoin.Proof
 }
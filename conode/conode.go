@@ -28,6 +28,7 @@ import (
 	_ "go.dedis.ch/cothority/v3/authprox"
 	_ "go.dedis.ch/cothority/v3/byzcoin"
 	_ "go.dedis.ch/cothority/v3/byzcoin/contracts"
+	_ "go.dedis.ch/cothority/v3/byzcoin/shard"
 	_ "go.dedis.ch/cothority/v3/calypso"
 	_ "go.dedis.ch/cothority/v3/eventlog"
 	_ "go.dedis.ch/cothority/v3/evoting/service"
@@ -0,0 +1,217 @@
+package darc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.dedis.ch/cothority/v3/darc/expression"
+)
+
+// RuleAnalysis describes who is able to use a rule of a darc.
+type RuleAnalysis struct {
+	Action Action
+	// Signers holds the minimal sets of identities that satisfy the rule,
+	// after all the delegations to other darcs have been resolved. The
+	// attributes and proxies of the rule are kept as they are.
+	Signers expression.Sets
+	// Cycles holds the darcs whose _sign rule refers back to themselves.
+	Cycles []string
+	// Missing holds the darcs that cannot be found or that have no _sign
+	// rule.
+	Missing []string
+}
+
+// Unreachable returns true if nobody can use the rule.
+func (ra RuleAnalysis) Unreachable() bool {
+	return len(ra.Signers) == 0
+}
+
+// String returns a human readable description of the analysis.
+func (ra RuleAnalysis) String() string {
+	res := new(strings.Builder)
+	fmt.Fprintf(res, "%s:", ra.Action)
+	if ra.Unreachable() {
+		res.WriteString(" unreachable")
+	}
+	for _, set := range ra.Signers {
+		fmt.Fprintf(res, "\n- %s", strings.Join(set, " & "))
+	}
+	for _, c := range ra.Cycles {
+		fmt.Fprintf(res, "\n! cycle through %s", c)
+	}
+	for _, m := range ra.Missing {
+		fmt.Fprintf(res, "\n! cannot resolve %s", m)
+	}
+	return res.String()
+}
+
+// PermissionChange holds the sets of identities that gained or lost the right
+// to use an action between two versions of a darc.
+type PermissionChange struct {
+	Action Action
+	Gained expression.Sets
+	Lost   expression.Sets
+}
+
+// AnalyzeRule resolves the expression of the action in the darc and returns
+// the minimal sets of identities that satisfy it. The darcs referenced by the
+// expression are fetched with getDarc and replaced by their latest _sign rule,
+// the same way EvalExpr does.
+func AnalyzeRule(d *Darc, a Action, getDarc GetDarc) (*RuleAnalysis, error) {
+	if !d.Rules.Contains(a) {
+		return nil, fmt.Errorf("action '%v' does not exist", a)
+	}
+	an := analyzer{
+		getDarc: getDarc,
+		cycles:  make(map[string]bool),
+		missing: make(map[string]bool),
+	}
+	// The darc itself is the start of every delegation path, so that a
+	// reference back to it is reported as a cycle through it.
+	visited := map[string]bool{d.GetIdentityString(): true}
+	signers, err := an.resolve(visited, d.Rules.Get(a))
+	if err != nil {
+		return nil, err
+	}
+	return &RuleAnalysis{
+		Action:  a,
+		Signers: signers,
+		Cycles:  sortedKeys(an.cycles),
+		Missing: sortedKeys(an.missing),
+	}, nil
+}
+
+// AnalyzeRules returns the analysis of all the rules of the darc.
+func AnalyzeRules(d *Darc, getDarc GetDarc) ([]RuleAnalysis, error) {
+	res := make([]RuleAnalysis, len(d.Rules.List))
+	for i, r := range d.Rules.List {
+		ra, err := AnalyzeRule(d, r.Action, getDarc)
+		if err != nil {
+			return nil, fmt.Errorf("analyzing %s: %v", r.Action, err)
+		}
+		res[i] = *ra
+	}
+	return res, nil
+}
+
+// DiffDarcs compares the rules of two versions of a darc. For every action, a
+// set of identities is gained if it satisfies the rule of newDarc but not the
+// one of oldDarc, and it is lost if it satisfies the rule of oldDarc but not
+// the one of newDarc. Only the actions that changed are returned.
+func DiffDarcs(oldDarc, newDarc *Darc, getDarc GetDarc) ([]PermissionChange, error) {
+	var actions []Action
+	for _, r := range oldDarc.Rules.List {
+		actions = append(actions, r.Action)
+	}
+	for _, r := range newDarc.Rules.List {
+		if !oldDarc.Rules.Contains(r.Action) {
+			actions = append(actions, r.Action)
+		}
+	}
+
+	signers := func(d *Darc, a Action) (expression.Sets, error) {
+		if !d.Rules.Contains(a) {
+			return expression.Sets{}, nil
+		}
+		ra, err := AnalyzeRule(d, a, getDarc)
+		if err != nil {
+			return nil, fmt.Errorf("analyzing %s: %v", a, err)
+		}
+		return ra.Signers, nil
+	}
+
+	var changes []PermissionChange
+	for _, a := range actions {
+		oldSigners, err := signers(oldDarc, a)
+		if err != nil {
+			return nil, err
+		}
+		newSigners, err := signers(newDarc, a)
+		if err != nil {
+			return nil, err
+		}
+		change := PermissionChange{
+			Action: a,
+			Gained: notSatisfying(newSigners, oldSigners),
+			Lost:   notSatisfying(oldSigners, newSigners),
+		}
+		if len(change.Gained) > 0 || len(change.Lost) > 0 {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+// notSatisfying returns the sets of candidates that don't satisfy the rule.
+func notSatisfying(candidates, rule expression.Sets) expression.Sets {
+	var res expression.Sets
+	for _, set := range candidates {
+		if !rule.SatisfiedBy(set) {
+			res = append(res, set)
+		}
+	}
+	return res
+}
+
+// analyzer keeps track of the darcs that cannot be resolved.
+type analyzer struct {
+	getDarc GetDarc
+	cycles  map[string]bool
+	missing map[string]bool
+}
+
+// resolve returns the minimal sets of the expression. The visited parameter
+// tracks the darcs of the current delegation path to detect the cycles.
+func (an analyzer) resolve(visited map[string]bool, expr expression.Expr) (expression.Sets, error) {
+	if len(expr) == 0 {
+		return expression.Sets{}, nil
+	}
+	var issue error
+	sets, err := expression.MinimalSets(expr, func(s string) expression.Sets {
+		if !strings.HasPrefix(s, "darc") {
+			return expression.Sets{{s}}
+		}
+		// A cycle can never be satisfied, as EvalExpr rejects it.
+		if visited[s] {
+			an.cycles[s] = true
+			return expression.Sets{}
+		}
+		if an.getDarc == nil {
+			an.missing[s] = true
+			return expression.Sets{}
+		}
+		d := an.getDarc(s, true)
+		if d == nil || !d.Rules.Contains(sign) {
+			an.missing[s] = true
+			return expression.Sets{}
+		}
+		newVisited := make(map[string]bool)
+		for k, v := range visited {
+			newVisited[k] = v
+		}
+		newVisited[s] = true
+		sub, err := an.resolve(newVisited, d.Rules.GetSignExpr())
+		if err != nil {
+			issue = err
+			return expression.Sets{}
+		}
+		return sub
+	})
+	if err != nil {
+		return nil, err
+	}
+	if issue != nil {
+		return nil, issue
+	}
+	return sets, nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
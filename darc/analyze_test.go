@@ -0,0 +1,109 @@
+package darc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3/darc/expression"
+)
+
+func TestAnalyzeRule(t *testing.T) {
+	td := createDarc(3, "analyze")
+	ids := make([]string, len(td.ids))
+	for i, id := range td.ids {
+		ids[i] = id.String()
+	}
+	require.NoError(t, td.darc.Rules.UpdateEvolution(expression.InitThresholdExpr(2, ids...)))
+
+	// The delegated darc refers back to itself through a second darc.
+	td2 := createDarc(1, "delegated")
+	td3 := createDarc(1, "cycle")
+	e2 := td2.darc.Copy()
+	e3 := td3.darc.Copy()
+	require.NoError(t, e2.Rules.UpdateSign(expression.InitOrExpr(
+		td2.ids[0].String(), td3.darc.GetIdentityString())))
+	require.NoError(t, e3.Rules.UpdateSign([]byte(td2.darc.GetIdentityString())))
+	require.NoError(t, localEvolution(e2, td2.darc, td2.owners...))
+	require.NoError(t, localEvolution(e3, td3.darc, td3.owners...))
+	getDarc := DarcsToGetDarcs([]*Darc{e2, e3})
+
+	rule := ids[0] + " | " + td2.darc.GetIdentityString() + " | darc:00"
+	require.NoError(t, td.darc.Rules.AddRule("spawn:test", expression.Expr(rule)))
+	require.NoError(t, td.darc.Rules.AddRule("spawn:none", expression.Expr("darc:00")))
+
+	ra, err := AnalyzeRule(td.darc, "_evolve", getDarc)
+	require.NoError(t, err)
+	require.Len(t, ra.Signers, 3)
+	for _, set := range ra.Signers {
+		require.Len(t, set, 2)
+		require.NoError(t, EvalExpr(td.darc.Rules.GetEvolutionExpr(), getDarc, set...))
+	}
+	require.Empty(t, ra.Cycles)
+
+	ra, err = AnalyzeRule(td.darc, "spawn:test", getDarc)
+	require.NoError(t, err)
+	require.Len(t, ra.Signers, 2)
+	require.True(t, ra.Signers.SatisfiedBy([]string{ids[0]}))
+	require.True(t, ra.Signers.SatisfiedBy([]string{td2.ids[0].String()}))
+	require.Equal(t, []string{td2.darc.GetIdentityString()}, ra.Cycles)
+	require.Equal(t, []string{"darc:00"}, ra.Missing)
+	require.False(t, ra.Unreachable())
+
+	ra, err = AnalyzeRule(td.darc, "spawn:none", getDarc)
+	require.NoError(t, err)
+	require.True(t, ra.Unreachable())
+
+	_, err = AnalyzeRule(td.darc, "spawn:unknown", getDarc)
+	require.Error(t, err)
+
+	all, err := AnalyzeRules(td.darc, getDarc)
+	require.NoError(t, err)
+	require.Len(t, all, 4)
+
+	// A darc delegating back to the analyzed darc is a cycle through it.
+	td4 := createDarc(1, "self")
+	e4 := td4.darc.Copy()
+	require.NoError(t, e4.Rules.UpdateSign([]byte(td.darc.GetIdentityString())))
+	require.NoError(t, localEvolution(e4, td4.darc, td4.owners...))
+	getDarc = DarcsToGetDarcs([]*Darc{e2, e3, e4, td.darc})
+	rule = ids[0] + " | " + td4.darc.GetIdentityString()
+	require.NoError(t, td.darc.Rules.AddRule("spawn:self", expression.Expr(rule)))
+	ra, err = AnalyzeRule(td.darc, "spawn:self", getDarc)
+	require.NoError(t, err)
+	require.Equal(t, expression.Sets{{ids[0]}}, ra.Signers)
+	require.Equal(t, []string{td.darc.GetIdentityString()}, ra.Cycles)
+	require.Empty(t, ra.Missing)
+}
+
+func TestDiffDarcs(t *testing.T) {
+	td := createDarc(3, "diff")
+	ids := make([]string, len(td.ids))
+	for i, id := range td.ids {
+		ids[i] = id.String()
+	}
+	d2 := td.darc.Copy()
+	require.NoError(t, d2.Rules.UpdateEvolution(expression.InitThresholdExpr(2, ids...)))
+	require.NoError(t, d2.Rules.AddRule("spawn:test", expression.Expr(ids[2])))
+
+	changes, err := DiffDarcs(td.darc, d2, nil)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+
+	require.Equal(t, Action("_evolve"), changes[0].Action)
+	require.Len(t, changes[0].Gained, 3)
+	require.Empty(t, changes[0].Lost)
+
+	require.Equal(t, Action("spawn:test"), changes[1].Action)
+	require.Equal(t, expression.Sets{{ids[2]}}, changes[1].Gained)
+
+	// The other way round, the permissions are lost.
+	changes, err = DiffDarcs(d2, td.darc, nil)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	require.Empty(t, changes[0].Gained)
+	require.Len(t, changes[0].Lost, 3)
+
+	changes, err = DiffDarcs(td.darc, td.darc, nil)
+	require.NoError(t, err)
+	require.Empty(t, changes)
+}
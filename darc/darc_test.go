@@ -445,6 +445,36 @@ func TestDarc_DelegationDiamond(t *testing.T) {
 	require.NoError(t, err)
 }
 
+// TestDarc_Threshold checks that an evolution needs enough signatures when the
+// evolve rule is a threshold expression.
+func TestDarc_Threshold(t *testing.T) {
+	td1 := createDarc(3, "threshold")
+	ids := make([]string, len(td1.ids))
+	for i, id := range td1.ids {
+		ids[i] = id.String()
+	}
+	require.NoError(t, td1.darc.Rules.UpdateEvolution(expression.InitThresholdExpr(2, ids...)))
+
+	td2 := createDarc(1, "evolved")
+	require.NoError(t, localEvolution(td2.darc, td1.darc, td1.owners[0]))
+	require.Error(t, td2.darc.Verify(true))
+	require.NoError(t, localEvolution(td2.darc, td1.darc, td1.owners[0], td1.owners[2]))
+	require.NoError(t, td2.darc.Verify(true))
+
+	// The first owner has enough weight on its own.
+	weighted := expression.InitWeightedExpr(3,
+		expression.WeightedID{ID: ids[0], Weight: 3},
+		expression.WeightedID{ID: ids[1], Weight: 2},
+		expression.WeightedID{ID: ids[2], Weight: 1})
+	require.NoError(t, td1.darc.Rules.UpdateEvolution(weighted))
+	require.NoError(t, localEvolution(td2.darc, td1.darc, td1.owners[0]))
+	require.NoError(t, td2.darc.Verify(true))
+	require.NoError(t, localEvolution(td2.darc, td1.darc, td1.owners[2]))
+	require.Error(t, td2.darc.Verify(true))
+	require.NoError(t, localEvolution(td2.darc, td1.darc, td1.owners[1], td1.owners[2]))
+	require.NoError(t, td2.darc.Verify(true))
+}
+
 func TestDarc_X509(t *testing.T) {
 	// TODO
 }
@@ -5,7 +5,10 @@ the syntax we use is from: https://en.wikipedia.org/wiki/Extended_Backus%E2%80%9
 
 	expr = term, [ '&', term ]*
 	term = factor, [ '|', factor ]*
-	factor = '(', expr, ')' | id | openid
+	factor = '(', expr, ')' | thexpr | id | openid
+	thexpr = '[', wfactor, [ ',', wfactor ]*, ']', '/', number
+	wfactor = [ number, '*' ], factor
+	number = [1-9][0-9]{0,8}
 	identity = (darc|ed25519|x509ec):[0-9a-fA-F]+
 	proxy = proxy:[0-9a-fA-F]+:[^ \n\t]*
 	attr = attr:[0-9a-zA-Z\-\_]+:[^ \n\t]*
@@ -16,6 +19,8 @@ Examples:
 	(ed25519:a & x509ec:b) | (darc:c & ed25519:d)
 	proxy:deadbeef:me@example.com // where deadbeef is a ed25519 public key
 	attr:time_interval:before=5pm&after=9am & ed25519:deadbeef
+	[ed25519:a, ed25519:b, darc:c]/2 // 2 out of 3
+	[3*ed25519:a, 2*ed25519:b, (darc:c & darc:d)]/4 // weighted threshold
 
 In the simplest case, the evaluation of an expression is performed against a
 set of valid ids.  Suppose we have the expression (a:a & b:b) | (c:c & d:d),
@@ -24,15 +29,21 @@ true.  If the set of valid ids is [a:a, c:c], then the expression will evaluate
 to false. However, the user is able to provide a ValueCheckFn to customise how
 the expressions are evaluated.
 
-EXTENSION - NOT YET IMPLEMENTED:
-To support threshold signatures, we extend the syntax to include the following.
-	thexpr = '[', id, [ ',', id ]*, ']', '/', digit
+A threshold expression [f1, f2, ..., fn]/k evaluates to true if at least k of
+its factors evaluate to true. Every factor can be given a weight w with w*f, in
+which case the weights of the factors that evaluate to true must add up to at
+least k. Factors without a weight count for one. A threshold that is bigger
+than the sum of all the weights is rejected by the parser. As proxy and attr
+consume everything until the next whitespace, they must be followed by a space
+inside a threshold expression, which InitThresholdExpr and InitWeightedExpr
+add.
 */
 package expression
 
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 
 	parsec "github.com/prataprc/goparsec"
@@ -50,8 +61,45 @@ type ValueCheckFn func(string) bool
 // Expr represents the unprocess expression of our DSL.
 type Expr []byte
 
+// algebra defines how the ids of an expression are turned into values and
+// how the values are combined by the operators.
+type algebra struct {
+	value     func(string) parsec.ParsecNode
+	and       func(a, b parsec.ParsecNode) parsec.ParsecNode
+	or        func(a, b parsec.ParsecNode) parsec.ParsecNode
+	threshold func(values []parsec.ParsecNode, weights []int, threshold int) parsec.ParsecNode
+}
+
+// boolAlgebra evaluates the expression to a boolean.
+func boolAlgebra(fn ValueCheckFn) algebra {
+	return algebra{
+		value: func(s string) parsec.ParsecNode {
+			return fn(s)
+		},
+		and: func(a, b parsec.ParsecNode) parsec.ParsecNode {
+			return a.(bool) && b.(bool)
+		},
+		or: func(a, b parsec.ParsecNode) parsec.ParsecNode {
+			return a.(bool) || b.(bool)
+		},
+		threshold: func(values []parsec.ParsecNode, weights []int, threshold int) parsec.ParsecNode {
+			var sum int
+			for i, v := range values {
+				if v.(bool) {
+					sum += weights[i]
+				}
+			}
+			return sum >= threshold
+		},
+	}
+}
+
 // InitParser creates the root parser
 func InitParser(fn ValueCheckFn) parsec.Parser {
+	return initParser(boolAlgebra(fn))
+}
+
+func initParser(alg algebra) parsec.Parser {
 	// Y is root Parser, usually called as `s` in CFG theory.
 	var Y parsec.Parser
 	var sum, value parsec.Parser // circular rats
@@ -61,6 +109,12 @@ func InitParser(fn ValueCheckFn) parsec.Parser {
 	var closeparan = parsec.Token(`\)`, "CLOSEPARAN")
 	var andop = parsec.Token(`&`, "AND")
 	var orop = parsec.Token(`\|`, "OR")
+	var openbracket = parsec.Token(`\[`, "OPENBRACKET")
+	var closebracket = parsec.Token(`\]`, "CLOSEBRACKET")
+	var comma = parsec.Token(`,`, "COMMA")
+	var slash = parsec.Token(`/`, "SLASH")
+	var star = parsec.Token(`\*`, "STAR")
+	var number = parsec.Token(`[1-9][0-9]{0,8}`, "NUMBER")
 
 	// NonTerminal rats
 	// sumOp -> "&" |  "|"
@@ -69,14 +123,26 @@ func InitParser(fn ValueCheckFn) parsec.Parser {
 	// value -> "(" expr ")"
 	var groupExpr = parsec.And(exprNode, openparan, &sum, closeparan)
 
+	// weighted -> number "*" value | value
+	var weighted = parsec.OrdChoice(one2one,
+		parsec.And(weightedNode, number, star, &value),
+		parsec.And(weightedNode, &value))
+
+	// ("," weighted)*
+	var weightedK = parsec.Kleene(nil, parsec.And(many2many, comma, weighted), nil)
+
+	// value -> "[" weighted ("," weighted)* "]" "/" number
+	var thresholdExpr = parsec.And(thresholdNode(alg), openbracket, weighted, weightedK,
+		closebracket, slash, number)
+
 	// (andop prod)*
 	var prodK = parsec.Kleene(nil, parsec.And(many2many, sumOp, &value), nil)
 
 	// Circular rats come to life
 	// sum -> prod (andop prod)*
-	sum = parsec.And(sumNode(fn), &value, prodK)
-	// value -> id | "(" expr ")"
-	value = parsec.OrdChoice(exprValueNode(fn), identity(), proxy(), attr(), groupExpr)
+	sum = parsec.And(sumNode(alg), &value, prodK)
+	// value -> id | "(" expr ")" | "[" weighted ("," weighted)* "]" "/" number
+	value = parsec.OrdChoice(exprValueNode(alg), identity(), proxy(), attr(), groupExpr, thresholdExpr)
 	// expr  -> sum
 	Y = parsec.OrdChoice(one2one, sum)
 	return Y
@@ -124,6 +190,47 @@ func InitOrExpr(ids ...string) Expr {
 	return Expr(strings.Join(ids, " | "))
 }
 
+// InitThresholdExpr creates an expression that evaluates to true if at least
+// threshold of the IDs evaluate to true.
+func InitThresholdExpr(threshold int, ids ...string) Expr {
+	factors := make([]string, len(ids))
+	for i, id := range ids {
+		factors[i] = ThresholdFactor(id)
+	}
+	return Expr(fmt.Sprintf("[%s]/%d", strings.Join(factors, ", "), threshold))
+}
+
+// ThresholdFactor returns the ID or the expression followed by a space if it
+// ends with a proxy or an attr, so that the token doesn't consume the "," or
+// the "]" or the ")" that follows it.
+func ThresholdFactor(id string) string {
+	fields := strings.Fields(id)
+	if len(fields) == 0 {
+		return id
+	}
+	last := strings.TrimLeft(fields[len(fields)-1], "(")
+	if strings.HasPrefix(last, "proxy:") || strings.HasPrefix(last, "attr:") {
+		return id + " "
+	}
+	return id
+}
+
+// WeightedID is an ID together with its weight in a threshold expression.
+type WeightedID struct {
+	ID     string
+	Weight int
+}
+
+// InitWeightedExpr creates an expression that evaluates to true if the
+// weights of the IDs that evaluate to true add up to at least threshold.
+func InitWeightedExpr(threshold int, ids ...WeightedID) Expr {
+	terms := make([]string, len(ids))
+	for i, id := range ids {
+		terms[i] = fmt.Sprintf("%d*%s", id.Weight, ThresholdFactor(id.ID))
+	}
+	return Expr(fmt.Sprintf("[%s]/%d", strings.Join(terms, ", "), threshold))
+}
+
 // Accepts tokens of the form "identity_type:HEX"
 func identity() parsec.Parser {
 	return func(s parsec.Scanner) (parsec.ParsecNode, parsec.Scanner) {
@@ -151,18 +258,18 @@ func attr() parsec.Parser {
 	}
 }
 
-func sumNode(fn ValueCheckFn) func(ns []parsec.ParsecNode) parsec.ParsecNode {
+func sumNode(alg algebra) func(ns []parsec.ParsecNode) parsec.ParsecNode {
 	return func(ns []parsec.ParsecNode) parsec.ParsecNode {
 		if len(ns) > 0 {
-			val := ns[0].(bool)
+			val := ns[0]
 			for _, x := range ns[1].([]parsec.ParsecNode) {
 				y := x.([]parsec.ParsecNode)
-				n := y[1].(bool)
+				n := y[1]
 				switch y[0].(*parsec.Terminal).Name {
 				case "AND":
-					val = val && n
+					val = alg.and(val, n)
 				case "OR":
-					val = val || n
+					val = alg.or(val, n)
 				}
 			}
 			return val
@@ -171,17 +278,68 @@ func sumNode(fn ValueCheckFn) func(ns []parsec.ParsecNode) parsec.ParsecNode {
 	}
 }
 
-func exprValueNode(fn ValueCheckFn) func(ns []parsec.ParsecNode) parsec.ParsecNode {
+func exprValueNode(alg algebra) func(ns []parsec.ParsecNode) parsec.ParsecNode {
 	return func(ns []parsec.ParsecNode) parsec.ParsecNode {
 		if len(ns) == 0 {
 			return nil
 		} else if term, ok := ns[0].(*parsec.Terminal); ok {
-			return fn(term.Value)
+			return alg.value(term.Value)
 		}
 		return ns[0]
 	}
 }
 
+// weightedValue is a factor of a threshold expression.
+type weightedValue struct {
+	weight int
+	value  parsec.ParsecNode
+}
+
+func weightedNode(ns []parsec.ParsecNode) parsec.ParsecNode {
+	switch len(ns) {
+	case 1:
+		return weightedValue{1, ns[0]}
+	case 3:
+		w, err := strconv.Atoi(ns[0].(*parsec.Terminal).Value)
+		if err != nil {
+			return nil
+		}
+		return weightedValue{w, ns[2]}
+	}
+	return nil
+}
+
+func thresholdNode(alg algebra) func(ns []parsec.ParsecNode) parsec.ParsecNode {
+	return func(ns []parsec.ParsecNode) parsec.ParsecNode {
+		if len(ns) != 6 {
+			return nil
+		}
+		factors := []weightedValue{ns[1].(weightedValue)}
+		for _, x := range ns[2].([]parsec.ParsecNode) {
+			factors = append(factors, x.([]parsec.ParsecNode)[1].(weightedValue))
+		}
+		threshold, err := strconv.Atoi(ns[5].(*parsec.Terminal).Value)
+		if err != nil {
+			return nil
+		}
+
+		values := make([]parsec.ParsecNode, len(factors))
+		weights := make([]int, len(factors))
+		var total int
+		for i, f := range factors {
+			values[i] = f.value
+			weights[i] = f.weight
+			total += f.weight
+		}
+		// A threshold that can never be reached is most probably a
+		// mistake, so the expression is rejected.
+		if threshold > total {
+			return nil
+		}
+		return alg.threshold(values, weights, threshold)
+	}
+}
+
 func exprNode(ns []parsec.ParsecNode) parsec.ParsecNode {
 	if len(ns) == 0 {
 		return nil
@@ -262,3 +262,98 @@ func TestEval_DefaultParser(t *testing.T) {
 		t.Fatal("evaluation should return false")
 	}
 }
+
+func TestParsing_Threshold(t *testing.T) {
+	keys := []string{"ed25519:a", "ed25519:b"}
+	tests := []struct {
+		expr string
+		ok   bool
+	}{
+		{"[ed25519:a, ed25519:b, ed25519:c]/2", true},
+		{"[ed25519:a, ed25519:c, ed25519:d]/2", false},
+		{"[ed25519:a,ed25519:b,ed25519:c]/3", false},
+		{"[ed25519:c]/1 | ed25519:a", true},
+		{"[(ed25519:a & ed25519:b), ed25519:c]/1", true},
+		{"[[ed25519:a, ed25519:c]/1, ed25519:d]/1", true},
+		{"[3*ed25519:a, 2*ed25519:c, ed25519:d]/3", true},
+		{"[2*ed25519:a, 2*ed25519:c, ed25519:b]/4", false},
+		{"[2*ed25519:a, 2*ed25519:c, ed25519:b]/3", true},
+		{"[attr:a:b=c , ed25519:c]/1", false},
+	}
+	for _, test := range tests {
+		ok, err := DefaultParser(Expr(test.expr), keys...)
+		if err != nil {
+			t.Fatalf("%s: %v", test.expr, err)
+		}
+		if ok != test.ok {
+			t.Fatalf("%s: expected %v", test.expr, test.ok)
+		}
+	}
+
+	invalid := []string{
+		"[]/1",
+		"[ed25519:a, ed25519:b]",
+		"[ed25519:a, ed25519:b]/0",
+		"[ed25519:a, ed25519:b]/3",
+		"[0*ed25519:a, ed25519:b]/1",
+		"[ed25519:a, ]/1",
+		"[ed25519:a & ed25519:b]/1",
+	}
+	for _, expr := range invalid {
+		if _, err := DefaultParser(Expr(expr), keys...); err == nil {
+			t.Fatalf("%s should fail", expr)
+		}
+	}
+}
+
+func TestInitThreshold(t *testing.T) {
+	expr := InitThresholdExpr(2, "ed25519:a", "ed25519:b", "darc:c")
+	if string(expr) != "[ed25519:a, ed25519:b, darc:c]/2" {
+		t.Fatalf("wrong expression %s", expr)
+	}
+	ok, err := DefaultParser(expr, "darc:c", "ed25519:b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok != true {
+		t.Fatal("evaluation should return true")
+	}
+
+	expr = InitWeightedExpr(3, WeightedID{"ed25519:a", 2}, WeightedID{"ed25519:b", 1})
+	if string(expr) != "[2*ed25519:a, 1*ed25519:b]/3" {
+		t.Fatalf("wrong expression %s", expr)
+	}
+	ok, err = DefaultParser(expr, "ed25519:a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok != false {
+		t.Fatal("evaluation should return false")
+	}
+
+	// The attr and proxy tokens must not consume the "," or the "]/k"
+	// that follow them.
+	attr := "attr:a:x=1"
+	proxy := "proxy:aef:me@example.com"
+	for _, expr := range []Expr{
+		InitThresholdExpr(2, attr, proxy, "ed25519:b"),
+		InitThresholdExpr(2, "ed25519:b", proxy, attr),
+		InitWeightedExpr(2, WeightedID{attr, 1}, WeightedID{proxy, 1}),
+		InitThresholdExpr(1, "ed25519:b", "(ed25519:c & "+ThresholdFactor(attr)+")"),
+	} {
+		ok, err = DefaultParser(expr, attr, proxy, "ed25519:c")
+		if err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		if ok != true {
+			t.Fatalf("%s: evaluation should return true", expr)
+		}
+		ok, err = DefaultParser(expr, attr)
+		if err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		if ok != false {
+			t.Fatalf("%s: evaluation should return false", expr)
+		}
+	}
+}
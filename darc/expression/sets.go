@@ -0,0 +1,214 @@
+package expression
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	parsec "github.com/prataprc/goparsec"
+)
+
+// MaxSets is the maximum number of sets that MinimalSets handles before
+// giving up, as the number of sets grows exponentially with the size of the
+// threshold and AND expressions.
+const MaxSets = 1024
+
+var errTooManySets = fmt.Errorf("more than %d sets satisfy the expression", MaxSets)
+
+// Sets is a list of alternatives, where every alternative is a sorted set of
+// ids. An expression is satisfied by a set of ids if it contains all the ids
+// of at least one of the alternatives. An empty Sets can never be satisfied,
+// while a Sets holding the empty set is always satisfied.
+type Sets [][]string
+
+// SetsFn is a function that returns the sets that satisfy a single id of an
+// expression.
+type SetsFn func(string) Sets
+
+// setsAlgebra combines the sets, and stops as soon as there are too many of
+// them.
+type setsAlgebra struct {
+	issue error
+}
+
+func (sa *setsAlgebra) combine(candidates Sets) Sets {
+	if sa.issue != nil {
+		return Sets{}
+	}
+	// The candidates are bounded so that minimize stays cheap.
+	if len(candidates) > 4*MaxSets {
+		sa.issue = errTooManySets
+		return Sets{}
+	}
+	res := candidates.minimize()
+	if len(res) > MaxSets {
+		sa.issue = errTooManySets
+		return Sets{}
+	}
+	return res
+}
+
+func (sa *setsAlgebra) or(a, b Sets) Sets {
+	candidates := make(Sets, 0, len(a)+len(b))
+	candidates = append(candidates, a...)
+	candidates = append(candidates, b...)
+	return sa.combine(candidates)
+}
+
+func (sa *setsAlgebra) and(a, b Sets) Sets {
+	if len(a)*len(b) > 4*MaxSets {
+		sa.issue = errTooManySets
+		return Sets{}
+	}
+	candidates := make(Sets, 0, len(a)*len(b))
+	for _, x := range a {
+		for _, y := range b {
+			union := make([]string, 0, len(x)+len(y))
+			union = append(union, x...)
+			union = append(union, y...)
+			candidates = append(candidates, union)
+		}
+	}
+	return sa.combine(candidates)
+}
+
+func (sa *setsAlgebra) threshold(values []Sets, weights []int, threshold int) Sets {
+	// partial maps the sum of the weights, up to the threshold, to the
+	// sets giving that sum.
+	partial := map[int]Sets{0: {{}}}
+	for i, v := range values {
+		next := make(map[int]Sets)
+		for w, s := range partial {
+			next[w] = sa.or(next[w], s)
+		}
+		for w, s := range partial {
+			sum := w + weights[i]
+			if sum > threshold {
+				sum = threshold
+			}
+			next[sum] = sa.or(next[sum], sa.and(s, v))
+		}
+		partial = next
+	}
+	if res, ok := partial[threshold]; ok {
+		return res
+	}
+	return Sets{}
+}
+
+// MinimalSets returns the minimal sets of ids that satisfy the expression,
+// every id being resolved by fn. A set is minimal if no other set of the
+// result is a subset of it.
+func MinimalSets(expr Expr, fn SetsFn) (Sets, error) {
+	sa := &setsAlgebra{}
+	Y := initParser(algebra{
+		value: func(s string) parsec.ParsecNode {
+			return sa.combine(fn(s))
+		},
+		and: func(a, b parsec.ParsecNode) parsec.ParsecNode {
+			return sa.and(a.(Sets), b.(Sets))
+		},
+		or: func(a, b parsec.ParsecNode) parsec.ParsecNode {
+			return sa.or(a.(Sets), b.(Sets))
+		},
+		threshold: func(values []parsec.ParsecNode, weights []int, threshold int) parsec.ParsecNode {
+			sets := make([]Sets, len(values))
+			for i, v := range values {
+				sets[i] = v.(Sets)
+			}
+			return sa.threshold(sets, weights, threshold)
+		},
+	})
+
+	v, s := Y(parsec.NewScanner(expr))
+	_, s = s.SkipWS()
+	if !s.Endof() {
+		rest, _ := s.Match(".*")
+		return nil, fmt.Errorf("%v: (rest = %v)", errScannerNotEmpty, string(rest))
+	}
+	if sa.issue != nil {
+		return nil, sa.issue
+	}
+	sets, ok := v.(Sets)
+	if !ok {
+		return nil, errors.New("evaluation failed - result is not a set")
+	}
+	return sets, nil
+}
+
+// SatisfiedBy returns true if the ids contain all the ids of at least one of
+// the sets.
+func (s Sets) SatisfiedBy(ids []string) bool {
+	has := make(map[string]bool)
+	for _, id := range ids {
+		has[id] = true
+	}
+	return containsSubset(s, has)
+}
+
+// String returns the sets as an expression made of ORs of ANDs.
+func (s Sets) String() string {
+	alternatives := make([]string, len(s))
+	for i, set := range s {
+		alternatives[i] = "(" + strings.Join(set, " & ") + ")"
+	}
+	return strings.Join(alternatives, " | ")
+}
+
+// minimize sorts and deduplicates every set, then removes the sets that are a
+// superset of another one.
+func (s Sets) minimize() Sets {
+	sets := make(Sets, len(s))
+	for i, set := range s {
+		sets[i] = normalize(set)
+	}
+	sort.SliceStable(sets, func(i, j int) bool {
+		if len(sets[i]) != len(sets[j]) {
+			return len(sets[i]) < len(sets[j])
+		}
+		return strings.Join(sets[i], ",") < strings.Join(sets[j], ",")
+	})
+
+	res := Sets{}
+	for _, set := range sets {
+		has := make(map[string]bool)
+		for _, id := range set {
+			has[id] = true
+		}
+		if !containsSubset(res, has) {
+			res = append(res, set)
+		}
+	}
+	return res
+}
+
+// containsSubset returns true if one of the sets only has ids of the map.
+func containsSubset(sets Sets, has map[string]bool) bool {
+	for _, set := range sets {
+		ok := true
+		for _, id := range set {
+			if !has[id] {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func normalize(set []string) []string {
+	res := make([]string, 0, len(set))
+	seen := make(map[string]bool)
+	for _, id := range set {
+		if !seen[id] {
+			seen[id] = true
+			res = append(res, id)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
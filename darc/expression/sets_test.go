@@ -0,0 +1,64 @@
+package expression
+
+import (
+	"strings"
+	"testing"
+)
+
+func idSets(s string) Sets {
+	return Sets{{s}}
+}
+
+func TestMinimalSets(t *testing.T) {
+	tests := []struct {
+		expr string
+		sets string
+	}{
+		{"ed25519:a", "(ed25519:a)"},
+		{"ed25519:a | ed25519:a & ed25519:b", "(ed25519:a)"},
+		{"(ed25519:a | ed25519:b) & ed25519:c", "(ed25519:a & ed25519:c) | (ed25519:b & ed25519:c)"},
+		{"[ed25519:a, ed25519:b, ed25519:c]/2",
+			"(ed25519:a & ed25519:b) | (ed25519:a & ed25519:c) | (ed25519:b & ed25519:c)"},
+		{"[2*ed25519:a, ed25519:b, ed25519:c]/2", "(ed25519:a) | (ed25519:b & ed25519:c)"},
+		{"ed25519:a & attr:block:after=2", "(attr:block:after=2 & ed25519:a)"},
+	}
+	for _, test := range tests {
+		sets, err := MinimalSets(Expr(test.expr), idSets)
+		if err != nil {
+			t.Fatalf("%s: %v", test.expr, err)
+		}
+		if sets.String() != test.sets {
+			t.Fatalf("%s: got %s, expected %s", test.expr, sets, test.sets)
+		}
+	}
+
+	// An id that can never be satisfied removes the alternatives using it.
+	sets, err := MinimalSets(Expr("ed25519:a & ed25519:b | ed25519:c"), func(s string) Sets {
+		if s == "ed25519:b" {
+			return Sets{}
+		}
+		return idSets(s)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sets.String() != "(ed25519:c)" {
+		t.Fatalf("got %s", sets)
+	}
+	if !sets.SatisfiedBy([]string{"ed25519:a", "ed25519:c"}) || sets.SatisfiedBy([]string{"ed25519:a"}) {
+		t.Fatal("wrong SatisfiedBy")
+	}
+
+	if _, err := MinimalSets(Expr("ed25519:a &"), idSets); err == nil {
+		t.Fatal("invalid expression should fail")
+	}
+
+	// 14 out of 28 has too many combinations.
+	ids := make([]string, 28)
+	for i := range ids {
+		ids[i] = "ed25519:" + strings.Repeat("a", i+1)
+	}
+	if _, err := MinimalSets(InitThresholdExpr(14, ids...), idSets); err == nil {
+		t.Fatal("too many sets should fail")
+	}
+}
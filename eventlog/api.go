@@ -68,6 +68,54 @@ func (c *Client) Create() error {
 	return nil
 }
 
+// SetRetention sets the retention policy of the event log, which is enforced
+// by Prune. The signers need the "invoke:eventlog.setRetention" permission.
+func (c *Client) SetRetention(policy RetentionPolicy) error {
+	buf, err := protobuf.Encode(&policy)
+	if err != nil {
+		return err
+	}
+	return c.invokeAndWait(retentionCmd, byzcoin.Arguments{{Name: "retention", Value: buf}})
+}
+
+// Prune removes the old events of the event log following its retention
+// policy, and logs an event with the ArchiveDigest of the removed events. It
+// removes at most about a thousand events at once, so it must be called until
+// it returns an error saying there is nothing to prune. The signers need the
+// "invoke:eventlog.prune" permission.
+func (c *Client) Prune() error {
+	return c.invokeAndWait(pruneCmd, nil)
+}
+
+func (c *Client) invokeAndWait(cmd string, args byzcoin.Arguments) error {
+	if c.signerCtrs == nil {
+		c.RefreshSignerCounters()
+	}
+
+	instr := byzcoin.Instruction{
+		InstanceID: c.Instance,
+		Invoke: &byzcoin.Invoke{
+			ContractID: contractName,
+			Command:    cmd,
+			Args:       args,
+		},
+		SignerCounter: c.nextCtrs(),
+	}
+	tx, err := c.ByzCoin.CreateTransaction(instr)
+	if err != nil {
+		return err
+	}
+	if err := tx.FillSignersAndSignWith(c.Signers...); err != nil {
+		return err
+	}
+	if _, err := c.ByzCoin.AddTransactionAndWait(tx, 10); err != nil {
+		return err
+	}
+
+	c.incrementCtrs()
+	return nil
+}
+
 // RefreshSignerCounters talks to the service to get the latest signer
 // counters, the client should call this function if the internal counters
 // become de-synchronised.
@@ -212,6 +260,21 @@ func (c *Client) Search(req *SearchRequest) (*SearchResponse, error) {
 	return reply, nil
 }
 
+// SignArchive asks the first node of the roster to sign the digest of the
+// archive event with the given ID, and verifies the signature.
+func (c *Client) SignArchive(eventID []byte) (*SignArchiveResponse, error) {
+	req := &SignArchiveRequest{ID: c.ByzCoin.ID, EventID: eventID}
+	reply := &SignArchiveResponse{}
+	si := c.ByzCoin.Roster.List[0]
+	if err := c.c.SendProtobuf(si, req, reply); err != nil {
+		return nil, err
+	}
+	if err := VerifyArchiveSignature(si.Public, c.ByzCoin.ID, eventID, reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
 // StreamHandler is the signature of the handler used when streaming events.
 type StreamHandler func(event Event, blockID []byte, err error)
 
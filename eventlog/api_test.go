@@ -303,6 +303,61 @@ func TestClient_Search(t *testing.T) {
 	require.True(t, resp.Truncated)
 	searchMax = sm
 
+	// Search by content.
+	req = &SearchRequest{Content: "time 1"}
+	resp, err = c.Search(req)
+	require.NoError(t, err)
+	require.Equal(t, 11, len(resp.Events))
+	req = &SearchRequest{ContentRegex: "time 1[0-4]$", Topics: []string{"b"}}
+	resp, err = c.Search(req)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(resp.Events))
+	req = &SearchRequest{ContentRegex: "time ("}
+	_, err = c.Search(req)
+	require.Error(t, err)
+
+	// Search with many topics.
+	req = &SearchRequest{Topics: []string{"a", "b"}}
+	resp, err = c.Search(req)
+	require.NoError(t, err)
+	require.Equal(t, 20, len(resp.Events))
+	req = &SearchRequest{Topic: "a", Topics: []string{"c"}}
+	resp, err = c.Search(req)
+	require.NoError(t, err)
+	require.Equal(t, 10, len(resp.Events))
+
+	// Page through the events with the cursor, in both orders.
+	for _, desc := range []bool{false, true} {
+		req = &SearchRequest{Descending: desc, Limit: 3}
+		var found []Event
+		for i := 0; i < 10; i++ {
+			resp, err = c.Search(req)
+			require.NoError(t, err)
+			require.True(t, len(resp.Events) <= 3)
+			found = append(found, resp.Events...)
+			if !resp.Truncated {
+				break
+			}
+			require.NotNil(t, resp.Cursor)
+			req.Cursor = resp.Cursor
+		}
+		require.False(t, resp.Truncated)
+		require.Equal(t, 20, len(found))
+		seen := make(map[string]bool)
+		for _, ev := range found {
+			require.False(t, seen[ev.Content])
+			seen[ev.Content] = true
+		}
+		if desc {
+			require.True(t, found[0].When > found[19].When)
+		} else {
+			require.True(t, found[0].When < found[19].When)
+		}
+	}
+	req = &SearchRequest{Cursor: []byte("not a cursor")}
+	_, err = c.Search(req)
+	require.Error(t, err)
+
 	// Put one more event on now.
 	tm := time.Now().UnixNano()
 	_, err = c.Log(Event{Topic: "none", Content: "one more", When: tm})
@@ -319,6 +374,255 @@ func TestClient_Search(t *testing.T) {
 	require.False(t, resp.Truncated)
 }
 
+func TestClient_Prune(t *testing.T) {
+	s, c := newSer(t)
+	leader := s.services[0]
+	defer s.close()
+
+	require.NoError(t, c.Create())
+	waitForKey(t, leader.omni, c.ByzCoin.ID, c.Instance.Slice(), testBlockInterval)
+	require.Error(t, c.Prune())
+
+	// Put the events in three buckets, as they are more than bucketMaxAge
+	// apart.
+	now := time.Now()
+	var events []Event
+	for _, ago := range []time.Duration{25, 15, 5} {
+		for i := 0; i < 2; i++ {
+			events = append(events, Event{
+				Topic:   "test",
+				Content: fmt.Sprintf("%ds ago, %d", ago, i),
+				When:    now.Add(-ago * time.Second).UnixNano(),
+			})
+		}
+	}
+	ids, err := c.Log(events...)
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		leader.waitForBlock(c.ByzCoin.ID)
+		if err = leader.checkBuckets(c.Instance, c.ByzCoin.ID, len(events)); err == nil {
+			break
+		}
+	}
+	require.NoError(t, err)
+
+	// Nothing to prune without a policy or with a large policy.
+	require.Error(t, c.Prune())
+	require.NoError(t, c.SetRetention(RetentionPolicy{MaxEvents: 10}))
+	require.Error(t, c.Prune())
+
+	// Only the latest bucket is kept.
+	require.NoError(t, c.SetRetention(RetentionPolicy{MaxEvents: 3}))
+
+	// The policy cannot be changed through its own instance, and the
+	// archive events can only be logged by prune.
+	logEvent := func(ev Event) error {
+		buf, err := protobuf.Encode(&ev)
+		require.NoError(t, err)
+		return c.invokeAndWait(logCmd, byzcoin.Arguments{{Name: "event", Value: buf}})
+	}
+	policyBuf, err := protobuf.Encode(&RetentionPolicy{MaxEvents: 100})
+	require.NoError(t, err)
+	instance := c.Instance
+	c.Instance = retentionID(instance)
+	require.Error(t, logEvent(NewEvent("test", "policy")))
+	require.Error(t, c.invokeAndWait(retentionCmd, byzcoin.Arguments{{Name: "retention", Value: policyBuf}}))
+	c.Instance = instance
+	require.Error(t, logEvent(NewEvent(ArchiveTopic, "")))
+
+	// The events hold the index of their block.
+	resp, err := c.Search(&SearchRequest{})
+	require.NoError(t, err)
+	stored := resp.Events
+	for i, ev := range stored {
+		require.NotZero(t, ev.Block)
+		ev.Block = 0
+		require.Equal(t, events[i], ev)
+	}
+
+	require.NoError(t, c.Prune())
+	require.NoError(t, leader.checkBuckets(c.Instance, c.ByzCoin.ID, 3))
+	resp, err = c.Search(&SearchRequest{})
+	require.NoError(t, err)
+	require.Equal(t, 3, len(resp.Events))
+	require.Equal(t, stored[4:], resp.Events[:2])
+	_, err = c.GetEvent(ids[0])
+	require.Error(t, err)
+
+	// The digest is verified with the removed events.
+	digest, err := ParseArchiveDigest(resp.Events[2])
+	require.NoError(t, err)
+	require.Equal(t, 4, digest.Events)
+	require.Equal(t, events[0].When, digest.From)
+	require.Equal(t, events[3].When, digest.To)
+	var archived []ArchivedEvent
+	for i := 0; i < 4; i++ {
+		archived = append(archived, ArchivedEvent{ID: ids[i], Event: stored[i]})
+	}
+	root, err := ArchiveRoot(archived)
+	require.NoError(t, err)
+	require.Equal(t, root, digest.Root)
+	archived[1].Event.Content = "forged"
+	root, err = ArchiveRoot(archived)
+	require.NoError(t, err)
+	require.NotEqual(t, root, digest.Root)
+
+	// The node signs the digest.
+	signed, err := c.SignArchive(resp.IDs[2])
+	require.NoError(t, err)
+	require.Equal(t, *digest, signed.Digest)
+	pub := s.roster.List[0].Public
+	require.NoError(t, VerifyArchiveSignature(pub, c.ByzCoin.ID, resp.IDs[2], signed))
+	require.Error(t, VerifyArchiveSignature(pub, c.ByzCoin.ID, resp.IDs[0], signed))
+	require.Error(t, VerifyArchiveSignature(s.roster.List[1].Public, c.ByzCoin.ID, resp.IDs[2], signed))
+	_, err = c.SignArchive(resp.IDs[0])
+	require.Error(t, err)
+
+	require.Error(t, c.Prune())
+}
+
+func TestClient_Export(t *testing.T) {
+	s, c := newSer(t)
+	leader := s.services[0]
+	defer s.close()
+
+	require.NoError(t, c.Create())
+	waitForKey(t, leader.omni, c.ByzCoin.ID, c.Instance.Slice(), testBlockInterval)
+
+	now := time.Now()
+	var events []Event
+	for _, ago := range []time.Duration{25, 15, 5} {
+		for i := 0; i < 2; i++ {
+			events = append(events, Event{
+				Topic:   "test",
+				Content: fmt.Sprintf("%ds ago, %d", ago, i),
+				When:    now.Add(-ago * time.Second).UnixNano(),
+			})
+		}
+	}
+	ids, err := c.Log(events...)
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		leader.waitForBlock(c.ByzCoin.ID)
+		if err = leader.checkBuckets(c.Instance, c.ByzCoin.ID, len(events)); err == nil {
+			break
+		}
+	}
+	require.NoError(t, err)
+
+	var exported []ExportedEvent
+	require.NoError(t, c.Export(func(ee ExportedEvent) error {
+		exported = append(exported, ee)
+		return nil
+	}))
+	require.Equal(t, len(events), len(exported))
+	for i, ee := range exported {
+		require.Equal(t, LogID(ee.ID), ids[i])
+		require.NotNil(t, ee.Block)
+		ev := ee.Event
+		ev.Block = 0
+		require.Equal(t, events[i], ev)
+	}
+	archived, err := c.VerifyExport(exported)
+	require.NoError(t, err)
+	require.Equal(t, 0, archived)
+
+	// A modified export is refused.
+	forged := append([]ExportedEvent{}, exported...)
+	forged[1].Event.Content = "forged"
+	_, err = c.VerifyExport(forged)
+	require.Error(t, err)
+	forged = append([]ExportedEvent{}, exported...)
+	forged[1].Block = c.ByzCoin.ID
+	_, err = c.VerifyExport(forged)
+	require.Error(t, err)
+
+	// The removed events are verified with the archive digest.
+	require.NoError(t, c.SetRetention(RetentionPolicy{MaxEvents: 3}))
+	require.NoError(t, c.Prune())
+	archived, err = c.VerifyExport(exported)
+	require.NoError(t, err)
+	require.Equal(t, 4, archived)
+	_, err = c.VerifyExport(exported[1:])
+	require.Error(t, err)
+
+	first := exported
+	exported = nil
+	require.NoError(t, c.Export(func(ee ExportedEvent) error {
+		exported = append(exported, ee)
+		return nil
+	}))
+	require.Equal(t, 3, len(exported))
+	require.Equal(t, ArchiveTopic, exported[2].Event.Topic)
+	archived, err = c.VerifyExport(exported)
+	require.NoError(t, err)
+	require.Equal(t, 0, archived)
+
+	// A second prune keeps the first archive event, and both exports are
+	// still verified with the chain of digests.
+	_, err = c.Log(NewEvent("test", "now, 0"), NewEvent("test", "now, 1"))
+	require.NoError(t, err)
+	require.NoError(t, c.Prune())
+	archived, err = c.VerifyExport(first)
+	require.NoError(t, err)
+	require.Equal(t, 6, archived)
+	archived, err = c.VerifyExport(exported)
+	require.NoError(t, err)
+	require.Equal(t, 2, archived)
+
+	exported = nil
+	require.NoError(t, c.Export(func(ee ExportedEvent) error {
+		exported = append(exported, ee)
+		return nil
+	}))
+	require.Equal(t, 4, len(exported))
+	require.Equal(t, ArchiveTopic, exported[0].Event.Topic)
+	require.Equal(t, ArchiveTopic, exported[3].Event.Topic)
+	archived, err = c.VerifyExport(exported)
+	require.NoError(t, err)
+	require.Equal(t, 0, archived)
+
+	// The export fails once the transactions of a block have been pruned.
+	for _, svc := range s.services {
+		db := svc.Service(skipchain.ServiceName).(*skipchain.Service).GetDB()
+		_, err := db.PrunePayload(exported[0].Block)
+		require.NoError(t, err)
+	}
+	err = c.Export(func(ExportedEvent) error { return nil })
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "pruned")
+	_, err = c.VerifyExport(exported)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "pruned")
+}
+
+func TestArchiveRoot(t *testing.T) {
+	_, err := ArchiveRoot(nil)
+	require.Error(t, err)
+
+	var events []ArchivedEvent
+	roots := make(map[string]bool)
+	for i := 0; i < 5; i++ {
+		events = append(events, ArchivedEvent{
+			ID:    []byte{byte(i)},
+			Event: NewEvent("topic", fmt.Sprintf("event %d", i)),
+		})
+		root, err := ArchiveRoot(events)
+		require.NoError(t, err)
+		require.Equal(t, 32, len(root))
+		require.False(t, roots[string(root)])
+		roots[string(root)] = true
+	}
+
+	// The order of the events matters.
+	root, err := ArchiveRoot(events)
+	require.NoError(t, err)
+	events[0], events[1] = events[1], events[0]
+	swapped, err := ArchiveRoot(events)
+	require.NoError(t, err)
+	require.NotEqual(t, root, swapped)
+}
+
 func TestClient_StreamEvents(t *testing.T) {
 	s, c := newSer(t)
 	leader := s.services[0]
@@ -546,7 +850,9 @@ func newSer(t *testing.T) (*ser, *Client) {
 
 	var err error
 	s.req, err = byzcoin.DefaultGenesisMsg(byzcoin.CurrentVersion, s.roster,
-		[]string{"spawn:" + contractName, "invoke:" + contractName + "." + logCmd, "_name:" + contractName}, s.owner.Identity())
+		[]string{"spawn:" + contractName, "invoke:" + contractName + "." + logCmd,
+			"invoke:" + contractName + "." + retentionCmd, "invoke:" + contractName + "." + pruneCmd,
+			"_name:" + contractName}, s.owner.Identity())
 	if err != nil {
 		t.Fatal(err)
 	}
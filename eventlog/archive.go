@@ -0,0 +1,423 @@
+package eventlog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/sign/schnorr"
+	"go.dedis.ch/onet/v3/network"
+	"go.dedis.ch/protobuf"
+)
+
+// ArchiveTopic is the topic of the events holding an ArchiveDigest.
+const ArchiveTopic = "eventlog.archive"
+
+// retentionContractName is the contract of the instances holding the
+// retention policies. It refuses all the instructions, so that the policies
+// can only be changed by the setRetention command of their event log.
+const retentionContractName = "eventlogRetention"
+
+// archiveContractName is the contract of the instances holding the hash of the
+// latest archive digest of the event logs. It refuses all the instructions,
+// so that the hashes can only be changed by the prune command.
+const archiveContractName = "eventlogArchive"
+
+const retentionCmd = "setRetention"
+const pruneCmd = "prune"
+
+// maxPruneEvents is the number of events after which a prune stops removing
+// buckets, so that the state changes of an instruction stay small. The prune
+// command must then be sent again.
+const maxPruneEvents = 1000
+
+// ArchivedEvent is an event removed from an event log, with its ID.
+type ArchivedEvent struct {
+	ID    []byte
+	Event Event
+}
+
+// ArchiveRoot returns the Merkle root over the events, in the order they have
+// been removed, which is the order of the buckets and of the events in the
+// buckets. The leaves are the hashes of the IDs and the events, and the last
+// node of an odd level is moved to the next level.
+func ArchiveRoot(events []ArchivedEvent) ([]byte, error) {
+	if len(events) == 0 {
+		return nil, errors.New("no events")
+	}
+	level := make([][]byte, len(events))
+	for i, ae := range events {
+		buf, err := protobuf.Encode(&ae.Event)
+		if err != nil {
+			return nil, err
+		}
+		h := sha256.New()
+		h.Write([]byte{0})
+		h.Write(ae.ID)
+		h.Write(buf)
+		level[i] = h.Sum(nil)
+	}
+	for len(level) > 1 {
+		var next [][]byte
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			h := sha256.New()
+			h.Write([]byte{1})
+			h.Write(level[i])
+			h.Write(level[i+1])
+			next = append(next, h.Sum(nil))
+		}
+		level = next
+	}
+	return level[0], nil
+}
+
+// Hash returns the hash of the digest, which is given as Prev to the next
+// digest of the log.
+func (d ArchiveDigest) Hash() ([]byte, error) {
+	buf, err := protobuf.Encode(&d)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.Sum256(buf)
+	return h[:], nil
+}
+
+// ParseArchiveDigest returns the digest held by an event with the
+// ArchiveTopic.
+func ParseArchiveDigest(ev Event) (*ArchiveDigest, error) {
+	if ev.Topic != ArchiveTopic {
+		return nil, errors.New("not an archive event")
+	}
+	buf, err := hex.DecodeString(ev.Content)
+	if err != nil {
+		return nil, err
+	}
+	digest := &ArchiveDigest{}
+	if err := protobuf.Decode(buf, digest); err != nil {
+		return nil, err
+	}
+	return digest, nil
+}
+
+// ArchiveMessage returns the message signed by a node for the digest of the
+// archive event with the given ID.
+func ArchiveMessage(id skipchain.SkipBlockID, eventID []byte, digest *ArchiveDigest) ([]byte, error) {
+	buf, err := protobuf.Encode(digest)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	h.Write(id)
+	h.Write(eventID)
+	h.Write(buf)
+	return h.Sum(nil), nil
+}
+
+// VerifyArchiveSignature verifies the signature of the node with the public
+// key pub over the digest of the archive event.
+func VerifyArchiveSignature(pub kyber.Point, id skipchain.SkipBlockID, eventID []byte, resp *SignArchiveResponse) error {
+	msg, err := ArchiveMessage(id, eventID, &resp.Digest)
+	if err != nil {
+		return err
+	}
+	return schnorr.Verify(cothority.Suite, pub, msg, resp.Signature)
+}
+
+// stateContract is the contract of the instances that are only changed by the
+// eventlog contract.
+type stateContract struct {
+	byzcoin.BasicContract
+}
+
+func stateContractFromBytes(in []byte) (byzcoin.Contract, error) {
+	return &stateContract{}, nil
+}
+
+// retentionID returns the ID of the instance holding the retention policy of
+// the event log.
+func retentionID(id byzcoin.InstanceID) byzcoin.InstanceID {
+	h := sha256.New()
+	h.Write(id.Slice())
+	h.Write([]byte("retention"))
+	return byzcoin.NewInstanceID(h.Sum(nil))
+}
+
+func getRetention(rst byzcoin.ReadOnlyStateTrie, id byzcoin.InstanceID) (*RetentionPolicy, error) {
+	key := retentionID(id).Slice()
+	proof, err := rst.GetProof(key)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := proof.Exists(key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+	v0, _, cid, _, err := rst.GetValues(key)
+	if err != nil {
+		return nil, err
+	}
+	if cid != retentionContractName {
+		return nil, fmt.Errorf("the retention policy is held by contract \"%s\"", cid)
+	}
+	policy := &RetentionPolicy{}
+	if err := protobuf.Decode(v0, policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// archiveID returns the ID of the instance holding the hash of the latest
+// archive digest of the event log.
+func archiveID(id byzcoin.InstanceID) byzcoin.InstanceID {
+	h := sha256.New()
+	h.Write(id.Slice())
+	h.Write([]byte("archive"))
+	return byzcoin.NewInstanceID(h.Sum(nil))
+}
+
+// getArchiveHash returns the hash of the latest archive digest of the event
+// log, or nil if nothing has been pruned yet.
+func getArchiveHash(rst byzcoin.ReadOnlyStateTrie, id byzcoin.InstanceID) ([]byte, error) {
+	key := archiveID(id).Slice()
+	proof, err := rst.GetProof(key)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := proof.Exists(key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+	hash, _, cid, _, err := rst.GetValues(key)
+	if err != nil {
+		return nil, err
+	}
+	if cid != archiveContractName {
+		return nil, fmt.Errorf("the archive hash is held by contract \"%s\"", cid)
+	}
+	return hash, nil
+}
+
+// setRetention stores the retention policy of the event log.
+func setRetention(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, darcID []byte) ([]byzcoin.StateChange, error) {
+	buf := inst.Invoke.Args.Search("retention")
+	if buf == nil {
+		return nil, errors.New("expected a named argument of \"retention\"")
+	}
+	policy := &RetentionPolicy{}
+	if err := protobuf.Decode(buf, policy); err != nil {
+		return nil, err
+	}
+	if policy.MaxAge < 0 || policy.MaxEvents < 0 {
+		return nil, errors.New("the retention policy cannot be negative")
+	}
+
+	old, err := getRetention(rst, inst.InstanceID)
+	if err != nil {
+		return nil, err
+	}
+	action := byzcoin.Create
+	if old != nil {
+		action = byzcoin.Update
+	}
+	return []byzcoin.StateChange{
+		byzcoin.NewStateChange(action, retentionID(inst.InstanceID), retentionContractName, buf, darcID),
+	}, nil
+}
+
+// prune removes the buckets of the event log that are not kept by the
+// retention policy, together with their events. The oldest kept bucket
+// becomes the first one, and an event with the digest of the removed events is
+// added to the latest bucket. The archive events are never removed: the ones
+// of the removed buckets are moved to the oldest kept bucket.
+func prune(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, darcID []byte) ([]byzcoin.StateChange, error) {
+	policy, err := getRetention(rst, inst.InstanceID)
+	if err != nil {
+		return nil, err
+	}
+	if policy == nil {
+		return nil, errors.New("the event log has no retention policy")
+	}
+	now, err := latestTimestamp(rst)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get all the buckets, from the latest to the first one.
+	el := &eventLog{Instance: inst.InstanceID, v: rst}
+	id, b, err := el.getLatestBucket()
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		return nil, errors.New("the event log is empty")
+	}
+	bids := [][]byte{id}
+	buckets := []*bucket{b}
+	for !b.isFirst() {
+		id = b.Prev
+		b, err = el.getBucketByID(id)
+		if err != nil {
+			return nil, err
+		}
+		bids = append(bids, id)
+		buckets = append(buckets, b)
+	}
+
+	// The events of a bucket are older than the start of the next one, so
+	// once a bucket is too old or doesn't fit anymore, the older ones are
+	// removed too.
+	keep := len(buckets)
+	events := buckets[0].events()
+	for i := 1; i < len(buckets); i++ {
+		tooOld := policy.MaxAge > 0 && buckets[i-1].Start <= now-policy.MaxAge
+		tooMany := policy.MaxEvents > 0 && events+buckets[i].events() > policy.MaxEvents
+		if tooOld || tooMany {
+			keep = i
+			break
+		}
+		events += buckets[i].events()
+	}
+	if keep == len(buckets) {
+		return nil, errors.New("nothing to prune")
+	}
+
+	// Remove the oldest buckets first, but at least one.
+	first := len(buckets)
+	removed := 0
+	for first > keep {
+		if removed > 0 && removed+buckets[first-1].events() > maxPruneEvents {
+			break
+		}
+		first--
+		removed += buckets[first].events()
+	}
+
+	var sc []byzcoin.StateChange
+	var archived []ArchivedEvent
+	var archives [][]byte
+	for i := len(buckets) - 1; i >= first; i-- {
+		for _, e := range buckets[i].EventRefs {
+			ev, err := getEventByID(rst, e)
+			if err != nil {
+				return nil, fmt.Errorf("bucket %x points to event %x, but the event was not found: %v", bids[i], e, err)
+			}
+			if ev.Topic == ArchiveTopic {
+				archives = append(archives, e)
+				continue
+			}
+			archived = append(archived, ArchivedEvent{ID: e, Event: *ev})
+			sc = append(sc, byzcoin.NewStateChange(byzcoin.Remove, byzcoin.NewInstanceID(e), contractName, nil, darcID))
+		}
+		sc = append(sc, byzcoin.NewStateChange(byzcoin.Remove, byzcoin.NewInstanceID(bids[i]), contractName, nil, darcID))
+	}
+
+	// The oldest kept bucket becomes the first one, and keeps the archive
+	// events of the removed buckets before its own events.
+	newFirst := first - 1
+	buckets[newFirst].Prev = nil
+	buckets[newFirst].EventRefs = append(archives, buckets[newFirst].EventRefs...)
+	buckets[newFirst].Archives += len(archives)
+
+	if len(archived) > 0 {
+		prev, err := getArchiveHash(rst, inst.InstanceID)
+		if err != nil {
+			return nil, err
+		}
+		digest := ArchiveDigest{
+			Events: len(archived),
+			From:   archived[0].Event.When,
+			To:     archived[0].Event.When,
+			Prev:   prev,
+		}
+		for _, ae := range archived {
+			if ae.Event.When < digest.From {
+				digest.From = ae.Event.When
+			}
+			if ae.Event.When > digest.To {
+				digest.To = ae.Event.When
+			}
+		}
+		digest.Root, err = ArchiveRoot(archived)
+		if err != nil {
+			return nil, err
+		}
+		digestBuf, err := protobuf.Encode(&digest)
+		if err != nil {
+			return nil, err
+		}
+		when := now
+		if when < buckets[0].Start {
+			when = buckets[0].Start
+		}
+		eventBuf, err := protobuf.Encode(&Event{
+			When:    when,
+			Topic:   ArchiveTopic,
+			Content: hex.EncodeToString(digestBuf),
+			Block:   rst.GetIndex() + 1,
+		})
+		if err != nil {
+			return nil, err
+		}
+		eventID := inst.DeriveID("archive")
+		sc = append(sc, byzcoin.NewStateChange(byzcoin.Create, eventID, contractName, eventBuf, darcID))
+		buckets[0].EventRefs = append(buckets[0].EventRefs, eventID.Slice())
+		buckets[0].Archives++
+
+		hash, err := digest.Hash()
+		if err != nil {
+			return nil, err
+		}
+		action := byzcoin.Create
+		if prev != nil {
+			action = byzcoin.Update
+		}
+		sc = append(sc, byzcoin.NewStateChange(action, archiveID(inst.InstanceID), archiveContractName, hash, darcID))
+	}
+
+	updated := []int{0}
+	if newFirst != 0 {
+		updated = append(updated, newFirst)
+	}
+	for _, i := range updated {
+		buf, err := protobuf.Encode(buckets[i])
+		if err != nil {
+			return nil, err
+		}
+		sc = append(sc, byzcoin.NewStateChange(byzcoin.Update, byzcoin.NewInstanceID(bids[i]), contractName, buf, darcID))
+	}
+	return sc, nil
+}
+
+// latestTimestamp returns the time of the latest block, in nanoseconds, so that
+// all the nodes agree on the events to remove.
+func latestTimestamp(rst byzcoin.ReadOnlyStateTrie) (int64, error) {
+	sc, ok := rst.(byzcoin.ReadOnlySkipChain)
+	if !ok {
+		return 0, errors.New("the skipchain is not available")
+	}
+	sb, err := sc.GetBlockByIndex(rst.GetIndex())
+	if err != nil {
+		return 0, err
+	}
+	var header byzcoin.DataHeader
+	err = protobuf.DecodeWithConstructors(sb.Data, &header, network.DefaultConstructors(cothority.Suite))
+	if err != nil {
+		return 0, err
+	}
+	return header.Timestamp, nil
+}
@@ -14,6 +14,14 @@ type bucket struct {
 	Start     int64
 	Prev      []byte
 	EventRefs [][]byte
+	// Archives is the number of archive events in EventRefs, which are
+	// never removed by prune.
+	Archives int `protobuf:"opt"`
+}
+
+// events returns the number of events of the bucket that can be removed.
+func (b bucket) events() int {
+	return len(b.EventRefs) - b.Archives
 }
 
 func (b bucket) isFirst() bool {
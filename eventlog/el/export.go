@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	cli "github.com/urfave/cli"
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/eventlog"
+	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/onet/v3/network"
+	"go.dedis.ch/protobuf"
+)
+
+// exportRecord is an exported event as it is written in the files. The IDs
+// are in hex, and the proof is the base64 of its protobuf representation.
+type exportRecord struct {
+	ID      string `json:"id"`
+	Block   string `json:"block"`
+	When    int64  `json:"when"`
+	Topic   string `json:"topic"`
+	Content string `json:"content"`
+	Proof   string `json:"proof"`
+}
+
+var csvHeader = []string{"id", "block", "when", "topic", "content", "proof"}
+
+func newExportRecord(ee eventlog.ExportedEvent) (*exportRecord, error) {
+	buf, err := protobuf.Encode(ee.Proof)
+	if err != nil {
+		return nil, err
+	}
+	return &exportRecord{
+		ID:      hex.EncodeToString(ee.ID),
+		Block:   hex.EncodeToString(ee.Block),
+		When:    ee.Event.When,
+		Topic:   ee.Event.Topic,
+		Content: ee.Event.Content,
+		Proof:   base64.StdEncoding.EncodeToString(buf),
+	}, nil
+}
+
+func (r exportRecord) exportedEvent() (*eventlog.ExportedEvent, error) {
+	id, err := hex.DecodeString(r.ID)
+	if err != nil {
+		return nil, err
+	}
+	block, err := hex.DecodeString(r.Block)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := base64.StdEncoding.DecodeString(r.Proof)
+	if err != nil {
+		return nil, err
+	}
+	proof := &byzcoin.Proof{}
+	err = protobuf.DecodeWithConstructors(buf, proof, network.DefaultConstructors(cothority.Suite))
+	if err != nil {
+		return nil, err
+	}
+	// The files don't hold the block index of the event, so it is taken
+	// from the proof.
+	ev := eventlog.Event{}
+	if val, _, _, err := proof.Get(id); err == nil {
+		if err := protobuf.Decode(val, &ev); err != nil {
+			return nil, err
+		}
+	}
+	return &eventlog.ExportedEvent{
+		ID:    id,
+		Block: block,
+		Event: eventlog.Event{
+			When:    r.When,
+			Topic:   r.Topic,
+			Content: r.Content,
+			Block:   ev.Block,
+		},
+		Proof: proof,
+	}, nil
+}
+
+func (r exportRecord) csv() []string {
+	return []string{r.ID, r.Block, strconv.FormatInt(r.When, 10), r.Topic, r.Content, r.Proof}
+}
+
+func exportRecordFromCSV(row []string) (*exportRecord, error) {
+	if len(row) != len(csvHeader) {
+		return nil, errors.New("wrong number of columns")
+	}
+	when, err := strconv.ParseInt(row[2], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &exportRecord{
+		ID:      row[0],
+		Block:   row[1],
+		When:    when,
+		Topic:   row[3],
+		Content: row[4],
+		Proof:   row[5],
+	}, nil
+}
+
+func checkFormat(format string) error {
+	if format != "jsonl" && format != "csv" {
+		return fmt.Errorf("unknown format %s, must be jsonl or csv", format)
+	}
+	return nil
+}
+
+func export(c *cli.Context) error {
+	format := c.String("format")
+	if err := checkFormat(format); err != nil {
+		return err
+	}
+	cl, err := getClient(c, false)
+	if err != nil {
+		return err
+	}
+	e := c.String("el")
+	if e == "" {
+		return errors.New("--el is required")
+	}
+	eb, err := hex.DecodeString(e)
+	if err != nil {
+		return err
+	}
+	cl.Instance = byzcoin.NewInstanceID(eb)
+
+	out := io.Writer(os.Stdout)
+	if o := c.String("out"); o != "" {
+		f, err := os.Create(o)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	w := bufio.NewWriter(out)
+	cw := csv.NewWriter(w)
+	if format == "csv" {
+		if err := cw.Write(csvHeader); err != nil {
+			return err
+		}
+	}
+	enc := json.NewEncoder(w)
+
+	count := 0
+	err = cl.Export(func(ee eventlog.ExportedEvent) error {
+		r, err := newExportRecord(ee)
+		if err != nil {
+			return err
+		}
+		count++
+		if format == "csv" {
+			return cw.Write(r.csv())
+		}
+		return enc.Encode(r)
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if out != os.Stdout {
+		log.Infof("Exported %d events", count)
+	}
+	return nil
+}
+
+func verify(c *cli.Context) error {
+	format := c.String("format")
+	if err := checkFormat(format); err != nil {
+		return err
+	}
+	if c.NArg() != 1 {
+		return errors.New("please give the file to verify")
+	}
+	cl, err := getClient(c, false)
+	if err != nil {
+		return err
+	}
+	e := c.String("el")
+	if e == "" {
+		return errors.New("--el is required")
+	}
+	eb, err := hex.DecodeString(e)
+	if err != nil {
+		return err
+	}
+	cl.Instance = byzcoin.NewInstanceID(eb)
+
+	f, err := os.Open(c.Args().First())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var records []exportRecord
+	if format == "csv" {
+		rows, err := csv.NewReader(f).ReadAll()
+		if err != nil {
+			return err
+		}
+		for i, row := range rows {
+			if i == 0 {
+				continue
+			}
+			r, err := exportRecordFromCSV(row)
+			if err != nil {
+				return fmt.Errorf("line %d: %v", i+1, err)
+			}
+			records = append(records, *r)
+		}
+	} else {
+		dec := json.NewDecoder(f)
+		for {
+			var r exportRecord
+			err := dec.Decode(&r)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			records = append(records, r)
+		}
+	}
+
+	events := make([]eventlog.ExportedEvent, len(records))
+	for i, r := range records {
+		ee, err := r.exportedEvent()
+		if err != nil {
+			return fmt.Errorf("event %d: %v", i+1, err)
+		}
+		events[i] = *ee
+	}
+	archived, err := cl.VerifyExport(events)
+	if err != nil {
+		return err
+	}
+	log.Infof("Verified %d events, %d of them have been archived", len(events), archived)
+	return nil
+}
@@ -140,6 +140,56 @@ var cmds = cli.Commands{
 		},
 		Action: doLog,
 	},
+	{
+		Name:  "retention",
+		Usage: "set the retention policy of the event log",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "sign",
+				Usage: "the ed25519 private key that will sign transactions",
+			},
+			cli.StringFlag{
+				Name:   "bc",
+				EnvVar: "BC",
+				Usage:  "the ByzCoin config",
+			},
+			cli.StringFlag{
+				Name:   "el",
+				EnvVar: "EL",
+				Usage:  "the eventlog id, from \"el create\"",
+			},
+			cli.DurationFlag{
+				Name:  "max-age",
+				Usage: "remove the events older than this (default: no limit)",
+			},
+			cli.IntFlag{
+				Name:  "max-events",
+				Usage: "remove the oldest events above this number (default: no limit)",
+			},
+		},
+		Action: retention,
+	},
+	{
+		Name:  "prune",
+		Usage: "remove the old events following the retention policy",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "sign",
+				Usage: "the ed25519 private key that will sign transactions",
+			},
+			cli.StringFlag{
+				Name:   "bc",
+				EnvVar: "BC",
+				Usage:  "the ByzCoin config",
+			},
+			cli.StringFlag{
+				Name:   "el",
+				EnvVar: "EL",
+				Usage:  "the eventlog id, from \"el create\"",
+			},
+		},
+		Action: prune,
+	},
 	{
 		Name:    "search",
 		Usage:   "search for messages",
@@ -175,9 +225,70 @@ var cmds = cli.Commands{
 				Name:  "for",
 				Usage: "return events for this long after the from time (when for is given, to is ignored)",
 			},
+			cli.StringFlag{
+				Name:  "content",
+				Usage: "limit results to logs containing this string",
+			},
+			cli.StringFlag{
+				Name:  "regex",
+				Usage: "limit results to logs matching this regular expression",
+			},
+			cli.BoolFlag{
+				Name:  "desc",
+				Usage: "return the latest events first",
+			},
 		},
 		Action: search,
 	},
+	{
+		Name:  "export",
+		Usage: "export all the events with their proofs",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:   "bc",
+				EnvVar: "BC",
+				Usage:  "the ByzCoin config",
+			},
+			cli.StringFlag{
+				Name:   "el",
+				EnvVar: "EL",
+				Usage:  "the eventlog id, from \"el create\"",
+			},
+			cli.StringFlag{
+				Name:  "format",
+				Usage: "the format of the export: jsonl or csv",
+				Value: "jsonl",
+			},
+			cli.StringFlag{
+				Name:  "out, o",
+				Usage: "the file to write (default: stdout)",
+			},
+		},
+		Action: export,
+	},
+	{
+		Name:      "verify",
+		Usage:     "verify an export against the chain",
+		ArgsUsage: "file",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:   "bc",
+				EnvVar: "BC",
+				Usage:  "the ByzCoin config",
+			},
+			cli.StringFlag{
+				Name:   "el",
+				EnvVar: "EL",
+				Usage:  "the eventlog id, from \"el create\"",
+			},
+			cli.StringFlag{
+				Name:  "format",
+				Usage: "the format of the export: jsonl or csv",
+				Value: "jsonl",
+			},
+		},
+		Action: verify,
+	},
 	{
 		Name:    "key",
 		Usage:   "generates a new keypair and prints the public key in the stdout",
@@ -356,6 +467,61 @@ func doLog(c *cli.Context) error {
 	return bcadminlib.WaitPropagation(c, cl.ByzCoin)
 }
 
+func retention(c *cli.Context) error {
+	cl, err := getClient(c, true)
+	if err != nil {
+		return err
+	}
+	e := c.String("el")
+	if e == "" {
+		return errors.New("--el is required")
+	}
+	eb, err := hex.DecodeString(e)
+	if err != nil {
+		return err
+	}
+	cl.Instance = byzcoin.NewInstanceID(eb)
+
+	err = cl.SetRetention(eventlog.RetentionPolicy{
+		MaxAge:    int64(c.Duration("max-age")),
+		MaxEvents: c.Int("max-events"),
+	})
+	if err != nil {
+		return err
+	}
+	return bcadminlib.WaitPropagation(c, cl.ByzCoin)
+}
+
+func prune(c *cli.Context) error {
+	cl, err := getClient(c, true)
+	if err != nil {
+		return err
+	}
+	e := c.String("el")
+	if e == "" {
+		return errors.New("--el is required")
+	}
+	eb, err := hex.DecodeString(e)
+	if err != nil {
+		return err
+	}
+	cl.Instance = byzcoin.NewInstanceID(eb)
+
+	// Every prune removes a limited number of events, so prune until
+	// there is nothing left to remove.
+	for i := 0; ; i++ {
+		err := cl.Prune()
+		if err != nil {
+			if i > 0 && strings.Contains(err.Error(), "nothing to prune") {
+				break
+			}
+			return err
+		}
+		log.Info("Pruned old events")
+	}
+	return bcadminlib.WaitPropagation(c, cl.ByzCoin)
+}
+
 var none = time.Unix(0, 0)
 
 // parseTime will accept either dates or "X ago" where X is a duration.
@@ -377,7 +543,10 @@ func parseTime(in string) (time.Time, error) {
 
 func search(c *cli.Context) error {
 	req := &eventlog.SearchRequest{
-		Topic: c.String("topic"),
+		Topic:        c.String("topic"),
+		Content:      c.String("content"),
+		ContentRegex: c.String("regex"),
+		Descending:   c.Bool("desc"),
 	}
 
 	f := c.String("from")
@@ -57,6 +57,13 @@ testEventLog(){
 	# The first form of relative date is for MacOS, the second for Linux.
 	testCountLines 0 $el search -t test -from '1h ago' -to `date -v -1d +%Y-%m-%d || date -d yesterday +%Y-%m-%d`
 	testCountLines 1 $el search -t test -to `date -v +1d +%Y-%m-%d || date -d tomorrow +%Y-%m-%d`
+
+	testOK $el export -o export.jsonl
+	testOK $el verify export.jsonl
+	testOK $el export -format csv -o export.csv
+	testOK $el verify -format csv export.csv
+	sed -i.bak 's/abc/abd/' export.jsonl
+	testFail $el verify export.jsonl
 }
 
 main
@@ -0,0 +1,285 @@
+package eventlog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/onet/v3/network"
+	"go.dedis.ch/protobuf"
+)
+
+// exportPage is the number of events fetched by every search of an export.
+const exportPage = 1000
+
+// ExportedEvent is an event of an export, with what is needed to verify it.
+type ExportedEvent struct {
+	// ID is the instance ID of the event.
+	ID []byte
+	// Block is the ID of the block that included the event.
+	Block skipchain.SkipBlockID
+	Event Event
+	// Proof proves that the event is in the global state, starting from the
+	// genesis block.
+	Proof *byzcoin.Proof
+}
+
+// Export calls the handler on every event of the log, in the order of the
+// buckets, which is the order in which the prune command removes them. Only
+// the blocks that included the events are fetched, given by the block index
+// stored with the events, and the export fails if one of them is missing or
+// has been pruned.
+func (c *Client) Export(handler func(ExportedEvent) error) error {
+	blocks := make(map[int]*eventBlock)
+	req := &SearchRequest{Limit: exportPage}
+	for {
+		resp, err := c.Search(req)
+		if err != nil {
+			return err
+		}
+		if len(resp.IDs) != len(resp.Events) {
+			return errors.New("the search didn't return the IDs of the events")
+		}
+		for i, ev := range resp.Events {
+			id := resp.IDs[i]
+			block, err := c.getEventBlock(id, ev, blocks)
+			if err != nil {
+				return err
+			}
+			reply, err := c.ByzCoin.GetProof(id)
+			if err != nil {
+				return err
+			}
+			err = handler(ExportedEvent{
+				ID:    id,
+				Block: block,
+				Event: ev,
+				Proof: &reply.Proof,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if !resp.Truncated {
+			return nil
+		}
+		req.Cursor = resp.Cursor
+	}
+}
+
+// VerifyExport checks the exported events against the chain. The proof of
+// every event must be valid from the genesis block and hold the event, and the
+// block of every event must hold the instruction that logged it. The events
+// that have been removed from the log since the export must be covered by the
+// chain of archive digests of the log. It returns the number of removed
+// events.
+func (c *Client) VerifyExport(events []ExportedEvent) (int, error) {
+	genesis, err := c.sc.GetSingleBlock(&c.ByzCoin.Roster, c.ByzCoin.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	blocks := make(map[string]map[string]bool)
+	var archived []ArchivedEvent
+	for _, ee := range events {
+		if ee.Proof == nil {
+			return 0, fmt.Errorf("event %x has no proof", ee.ID)
+		}
+		if err := ee.Proof.VerifyFromBlock(genesis); err != nil {
+			return 0, fmt.Errorf("event %x: %v", ee.ID, err)
+		}
+		val, cid, _, err := ee.Proof.Get(ee.ID)
+		if err != nil {
+			return 0, fmt.Errorf("event %x: %v", ee.ID, err)
+		}
+		var ev Event
+		if cid != contractName || protobuf.Decode(val, &ev) != nil || ev != ee.Event {
+			return 0, fmt.Errorf("event %x doesn't match its proof", ee.ID)
+		}
+
+		ids, ok := blocks[string(ee.Block)]
+		if !ok {
+			if ee.Block == nil {
+				return 0, fmt.Errorf("event %x has no block", ee.ID)
+			}
+			sb, err := c.sc.GetSingleBlock(&c.ByzCoin.Roster, ee.Block)
+			if err != nil {
+				return 0, fmt.Errorf("event %x: getting block: %v", ee.ID, err)
+			}
+			ids, err = blockEvents(sb, c.Instance)
+			if err != nil {
+				return 0, fmt.Errorf("event %x: %v", ee.ID, err)
+			}
+			blocks[string(ee.Block)] = ids
+		}
+		if !ids[string(ee.ID)] {
+			return 0, fmt.Errorf("event %x is not in block %x", ee.ID, ee.Block)
+		}
+
+		reply, err := c.ByzCoin.GetProofFromLatest(ee.ID)
+		if err != nil {
+			return 0, err
+		}
+		if !reply.Proof.InclusionProof.Match(ee.ID) {
+			archived = append(archived, ArchivedEvent{ID: ee.ID, Event: ee.Event})
+		}
+	}
+	if len(archived) == 0 {
+		return 0, nil
+	}
+
+	digests, err := c.archiveDigests(genesis)
+	if err != nil {
+		return 0, err
+	}
+
+	// Every prune removes the oldest events left, so the digests cover the
+	// removed events one after the other. The digests of the events removed
+	// before the export are skipped.
+	covered := 0
+	for _, digest := range digests {
+		if covered+digest.Events > len(archived) {
+			continue
+		}
+		root, err := ArchiveRoot(archived[covered : covered+digest.Events])
+		if err != nil {
+			return 0, err
+		}
+		if bytes.Equal(root, digest.Root) {
+			covered += digest.Events
+		}
+	}
+	if covered != len(archived) {
+		return 0, fmt.Errorf("%d removed events are not covered by an archive digest", len(archived)-covered)
+	}
+	return len(archived), nil
+}
+
+// archiveDigests returns the archive digests of the log, from the first one
+// to the latest one. The digests are followed back from the hash of the
+// latest one, which is proven from the genesis block, so that none of them can
+// be left out.
+func (c *Client) archiveDigests(genesis *skipchain.SkipBlock) ([]*ArchiveDigest, error) {
+	key := archiveID(c.Instance).Slice()
+	reply, err := c.ByzCoin.GetProof(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := reply.Proof.VerifyFromBlock(genesis); err != nil {
+		return nil, fmt.Errorf("archive hash: %v", err)
+	}
+	if !reply.Proof.InclusionProof.Match(key) {
+		return nil, nil
+	}
+	hash, cid, _, err := reply.Proof.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if cid != archiveContractName {
+		return nil, errors.New("the archive hash has the wrong contract")
+	}
+
+	byHash := make(map[string]*ArchiveDigest)
+	req := &SearchRequest{Topic: ArchiveTopic, Limit: exportPage}
+	for {
+		resp, err := c.Search(req)
+		if err != nil {
+			return nil, err
+		}
+		for _, ev := range resp.Events {
+			digest, err := ParseArchiveDigest(ev)
+			if err != nil {
+				return nil, err
+			}
+			h, err := digest.Hash()
+			if err != nil {
+				return nil, err
+			}
+			byHash[string(h)] = digest
+		}
+		if !resp.Truncated {
+			break
+		}
+		req.Cursor = resp.Cursor
+	}
+
+	var digests []*ArchiveDigest
+	for hash != nil {
+		digest, ok := byHash[string(hash)]
+		if !ok {
+			return nil, fmt.Errorf("the archive digest %x is missing", hash)
+		}
+		digests = append([]*ArchiveDigest{digest}, digests...)
+		hash = digest.Prev
+	}
+	return digests, nil
+}
+
+// eventBlock is a block that included events of the log.
+type eventBlock struct {
+	id  skipchain.SkipBlockID
+	ids map[string]bool
+}
+
+// getEventBlock returns the ID of the block that included the event, given by
+// the block index stored with the event. The block is kept in blocks for the
+// next events.
+func (c *Client) getEventBlock(id []byte, ev Event, blocks map[int]*eventBlock) (skipchain.SkipBlockID, error) {
+	if ev.Block == 0 {
+		return nil, fmt.Errorf("event %x: the block of the event is unknown", id)
+	}
+	eb, ok := blocks[ev.Block]
+	if !ok {
+		sbr, err := c.sc.GetSingleBlockByIndex(&c.ByzCoin.Roster, c.ByzCoin.ID, ev.Block)
+		if err != nil {
+			return nil, fmt.Errorf("event %x: getting block %d: %v", id, ev.Block, err)
+		}
+		ids, err := blockEvents(sbr.SkipBlock, c.Instance)
+		if err != nil {
+			return nil, fmt.Errorf("event %x: %v", id, err)
+		}
+		eb = &eventBlock{id: sbr.SkipBlock.Hash, ids: ids}
+		blocks[ev.Block] = eb
+	}
+	if !eb.ids[string(id)] {
+		return nil, fmt.Errorf("event %x is not in block %d", id, ev.Block)
+	}
+	return eb.id, nil
+}
+
+// blockEvents returns the IDs of the events added to the log by the block.
+func blockEvents(sb *skipchain.SkipBlock, instance byzcoin.InstanceID) (map[string]bool, error) {
+	if sb == nil {
+		return nil, errors.New("the block is missing")
+	}
+	if len(sb.Payload) == 0 {
+		return nil, fmt.Errorf("the transactions of block %d have been pruned", sb.Index)
+	}
+	var body byzcoin.DataBody
+	err := protobuf.DecodeWithConstructors(sb.Payload, &body, network.DefaultConstructors(cothority.Suite))
+	if err != nil {
+		return nil, errors.New("could not unmarshal body of block: " + err.Error())
+	}
+
+	ids := make(map[string]bool)
+	for _, tx := range body.TxResults {
+		if !tx.Accepted {
+			continue
+		}
+		for _, instr := range tx.ClientTransaction.Instructions {
+			if instr.Invoke == nil || !instr.InstanceID.Equal(instance) {
+				continue
+			}
+			switch instr.Invoke.Command {
+			case logCmd:
+				ids[string(instr.DeriveID("").Slice())] = true
+			case pruneCmd:
+				ids[string(instr.DeriveID("archive").Slice())] = true
+			}
+		}
+	}
+	return ids, nil
+}
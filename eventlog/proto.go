@@ -10,8 +10,9 @@ import (
 
 func init() {
 	network.RegisterMessages(
-		&Event{},
+		&Event{}, &RetentionPolicy{}, &ArchiveDigest{},
 		&SearchRequest{}, &SearchResponse{},
+		&SignArchiveRequest{}, &SignArchiveResponse{},
 	)
 }
 
@@ -51,15 +52,34 @@ type SearchRequest struct {
 	From int64
 	// Return events where When is <= To.
 	To int64
+	// Return events where Event.Topic is one of Topics, or equal to Topic,
+	// if Topics is not empty.
+	Topics []string
+	// Return events where Event.Content contains Content, if Content != "".
+	Content string `protobuf:"opt"`
+	// Return events where Event.Content matches the regular expression, if
+	// ContentRegex != "".
+	ContentRegex string `protobuf:"opt"`
+	// Return the latest events first.
+	Descending bool `protobuf:"opt"`
+	// Return at most Limit events. Limit == 0 means the maximum allowed by
+	// the service.
+	Limit int `protobuf:"opt"`
+	// Cursor is the Cursor of the previous SearchResponse, to get the next
+	// events. The other parameters must not change between the requests.
+	Cursor []byte `protobuf:"opt"`
 }
 
 // SearchResponse is the reply to LogRequest.
 type SearchResponse struct {
 	Events []Event
-	// Events does not contain all the results. The caller should formulate
-	// a new SearchRequest to continue searching, for instance by setting
-	// From to the time of the last received event.
+	// Events does not contain all the results. The caller should send the
+	// same SearchRequest with the Cursor to continue searching.
 	Truncated bool
+	// Cursor points to the last returned event, if Truncated is set.
+	Cursor []byte `protobuf:"opt"`
+	// IDs holds the instance IDs of the events.
+	IDs [][]byte
 }
 
 // Event is sent to create an event log. When should be set using the UnixNano() method
@@ -68,4 +88,53 @@ type Event struct {
 	When    int64
 	Topic   string
 	Content string
+	// Block is the index of the block that included the event. It is set
+	// by the contract, and is 0 for the events logged before it was.
+	Block int `protobuf:"opt"`
+}
+
+// RetentionPolicy tells which events are removed from an event log by the
+// prune command. The events are removed by whole buckets, and the latest
+// bucket is always kept.
+type RetentionPolicy struct {
+	// MaxAge, in nanoseconds, removes the buckets whose events are all older
+	// than the latest block by more than MaxAge. It is ignored if 0.
+	MaxAge int64
+	// MaxEvents removes the oldest buckets that don't fit in MaxEvents
+	// events. It is ignored if 0.
+	MaxEvents int
+}
+
+// ArchiveDigest is logged as the content of an event with the ArchiveTopic
+// when events are removed from an event log. It lets an auditor verify a copy
+// of the removed events. The digests of a log form a chain, whose latest hash
+// is kept in the global state, so that none of them can be left out.
+type ArchiveDigest struct {
+	// Root is the Merkle root over the removed events, as computed by
+	// ArchiveRoot.
+	Root []byte
+	// Events is the number of removed events.
+	Events int
+	// From is the time of the oldest removed event.
+	From int64
+	// To is the time of the latest removed event.
+	To int64
+	// Prev is the hash of the previous digest of the log, as computed by
+	// Hash, or nil for the first one.
+	Prev []byte `protobuf:"opt"`
+}
+
+// SignArchiveRequest asks a node to sign the ArchiveDigest of an archive event
+// of its global state.
+type SignArchiveRequest struct {
+	ID skipchain.SkipBlockID
+	// EventID is the instance ID of the archive event.
+	EventID []byte
+}
+
+// SignArchiveResponse holds the ArchiveDigest of the archive event and the
+// Schnorr signature of the node over the ArchiveMessage.
+type SignArchiveResponse struct {
+	Digest    ArchiveDigest
+	Signature []byte
 }
@@ -0,0 +1,153 @@
+package eventlog
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/protobuf"
+)
+
+// maxRegexLength is the longest regular expression accepted in a search.
+const maxRegexLength = 1024
+
+// maxIndexedBuckets is the number of buckets after which the topic index is
+// reset, to bound its memory.
+const maxIndexedBuckets = 100000
+
+// searchFilter holds the parsed parameters of a SearchRequest.
+type searchFilter struct {
+	from    int64
+	to      int64
+	topics  map[string]bool
+	content string
+	re      *regexp.Regexp
+}
+
+func newSearchFilter(req *SearchRequest) (*searchFilter, error) {
+	f := &searchFilter{
+		from:    req.From,
+		to:      req.To,
+		content: req.Content,
+	}
+	if req.Topic != "" || len(req.Topics) > 0 {
+		f.topics = make(map[string]bool)
+		if req.Topic != "" {
+			f.topics[req.Topic] = true
+		}
+		for _, t := range req.Topics {
+			f.topics[t] = true
+		}
+	}
+	if req.ContentRegex != "" {
+		if len(req.ContentRegex) > maxRegexLength {
+			return nil, fmt.Errorf("regular expression longer than %d bytes", maxRegexLength)
+		}
+		re, err := regexp.Compile(req.ContentRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression: %v", err)
+		}
+		f.re = re
+	}
+	return f, nil
+}
+
+func (f *searchFilter) match(ev *Event) bool {
+	if ev.When < f.from || ev.When >= f.to {
+		return false
+	}
+	if f.topics != nil && !f.topics[ev.Topic] {
+		return false
+	}
+	if f.content != "" && !strings.Contains(ev.Content, f.content) {
+		return false
+	}
+	if f.re != nil && !f.re.MatchString(ev.Content) {
+		return false
+	}
+	return true
+}
+
+// searchCursor is the position of the last event returned by a search. It is
+// sent to the client as an opaque slice of bytes.
+type searchCursor struct {
+	Bucket []byte
+	Event  int
+}
+
+func decodeCursor(buf []byte) (*searchCursor, error) {
+	if len(buf) == 0 {
+		return nil, nil
+	}
+	c := &searchCursor{}
+	if err := protobuf.Decode(buf, c); err != nil {
+		return nil, fmt.Errorf("invalid cursor: %v", err)
+	}
+	if len(c.Bucket) == 0 || c.Event < 0 {
+		return nil, errors.New("invalid cursor")
+	}
+	return c, nil
+}
+
+// isBefore returns true if the event at index ref of the bucket of the cursor
+// has already been returned.
+func (c searchCursor) isBefore(ref int, descending bool) bool {
+	if descending {
+		return ref >= c.Event
+	}
+	return ref <= c.Event
+}
+
+func (c searchCursor) encode() ([]byte, error) {
+	return protobuf.Encode(&c)
+}
+
+// topicIndex remembers the topics of the events in every bucket, so that a
+// search for some topics only reads the events of the buckets holding them.
+// As events are only ever appended to a bucket, the index of a bucket is
+// updated with the new events when the bucket grows.
+type topicIndex struct {
+	sync.Mutex
+	buckets map[string]*bucketTopics
+}
+
+type bucketTopics struct {
+	events int
+	topics map[string]bool
+}
+
+// hasTopic returns true if one of the events of the bucket has one of the
+// topics.
+func (ti *topicIndex) hasTopic(v byzcoin.ReadOnlyStateTrie, scID, bID []byte, b *bucket,
+	topics map[string]bool) (bool, error) {
+	ti.Lock()
+	defer ti.Unlock()
+	if ti.buckets == nil || len(ti.buckets) > maxIndexedBuckets {
+		ti.buckets = make(map[string]*bucketTopics)
+	}
+
+	key := string(scID) + string(bID)
+	bt := ti.buckets[key]
+	if bt == nil || bt.events > len(b.EventRefs) {
+		bt = &bucketTopics{topics: make(map[string]bool)}
+		ti.buckets[key] = bt
+	}
+	for _, e := range b.EventRefs[bt.events:] {
+		ev, err := getEventByID(v, e)
+		if err != nil {
+			return false, fmt.Errorf("bucket %x points to event %x, but the event was not found: %v", bID, e, err)
+		}
+		bt.topics[ev.Topic] = true
+		bt.events++
+	}
+
+	for t := range topics {
+		if bt.topics[t] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -1,11 +1,14 @@
 package eventlog
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"time"
 
+	"go.dedis.ch/cothority/v3"
 	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/kyber/v3/sign/schnorr"
 	"go.dedis.ch/onet/v3"
 	"go.dedis.ch/onet/v3/log"
 	"go.dedis.ch/protobuf"
@@ -36,6 +39,14 @@ func init() {
 	if err != nil {
 		log.ErrFatal(err)
 	}
+	err = byzcoin.RegisterGlobalContract(retentionContractName, stateContractFromBytes)
+	if err != nil {
+		log.ErrFatal(err)
+	}
+	err = byzcoin.RegisterGlobalContract(archiveContractName, stateContractFromBytes)
+	if err != nil {
+		log.ErrFatal(err)
+	}
 }
 
 // Service is the EventLog service.
@@ -43,6 +54,7 @@ type Service struct {
 	*onet.ServiceProcessor
 	omni         *byzcoin.Service
 	bucketMaxAge time.Duration
+	index        topicIndex
 }
 
 const defaultBlockInterval = 5 * time.Second
@@ -59,6 +71,18 @@ func (s *Service) Search(req *SearchRequest) (*SearchResponse, error) {
 	if req.To == 0 {
 		req.To = time.Now().UnixNano()
 	}
+	filter, err := newSearchFilter(req)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := decodeCursor(req.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	limit := req.Limit
+	if limit <= 0 || limit > searchMax {
+		limit = searchMax
+	}
 
 	v, err := s.omni.GetReadOnlyStateTrie(req.ID)
 	if err != nil {
@@ -113,29 +137,66 @@ func (s *Service) Search(req *SearchRequest) (*SearchResponse, error) {
 		}
 	}
 
-	reply := &SearchResponse{}
-
 	// Process the time buckets from earliest to latest so that
 	// if we truncate, it is the latest events that are not returned,
-	// so that they can set req.From = resp.Events[len(resp.Events)-1].When.
-filter:
-	for i := len(buckets) - 1; i >= 0; i-- {
+	// unless the latest events are requested first.
+	if !req.Descending {
+		for i, j := 0, len(buckets)-1; i < j; i, j = i+1, j-1 {
+			buckets[i], buckets[j] = buckets[j], buckets[i]
+			bids[i], bids[j] = bids[j], bids[i]
+		}
+	}
+
+	// Skip the buckets before the one of the cursor.
+	start := 0
+	if cursor != nil {
+		for start < len(buckets) && !bytes.Equal(bids[start], cursor.Bucket) {
+			start++
+		}
+		if start == len(buckets) {
+			return nil, errors.New("the cursor is not in the search range")
+		}
+	}
+
+	reply := &SearchResponse{}
+	for i := start; i < len(buckets); i++ {
 		b := buckets[i]
-		for _, e := range b.EventRefs {
-			ev, err := getEventByID(v, e)
+		if filter.topics != nil {
+			ok, err := s.index.hasTopic(v, req.ID, bids[i], b, filter.topics)
 			if err != nil {
-				log.Errorf("bucket %x points to event %x, but the event was not found: %v", bids[i], e, err)
+				log.Error(err)
 				return nil, err
 			}
+			if !ok {
+				continue
+			}
+		}
 
-			if req.From <= ev.When && ev.When < req.To {
-				if req.Topic == "" || req.Topic == ev.Topic {
-					reply.Events = append(reply.Events, *ev)
-					if len(reply.Events) >= searchMax {
-						reply.Truncated = true
-						break filter
-					}
+		for j := range b.EventRefs {
+			ref := j
+			if req.Descending {
+				ref = len(b.EventRefs) - 1 - j
+			}
+			if cursor != nil && i == start && cursor.isBefore(ref, req.Descending) {
+				continue
+			}
+			ev, err := getEventByID(v, b.EventRefs[ref])
+			if err != nil {
+				log.Errorf("bucket %x points to event %x, but the event was not found: %v", bids[i], b.EventRefs[ref], err)
+				return nil, err
+			}
+			if !filter.match(ev) {
+				continue
+			}
+			reply.Events = append(reply.Events, *ev)
+			reply.IDs = append(reply.IDs, b.EventRefs[ref])
+			if len(reply.Events) >= limit {
+				reply.Truncated = true
+				reply.Cursor, err = searchCursor{Bucket: bids[i], Event: ref}.encode()
+				if err != nil {
+					return nil, err
 				}
+				return reply, nil
 			}
 		}
 	}
@@ -143,6 +204,42 @@ filter:
 	return reply, nil
 }
 
+// SignArchive signs the digest of an archive event with the private key of
+// the node, so that the digest can be shown outside of the ledger.
+func (s *Service) SignArchive(req *SignArchiveRequest) (*SignArchiveResponse, error) {
+	if req.ID.IsNull() {
+		return nil, errors.New("skipchain ID required")
+	}
+	v, err := s.omni.GetReadOnlyStateTrie(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	_, _, cid, _, err := v.GetValues(req.EventID)
+	if err != nil {
+		return nil, err
+	}
+	if cid != contractName {
+		return nil, errors.New("not an event")
+	}
+	ev, err := getEventByID(v, req.EventID)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := ParseArchiveDigest(*ev)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := ArchiveMessage(req.ID, req.EventID, digest)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := schnorr.Sign(cothority.Suite, s.ServerIdentity().GetPrivate(), msg)
+	if err != nil {
+		return nil, err
+	}
+	return &SignArchiveResponse{Digest: *digest, Signature: sig}, nil
+}
+
 func decodeAndCheckEvent(coll byzcoin.ReadOnlyStateTrie, eventBuf []byte) (*Event, error) {
 	// Check the timestamp of the event: it should never be in the future,
 	// and it should not be more than 30 seconds in the past. (Why 30 sec
@@ -157,6 +254,11 @@ func decodeAndCheckEvent(coll byzcoin.ReadOnlyStateTrie, eventBuf []byte) (*Even
 	if err != nil {
 		return nil, err
 	}
+	// Only prune logs the archive events, so that their digests can be
+	// trusted.
+	if event.Topic == ArchiveTopic {
+		return nil, errors.New("the archive events cannot be logged")
+	}
 	when := time.Unix(0, event.When)
 	now := time.Now()
 	if when.Before(now.Add(-30 * time.Second)) {
@@ -168,7 +270,8 @@ func decodeAndCheckEvent(coll byzcoin.ReadOnlyStateTrie, eventBuf []byte) (*Even
 	return event, nil
 }
 
-// invoke will add an event and update the corresponding indices.
+// invoke will add an event and update the corresponding indices, or manage
+// the retention of the events.
 func (c *contract) Invoke(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, coins []byzcoin.Coin) (sc []byzcoin.StateChange, cout []byzcoin.Coin, err error) {
 	cout = coins
 
@@ -179,7 +282,15 @@ func (c *contract) Invoke(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instructio
 	if cid != contractName {
 		return nil, nil, fmt.Errorf("expected contract ID to be \"%s\" but got \"%s\"", contractName, cid)
 	}
-	if inst.Invoke.Command != logCmd {
+	switch inst.Invoke.Command {
+	case logCmd:
+	case retentionCmd:
+		sc, err = setRetention(rst, inst, darcID)
+		return
+	case pruneCmd:
+		sc, err = prune(rst, inst, darcID)
+		return
+	default:
 		return nil, nil, fmt.Errorf("invalid command, got \"%s\" but need \"%s\"", inst.Invoke.Command, logCmd)
 	}
 
@@ -192,6 +303,12 @@ func (c *contract) Invoke(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instructio
 	if err != nil {
 		return nil, nil, err
 	}
+	// The event is in the block following the one of the trie.
+	event.Block = rst.GetIndex() + 1
+	eventBuf, err = protobuf.Encode(event)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// Even though this is an invoke, we'll use the Spawn convention,
 	// since the new event is essentially being spawned on this eventlog.
@@ -327,7 +444,7 @@ func newService(c *onet.Context) (onet.Service, error) {
 		ServiceProcessor: onet.NewServiceProcessor(c),
 		omni:             c.Service(byzcoin.ServiceName).(*byzcoin.Service),
 	}
-	if err := s.RegisterHandlers(s.Search); err != nil {
+	if err := s.RegisterHandlers(s.Search, s.SignArchive); err != nil {
 		log.ErrFatal(err, "Couldn't register messages")
 	}
 	return s, nil
@@ -1267,6 +1267,32 @@ func (db *SkipBlockDB) RemoveBlock(blockID SkipBlockID) error {
 	})
 }
 
+// PrunePayload removes the payload of the given block from the database and
+// returns the number of bytes that were removed. The payload is not part of
+// the hash of the block, so the links stay valid.
+func (db *SkipBlockDB) PrunePayload(blockID SkipBlockID) (int, error) {
+	var pruned int
+	err := db.Update(func(tx *bbolt.Tx) error {
+		sb, err := db.getFromTx(tx, blockID)
+		if err != nil {
+			return err
+		}
+		if sb == nil {
+			return errors.New("unknown block")
+		}
+		if len(sb.Payload) == 0 {
+			return nil
+		}
+		pruned = len(sb.Payload)
+		sb.Payload = nil
+		return db.storeToTx(tx, sb)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return pruned, nil
+}
+
 // storeToTx stores the skipblock into the database.
 // An error is returned on failure.
 // The caller must ensure that this function is called from within a valid transaction.
@@ -321,6 +321,34 @@ func TestSkipBlock_Payload(t *testing.T) {
 	require.Equal(t, h, sb.CalculateHash())
 }
 
+func TestSkipBlockDB_PrunePayload(t *testing.T) {
+	db, file := setupSkipBlockDB(t)
+	defer db.Close()
+	defer os.Remove(file)
+
+	sb := NewSkipBlock()
+	sb.Payload = []byte{1, 2, 3}
+	sb.updateHash()
+	db.Store(sb)
+
+	n, err := db.PrunePayload(sb.Hash)
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+
+	stored := db.GetByID(sb.Hash)
+	require.NotNil(t, stored)
+	require.Empty(t, stored.Payload)
+	require.True(t, stored.Hash.Equal(stored.CalculateHash()))
+
+	// Nothing left to prune.
+	n, err = db.PrunePayload(sb.Hash)
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+
+	_, err = db.PrunePayload(SkipBlockID{1, 2, 3})
+	require.Error(t, err)
+}
+
 // Vector testing of the function to get the index of the next
 // block when following the chain.
 func TestSkipBlock_PathForIndex(t *testing.T) {